@@ -35,6 +35,9 @@ func main() {
 	redisClient := cfg.Redis.RedisInit()
 	logger.Println("Redis connected successfully")
 
+	mediaStorage := cfg.Storage.StorageInit()
+	logger.Printf("Using %s media storage", cfg.Storage.Backend)
+
 	// Getting all repositories
 	subjectRepository := repository.NewSubjectRepository(dbConn)
 	userRepository := repository.NewUserRepository(dbConn)
@@ -42,13 +45,44 @@ func main() {
 	scoreRepository := repository.NewScoreRepository(dbConn)
 	questionRepository := repository.NewQuestionRepository(dbConn)
 	leaderboardRepository := repository.NewLeaderboardRepository(dbConn)
+	quizSessionRepository := repository.NewQuizSessionRepository(dbConn)
+	reviewQueueRepository := repository.NewReviewQueueRepository(dbConn)
+	quizChallengeRepository := repository.NewQuizChallengeRepository(dbConn)
+	mediaRepository := repository.NewMediaRepository(dbConn)
+	contestRepository := repository.NewContestRepository(dbConn)
+	streakRepository := repository.NewStreakRepository(dbConn)
+	dailyChallengeRepository := repository.NewDailyChallengeRepository(dbConn)
+	achievementRepository := repository.NewAchievementRepository(dbConn)
+	xpRepository := repository.NewXPRepository(dbConn)
+	studyPlanRepository := repository.NewStudyPlanRepository(dbConn)
+
+	badges, err := service.LoadBadges(cfg.Achievements.File)
+	if err != nil {
+		logger.Fatal("Failed to load badges: ", err)
+	}
 
 	// Getting all services
 	subjectService := service.NewSubjectService(subjectRepository)
-	userService := service.NewUserService(*userRepository, scoreRepository, logger)
-	quizService := service.NewQuizService(quizRepository, subjectRepository, questionRepository, scoreRepository)
-	questionService := service.NewQuestionService(questionRepository, subjectRepository, logger)
+	achievementService := service.NewAchievementService(badges, achievementRepository, scoreRepository, streakRepository, leaderboardRepository)
+	userService := service.NewUserService(*userRepository, scoreRepository, streakRepository, xpRepository, achievementService, logger)
+	quizService := service.NewQuizService(quizRepository, subjectRepository, questionRepository, scoreRepository, quizSessionRepository, reviewQueueRepository, quizChallengeRepository, mediaRepository, mediaStorage)
+	questionService := service.NewQuestionService(questionRepository, subjectRepository, mediaRepository, mediaStorage, logger)
 	leaderboardService := service.NewLeaderboardService(leaderboardRepository, subjectRepository)
+	liveService := service.NewLiveRoomManager(redisClient, questionRepository, subjectRepository, scoreRepository, quizSessionRepository, userRepository, mediaRepository, mediaStorage)
+	contestService := service.NewContestService(contestRepository, subjectRepository, questionRepository, quizSessionRepository, mediaRepository, mediaStorage)
+	dailyChallengeService := service.NewDailyChallengeService(redisClient, dailyChallengeRepository, streakRepository, subjectRepository, questionRepository, quizSessionRepository, mediaRepository, mediaStorage)
+	quizService.OnSubmit(dailyChallengeService.RecordSubmission)
+	quizService.OnSubmit(achievementService.RecordSubmission)
+	xpService := service.NewXPService(xpRepository, questionRepository, streakRepository)
+	quizService.OnSubmit(xpService.RecordSubmission)
+	studyPlanService := service.NewStudyPlanService(studyPlanRepository, subjectRepository, scoreRepository, reviewQueueRepository)
+	analyticsService := service.NewAnalyticsService(redisClient, scoreRepository, subjectRepository, cfg.Analytics.CacheTTL)
+	quizService.OnSubmit(analyticsService.RecordSubmission)
+	// Live results go through the same hooks, in the same order
+	liveService.OnSubmit(dailyChallengeService.RecordSubmission)
+	liveService.OnSubmit(achievementService.RecordSubmission)
+	liveService.OnSubmit(xpService.RecordSubmission)
+	liveService.OnSubmit(analyticsService.RecordSubmission)
 	emailService := service.NewEmailService(service.EmailConfig{
 		RedisClient: redisClient,
 		SMTPHost:    cfg.Email.Host,
@@ -66,9 +100,16 @@ func main() {
 	userHandler := handler.NewUserHandler(userService, emailService, logger, cfg.Server.JWTSecret)
 	quizHandler := handler.NewQuizHandler(quizService, subjectService, logger)
 	leaderboardHandler := handler.NewLeaderboardHandler(leaderboardService, logger)
+	mediaHandler := handler.NewMediaHandler(mediaStorage, logger)
+	liveHandler := handler.NewLiveHandler(liveService, cfg.Server.AllowOrigins, logger)
+	contestHandler := handler.NewContestHandler(contestService, logger)
+	dailyHandler := handler.NewDailyHandler(dailyChallengeService, logger)
+	achievementHandler := handler.NewAchievementHandler(achievementService, logger)
+	studyPlanHandler := handler.NewStudyPlanHandler(studyPlanService, logger)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsService, logger)
 
 	e := echo.New()
-	router.NewRouter(e, adminHandler, userHandler, quizHandler, leaderboardHandler, cfg)
+	router.NewRouter(e, adminHandler, userHandler, quizHandler, leaderboardHandler, mediaHandler, liveHandler, contestHandler, dailyHandler, achievementHandler, studyPlanHandler, analyticsHandler, cfg)
 
 	// Start server in a goroutine
 	go func() {
@@ -78,6 +119,12 @@ func main() {
 		}
 	}()
 
+	// Award leaderboard badges in the background
+	snapshotCtx, stopSnapshots := context.WithCancel(context.Background())
+	if cfg.Achievements.SnapshotInterval > 0 {
+		go achievementService.RunSnapshots(snapshotCtx, cfg.Achievements.SnapshotInterval)
+	}
+
 	// Wait for interrupt signal to gracefully shut down the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -94,6 +141,12 @@ func main() {
 		logger.Printf("Error during server shutdown: %v", err)
 	}
 
+	// Stop leaderboard snapshots and live rooms before the connections they use
+	stopSnapshots()
+	if err := liveService.Close(); err != nil {
+		logger.Printf("Error closing live rooms: %v", err)
+	}
+
 	// Close database connection
 	if err := dbConn.Close(); err != nil {
 		logger.Printf("Error closing database connection: %v", err)
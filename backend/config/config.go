@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,14 +11,18 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/lawson/otterprep/internal/storage"
 	"github.com/redis/go-redis/v9"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Email    EmailConfig
+	Server       ServerConfig
+	Database     DatabaseConfig
+	Redis        RedisConfig
+	Email        EmailConfig
+	Storage      StorageConfig
+	Achievements AchievementsConfig
+	Analytics    AnalyticsConfig
 }
 
 type ServerConfig struct {
@@ -44,6 +49,30 @@ type RedisConfig struct {
 	DB       int
 }
 
+// StorageConfig is where media attached to questions is kept. The "local" backend keeps
+// it under LocalDir and the API serves it from PublicURL at URLs signed with SigningKey,
+// which it requires and which must differ from the JWT secret; the "s3" backend keeps it in a bucket of an S3-compatible store.
+type StorageConfig struct {
+	Backend    string
+	LocalDir   string
+	PublicURL  string
+	SigningKey string
+	S3         storage.S3Config
+}
+
+// AchievementsConfig is where badges are defined and how often leaderboard badges are
+// awarded. File is a JSON array of badge definitions replacing the built-in ones; a
+// SnapshotInterval of 0 turns leaderboard snapshots off.
+type AchievementsConfig struct {
+	File             string
+	SnapshotInterval time.Duration
+}
+
+// AnalyticsConfig is how long learner analytics are cached in Redis; 0 turns caching off.
+type AnalyticsConfig struct {
+	CacheTTL time.Duration
+}
+
 type EmailConfig struct {
 	Host     string
 	Port     int
@@ -91,6 +120,31 @@ func Load() (*Config, error) {
 			From:     getEnv("SMTP_FROM", "noreply@acethatpaper.com"),
 			FromName: getEnv("SMTP_FROM_NAME", "AceThatPaper"),
 		},
+		Storage: StorageConfig{
+			Backend:    getEnv("MEDIA_STORAGE", "local"),
+			LocalDir:   getEnv("MEDIA_DIR", "./media"),
+			PublicURL:  getEnv("MEDIA_PUBLIC_URL", "http://localhost:8080/media"),
+			SigningKey: getEnv("MEDIA_SIGNING_KEY", ""),
+			S3: storage.S3Config{
+				Endpoint:  getEnv("S3_ENDPOINT", "localhost:9000"),
+				Region:    getEnv("S3_REGION", "us-east-1"),
+				Bucket:    getEnv("S3_BUCKET", "otterprep-media"),
+				AccessKey: getEnv("S3_ACCESS_KEY", ""),
+				SecretKey: getEnv("S3_SECRET_KEY", ""),
+				UseSSL:    getEnvBool("S3_USE_SSL", false),
+			},
+		},
+		Achievements: AchievementsConfig{
+			File:             getEnv("ACHIEVEMENTS_FILE", ""),
+			SnapshotInterval: parseDuration(getEnv("ACHIEVEMENTS_SNAPSHOT_INTERVAL", "1h"), time.Hour),
+		},
+		Analytics: AnalyticsConfig{
+			CacheTTL: parseDuration(getEnv("ANALYTICS_CACHE_TTL", "5m"), 5*time.Minute),
+		},
+	}
+	// Media URLs and auth tokens are signed with separate keys so one leaking does not expose the other
+	if cfg.Storage.SigningKey != "" && cfg.Storage.SigningKey == cfg.Server.JWTSecret {
+		return nil, errors.New("MEDIA_SIGNING_KEY must not be the same as JWT_SECRET")
 	}
 
 	return cfg, nil
@@ -152,6 +206,27 @@ func (c *RedisConfig) RedisInit() *redis.Client {
 	return client
 }
 
+// StorageInit creates the configured media storage
+func (c *StorageConfig) StorageInit() storage.Storage {
+	switch c.Backend {
+	case "local":
+		store, err := storage.NewLocal(c.LocalDir, c.PublicURL, []byte(c.SigningKey))
+		if err != nil {
+			log.Fatal("Failed to set up local media storage: ", err)
+		}
+		return store
+	case "s3":
+		store, err := storage.NewS3(c.S3)
+		if err != nil {
+			log.Fatal("Failed to set up S3 media storage: ", err)
+		}
+		return store
+	default:
+		log.Fatalf("Unknown media storage %q, expected local or s3", c.Backend)
+		return nil
+	}
+}
+
 // getEnv returns the value of the environment variable with the given key
 // If the environment variable is not set, it returns the default value
 func getEnv(key, defaultValue string) string {
@@ -172,6 +247,17 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvBool returns the boolean value of the environment variable with the given key
+// If the environment variable is not set or invalid, it returns the default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
 // getEnvSlice returns a slice of strings from a comma-separated environment variable
 func getEnvSlice(key string, defaultValue []string) []string {
 	if value := os.Getenv(key); value != "" {
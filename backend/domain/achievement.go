@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+// Achievement events, the points at which badge rules are evaluated
+var (
+	EventQuizSubmitted       = "quiz_submitted"
+	EventLeaderboardSnapshot = "leaderboard_snapshot"
+)
+
+// BadgeDefinition is a badge users can earn, and the rules that earn it. A badge is
+// earned once, the first time all of its rules hold when its event happens.
+type BadgeDefinition struct {
+	Code        string      `json:"code"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Event       string      `json:"event"`           // quiz_submitted or leaderboard_snapshot
+	Modes       []string    `json:"modes,omitempty"` // quiz modes a quiz_submitted badge can be earned in, any when empty
+	Rules       []BadgeRule `json:"rules"`
+}
+
+// BadgeRule compares a metric of the event with a value, e.g. quizzes_taken gte 100
+type BadgeRule struct {
+	Metric string  `json:"metric"`
+	Op     string  `json:"op"` // gte, lte or eq
+	Value  float64 `json:"value"`
+}
+
+// Badge is a badge a user has earned
+type Badge struct {
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	EarnedAt    time.Time `json:"earned_at"`
+}
+
+// PublicProfile is what other users can see of a user
+type PublicProfile struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"full_name"`
+	CreatedAt time.Time `json:"created_at"`
+	UserStats
+	Streak Streak     `json:"streak"`
+	Badges []Badge    `json:"badges"`
+	XP     XPProgress `json:"xp"`
+}
@@ -0,0 +1,79 @@
+package domain
+
+import "time"
+
+// Which way a user's accuracy is going
+var (
+	TrendImproving        = "improving"
+	TrendDeclining        = "declining"
+	TrendSteady           = "steady"
+	TrendInsufficientData = "insufficient_data" // not enough quizzes to compare
+)
+
+// AnalyticsQuery is the query for the user's learner analytics
+type AnalyticsQuery struct {
+	Interval string `query:"interval" validate:"omitempty,oneof=daily weekly"` // the series' buckets, weekly by default
+	Periods  int    `query:"periods" validate:"omitempty,gte=1,lte=90"`        // buckets in the series, 30 days or 12 weeks by default
+}
+
+// LearnerAnalytics is how a user is doing overall and in every subject they have
+// answered questions in, and how that has changed over time.
+type LearnerAnalytics struct {
+	Interval     string             `json:"interval"`
+	From         string             `json:"from"` // the first day of the series
+	Overall      AnalyticsSummary   `json:"overall"`
+	BestSubject  *SubjectHighlight  `json:"best_subject,omitempty"`  // of the subjects with enough questions answered, when there are two or more
+	WorstSubject *SubjectHighlight  `json:"worst_subject,omitempty"` // likewise
+	Subjects     []SubjectAnalytics `json:"subjects"`
+	GeneratedAt  time.Time          `json:"generated_at"`
+}
+
+// AnalyticsSummary is how a user has done across every subject
+type AnalyticsSummary struct {
+	QuestionsAnswered     int64         `json:"questions_answered"`
+	CorrectAnswers        int64         `json:"correct_answers"`
+	Accuracy              float64       `json:"accuracy"` // percent
+	AvgSecondsPerQuestion float64       `json:"avg_seconds_per_question"`
+	Trend                 AccuracyTrend `json:"trend"`
+}
+
+// SubjectAnalytics is how a user has done in a subject. Percentile is the share of the
+// other users who have answered questions in the subject whose accuracy in it is lower.
+type SubjectAnalytics struct {
+	SubjectId             int64           `json:"subject_id"`
+	SubjectName           string          `json:"subject_name"`
+	QuizzesTaken          int64           `json:"quizzes_taken"`
+	QuestionsAnswered     int64           `json:"questions_answered"`
+	CorrectAnswers        int64           `json:"correct_answers"`
+	Accuracy              float64         `json:"accuracy"` // percent
+	TimeTakenSeconds      int64           `json:"time_taken_seconds"`
+	AvgSecondsPerQuestion float64         `json:"avg_seconds_per_question"`
+	Percentile            float64         `json:"percentile"`
+	UsersRanked           int64           `json:"users_ranked"` // users who have answered questions in the subject, the user included
+	Trend                 AccuracyTrend   `json:"trend"`
+	Series                []AccuracyPoint `json:"series"` // oldest first, leaving out buckets without answers
+}
+
+// SubjectHighlight picks out one of the user's subjects
+type SubjectHighlight struct {
+	SubjectId   int64   `json:"subject_id"`
+	SubjectName string  `json:"subject_name"`
+	Accuracy    float64 `json:"accuracy"`
+}
+
+// AccuracyTrend compares a user's accuracy over their most recent quizzes with the quizzes
+// before them. Change is in percentage points.
+type AccuracyTrend struct {
+	RecentAccuracy   float64 `json:"recent_accuracy"`
+	PreviousAccuracy float64 `json:"previous_accuracy"`
+	Change           float64 `json:"change"`
+	Direction        string  `json:"direction"`
+}
+
+// AccuracyPoint is a user's accuracy in a subject over a day or a week
+type AccuracyPoint struct {
+	Start             string  `json:"start"` // the first day of the bucket
+	QuestionsAnswered int64   `json:"questions_answered"`
+	CorrectAnswers    int64   `json:"correct_answers"`
+	Accuracy          float64 `json:"accuracy"`
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// QuizChallengeResponse is a quiz that can be shared by its code.
+// Everyone who redeems the code sits the same questions in the same order.
+type QuizChallengeResponse struct {
+	Code            string               `json:"code"`
+	SubjectId       int64                `json:"subject_id"`
+	Subjects        []QuizSubjectRequest `json:"subjects"`
+	Mode            string               `json:"mode"`
+	DurationSeconds int64                `json:"duration_seconds,omitempty"`
+	TotalCount      int                  `json:"total_count"`
+	CreatedBy       int64                `json:"created_by"`
+	CreatedAt       time.Time            `json:"created_at"`
+}
+
+// ChallengeResult is how one user did on a challenge
+type ChallengeResult struct {
+	Rank             int64     `json:"rank"`
+	UserID           int64     `json:"user_id"`
+	UserName         string    `json:"user_name"`
+	Score            int64     `json:"score"`
+	Points           float64   `json:"points"`
+	CorrectAnswers   int64     `json:"correct_answers"`
+	TotalQuestions   int64     `json:"total_questions"`
+	TimeTakenSeconds int64     `json:"time_taken_seconds"`
+	SubmittedAt      time.Time `json:"submitted_at"`
+}
+
+// ChallengeResultsResponse is the standings of everyone who has submitted a challenge
+type ChallengeResultsResponse struct {
+	Code    string            `json:"code"`
+	Takers  int64             `json:"takers"`
+	Results []ChallengeResult `json:"results"`
+}
@@ -0,0 +1,80 @@
+package domain
+
+import "time"
+
+// ContestData is used when an admin schedules a contest on a subject. The questions are
+// either the QuestionIds given, in that order, or NumOfQuestions drawn from the subject
+// when the contest is created. DurationSeconds limits each entry; without it an entry
+// lasts until the contest ends.
+type ContestData struct {
+	Title           string    `json:"title" validate:"required,min=1,max=200"`
+	Description     string    `json:"description" validate:"omitempty,max=2000"`
+	SubjectId       int64     `json:"subject_id" validate:"required,gt=0"`
+	QuestionIds     []int64   `json:"question_ids" validate:"omitempty,max=200,dive,gt=0"`
+	NumOfQuestions  int64     `json:"num_of_questions" validate:"omitempty,gte=1,lte=200"`
+	DurationSeconds int64     `json:"duration_seconds" validate:"omitempty,gte=60,lte=14400"`
+	StartsAt        time.Time `json:"starts_at" validate:"required"`
+	EndsAt          time.Time `json:"ends_at" validate:"required,gtfield=StartsAt"`
+}
+
+// Contest statuses, from the contest's window: upcoming before it opens, live while it
+// is open and past once it has closed.
+var (
+	ContestUpcoming = "upcoming"
+	ContestLive     = "live"
+	ContestPast     = "past"
+)
+
+// Contest is a scheduled contest. Entered is whether the user asking has entered it.
+type Contest struct {
+	Id              int64     `json:"id"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	SubjectId       int64     `json:"subject_id"`
+	TotalQuestions  int       `json:"total_questions"`
+	DurationSeconds int64     `json:"duration_seconds,omitempty"`
+	ScoringPolicy   string    `json:"scoring_policy"`
+	StartsAt        time.Time `json:"starts_at"`
+	EndsAt          time.Time `json:"ends_at"`
+	Status          string    `json:"status"`
+	Entered         bool      `json:"entered"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ContestQuery picks the contests to list by status, live by default
+type ContestQuery struct {
+	Status string `query:"status" validate:"omitempty,oneof=upcoming live past"`
+	Limit  int    `query:"limit" validate:"omitempty,gte=1,lte=100"`
+	Offset int    `query:"offset" validate:"omitempty,gte=0"`
+}
+
+// ContestListResponse is a page of contests of one status. Upcoming contests come soonest
+// first, live ones closing soonest first and past ones most recently closed first.
+type ContestListResponse struct {
+	Status   string    `json:"status"`
+	Total    int64     `json:"total"`
+	Contests []Contest `json:"contests"`
+}
+
+// ContestStanding is a user's place on a contest's leaderboard
+type ContestStanding struct {
+	Rank             int64     `json:"rank"`
+	UserID           int64     `json:"user_id"`
+	UserName         string    `json:"user_name"`
+	Score            int64     `json:"score"`
+	Points           float64   `json:"points"`
+	CorrectAnswers   int64     `json:"correct_answers"`
+	TotalQuestions   int64     `json:"total_questions"`
+	TimeTakenSeconds int64     `json:"time_taken_seconds"`
+	SubmittedAt      time.Time `json:"submitted_at"`
+}
+
+// ContestLeaderboardResponse is the leaderboard of a contest, frozen at FrozenAt once the
+// contest has closed. Me is the user asking, if they are on it.
+type ContestLeaderboardResponse struct {
+	ContestId int64             `json:"contest_id"`
+	FrozenAt  time.Time         `json:"frozen_at"`
+	Entrants  int64             `json:"entrants"`
+	Standings []ContestStanding `json:"standings"`
+	Me        *ContestStanding  `json:"me,omitempty"`
+}
@@ -0,0 +1,52 @@
+package domain
+
+import "time"
+
+// DailyChallenge is a subject's question set of the day. Every user gets the same
+// questions from a subject on the same day. Taken is whether the user asking has taken it.
+type DailyChallenge struct {
+	Date           string `json:"date"` // YYYY-MM-DD, in UTC
+	SubjectId      int64  `json:"subject_id"`
+	TotalQuestions int    `json:"total_questions"`
+	Taken          bool   `json:"taken"`
+	Streak         Streak `json:"streak"`
+}
+
+// Streak counts the days in a row a user has submitted a daily challenge. A streak
+// freeze covers a missed day so the streak carries on; one is earned every 7 days of a
+// streak. Current is 0 once the streak has lapsed.
+type Streak struct {
+	Current      int64      `json:"current"`
+	Longest      int64      `json:"longest"`
+	Freezes      int64      `json:"freezes"`
+	LastActiveOn *time.Time `json:"last_active_on,omitempty"`
+}
+
+// DailyBoardQuery picks today's daily challenge board, of one subject or of every subject
+type DailyBoardQuery struct {
+	SubjectId *int64 `query:"subject_id"`
+	Limit     int    `query:"limit" validate:"omitempty,gte=1,lte=100"`
+	Offset    int    `query:"offset" validate:"omitempty,gte=0"`
+}
+
+// DailyBoardEntry is a user's place among today's daily challenge participants
+type DailyBoardEntry struct {
+	Rank             int64   `json:"rank"`
+	UserID           int64   `json:"user_id"`
+	UserName         string  `json:"user_name"`
+	Challenges       int64   `json:"challenges"`
+	Points           float64 `json:"points"`
+	CorrectAnswers   int64   `json:"correct_answers"`
+	TotalQuestions   int64   `json:"total_questions"`
+	TimeTakenSeconds int64   `json:"time_taken_seconds"`
+}
+
+// DailyBoardResponse ranks today's daily challenge participants by points, then time
+// taken. Me is the user asking, if they have taken part.
+type DailyBoardResponse struct {
+	Date         string            `json:"date"`
+	SubjectId    *int64            `json:"subject_id,omitempty"`
+	Participants int64             `json:"participants"`
+	Entries      []DailyBoardEntry `json:"entries"`
+	Me           *DailyBoardEntry  `json:"me,omitempty"`
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// QuizAnswer is the stored answer to one question of a submitted quiz.
+// Questions left unanswered are stored with no selected options, value, text or pairs.
+type QuizAnswer struct {
+	QuestionId        int64       `json:"question_id"`
+	SubjectId         int64       `json:"subject_id"`
+	Position          int         `json:"position"`
+	SelectedOptionIds []int64     `json:"selected_option_ids"`
+	Value             *float64    `json:"value,omitempty"` // numeric questions
+	Unit              string      `json:"unit,omitempty"`
+	Text              string      `json:"text,omitempty"`  // short text questions
+	Pairs             []MatchPair `json:"pairs,omitempty"` // matching questions
+	IsCorrect         bool        `json:"is_correct"`
+	Credit            float64     `json:"credit"`
+	Points            float64     `json:"points"`
+	AnsweredAt        time.Time   `json:"answered_at"`
+}
+
+// QuizHistoryEntry summarises one submitted quiz.
+// SubjectId is 0 for a quiz mixing several subjects.
+type QuizHistoryEntry struct {
+	SessionId        int64     `json:"session_id"`
+	SubjectId        int64     `json:"subject_id"`
+	Mode             string    `json:"mode"`
+	TotalQuestions   int64     `json:"total_questions"`
+	CorrectAnswers   int64     `json:"correct_answers"`
+	IncorrectAnswers int64     `json:"incorrect_answers"`
+	Score            int64     `json:"score"`
+	Points           float64   `json:"points"`
+	TimeTakenSeconds int64     `json:"time_taken_seconds"`
+	SubmittedAt      time.Time `json:"submitted_at"`
+}
+
+// QuizHistoryQuery is the paging of a user's quiz history
+type QuizHistoryQuery struct {
+	Limit  int `query:"limit" validate:"omitempty,gte=1,lte=100"`
+	Offset int `query:"offset" validate:"omitempty,gte=0"`
+}
+
+// QuizHistoryResponse is a page of a user's past quizzes, most recent first
+type QuizHistoryResponse struct {
+	Total   int64              `json:"total"`
+	Entries []QuizHistoryEntry `json:"entries"`
+}
@@ -0,0 +1,113 @@
+package domain
+
+import "time"
+
+// LiveRoomRequest is used when a host opens a live room. NumOfQuestions are drawn from
+// SubjectId, narrowed down by TopicIds and Tags like a quiz, and each is open for
+// QuestionSeconds.
+type LiveRoomRequest struct {
+	SubjectId       int64    `json:"subject_id" validate:"required,gt=0"`
+	NumOfQuestions  int64    `json:"num_of_questions" validate:"required,gte=1,lte=50"`
+	QuestionSeconds int64    `json:"question_seconds" validate:"omitempty,gte=5,lte=120"`
+	TopicIds        []int64  `json:"topic_ids" validate:"omitempty,max=20,dive,gt=0"`
+	Tags            []string `json:"tags" validate:"omitempty,max=20,dive,required,max=50"`
+}
+
+// Live room statuses. A room waits in the lobby for players, then goes back and forth
+// between a question being open and the scoreboard after it, until it is finished.
+var (
+	LiveRoomLobby      = "lobby"
+	LiveRoomQuestion   = "question"
+	LiveRoomScoreboard = "scoreboard"
+	LiveRoomFinished   = "finished"
+)
+
+// LiveRoom is a live quiz room. QuestionIndex is the question open or last closed, -1
+// before the first one.
+type LiveRoom struct {
+	Pin             string       `json:"pin"`
+	HostId          int64        `json:"host_id"`
+	SubjectId       int64        `json:"subject_id"`
+	Status          string       `json:"status"`
+	QuestionIndex   int          `json:"question_index"`
+	TotalQuestions  int          `json:"total_questions"`
+	QuestionSeconds int64        `json:"question_seconds"`
+	Players         []LivePlayer `json:"players"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// LivePlayer is a player in a live room
+type LivePlayer struct {
+	UserId int64  `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// Live room commands, sent by clients over the room's socket. The host starts the room,
+// moves on to the next question or ends it; players answer.
+var (
+	LiveCommandStart  = "start"
+	LiveCommandNext   = "next"
+	LiveCommandEnd    = "end"
+	LiveCommandAnswer = "answer"
+)
+
+// LiveCommand is a message from a client of a live room. An answer is to the question at
+// QuestionIndex and is given like an answer to a quiz question.
+type LiveCommand struct {
+	Type          string             `json:"type"`
+	QuestionIndex int                `json:"question_index"`
+	Answer        *SubmitQuizRequest `json:"answer,omitempty"`
+}
+
+// Live room events, sent to the clients of a room. The room is sent when players join or
+// leave the lobby, the question when it opens, progress when a player answers, the
+// scoreboard with the answer after each question and the final scoreboard when the room
+// is finished. Answered only goes to the player who answered, and error only to the
+// client whose command failed.
+var (
+	LiveEventRoom       = "room"
+	LiveEventQuestion   = "question"
+	LiveEventProgress   = "progress"
+	LiveEventAnswered   = "answered"
+	LiveEventScoreboard = "scoreboard"
+	LiveEventFinished   = "finished"
+	LiveEventError      = "error"
+)
+
+// LiveEvent is a message to the clients of a live room
+type LiveEvent struct {
+	Type       string              `json:"type"`
+	Room       *LiveRoom           `json:"room,omitempty"`
+	Question   *LiveQuestion       `json:"question,omitempty"`
+	Progress   *LiveProgress       `json:"progress,omitempty"`
+	Result     *QuizResultResponse `json:"result,omitempty"` // the answer to the question just closed
+	Scoreboard []LiveScore         `json:"scoreboard,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// LiveQuestion is a question pushed to a live room, open until Deadline
+type LiveQuestion struct {
+	Index          int                  `json:"index"`
+	TotalQuestions int                  `json:"total_questions"`
+	Seconds        int64                `json:"seconds"`
+	Deadline       time.Time            `json:"deadline"`
+	Question       QuizQuestionResponse `json:"question"`
+}
+
+// LiveProgress is how many players have answered the open question
+type LiveProgress struct {
+	QuestionIndex int `json:"question_index"`
+	Answered      int `json:"answered"`
+	Players       int `json:"players"`
+}
+
+// LiveScore is a player's place on a live room's scoreboard. Gained is what the player
+// earned for the question just closed.
+type LiveScore struct {
+	Rank           int    `json:"rank"`
+	UserId         int64  `json:"user_id"`
+	Name           string `json:"name"`
+	Points         int64  `json:"points"`
+	Gained         int64  `json:"gained"`
+	CorrectAnswers int    `json:"correct_answers"`
+}
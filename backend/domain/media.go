@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// Media kinds
+var (
+	MediaKindImage = "image"
+	MediaKindAudio = "audio"
+)
+
+// What media can be attached to. Media attached to an explanation is only shown with the
+// results of a quiz, like the explanation itself.
+var (
+	MediaOnQuestion    = "question"
+	MediaOnOption      = "option"
+	MediaOnExplanation = "explanation"
+)
+
+// MediaUpload describes an image or audio file uploaded to a question. OptionId is the
+// option of the question the file is attached to, and only set when AttachTo is option.
+type MediaUpload struct {
+	AttachTo string `form:"attach_to" validate:"required,oneof=question option explanation"`
+	OptionId int64  `form:"option_id" validate:"required_if=AttachTo option,excluded_unless=AttachTo option"`
+	AltText  string `form:"alt_text" validate:"max=300"`
+}
+
+// Media is an image or audio file attached to a question, one of its options or its
+// explanation. URL is signed and stops working at ExpiresAt.
+type Media struct {
+	Id          int64     `json:"id"`
+	Kind        string    `json:"kind"`
+	ContentType string    `json:"content_type"`
+	AttachTo    string    `json:"attach_to"`
+	OptionId    int64     `json:"option_id,omitempty"`
+	AltText     string    `json:"alt_text,omitempty"`
+	SizeBytes   int64     `json:"size_bytes"`
+	URL         string    `json:"url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
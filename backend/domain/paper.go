@@ -0,0 +1,53 @@
+package domain
+
+// PastPaperData is used when adding a past paper to a subject, e.g. WAEC 2019 "Paper 1".
+// DurationSeconds is how long candidates had to sit the paper, and how long a mock exam of it lasts.
+type PastPaperData struct {
+	ExamBody        string `json:"exam_body" validate:"required,min=1,max=50"`
+	Year            int    `json:"year" validate:"required,gte=1900,lte=2100"`
+	Paper           string `json:"paper" validate:"required,min=1,max=50"`
+	DurationSeconds int64  `json:"duration_seconds" validate:"required,gte=60,lte=14400"`
+}
+
+// PastPaper is a past paper of a subject
+type PastPaper struct {
+	Id              int64  `json:"id"`
+	SubjectId       int64  `json:"subject_id"`
+	ExamBody        string `json:"exam_body"`
+	Year            int    `json:"year"`
+	Paper           string `json:"paper"`
+	DurationSeconds int64  `json:"duration_seconds"`
+	Questions       int64  `json:"questions"` // questions of the paper that have been added
+}
+
+// QuestionSourceData sets the past paper a question was set in and its number on the
+// paper. A PaperId of 0 clears the source.
+type QuestionSourceData struct {
+	PaperId        int64 `json:"paper_id" validate:"omitempty,gt=0"`
+	QuestionNumber int   `json:"question_number" validate:"required_with=PaperId,omitempty,gte=1,lte=500"`
+}
+
+// QuestionSource is where a question was set
+type QuestionSource struct {
+	PaperId        int64  `json:"paper_id"`
+	ExamBody       string `json:"exam_body"`
+	Year           int    `json:"year"`
+	Paper          string `json:"paper"`
+	QuestionNumber int    `json:"question_number"`
+}
+
+// MockExamRequest is used when requesting a mock exam of a past paper
+type MockExamRequest struct {
+	PaperId int64 `json:"paper_id" validate:"required,gt=0"`
+}
+
+// PaperComparison compares a mock exam with everyone else's first sitting of the same
+// paper. Sittings submitted after their deadline are left out of it.
+type PaperComparison struct {
+	PaperId       int64   `json:"paper_id"`
+	Candidates    int64   `json:"candidates"` // everyone compared, you included
+	Rank          int64   `json:"rank"`
+	Percentile    float64 `json:"percentile"` // share of candidates who scored the same as you or less
+	AveragePoints float64 `json:"average_points"`
+	HighestPoints float64 `json:"highest_points"`
+}
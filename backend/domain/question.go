@@ -1,9 +1,53 @@
 package domain
 
 type Question struct {
-	ID          int64    `json:"id"`
-	Text        string   `json:"text"`
-	Option      []string `json:"option"`
-	Answer      string   `json:"answer"`
-	Explanation string   `json:"explanation"`
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Text        string          `json:"text"`
+	TextHTML    string          `json:"text_html"` // Text rendered as sanitised HTML
+	Option      []string        `json:"option"`
+	OptionHTML  []string        `json:"option_html"` // Option rendered as sanitised HTML, in the same order
+	OptionIds   []int64         `json:"option_ids"`  // the ids of Option, in the same order
+	Answer      string          `json:"answer"`
+	Explanation string          `json:"explanation"`
+	Difficulty  int             `json:"difficulty"`
+	TopicId     int64           `json:"topic_id,omitempty"`
+	Tags        []string        `json:"tags"`
+	Source      *QuestionSource `json:"source,omitempty"` // the past paper the question was set in
+	Media       []Media         `json:"media"`            // images and audio of the question, its options and its explanation
 }
+
+// Question difficulty levels
+var (
+	DifficultyEasy   = 1
+	DifficultyMedium = 2
+	DifficultyHard   = 3
+)
+
+// Question types. Choice and true/false questions are answered by picking options, numeric
+// questions with a number and short text questions with a few words. Ordering questions
+// are answered by putting their options in order, and matching questions by pairing each
+// option with one of its matches.
+var (
+	QuestionTypeChoice    = "choice"
+	QuestionTypeTrueFalse = "true_false"
+	QuestionTypeNumeric   = "numeric"
+	QuestionTypeShortText = "short_text"
+	QuestionTypeOrdering  = "ordering"
+	QuestionTypeMatching  = "matching"
+)
+
+// The options a true/false question is issued with, in this order
+var (
+	TrueOption  = "True"
+	FalseOption = "False"
+)
+
+// Difficulty calibration. Once a question has been answered DifficultyCalibrationMinAttempts
+// times its difficulty is recalculated from how often it was answered correctly: easy at or
+// above DifficultyEasyAccuracy, hard below DifficultyHardAccuracy and medium in between.
+const (
+	DifficultyCalibrationMinAttempts = 20
+	DifficultyEasyAccuracy           = 0.75
+	DifficultyHardAccuracy           = 0.4
+)
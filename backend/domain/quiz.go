@@ -1,79 +1,255 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"strings"
+	"time"
+)
 
-// SubmitQuizRequest is used when submitting quiz answers
+// SubmitQuizRequest is used when submitting quiz answers.
+// Choice and true/false questions are answered with OptionIds, ordering questions with
+// every OptionId in order, numeric questions with Value and optionally Unit, short text
+// questions with Text and matching questions with Pairs.
 type SubmitQuizRequest struct {
-	QuestionId       int64   `json:"question_id" validate:"required,gt=0"`
-	IsMultipleChoice bool    `json:"is_multiple_choice"`
-	OptionIds        []int64 `json:"option_ids" validate:"required,min=1,dive,gt=0"`
+	QuestionId       int64       `json:"question_id" validate:"required,gt=0"`
+	IsMultipleChoice bool        `json:"is_multiple_choice"`
+	OptionIds        []int64     `json:"option_ids" validate:"required_without_all=Value Text Pairs,omitempty,dive,gt=0"`
+	Value            *float64    `json:"value"`
+	Unit             string      `json:"unit" validate:"omitempty,max=32"`
+	Text             string      `json:"text" validate:"omitempty,max=500"`
+	Pairs            []MatchPair `json:"pairs" validate:"omitempty,dive"`
 }
 
-// QuizRequest is used when requesting to generate a quiz
+// MatchPair pairs an option of a matching question with one of its matches
+type MatchPair struct {
+	OptionId int64 `json:"option_id" validate:"required,gt=0"`
+	MatchId  int64 `json:"match_id" validate:"required,gt=0"`
+}
+
+// Answered reports whether anything was given as the answer
+func (sr *SubmitQuizRequest) Answered() bool {
+	return len(sr.OptionIds) > 0 || sr.Value != nil || strings.TrimSpace(sr.Text) != "" || len(sr.Pairs) > 0
+}
+
+// QuizSubmission is used when submitting the answers for a quiz session
+type QuizSubmission struct {
+	SessionId int64               `json:"session_id" validate:"required,gt=0"`
+	Answers   []SubmitQuizRequest `json:"answers" validate:"required,min=1,dive"`
+}
+
+// QuizRequest is used when requesting to generate a quiz.
+// A quiz either draws NumOfQuestions from SubjectId, or mixes several subjects
+// with a question count for each in Subjects.
+// TopicIds and Tags narrow down the questions drawn, see QuizSubjectRequest.
 type QuizRequest struct {
-	SubjectId      int64 `json:"subject_id" validate:"required,gt=0"`
-	NumOfQuestions int64 `json:"num_of_questions" validate:"required,gte=1,lte=100"`
+	SubjectId       int64                `json:"subject_id" validate:"required_without=Subjects,excluded_with=Subjects,omitempty,gt=0"`
+	NumOfQuestions  int64                `json:"num_of_questions" validate:"required_without=Subjects,excluded_with=Subjects,omitempty,gte=1,lte=100"`
+	TopicIds        []int64              `json:"topic_ids" validate:"excluded_with=Subjects,omitempty,max=20,dive,gt=0"`
+	Tags            []string             `json:"tags" validate:"excluded_with=Subjects,omitempty,max=20,dive,required,max=50"`
+	Subjects        []QuizSubjectRequest `json:"subjects" validate:"omitempty,max=20,dive"`
+	Mode            string               `json:"mode" validate:"omitempty,oneof=practice exam adaptive"`
+	DurationSeconds int64                `json:"duration_seconds" validate:"omitempty,gte=60,lte=14400"`
+	// ShuffleQuestions mixes the questions up; by default they come subject by subject
+	ShuffleQuestions bool `json:"shuffle_questions"`
+}
+
+// QuizSubjectRequest is the number of questions to draw from a subject in a mixed quiz.
+// With TopicIds only questions filed under one of the topics, or a topic below one of
+// them, are drawn. With Tags only questions carrying at least one of the tags are drawn.
+type QuizSubjectRequest struct {
+	SubjectId      int64    `json:"subject_id" validate:"required,gt=0"`
+	NumOfQuestions int64    `json:"num_of_questions" validate:"required,gte=1,lte=100"`
+	TopicIds       []int64  `json:"topic_ids,omitempty" validate:"omitempty,max=20,dive,gt=0"`
+	Tags           []string `json:"tags,omitempty" validate:"omitempty,max=20,dive,required,max=50"`
 }
 
 // QuizOptionResponse represents an option without revealing if it's correct
 type QuizOptionResponse struct {
-	Id     int64  `json:"id"`
-	Option string `json:"option"`
+	Id         int64   `json:"id"`
+	Option     string  `json:"option"`
+	OptionHTML string  `json:"option_html"` // Option rendered as sanitised HTML
+	Media      []Media `json:"media,omitempty"`
 }
 
 // QuizQuestionResponse represents a question in a generated quiz (for frontend)
 type QuizQuestionResponse struct {
 	QuestionId       int64                `json:"question_id"`
+	Type             string               `json:"type"`
 	Question         string               `json:"question"`
+	QuestionHTML     string               `json:"question_html"` // Question rendered as sanitised HTML
 	SubjectId        int64                `json:"subject_id"`
 	IsMultipleChoice bool                 `json:"is_multiple_choice"`
-	Options          []QuizOptionResponse `json:"options"`
+	Difficulty       int                  `json:"difficulty"`
+	Review           bool                 `json:"review,omitempty"`  // served from the user's review queue
+	Unit             string               `json:"unit,omitempty"`    // the unit a numeric answer is expected in
+	Media            []Media              `json:"media,omitempty"`   // images and audio of the question
+	Options          []QuizOptionResponse `json:"options"`           // empty for numeric and short text questions
+	Matches          []QuizOptionResponse `json:"matches,omitempty"` // what the options of a matching question are paired with
 }
 
-// GeneratedQuizResponse is the response when generating a quiz
+// GeneratedQuizResponse is the response when generating a quiz.
+// SubjectId is 0 for a quiz mixing several subjects.
 type GeneratedQuizResponse struct {
-	SubjectId  int64                  `json:"subject_id"`
-	TotalCount int                    `json:"total_count"`
-	Questions  []QuizQuestionResponse `json:"questions"`
+	SessionId       int64                  `json:"session_id"`
+	SubjectId       int64                  `json:"subject_id"`
+	Subjects        []QuizSubjectRequest   `json:"subjects"`
+	Mode            string                 `json:"mode"`
+	ChallengeCode   string                 `json:"challenge_code,omitempty"`
+	DurationSeconds int64                  `json:"duration_seconds,omitempty"`
+	ExpiresAt       *time.Time             `json:"expires_at,omitempty"`
+	Paper           *PastPaper             `json:"paper,omitempty"` // the past paper a mock exam reconstructs
+	ContestId       int64                  `json:"contest_id,omitempty"`
+	DailyDate       string                 `json:"daily_date,omitempty"` // the day of the daily challenge, YYYY-MM-DD
+	TotalCount      int                    `json:"total_count"`
+	Questions       []QuizQuestionResponse `json:"questions"`
 }
 
 // QuizResultResponse is the response after submitting a quiz (reveals answers)
 type QuizResultResponse struct {
-	QuestionId      int64    `json:"question_id"`
-	Question        string   `json:"question"`
-	SelectedOptions []string `json:"selected_options"`
-	CorrectAnswer   string   `json:"correct_answer"`
-	IsCorrect       bool     `json:"is_correct"`
-	Explanation     string   `json:"explanation"`
+	QuestionId       int64    `json:"question_id"`
+	Type             string   `json:"type"`
+	Question         string   `json:"question"`
+	QuestionHTML     string   `json:"question_html"` // Question rendered as sanitised HTML
+	SelectedOptions  []string `json:"selected_options"`
+	Response         string   `json:"response,omitempty"` // the numeric or short text answer given
+	CorrectAnswer    string   `json:"correct_answer"`
+	CorrectAnswers   []string `json:"correct_answers"` // every accepted answer of a short text question
+	Tolerance        float64  `json:"tolerance,omitempty"`
+	IsCorrect        bool     `json:"is_correct"`
+	Credit           float64  `json:"credit"` // 0 to 1, fractional only for partial credit questions
+	Points           float64  `json:"points"`
+	Explanation      string   `json:"explanation"`
+	ExplanationHTML  string   `json:"explanation_html"`            // Explanation rendered as sanitised HTML
+	Error            string   `json:"error,omitempty"`             // why the question could not be graded, if it could not
+	Media            []Media  `json:"media,omitempty"`             // images and audio of the question
+	ExplanationMedia []Media  `json:"explanation_media,omitempty"` // images and audio of the explanation
 }
 
 // QuizSubmitResponse is the full response after submitting a quiz
 type QuizSubmitResponse struct {
+	SessionId        int64                `json:"session_id"`
 	UserId           int64                `json:"user_id"`
 	SubjectId        int64                `json:"subject_id"`
 	TotalQuestions   int64                `json:"total_questions"`
 	CorrectAnswers   int64                `json:"correct_answers"`
 	IncorrectAnswers int64                `json:"incorrect_answers"`
-	Score            int64                `json:"score"`
+	Score            int64                `json:"score"`                    // points rounded to the nearest whole number
+	Points           float64              `json:"points"`                   // points awarded by the scoring policy
+	ScoringPolicy    string               `json:"scoring_policy,omitempty"` // empty when subjects of a mixed quiz use different policies
+	Mode             string               `json:"mode"`
+	TimeTakenSeconds int64                `json:"time_taken_seconds"`
+	IsLate           bool                 `json:"is_late"`
+	Subjects         []QuizSubjectResult  `json:"subjects"`
+	Paper            *PaperComparison     `json:"paper,omitempty"`  // how a mock exam compares with others who sat the paper
+	Badges           []Badge              `json:"badges,omitempty"` // badges earned by this submission
+	XP               int64                `json:"xp,omitempty"`     // XP earned by this submission
 	Results          []QuizResultResponse `json:"results"`
 }
 
+// QuizSubjectResult is the part of a quiz result for one subject
+type QuizSubjectResult struct {
+	SubjectId        int64   `json:"subject_id"`
+	TotalQuestions   int64   `json:"total_questions"`
+	CorrectAnswers   int64   `json:"correct_answers"`
+	IncorrectAnswers int64   `json:"incorrect_answers"`
+	Score            int64   `json:"score"`
+	Points           float64 `json:"points"`
+	ScoringPolicy    string  `json:"scoring_policy"`
+}
+
+// QuestionsData is used when authoring a question.
+// For choice questions, the default type, Answer holds the single correct option and
+// Answers lists every correct option for "select all that apply" questions. Either one
+// may be used, or both.
+// True/false questions have no Options and an Answer of "true" or "false".
+// Numeric questions have no Options either; NumericAnswer is the answer, and a value
+// within Tolerance of it is correct. Unit is what the answer is measured in, if anything.
+// Short text questions accept Answer and every one of Answers, ignoring case and spacing.
+// Ordering questions list their Options in the correct order.
+// Matching questions have no Options; each of Pairs is an option and its match.
+// Choice, ordering and matching questions can give PartialCredit, for each correct option,
+// position or pair.
+// TopicId files the question under a topic of its subject, and Tags are free-form labels.
+// Source is the past paper of the subject the question was set in, if any.
+// Name, Explanation and the Options, correct Answers and Pairs of choice, ordering and
+// matching questions are Markdown with LaTeX math between $ signs, see package richtext.
 type QuestionsData struct {
-	Name        string   `json:"name" validate:"required,min=1"`
-	Options     []string `json:"options" validate:"required,min=2,dive,required"`
-	Answer      string   `json:"answer" validate:"required"`
-	Explanation string   `json:"explanation" validate:"required"`
+	Type          string              `json:"type" validate:"omitempty,oneof=choice true_false numeric short_text ordering matching"`
+	Name          string              `json:"name" validate:"required,min=1"`
+	Options       []string            `json:"options" validate:"omitempty,min=2,dive,required"`
+	Answer        string              `json:"answer"`
+	Answers       []string            `json:"answers" validate:"omitempty,dive,required"`
+	NumericAnswer *float64            `json:"numeric_answer"`
+	Tolerance     float64             `json:"tolerance" validate:"gte=0"`
+	Unit          string              `json:"unit" validate:"omitempty,max=32"`
+	Pairs         []QuestionPair      `json:"pairs" validate:"omitempty,dive"`
+	PartialCredit bool                `json:"partial_credit"`
+	Weight        float64             `json:"weight" validate:"omitempty,gt=0,lte=100"`
+	Difficulty    int                 `json:"difficulty" validate:"omitempty,gte=1,lte=3"` // 1 easy, 2 medium (default), 3 hard
+	Explanation   string              `json:"explanation" validate:"required"`
+	TopicId       int64               `json:"topic_id" validate:"omitempty,gt=0"`
+	Tags          []string            `json:"tags" validate:"omitempty,max=20,dive,required,max=50"`
+	Source        *QuestionSourceData `json:"source" validate:"omitempty"`
+}
+
+// QuestionPair is an option of a matching question and what it matches
+type QuestionPair struct {
+	Option string `json:"option" validate:"required"`
+	Match  string `json:"match" validate:"required"`
+}
+
+// QuestionType returns the type of the question, choice when none is set.
+func (qd *QuestionsData) QuestionType() string {
+	if qd.Type == "" {
+		return QuestionTypeChoice
+	}
+	return qd.Type
+}
+
+// CorrectAnswers returns the de-duplicated list of correct options from Answer and Answers.
+func (qd *QuestionsData) CorrectAnswers() []string {
+	answers := make([]string, 0, len(qd.Answers)+1)
+	seen := make(map[string]bool, len(qd.Answers)+1)
+	for _, answer := range append([]string{qd.Answer}, qd.Answers...) {
+		if answer == "" || seen[answer] {
+			continue
+		}
+		seen[answer] = true
+		answers = append(answers, answer)
+	}
+	return answers
 }
 
 func (qd *QuestionsData) Validate() error {
 	if qd.Name == "" {
 		return errors.New("question name is empty")
 	}
-	if len(qd.Options) == 0 {
-		return errors.New("question options are empty")
-	}
-	if qd.Answer == "" {
-		return errors.New("question answer is empty")
+	switch qd.QuestionType() {
+	case QuestionTypeChoice:
+		if len(qd.Options) == 0 {
+			return errors.New("question options are empty")
+		}
+		if len(qd.CorrectAnswers()) == 0 {
+			return errors.New("question answer is empty")
+		}
+	case QuestionTypeTrueFalse, QuestionTypeShortText:
+		if len(qd.CorrectAnswers()) == 0 {
+			return errors.New("question answer is empty")
+		}
+	case QuestionTypeNumeric:
+		if qd.NumericAnswer == nil {
+			return errors.New("question answer is empty")
+		}
+	case QuestionTypeOrdering:
+		if len(qd.Options) == 0 {
+			return errors.New("question options are empty")
+		}
+	case QuestionTypeMatching:
+		if len(qd.Pairs) == 0 {
+			return errors.New("question pairs are empty")
+		}
+	default:
+		return errors.New("question type is invalid")
 	}
 	if qd.Explanation == "" {
 		return errors.New("question explanation is empty")
@@ -0,0 +1,16 @@
+package domain
+
+// ReviewSubjectQueue is how many missed questions a user has queued for review in a
+// subject, and how many of them are due now.
+type ReviewSubjectQueue struct {
+	SubjectId int64 `json:"subject_id"`
+	Queued    int64 `json:"queued"`
+	Due       int64 `json:"due"`
+}
+
+// ReviewQueueResponse is a user's review queue across all subjects
+type ReviewQueueResponse struct {
+	Queued   int64                `json:"queued"`
+	Due      int64                `json:"due"`
+	Subjects []ReviewSubjectQueue `json:"subjects"`
+}
@@ -0,0 +1,23 @@
+package domain
+
+// Scoring policy names
+var (
+	ScoringStandard        = "standard"
+	ScoringNegativeMarking = "negative_marking"
+	ScoringAllOrNothing    = "all_or_nothing"
+)
+
+// ScoringPolicy configures how graded answers are turned into points.
+// WrongPenalty and UnansweredPenalty are multiplied by the question weight and
+// subtracted, they only apply to the negative_marking policy.
+type ScoringPolicy struct {
+	Name              string  `json:"name" validate:"required,oneof=standard negative_marking all_or_nothing"`
+	WrongPenalty      float64 `json:"wrong_penalty" validate:"gte=0,lte=10"`
+	UnansweredPenalty float64 `json:"unanswered_penalty" validate:"gte=0,lte=10"`
+}
+
+// DefaultScoringPolicy is used for subjects that have no policy configured:
+// one point per question weight for a correct answer and nothing taken away.
+func DefaultScoringPolicy() ScoringPolicy {
+	return ScoringPolicy{Name: ScoringStandard}
+}
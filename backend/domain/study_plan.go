@@ -0,0 +1,78 @@
+package domain
+
+import "time"
+
+// StudyPlanData is a user's goal: the day of their exam, the subjects it covers and the
+// accuracy they are aiming for. DailyQuestions is how many questions a day the schedule
+// asks for, 20 by default.
+type StudyPlanData struct {
+	ExamDate       string  `json:"exam_date" validate:"required,datetime=2006-01-02"`
+	SubjectIds     []int64 `json:"subject_ids" validate:"required,min=1,max=10,dive,gt=0"`
+	TargetAccuracy float64 `json:"target_accuracy" validate:"required,gt=0,lte=100"` // percent
+	DailyQuestions int64   `json:"daily_questions" validate:"omitempty,gte=5,lte=200"`
+}
+
+// Why a study task was scheduled
+var (
+	StudyReasonNotStarted  = "not_started"  // too few questions answered in the subject to know how it is going
+	StudyReasonBelowTarget = "below_target" // recent accuracy is below the target
+	StudyReasonOnTarget    = "on_target"    // keeping a subject at the target going
+	StudyReasonReviewsDue  = "reviews_due"  // questions in the review queue are due
+	StudyReasonExamWeek    = "exam_week"    // timed practice in the last week before the exam
+)
+
+// StudyPlan is a user's study plan, how they are doing in its subjects and how closely
+// they have kept to its schedule.
+type StudyPlan struct {
+	Id             int64              `json:"id"`
+	ExamDate       string             `json:"exam_date"`
+	TargetAccuracy float64            `json:"target_accuracy"`
+	DailyQuestions int64              `json:"daily_questions"`
+	DaysLeft       int64              `json:"days_left"` // days until the exam, 0 on the day of it
+	Subjects       []StudyPlanSubject `json:"subjects"`
+	Adherence      StudyAdherence     `json:"adherence"`
+	Days           []StudyDay         `json:"days"` // every day of the plan so far, oldest first
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+// StudyPlanSubject is how the user is doing in a subject of their study plan, from their
+// most recent quizzes in it.
+type StudyPlanSubject struct {
+	SubjectId         int64   `json:"subject_id"`
+	SubjectName       string  `json:"subject_name"`
+	QuestionsAnswered int64   `json:"questions_answered"`
+	Accuracy          float64 `json:"accuracy"` // percent
+	ReviewsDue        int64   `json:"reviews_due"`
+	OnTarget          bool    `json:"on_target"`
+}
+
+// StudyTask is a quiz the schedule recommends for a day, and how much of it has been done.
+// Any quiz in the subject that day counts towards it.
+type StudyTask struct {
+	SubjectId      int64  `json:"subject_id"`
+	SubjectName    string `json:"subject_name"`
+	Mode           string `json:"mode"` // the quiz mode to take it in
+	NumOfQuestions int64  `json:"num_of_questions"`
+	Reason         string `json:"reason"`
+	Answered       int64  `json:"answered"`
+	Done           bool   `json:"done"`
+}
+
+// StudyDay is a day of a study plan's schedule. A day is met once every task is done; a
+// day the schedule was never looked at is met once the plan's daily questions have been
+// answered in its subjects.
+type StudyDay struct {
+	Date            string      `json:"date"`
+	Tasks           []StudyTask `json:"tasks"`
+	TargetQuestions int64       `json:"target_questions"`
+	Answered        int64       `json:"answered"`
+	Met             bool        `json:"met"`
+}
+
+// StudyAdherence is how many days of a study plan have been met. Today only counts once
+// it has been met.
+type StudyAdherence struct {
+	DaysPlanned int64   `json:"days_planned"`
+	DaysMet     int64   `json:"days_met"`
+	Percent     float64 `json:"percent"`
+}
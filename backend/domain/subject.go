@@ -4,3 +4,46 @@ type Subject struct {
 	Id   int64  `json:"id"`
 	Name string `json:"name" validate:"required,min=1,max=100"`
 }
+
+// TopicData is used when creating or renaming a topic. A topic can be nested under a
+// parent topic of the same subject; the parent cannot be changed once it is created.
+type TopicData struct {
+	Name     string `json:"name" validate:"required,min=1,max=100"`
+	ParentId int64  `json:"parent_id" validate:"omitempty,gt=0"`
+}
+
+// Topic is a topic within a subject. Path names it along with every topic above it,
+// e.g. "Algebra → Quadratics".
+type Topic struct {
+	Id        int64  `json:"id"`
+	SubjectId int64  `json:"subject_id"`
+	ParentId  int64  `json:"parent_id,omitempty"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Questions int64  `json:"questions"` // questions filed directly under the topic
+}
+
+// SubjectTag is a tag used on questions of a subject, and how many of them carry it
+type SubjectTag struct {
+	Tag       string `json:"tag"`
+	Questions int64  `json:"questions"`
+}
+
+// QuestionClassification files a question under a topic and replaces its tags.
+// A TopicId of 0 takes the question out of its topic.
+type QuestionClassification struct {
+	TopicId int64    `json:"topic_id" validate:"omitempty,gt=0"`
+	Tags    []string `json:"tags" validate:"omitempty,max=20,dive,required,max=50"`
+}
+
+// TopicAccuracy is how a user has done on the questions filed under a topic. Like the
+// rest of the user's stats, questions left unanswered in a quiz count against them.
+type TopicAccuracy struct {
+	TopicId         int64   `json:"topic_id"`
+	SubjectId       int64   `json:"subject_id"`
+	ParentId        int64   `json:"parent_id,omitempty"`
+	Topic           string  `json:"topic"`
+	TotalQuestions  int64   `json:"total_questions"`
+	CorrectAnswers  int64   `json:"correct_answers"`
+	AccuracyPercent float64 `json:"accuracy_percent"`
+}
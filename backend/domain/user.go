@@ -43,17 +43,21 @@ type UpdatePassword struct {
 }
 
 type UserScore struct {
-	ID               int64     `json:"id"`
-	UserID           int64     `json:"user_id"`
-	SubjectID        int64     `json:"subject_id"`
-	Score            int64     `json:"score"`
-	CorrectAnswers   int64     `json:"correct_answers"`
-	IncorrectAnswers int64     `json:"incorrect_answers"`
-	TotalQuestions   int64     `json:"total_questions"`
-	TimeTakenSeconds int64     `json:"time_taken_seconds"`
-	Mode             string    `json:"mode"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID               int64        `json:"id"`
+	UserID           int64        `json:"user_id"`
+	SessionID        int64        `json:"session_id,omitempty"`
+	SubjectID        int64        `json:"subject_id"`
+	Score            int64        `json:"score"`
+	Points           float64      `json:"points"`
+	ScoringPolicy    string       `json:"scoring_policy"`
+	CorrectAnswers   int64        `json:"correct_answers"`
+	IncorrectAnswers int64        `json:"incorrect_answers"`
+	TotalQuestions   int64        `json:"total_questions"`
+	TimeTakenSeconds int64        `json:"time_taken_seconds"`
+	Mode             string       `json:"mode"`
+	Answers          []QuizAnswer `json:"answers,omitempty"` // stored along with the score, one per question
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
 }
 
 // User roles
@@ -66,13 +70,25 @@ var (
 var (
 	ModeSingle   = "single"
 	ModeMultiple = "multiple"
+	ModePractice = "practice"
+	ModeExam     = "exam"
+	ModeAdaptive = "adaptive"
+	ModeReview   = "review"
+	ModeMock     = "mock"
+	ModeLive     = "live"
+	ModeContest  = "contest"
+	ModeDaily    = "daily"
 )
 
 // User Dashboard details, including scores and other details
 type UserDashboard struct {
 	UserResponse
 	UserStats
-	Roles []string `json:"roles"`
+	Roles  []string        `json:"roles"`
+	Topics []TopicAccuracy `json:"topics"` // accuracy in every topic the user has answered questions from
+	Streak Streak          `json:"streak"` // days in a row the user has taken a daily challenge
+	Badges []Badge         `json:"badges"` // badges the user has earned, oldest first
+	XP     XPProgress      `json:"xp"`
 }
 
 // User stats
@@ -82,6 +98,7 @@ type UserStats struct {
 	TotalCorrectAnswers    int64 `json:"total_correct_answers"`
 	TotalIncorrectAnswers  int64 `json:"total_incorrect_answers"`
 	TotalQuestionsAnswered int64 `json:"total_questions_answered"`
+	TotalTimeTakenSeconds  int64 `json:"total_time_taken_seconds"`
 }
 
 // ForgotPasswordRequest is the request body for initiating a password reset
@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// Reasons XP is awarded for
+var (
+	XPReasonCorrectAnswers  = "correct_answers"
+	XPReasonDifficulty      = "difficulty"
+	XPReasonStreak          = "streak"
+	XPReasonFirstCompletion = "first_completion"
+)
+
+// XPEntry is a line of the XP ledger. The ledger is only ever appended to, so a user's XP
+// is the sum of their entries and each can be traced back to the attempt that earned it.
+type XPEntry struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	SessionID int64     `json:"session_id,omitempty"`
+	SubjectID int64     `json:"subject_id,omitempty"`
+	Reason    string    `json:"reason"`
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// XPProgress is a user's XP and how far they are through their level
+type XPProgress struct {
+	Total        int64 `json:"total"`
+	Level        int64 `json:"level"`
+	LevelStartXP int64 `json:"level_start_xp"` // total XP the level was reached at
+	NextLevelXP  int64 `json:"next_level_xp"`  // total XP the next level is reached at
+}
+
+// XPHistoryQuery is the query for a page of the user's XP ledger
+type XPHistoryQuery struct {
+	Limit  int `query:"limit" validate:"omitempty,gte=1,lte=100"`
+	Offset int `query:"offset" validate:"omitempty,gte=0"`
+}
+
+// XPHistoryResponse is the user's XP and a page of their ledger, newest first
+type XPHistoryResponse struct {
+	XPProgress
+	TotalEntries int64     `json:"total_entries"`
+	Entries      []XPEntry `json:"entries"`
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lawson/otterprep/internal/service"
+	"github.com/lawson/otterprep/pkg"
+)
+
+type AchievementHandler struct {
+	achievementService service.AchievementService
+	logger             *log.Logger
+}
+
+func NewAchievementHandler(achievementService service.AchievementService, logger *log.Logger) *AchievementHandler {
+	return &AchievementHandler{
+		achievementService: achievementService,
+		logger:             logger,
+	}
+}
+
+// =========================================================
+// 		Achievement Handler
+// =========================================================
+
+// GetBadges returns every badge that can be earned
+// @Summary List badges
+// @Description List every badge that can be earned, with the rules that earn it
+// @Tags Achievements
+// @Produce JSON
+// @Success 200 {array} domain.BadgeDefinition
+// @Router /achievements [get]
+func (ah *AchievementHandler) GetBadges(c echo.Context) error {
+	return pkg.SuccessResponse(c, ah.achievementService.GetBadges(), http.StatusOK)
+}
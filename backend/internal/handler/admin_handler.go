@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -85,7 +86,11 @@ func (ah *AdminHandler) UploadSingleQuestion(c echo.Context) error {
 	_, err = ah.questionService.CreateQuestion(c.Request().Context(), subjectIdInt, question)
 	if err != nil {
 		ah.logger.Println("error creating question: ", err)
-		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pkg.ErrInvalidRichText) {
+			status = http.StatusBadRequest
+		}
+		return pkg.ErrorResponse(c, err, status)
 	}
 	ah.logger.Println("Successfully created question. Proceeding to return success response.")
 	return pkg.SuccessResponse(c, nil, http.StatusCreated)
@@ -282,3 +287,526 @@ func (ah *AdminHandler) GetAllSubjects(c echo.Context) error {
 	ah.logger.Println("Successfully got all subjects. Proceeding to return success response.")
 	return pkg.SuccessResponse(c, subjects, http.StatusOK)
 }
+
+// GetSubjectScoringPolicy gets the scoring policy of a subject.
+// @Summary Get the scoring policy of a subject
+// @Description Get the scoring policy applied to new quizzes for a subject
+// @Tags Subject
+// @Produce json
+// @Param id path int true "Subject ID"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /admin/subject/{id}/scoring-policy [get]
+func (ah *AdminHandler) GetSubjectScoringPolicy(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	subjectIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	policy, err := ah.questionService.GetSubjectScoringPolicy(c.Request().Context(), subjectIdInt)
+	if err != nil {
+		ah.logger.Println("error getting scoring policy: ", err)
+		if errors.Is(err, pkg.ErrSubjectNotFound) {
+			return pkg.ErrorResponse(c, err, http.StatusNotFound)
+		}
+		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+	}
+	return pkg.SuccessResponse(c, policy, http.StatusOK)
+}
+
+// SetSubjectScoringPolicy sets the scoring policy of a subject.
+// @Summary Set the scoring policy of a subject
+// @Description Set the scoring policy applied to new quizzes for a subject
+// @Tags Subject
+// @Accept json
+// @Produce json
+// @Param id path int true "Subject ID"
+// @Param policy body domain.ScoringPolicy true "Scoring policy"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /admin/subject/{id}/scoring-policy [put]
+func (ah *AdminHandler) SetSubjectScoringPolicy(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	subjectIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	var policy domain.ScoringPolicy
+	if err := c.Bind(&policy); err != nil {
+		ah.logger.Println("error binding scoring policy: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&policy); err != nil {
+		return err
+	}
+	err = ah.questionService.SetSubjectScoringPolicy(c.Request().Context(), subjectIdInt, policy)
+	if err != nil {
+		ah.logger.Println("error setting scoring policy: ", err)
+		switch {
+		case errors.Is(err, pkg.ErrSubjectNotFound):
+			return pkg.ErrorResponse(c, err, http.StatusNotFound)
+		case errors.Is(err, pkg.ErrInvalidScoringPolicy):
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+	}
+	ah.logger.Println("Successfully set scoring policy. Proceeding to return success response.")
+	return pkg.SuccessResponse(c, policy, http.StatusOK)
+}
+
+// topicErrorStatus maps the errors of managing topics and classifying questions to a status code.
+func topicErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrSubjectNotFound), errors.Is(err, pkg.ErrTopicNotFound), errors.Is(err, pkg.ErrQuestionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrTopicWithNameExists):
+		return http.StatusConflict
+	case errors.Is(err, pkg.ErrInvalidName), errors.Is(err, pkg.ErrInvalidParentTopic):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateTopic creates a topic in a subject.
+// @Summary Create a topic
+// @Description Create a topic in a subject, optionally nested under another topic of the subject
+// @Tags Subject
+// @Accept json
+// @Produce json
+// @Param id path int true "Subject ID"
+// @Param topic body domain.TopicData true "Topic"
+// @Success 201 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Router /admin/subject/{id}/topics [post]
+func (ah *AdminHandler) CreateTopic(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	subjectIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	var topic domain.TopicData
+	if err := c.Bind(&topic); err != nil {
+		ah.logger.Println("error binding topic: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&topic); err != nil {
+		return err
+	}
+	topicId, err := ah.questionService.CreateTopic(c.Request().Context(), subjectIdInt, topic)
+	if err != nil {
+		ah.logger.Println("error creating topic: ", err)
+		return pkg.ErrorResponse(c, err, topicErrorStatus(err))
+	}
+	ah.logger.Println("Successfully created topic with id: ", topicId)
+	result := map[string]interface{}{
+		"topic_id": topicId,
+	}
+	return pkg.SuccessResponse(c, result, http.StatusCreated)
+}
+
+// GetSubjectTopics gets the topics of a subject.
+// @Summary Get the topics of a subject
+// @Description Get every topic of a subject with its path, for picking the topics of a quiz
+// @Tags Subject
+// @Produce json
+// @Param id path int true "Subject ID"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /admin/subject/{id}/topics [get]
+func (ah *AdminHandler) GetSubjectTopics(c echo.Context) error {
+	subjectIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	topics, err := ah.questionService.GetSubjectTopics(c.Request().Context(), subjectIdInt)
+	if err != nil {
+		ah.logger.Println("error getting subject topics: ", err)
+		return pkg.ErrorResponse(c, err, topicErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, topics, http.StatusOK)
+}
+
+// GetSubjectTags gets the tags used on the questions of a subject.
+// @Summary Get the tags of a subject
+// @Description Get every tag used on the questions of a subject and how many questions carry it
+// @Tags Subject
+// @Produce json
+// @Param id path int true "Subject ID"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /admin/subject/{id}/tags [get]
+func (ah *AdminHandler) GetSubjectTags(c echo.Context) error {
+	subjectIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	tags, err := ah.questionService.GetSubjectTags(c.Request().Context(), subjectIdInt)
+	if err != nil {
+		ah.logger.Println("error getting subject tags: ", err)
+		return pkg.ErrorResponse(c, err, topicErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, tags, http.StatusOK)
+}
+
+// UpdateTopic renames a topic.
+// @Summary Rename a topic
+// @Tags Subject
+// @Accept json
+// @Produce json
+// @Param id path int true "Topic ID"
+// @Param topic body domain.TopicData true "Topic"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Router /admin/topics/{id} [put]
+func (ah *AdminHandler) UpdateTopic(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	topicIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing topic id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrTopicNotFound, http.StatusBadRequest)
+	}
+	var topic domain.TopicData
+	if err := c.Bind(&topic); err != nil {
+		ah.logger.Println("error binding topic: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&topic); err != nil {
+		return err
+	}
+	if err := ah.questionService.UpdateTopic(c.Request().Context(), topicIdInt, topic); err != nil {
+		ah.logger.Println("error updating topic: ", err)
+		return pkg.ErrorResponse(c, err, topicErrorStatus(err))
+	}
+	ah.logger.Println("Successfully updated topic. Proceeding to return success response.")
+	return pkg.SuccessResponse(c, nil, http.StatusOK)
+}
+
+// DeleteTopic deletes a topic and the topics below it.
+// @Summary Delete a topic
+// @Description Delete a topic and every topic below it; their questions are kept without a topic
+// @Tags Subject
+// @Produce json
+// @Param id path int true "Topic ID"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /admin/topics/{id} [delete]
+func (ah *AdminHandler) DeleteTopic(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	topicIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing topic id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrTopicNotFound, http.StatusBadRequest)
+	}
+	if err := ah.questionService.DeleteTopic(c.Request().Context(), topicIdInt); err != nil {
+		ah.logger.Println("error deleting topic: ", err)
+		return pkg.ErrorResponse(c, err, topicErrorStatus(err))
+	}
+	ah.logger.Println("Successfully deleted topic. Proceeding to return success response.")
+	return pkg.SuccessResponse(c, nil, http.StatusOK)
+}
+
+// ClassifyQuestion sets the topic and tags of a question.
+// @Summary Set the topic and tags of a question
+// @Description File a question under a topic of its subject and replace its tags
+// @Tags Questions
+// @Accept json
+// @Produce json
+// @Param id path int true "Question ID"
+// @Param classification body domain.QuestionClassification true "Topic and tags"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /admin/questions/{id}/classification [put]
+func (ah *AdminHandler) ClassifyQuestion(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	questionIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing question id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrQuestionNotFound, http.StatusBadRequest)
+	}
+	var classification domain.QuestionClassification
+	if err := c.Bind(&classification); err != nil {
+		ah.logger.Println("error binding classification: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&classification); err != nil {
+		return err
+	}
+	if err := ah.questionService.ClassifyQuestion(c.Request().Context(), questionIdInt, classification); err != nil {
+		ah.logger.Println("error classifying question: ", err)
+		return pkg.ErrorResponse(c, err, topicErrorStatus(err))
+	}
+	ah.logger.Println("Successfully classified question. Proceeding to return success response.")
+	return pkg.SuccessResponse(c, nil, http.StatusOK)
+}
+
+// paperErrorStatus maps past paper errors to the matching HTTP status code.
+func paperErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrSubjectNotFound), errors.Is(err, pkg.ErrPastPaperNotFound), errors.Is(err, pkg.ErrQuestionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrPastPaperExists), errors.Is(err, pkg.ErrPaperQuestionNumberTaken):
+		return http.StatusConflict
+	case errors.Is(err, pkg.ErrInvalidName):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreatePastPaper adds a past paper to a subject.
+// @Summary Create a past paper
+// @Description Add a paper set by an exam body in a year to a subject, with the time candidates had to sit it
+// @Tags Subject
+// @Accept json
+// @Produce json
+// @Param id path int true "Subject ID"
+// @Param paper body domain.PastPaperData true "Past paper"
+// @Success 201 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Router /admin/subject/{id}/papers [post]
+func (ah *AdminHandler) CreatePastPaper(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	subjectIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	var paper domain.PastPaperData
+	if err := c.Bind(&paper); err != nil {
+		ah.logger.Println("error binding past paper: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&paper); err != nil {
+		return err
+	}
+	paperId, err := ah.questionService.CreatePastPaper(c.Request().Context(), subjectIdInt, paper)
+	if err != nil {
+		ah.logger.Println("error creating past paper: ", err)
+		return pkg.ErrorResponse(c, err, paperErrorStatus(err))
+	}
+	ah.logger.Println("Successfully created past paper with id: ", paperId)
+	result := map[string]interface{}{
+		"paper_id": paperId,
+	}
+	return pkg.SuccessResponse(c, result, http.StatusCreated)
+}
+
+// GetSubjectPastPapers gets the past papers of a subject.
+// @Summary Get the past papers of a subject
+// @Description Get every past paper of a subject, most recent year first, for picking a mock exam
+// @Tags Subject
+// @Produce json
+// @Param id path int true "Subject ID"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /admin/subject/{id}/papers [get]
+func (ah *AdminHandler) GetSubjectPastPapers(c echo.Context) error {
+	subjectIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	papers, err := ah.questionService.GetSubjectPastPapers(c.Request().Context(), subjectIdInt)
+	if err != nil {
+		ah.logger.Println("error getting subject past papers: ", err)
+		return pkg.ErrorResponse(c, err, paperErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, papers, http.StatusOK)
+}
+
+// SetQuestionSource sets the past paper a question was set in.
+// @Summary Set the source of a question
+// @Description Record the past paper of its subject a question was set in and its number on the paper
+// @Tags Questions
+// @Accept json
+// @Produce json
+// @Param id path int true "Question ID"
+// @Param source body domain.QuestionSourceData true "Past paper and question number"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 409 {object} pkg.ErrorResponse
+// @Router /admin/questions/{id}/source [put]
+func (ah *AdminHandler) SetQuestionSource(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	questionIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing question id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrQuestionNotFound, http.StatusBadRequest)
+	}
+	var source domain.QuestionSourceData
+	if err := c.Bind(&source); err != nil {
+		ah.logger.Println("error binding question source: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&source); err != nil {
+		return err
+	}
+	if err := ah.questionService.SetQuestionSource(c.Request().Context(), questionIdInt, source); err != nil {
+		ah.logger.Println("error setting question source: ", err)
+		return pkg.ErrorResponse(c, err, paperErrorStatus(err))
+	}
+	ah.logger.Println("Successfully set question source. Proceeding to return success response.")
+	return pkg.SuccessResponse(c, nil, http.StatusOK)
+}
+
+// maxMediaRequestBytes caps the body of a media upload: the largest file allowed plus
+// room for the rest of the form.
+const maxMediaRequestBytes = service.MaxAudioBytes + 1<<20
+
+// mediaErrorStatus maps media errors to the matching HTTP status code.
+func mediaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrQuestionNotFound), errors.Is(err, pkg.ErrMediaNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrQuestionOptionNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, pkg.ErrMediaTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, pkg.ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// UploadQuestionMedia attaches an image or audio file to a question.
+// @Summary Upload media for a question
+// @Description Attach a PNG, JPEG, GIF or WebP image (up to 5 MB) or an MP3, WAV or Ogg audio file (up to 20 MB) to a question, one of its options or its explanation
+// @Tags Questions
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Question ID"
+// @Param file formData file true "Image or audio file"
+// @Param attach_to formData string true "question, option or explanation"
+// @Param option_id formData int false "Option ID, when attaching to an option"
+// @Param alt_text formData string false "Text alternative for the media"
+// @Success 201 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Failure 413 {object} pkg.ErrorResponse
+// @Failure 415 {object} pkg.ErrorResponse
+// @Router /admin/questions/{id}/media [post]
+func (ah *AdminHandler) UploadQuestionMedia(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	questionIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing question id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrQuestionNotFound, http.StatusBadRequest)
+	}
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxMediaRequestBytes)
+	var upload domain.MediaUpload
+	if err := c.Bind(&upload); err != nil {
+		ah.logger.Println("error binding media upload: ", err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return pkg.ErrorResponse(c, pkg.ErrMediaTooLarge, http.StatusRequestEntityTooLarge)
+		}
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&upload); err != nil {
+		return err
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		ah.logger.Println("error getting media file: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrMediaFileRequired, http.StatusBadRequest)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ah.logger.Println("error opening media file: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrInternalServerError, http.StatusInternalServerError)
+	}
+	defer file.Close()
+	media, err := ah.questionService.UploadQuestionMedia(c.Request().Context(), questionIdInt, upload, file, fileHeader.Size)
+	if err != nil {
+		ah.logger.Println("error uploading question media: ", err)
+		return pkg.ErrorResponse(c, err, mediaErrorStatus(err))
+	}
+	ah.logger.Println("Successfully uploaded question media with id: ", media.Id)
+	return pkg.SuccessResponse(c, media, http.StatusCreated)
+}
+
+// DeleteMedia deletes a media file from its question.
+// @Summary Delete media
+// @Description Remove an image or audio file from its question and from storage
+// @Tags Questions
+// @Produce json
+// @Param id path int true "Media ID"
+// @Success 200 {object} pkg.SuccessResponse
+// @Failure 400 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /admin/media/{id} [delete]
+func (ah *AdminHandler) DeleteMedia(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ah.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	mediaIdInt, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ah.logger.Println("error parsing media id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrMediaNotFound, http.StatusBadRequest)
+	}
+	if err := ah.questionService.DeleteMedia(c.Request().Context(), mediaIdInt); err != nil {
+		ah.logger.Println("error deleting media: ", err)
+		return pkg.ErrorResponse(c, err, mediaErrorStatus(err))
+	}
+	ah.logger.Println("Successfully deleted media. Proceeding to return success response.")
+	return pkg.SuccessResponse(c, nil, http.StatusOK)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/service"
+	"github.com/lawson/otterprep/pkg"
+)
+
+type AnalyticsHandler struct {
+	analyticsService service.AnalyticsService
+	logger           *log.Logger
+}
+
+func NewAnalyticsHandler(analyticsService service.AnalyticsService, logger *log.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsService: analyticsService,
+		logger:           logger,
+	}
+}
+
+// =========================================================
+// 		Analytics Handler
+// =========================================================
+
+// GetLearnerAnalytics returns the user's learner analytics
+// @Summary Get my analytics
+// @Description Get your accuracy and time per question in every subject, your percentile against other users, your best and worst subjects, your trend and your accuracy by day or week
+// @Tags Users
+// @Produce json
+// @Param interval query string false "Series buckets: daily or weekly" default(weekly)
+// @Param periods query int false "Number of buckets in the series" default(12)
+// @Success 200 {object} domain.LearnerAnalytics
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /user/analytics [get]
+func (ah *AnalyticsHandler) GetLearnerAnalytics(c echo.Context) error {
+	query := domain.AnalyticsQuery{Interval: c.QueryParam("interval")}
+	if periodsStr := c.QueryParam("periods"); periodsStr != "" {
+		periods, err := strconv.Atoi(periodsStr)
+		if err != nil {
+			ah.logger.Println("error parsing periods: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Periods = periods
+	}
+	if err := c.Validate(&query); err != nil {
+		return err
+	}
+
+	userId := c.Get("user_id").(int64)
+	analytics, err := ah.analyticsService.GetLearnerAnalytics(c.Request().Context(), userId, query)
+	if err != nil {
+		ah.logger.Println("error getting learner analytics: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+	}
+	return pkg.SuccessResponse(c, analytics, http.StatusOK)
+}
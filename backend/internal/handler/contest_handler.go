@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/middleware"
+	"github.com/lawson/otterprep/internal/service"
+	"github.com/lawson/otterprep/pkg"
+)
+
+type ContestHandler struct {
+	contestService service.ContestService
+	logger         *log.Logger
+}
+
+func NewContestHandler(contestService service.ContestService, logger *log.Logger) *ContestHandler {
+	return &ContestHandler{
+		contestService: contestService,
+		logger:         logger,
+	}
+}
+
+// =========================================================
+// 		Contest Handler
+// =========================================================
+
+// CreateContest schedules a contest
+// @Summary Create a contest
+// @Description Schedule a contest on a fixed set of a subject's questions, open between starts_at and ends_at
+// @Tags Contests
+// @Accept JSON
+// @Produce JSON
+// @Param contest body domain.ContestData true "Contest"
+// @Success 201 {object} domain.Contest
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/contests [post]
+func (ch *ContestHandler) CreateContest(c echo.Context) error {
+	userRole, ok := middleware.GetUserRole(c)
+	if !ok || userRole != "admin" {
+		ch.logger.Println("user is not admin. Proceeding to return error.")
+		return pkg.ErrorResponse(c, pkg.ErrUnauthorized, http.StatusUnauthorized)
+	}
+	var contest domain.ContestData
+	if err := c.Bind(&contest); err != nil {
+		ch.logger.Println("error binding contest: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&contest); err != nil {
+		return err
+	}
+	userId := c.Get("user_id").(int64)
+	created, err := ch.contestService.CreateContest(c.Request().Context(), userId, contest)
+	if err != nil {
+		ch.logger.Println("error creating contest: ", err)
+		return pkg.ErrorResponse(c, err, contestErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, created, http.StatusCreated)
+}
+
+// GetContests lists upcoming, live or past contests
+// @Summary List contests
+// @Tags Contests
+// @Produce JSON
+// @Param status query string false "upcoming, live or past" default(live)
+// @Param limit query int false "Number of contests to return" default(20)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} domain.ContestListResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /contests [get]
+func (ch *ContestHandler) GetContests(c echo.Context) error {
+	query := domain.ContestQuery{Status: c.QueryParam("status")}
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			ch.logger.Println("error parsing limit: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Limit = limit
+	}
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil {
+			ch.logger.Println("error parsing offset: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Offset = offset
+	}
+	if err := c.Validate(&query); err != nil {
+		return err
+	}
+
+	userId := c.Get("user_id").(int64)
+	contests, err := ch.contestService.GetContests(c.Request().Context(), userId, query)
+	if err != nil {
+		ch.logger.Println("error getting contests: ", err)
+		return pkg.ErrorResponse(c, err, contestErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, contests, http.StatusOK)
+}
+
+// GetContest returns a contest
+// @Summary Get a contest
+// @Tags Contests
+// @Produce JSON
+// @Param id path int true "Contest ID"
+// @Success 200 {object} domain.Contest
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /contests/{id} [get]
+func (ch *ContestHandler) GetContest(c echo.Context) error {
+	contestId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ch.logger.Println("error parsing contest id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrContestNotFound, http.StatusBadRequest)
+	}
+	userId := c.Get("user_id").(int64)
+	contest, err := ch.contestService.GetContest(c.Request().Context(), userId, contestId)
+	if err != nil {
+		ch.logger.Println("error getting contest: ", err)
+		return pkg.ErrorResponse(c, err, contestErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, contest, http.StatusOK)
+}
+
+// EnterContest starts the user's one entry to a live contest
+// @Summary Enter a contest
+// @Description Issue the contest's questions as a quiz, submitted through /quiz/submit before the contest ends
+// @Tags Contests
+// @Produce JSON
+// @Param id path int true "Contest ID"
+// @Success 201 {object} domain.GeneratedQuizResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /contests/{id}/enter [post]
+func (ch *ContestHandler) EnterContest(c echo.Context) error {
+	contestId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ch.logger.Println("error parsing contest id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrContestNotFound, http.StatusBadRequest)
+	}
+	userId := c.Get("user_id").(int64)
+	quiz, err := ch.contestService.EnterContest(c.Request().Context(), userId, contestId)
+	if err != nil {
+		ch.logger.Println("error entering contest: ", err)
+		return pkg.ErrorResponse(c, err, contestErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, quiz, http.StatusCreated)
+}
+
+// GetContestLeaderboard returns the frozen leaderboard of a closed contest
+// @Summary Get a contest's leaderboard
+// @Tags Contests
+// @Produce JSON
+// @Param id path int true "Contest ID"
+// @Success 200 {object} domain.ContestLeaderboardResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /contests/{id}/leaderboard [get]
+func (ch *ContestHandler) GetContestLeaderboard(c echo.Context) error {
+	contestId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ch.logger.Println("error parsing contest id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrContestNotFound, http.StatusBadRequest)
+	}
+	userId := c.Get("user_id").(int64)
+	leaderboard, err := ch.contestService.GetContestLeaderboard(c.Request().Context(), userId, contestId)
+	if err != nil {
+		ch.logger.Println("error getting contest leaderboard: ", err)
+		return pkg.ErrorResponse(c, err, contestErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, leaderboard, http.StatusOK)
+}
+
+// contestErrorStatus maps contest errors to the matching HTTP status code.
+func contestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrContestNotFound), errors.Is(err, pkg.ErrSubjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrContestNotOpen), errors.Is(err, pkg.ErrContestAlreadyEntered),
+		errors.Is(err, pkg.ErrContestLeaderboardNotReady):
+		return http.StatusConflict
+	case errors.Is(err, pkg.ErrInvalidContestWindow), errors.Is(err, pkg.ErrContestQuestionsRequired),
+		errors.Is(err, pkg.ErrInvalidContestQuestions), errors.Is(err, pkg.ErrNotEnoughQuestions):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
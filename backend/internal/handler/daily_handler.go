@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/service"
+	"github.com/lawson/otterprep/pkg"
+)
+
+type DailyHandler struct {
+	dailyChallengeService service.DailyChallengeService
+	logger                *log.Logger
+}
+
+func NewDailyHandler(dailyChallengeService service.DailyChallengeService, logger *log.Logger) *DailyHandler {
+	return &DailyHandler{
+		dailyChallengeService: dailyChallengeService,
+		logger:                logger,
+	}
+}
+
+// =========================================================
+// 		Daily Challenge Handler
+// =========================================================
+
+// GetDailyChallenge returns today's daily challenge of a subject
+// @Summary Get a daily challenge
+// @Description Get today's question set of a subject, whether you have taken it and your streak
+// @Tags Daily
+// @Produce JSON
+// @Param subject_id path int true "Subject ID"
+// @Success 200 {object} domain.DailyChallenge
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /daily/{subject_id} [get]
+func (dh *DailyHandler) GetDailyChallenge(c echo.Context) error {
+	subjectId, err := strconv.ParseInt(c.Param("subject_id"), 10, 64)
+	if err != nil {
+		dh.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	userId := c.Get("user_id").(int64)
+	challenge, err := dh.dailyChallengeService.GetDailyChallenge(c.Request().Context(), userId, subjectId)
+	if err != nil {
+		dh.logger.Println("error getting daily challenge: ", err)
+		return pkg.ErrorResponse(c, err, dailyErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, challenge, http.StatusOK)
+}
+
+// TakeDailyChallenge starts the user's attempt at today's daily challenge of a subject
+// @Summary Take a daily challenge
+// @Description Issue today's question set of a subject as a quiz, submitted through /quiz/submit
+// @Tags Daily
+// @Produce JSON
+// @Param subject_id path int true "Subject ID"
+// @Success 201 {object} domain.GeneratedQuizResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /daily/{subject_id} [post]
+func (dh *DailyHandler) TakeDailyChallenge(c echo.Context) error {
+	subjectId, err := strconv.ParseInt(c.Param("subject_id"), 10, 64)
+	if err != nil {
+		dh.logger.Println("error parsing subject id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrSubjectNotFound, http.StatusBadRequest)
+	}
+	userId := c.Get("user_id").(int64)
+	quiz, err := dh.dailyChallengeService.TakeDailyChallenge(c.Request().Context(), userId, subjectId)
+	if err != nil {
+		dh.logger.Println("error taking daily challenge: ", err)
+		return pkg.ErrorResponse(c, err, dailyErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, quiz, http.StatusCreated)
+}
+
+// GetDailyBoard ranks today's daily challenge participants
+// @Summary Get the daily challenge board
+// @Tags Daily
+// @Produce JSON
+// @Param subject_id query int false "Subject ID, for one subject's board"
+// @Param limit query int false "Number of entries to return" default(10)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} domain.DailyBoardResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /daily/board [get]
+func (dh *DailyHandler) GetDailyBoard(c echo.Context) error {
+	var query domain.DailyBoardQuery
+	if subjectIdStr := c.QueryParam("subject_id"); subjectIdStr != "" {
+		subjectId, err := strconv.ParseInt(subjectIdStr, 10, 64)
+		if err != nil {
+			dh.logger.Println("error parsing subject_id: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.SubjectId = &subjectId
+	}
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			dh.logger.Println("error parsing limit: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Limit = limit
+	}
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil {
+			dh.logger.Println("error parsing offset: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Offset = offset
+	}
+	if err := c.Validate(&query); err != nil {
+		return err
+	}
+
+	userId := c.Get("user_id").(int64)
+	board, err := dh.dailyChallengeService.GetDailyBoard(c.Request().Context(), userId, query)
+	if err != nil {
+		dh.logger.Println("error getting daily board: ", err)
+		return pkg.ErrorResponse(c, err, dailyErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, board, http.StatusOK)
+}
+
+// dailyErrorStatus maps daily challenge errors to the matching HTTP status code.
+func dailyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrSubjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrDailyChallengeTaken):
+		return http.StatusConflict
+	case errors.Is(err, pkg.ErrNotEnoughQuestions):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -33,6 +33,7 @@ func NewLeaderboardHandler(leaderboardService service.LeaderboardService, logger
 // @Produce json
 // @Param subject_id query int false "Subject ID for subject-specific leaderboard"
 // @Param period query string false "Time period: all_time, weekly, monthly" default(all_time)
+// @Param metric query string false "What users are ranked on: score, xp" default(score)
 // @Param limit query int false "Number of entries to return" default(10)
 // @Param offset query int false "Offset for pagination" default(0)
 // @Success 200 {object} domain.LeaderboardResponse
@@ -59,6 +60,9 @@ func (h *LeaderboardHandler) GetLeaderboard(c echo.Context) error {
 		query.Period = "all_time"
 	}
 
+	// Parse metric
+	query.Metric = c.QueryParam("metric")
+
 	// Parse limit
 	limitStr := c.QueryParam("limit")
 	if limitStr != "" {
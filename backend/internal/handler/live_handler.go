@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/service"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// Live room socket timings. The server pings every livePingPeriod and drops a client it has
+// not heard from within livePongWait.
+const (
+	liveWriteWait      = 10 * time.Second
+	livePongWait       = 60 * time.Second
+	livePingPeriod     = livePongWait * 9 / 10
+	liveMaxMessageSize = 16 * 1024
+)
+
+type LiveHandler struct {
+	liveService service.LiveService
+	upgrader    websocket.Upgrader
+	logger      *log.Logger
+}
+
+// NewLiveHandler returns a handler for live rooms. Sockets are only accepted from the
+// allowed origins, or from any origin when they include "*".
+func NewLiveHandler(liveService service.LiveService, allowedOrigins []string, logger *log.Logger) *LiveHandler {
+	return &LiveHandler{
+		liveService: liveService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				if origin == "" || slices.Contains(allowedOrigins, "*") {
+					return true
+				}
+				if slices.Contains(allowedOrigins, origin) {
+					return true
+				}
+				// Same origin requests are always fine
+				u, err := url.Parse(origin)
+				return err == nil && u.Host == r.Host
+			},
+		},
+		logger: logger,
+	}
+}
+
+// =========================================================
+// 		Live Room Handler
+// =========================================================
+
+// CreateRoom opens a live room hosted by the user
+// @Summary Create a live room
+// @Tags Live
+// @Accept JSON
+// @Produce JSON
+// @Param room body domain.LiveRoomRequest true "Live room"
+// @Success 201 {object} domain.LiveRoom
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /live/rooms [post]
+func (lh *LiveHandler) CreateRoom(c echo.Context) error {
+	var request domain.LiveRoomRequest
+	if err := c.Bind(&request); err != nil {
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+	userId := c.Get("user_id").(int64)
+	room, err := lh.liveService.CreateRoom(c.Request().Context(), userId, request)
+	if err != nil {
+		lh.logger.Println("error creating live room: ", err)
+		return pkg.ErrorResponse(c, err, liveErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, room, http.StatusCreated)
+}
+
+// GetRoom returns a live room and its players
+// @Summary Get a live room
+// @Tags Live
+// @Produce JSON
+// @Param pin path string true "Room pin"
+// @Success 200 {object} domain.LiveRoom
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /live/rooms/{pin} [get]
+func (lh *LiveHandler) GetRoom(c echo.Context) error {
+	room, err := lh.liveService.GetRoom(c.Request().Context(), c.Param("pin"))
+	if err != nil {
+		if liveErrorStatus(err) == http.StatusInternalServerError {
+			lh.logger.Println("error getting live room: ", err)
+		}
+		return pkg.ErrorResponse(c, err, liveErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, room, http.StatusOK)
+}
+
+// Connect joins the user to a live room over a WebSocket. The host joins to run the room
+// and everyone else joins as a player. Commands are read from the socket and the room's
+// events are written to it, as JSON messages.
+// @Summary Connect to a live room
+// @Tags Live
+// @Param pin path string true "Room pin"
+// @Param token query string false "Access token, for clients that cannot set the Authorization header"
+// @Success 101
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /live/rooms/{pin}/ws [get]
+func (lh *LiveHandler) Connect(c echo.Context) error {
+	userId := c.Get("user_id").(int64)
+	// Join before upgrading, so a client that cannot join gets a proper status
+	client, err := lh.liveService.Join(c.Request().Context(), c.Param("pin"), userId)
+	if err != nil {
+		if liveErrorStatus(err) == http.StatusInternalServerError {
+			lh.logger.Println("error joining live room: ", err)
+		}
+		return pkg.ErrorResponse(c, err, liveErrorStatus(err))
+	}
+	defer lh.liveService.Leave(client)
+	conn, err := lh.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// The upgrader has already written the error response
+		lh.logger.Println("error upgrading live room connection: ", err)
+		return nil
+	}
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	write := func(messageType int, payload any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+		if messageType == websocket.PingMessage {
+			return conn.WriteMessage(websocket.PingMessage, nil)
+		}
+		return conn.WriteJSON(payload)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(livePingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case event, ok := <-client.Events():
+				if !ok {
+					// The client fell behind or left; closing the socket ends the read loop
+					conn.Close()
+					return
+				}
+				if err := write(websocket.TextMessage, event); err != nil {
+					conn.Close()
+					return
+				}
+			case <-ticker.C:
+				if err := write(websocket.PingMessage, nil); err != nil {
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	conn.SetReadLimit(liveMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(livePongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return nil
+		}
+		var command domain.LiveCommand
+		if err := json.Unmarshal(message, &command); err != nil {
+			if err := write(websocket.TextMessage, domain.LiveEvent{Type: domain.LiveEventError, Error: pkg.ErrInvalidLiveCommand.Error()}); err != nil {
+				return nil
+			}
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(livePongWait))
+		if err := lh.liveService.Handle(c.Request().Context(), client, command); err != nil {
+			message := err.Error()
+			if liveErrorStatus(err) == http.StatusInternalServerError {
+				lh.logger.Println("error handling live room command: ", err)
+				message = pkg.ErrInternalServerError.Error()
+			}
+			if err := write(websocket.TextMessage, domain.LiveEvent{Type: domain.LiveEventError, Error: message}); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func liveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrLiveRoomNotFound), errors.Is(err, pkg.ErrSubjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrNotLiveRoomHost), errors.Is(err, pkg.ErrNotLiveRoomPlayer):
+		return http.StatusForbidden
+	case errors.Is(err, pkg.ErrLiveRoomStarted), errors.Is(err, pkg.ErrLiveRoomFull),
+		errors.Is(err, pkg.ErrLiveRoomNoPlayers), errors.Is(err, pkg.ErrLiveCommandNotAllowed),
+		errors.Is(err, pkg.ErrLiveQuestionClosed), errors.Is(err, pkg.ErrLiveQuestionAnswered):
+		return http.StatusConflict
+	case errors.Is(err, pkg.ErrInvalidLiveCommand), errors.Is(err, pkg.ErrNotEnoughQuestions),
+		errors.Is(err, pkg.ErrTopicNotFound), errors.Is(err, pkg.ErrOptionNotInSession),
+		errors.Is(err, pkg.ErrDuplicateQuizAnswer), errors.Is(err, pkg.ErrAnswerDoesNotMatchType):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
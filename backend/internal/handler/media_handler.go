@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lawson/otterprep/internal/storage"
+	"github.com/lawson/otterprep/pkg"
+)
+
+type MediaHandler struct {
+	local  *storage.Local
+	logger *log.Logger
+}
+
+// NewMediaHandler returns a handler serving media kept in local storage. With any other
+// storage media is fetched from the storage itself and the handler serves nothing.
+func NewMediaHandler(store storage.Storage, logger *log.Logger) *MediaHandler {
+	local, _ := store.(*storage.Local)
+	return &MediaHandler{
+		local:  local,
+		logger: logger,
+	}
+}
+
+// ServeMedia serves a media file at a signed URL handed out with a question, quiz or result.
+// @Summary Get media
+// @Description Download an image or audio file from local media storage. The URL is only valid until it expires.
+// @Tags Media
+// @Produce octet-stream
+// @Param key path string true "Media key"
+// @Param expires query int true "Unix time the URL expires at"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} binary
+// @Failure 403 {object} pkg.ErrorResponse
+// @Failure 404 {object} pkg.ErrorResponse
+// @Router /media/{key} [get]
+func (mh *MediaHandler) ServeMedia(c echo.Context) error {
+	if mh.local == nil {
+		return pkg.ErrorResponse(c, pkg.ErrMediaNotFound, http.StatusNotFound)
+	}
+	key := c.Param("*")
+	expires, err := strconv.ParseInt(c.QueryParam("expires"), 10, 64)
+	if err != nil {
+		return pkg.ErrorResponse(c, pkg.ErrMediaLinkExpired, http.StatusForbidden)
+	}
+	file, err := mh.local.Open(key, expires, c.QueryParam("signature"), time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, pkg.ErrMediaLinkExpired):
+			return pkg.ErrorResponse(c, err, http.StatusForbidden)
+		case errors.Is(err, pkg.ErrMediaNotFound):
+			return pkg.ErrorResponse(c, err, http.StatusNotFound)
+		default:
+			mh.logger.Println("error opening media: ", err)
+			return pkg.ErrorResponse(c, pkg.ErrInternalServerError, http.StatusInternalServerError)
+		}
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		mh.logger.Println("error reading media: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrInternalServerError, http.StatusInternalServerError)
+	}
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	c.Response().Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(expires-time.Now().Unix(), 0), 10))
+	http.ServeContent(c.Response(), c.Request(), path.Base(key), info.ModTime(), file)
+	return nil
+}
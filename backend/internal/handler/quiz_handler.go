@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lawson/otterprep/domain"
@@ -46,45 +48,291 @@ func (h *QuizHandler) CreateQuiz(c echo.Context) error {
 	if err := c.Validate(&quizRequest); err != nil {
 		return err
 	}
-	subjectId := quizRequest.SubjectId
-	// check if subject with id exists
-	_, err := h.subjectService.GetSubjectById(c.Request().Context(), subjectId)
-	if err != nil {
-		h.logger.Println("error getting subject: ", err)
-		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+	userId := c.Get("user_id").(int64)
+	if err := h.checkQuizSubjects(c, quizRequest); err != nil {
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
 	}
-	quiz, err := h.quizService.GenerateQuizBySubjectID(c.Request().Context(), quizRequest.SubjectId, quizRequest.NumOfQuestions)
+	quiz, err := h.quizService.GenerateQuizBySubjectID(c.Request().Context(), userId, quizRequest)
 	if err != nil {
 		h.logger.Println("error creating quiz: ", err)
-		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, quiz, http.StatusOK)
+}
+
+// CreateReviewQuiz creates a quiz that serves the user's due review items first
+// @Summary Create a review quiz
+// @Tags Quizzes
+// @Accept JSON
+// @Produce JSON
+// @Param quiz body domain.QuizRequest true "Quiz"
+// @Success 200 {object} domain.GeneratedQuizResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /quiz/review [post]
+func (h *QuizHandler) CreateReviewQuiz(c echo.Context) error {
+	var quizRequest domain.QuizRequest
+	if err := c.Bind(&quizRequest); err != nil {
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&quizRequest); err != nil {
+		return err
+	}
+	userId := c.Get("user_id").(int64)
+	if err := h.checkQuizSubjects(c, quizRequest); err != nil {
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	quiz, err := h.quizService.GenerateReviewQuiz(c.Request().Context(), userId, quizRequest)
+	if err != nil {
+		h.logger.Println("error creating review quiz: ", err)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, quiz, http.StatusOK)
+}
+
+// GetReviewQueue returns how many questions the user has queued and due for review
+// @Summary Get the review queue
+// @Tags Quizzes
+// @Produce JSON
+// @Success 200 {object} domain.ReviewQueueResponse
+// @Failure 500 {object} map[string]interface{}
+// @Router /quiz/review [get]
+func (h *QuizHandler) GetReviewQueue(c echo.Context) error {
+	userId := c.Get("user_id").(int64)
+	queue, err := h.quizService.GetReviewQueue(c.Request().Context(), userId)
+	if err != nil {
+		h.logger.Println("error getting review queue: ", err)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, queue, http.StatusOK)
+}
+
+// CreateChallenge creates a quiz that can be shared by its code
+// @Summary Create a quiz challenge
+// @Tags Quizzes
+// @Accept JSON
+// @Produce JSON
+// @Param quiz body domain.QuizRequest true "Quiz"
+// @Success 200 {object} domain.QuizChallengeResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /quiz/challenge [post]
+func (h *QuizHandler) CreateChallenge(c echo.Context) error {
+	var quizRequest domain.QuizRequest
+	if err := c.Bind(&quizRequest); err != nil {
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&quizRequest); err != nil {
+		return err
+	}
+	userId := c.Get("user_id").(int64)
+	if err := h.checkQuizSubjects(c, quizRequest); err != nil {
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	challenge, err := h.quizService.CreateChallenge(c.Request().Context(), userId, quizRequest)
+	if err != nil {
+		h.logger.Println("error creating challenge: ", err)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, challenge, http.StatusOK)
+}
+
+// TakeChallenge issues the user a quiz session for a challenge code
+// @Summary Take a quiz challenge
+// @Tags Quizzes
+// @Produce JSON
+// @Param code path string true "Challenge code"
+// @Success 200 {object} domain.GeneratedQuizResponse
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /quiz/challenge/{code} [post]
+func (h *QuizHandler) TakeChallenge(c echo.Context) error {
+	userId := c.Get("user_id").(int64)
+	quiz, err := h.quizService.TakeChallenge(c.Request().Context(), userId, c.Param("code"))
+	if err != nil {
+		h.logger.Println("error taking challenge: ", err)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, quiz, http.StatusOK)
+}
+
+// GetChallengeResults returns how everyone who took a challenge did
+// @Summary Get quiz challenge results
+// @Tags Quizzes
+// @Produce JSON
+// @Param code path string true "Challenge code"
+// @Success 200 {object} domain.ChallengeResultsResponse
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /quiz/challenge/{code}/results [get]
+func (h *QuizHandler) GetChallengeResults(c echo.Context) error {
+	results, err := h.quizService.GetChallengeResults(c.Request().Context(), c.Param("code"))
+	if err != nil {
+		h.logger.Println("error getting challenge results: ", err)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, results, http.StatusOK)
+}
+
+// CreateMockExam reconstructs a past paper as a timed exam
+// @Summary Create a mock exam
+// @Tags Quizzes
+// @Accept JSON
+// @Produce JSON
+// @Param quiz body domain.MockExamRequest true "Past paper"
+// @Success 200 {object} domain.GeneratedQuizResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /quiz/mock [post]
+func (h *QuizHandler) CreateMockExam(c echo.Context) error {
+	var request domain.MockExamRequest
+	if err := c.Bind(&request); err != nil {
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+	userId := c.Get("user_id").(int64)
+	quiz, err := h.quizService.GenerateMockExam(c.Request().Context(), userId, request)
+	if err != nil {
+		h.logger.Println("error creating mock exam: ", err)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
 	}
 	return pkg.SuccessResponse(c, quiz, http.StatusOK)
 }
 
-// SubmitQuiz submits a quiz
+// checkQuizSubjects checks that every subject a quiz request draws from exists.
+func (h *QuizHandler) checkQuizSubjects(c echo.Context, quizRequest domain.QuizRequest) error {
+	subjectIds := []int64{quizRequest.SubjectId}
+	if len(quizRequest.Subjects) > 0 {
+		subjectIds = subjectIds[:0]
+		for _, subject := range quizRequest.Subjects {
+			subjectIds = append(subjectIds, subject.SubjectId)
+		}
+	}
+	for _, subjectId := range subjectIds {
+		_, err := h.subjectService.GetSubjectById(c.Request().Context(), subjectId)
+		if err != nil {
+			h.logger.Println("error getting subject: ", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// SubmitQuiz submits the answers for a quiz session
 // @Summary Submit a quiz
 // @Tags Quizzes
 // @Accept JSON
 // @Produce JSON
-// @Param quiz body []domain.SubmitQuizRequest true "Quiz Submission"
+// @Param quiz body domain.QuizSubmission true "Quiz Submission"
 // @Success 200 {object} domain.QuizSubmitResponse
 // @Failure 400 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /quizzes/submit [post]
 func (h *QuizHandler) SubmitQuiz(c echo.Context) error {
-	var quizRequest []domain.SubmitQuizRequest
-	if err := c.Bind(&quizRequest); err != nil {
+	var submission domain.QuizSubmission
+	if err := c.Bind(&submission); err != nil {
 		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
 	}
-	if err := c.Validate(&quizRequest); err != nil {
+	if err := c.Validate(&submission); err != nil {
 		return err
 	}
 
 	userId := c.Get("user_id").(int64)
-	result, err := h.quizService.SubmitQuiz(c.Request().Context(), userId, quizRequest)
+	result, err := h.quizService.SubmitQuiz(c.Request().Context(), userId, submission)
 	if err != nil {
 		h.logger.Println("error submitting quiz: ", err)
-		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
 	}
 	return pkg.SuccessResponse(c, result, http.StatusOK)
 }
+
+// GetQuizHistory lists the quizzes the user has submitted
+// @Summary List past quizzes
+// @Tags Quizzes
+// @Produce JSON
+// @Param limit query int false "Number of entries to return" default(10)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} domain.QuizHistoryResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /quiz/history [get]
+func (h *QuizHandler) GetQuizHistory(c echo.Context) error {
+	var query domain.QuizHistoryQuery
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			h.logger.Println("error parsing limit: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Limit = limit
+	}
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil {
+			h.logger.Println("error parsing offset: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Offset = offset
+	}
+	if err := c.Validate(&query); err != nil {
+		return err
+	}
+
+	userId := c.Get("user_id").(int64)
+	history, err := h.quizService.GetQuizHistory(c.Request().Context(), userId, query)
+	if err != nil {
+		h.logger.Println("error getting quiz history: ", err)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, history, http.StatusOK)
+}
+
+// GetQuizReview re-opens a submitted quiz with its full breakdown
+// @Summary Review a past quiz
+// @Tags Quizzes
+// @Produce JSON
+// @Param session_id path int true "Quiz session ID"
+// @Success 200 {object} domain.QuizSubmitResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /quiz/history/{session_id} [get]
+func (h *QuizHandler) GetQuizReview(c echo.Context) error {
+	sessionId, err := strconv.ParseInt(c.Param("session_id"), 10, 64)
+	if err != nil {
+		h.logger.Println("error parsing session id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrQuizAttemptNotFound, http.StatusBadRequest)
+	}
+
+	userId := c.Get("user_id").(int64)
+	review, err := h.quizService.GetQuizReview(c.Request().Context(), userId, sessionId)
+	if err != nil {
+		h.logger.Println("error getting quiz review: ", err)
+		return pkg.ErrorResponse(c, err, quizErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, review, http.StatusOK)
+}
+
+// quizErrorStatus maps quiz errors to the matching HTTP status code.
+func quizErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrQuizSessionNotFound), errors.Is(err, pkg.ErrQuizAttemptNotFound),
+		errors.Is(err, pkg.ErrChallengeNotFound), errors.Is(err, pkg.ErrPastPaperNotFound),
+		errors.Is(err, pkg.ErrSubjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrQuizSessionAlreadySubmitted), errors.Is(err, pkg.ErrChallengeAlreadyTaken):
+		return http.StatusConflict
+	case errors.Is(err, pkg.ErrQuestionNotInSession), errors.Is(err, pkg.ErrOptionNotInSession),
+		errors.Is(err, pkg.ErrDuplicateQuizAnswer), errors.Is(err, pkg.ErrExamDurationRequired),
+		errors.Is(err, pkg.ErrInvalidQuizSubjects), errors.Is(err, pkg.ErrNotEnoughQuestions),
+		errors.Is(err, pkg.ErrChallengeModeNotSupported), errors.Is(err, pkg.ErrAnswerDoesNotMatchType),
+		errors.Is(err, pkg.ErrTopicNotFound):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
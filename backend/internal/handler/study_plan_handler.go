@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/service"
+	"github.com/lawson/otterprep/pkg"
+)
+
+type StudyPlanHandler struct {
+	studyPlanService service.StudyPlanService
+	logger           *log.Logger
+}
+
+func NewStudyPlanHandler(studyPlanService service.StudyPlanService, logger *log.Logger) *StudyPlanHandler {
+	return &StudyPlanHandler{
+		studyPlanService: studyPlanService,
+		logger:           logger,
+	}
+}
+
+// =========================================================
+// 		Study Plan Handler
+// =========================================================
+
+// CreateStudyPlan sets the user's study goal
+// @Summary Create a study plan
+// @Description Set an exam date, the subjects it covers and a target accuracy, replacing any plan you had
+// @Tags Study Plan
+// @Accept JSON
+// @Produce JSON
+// @Param plan body domain.StudyPlanData true "Study plan"
+// @Success 201 {object} domain.StudyPlan
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /study-plan [post]
+func (sh *StudyPlanHandler) CreateStudyPlan(c echo.Context) error {
+	var data domain.StudyPlanData
+	if err := c.Bind(&data); err != nil {
+		sh.logger.Println("error binding study plan: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+	}
+	if err := c.Validate(&data); err != nil {
+		return err
+	}
+	userId := c.Get("user_id").(int64)
+	plan, err := sh.studyPlanService.CreateStudyPlan(c.Request().Context(), userId, data)
+	if err != nil {
+		sh.logger.Println("error creating study plan: ", err)
+		return pkg.ErrorResponse(c, err, studyPlanErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, plan, http.StatusCreated)
+}
+
+// GetStudyPlan returns the user's study plan
+// @Summary Get your study plan
+// @Description Get your study plan, how you are doing in its subjects, its schedule so far and how closely you have kept to it
+// @Tags Study Plan
+// @Produce JSON
+// @Success 200 {object} domain.StudyPlan
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /study-plan [get]
+func (sh *StudyPlanHandler) GetStudyPlan(c echo.Context) error {
+	userId := c.Get("user_id").(int64)
+	plan, err := sh.studyPlanService.GetStudyPlan(c.Request().Context(), userId)
+	if err != nil {
+		sh.logger.Println("error getting study plan: ", err)
+		return pkg.ErrorResponse(c, err, studyPlanErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, plan, http.StatusOK)
+}
+
+// GetStudyPlanToday returns today's tasks of the user's study plan
+// @Summary Get today's study tasks
+// @Description Get the quizzes your study plan recommends for today and how much of them you have done
+// @Tags Study Plan
+// @Produce JSON
+// @Success 200 {object} domain.StudyDay
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /study-plan/today [get]
+func (sh *StudyPlanHandler) GetStudyPlanToday(c echo.Context) error {
+	userId := c.Get("user_id").(int64)
+	day, err := sh.studyPlanService.GetStudyPlanToday(c.Request().Context(), userId)
+	if err != nil {
+		sh.logger.Println("error getting today's study tasks: ", err)
+		return pkg.ErrorResponse(c, err, studyPlanErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, day, http.StatusOK)
+}
+
+// DeleteStudyPlan removes the user's study plan
+// @Summary Delete your study plan
+// @Tags Study Plan
+// @Produce JSON
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /study-plan [delete]
+func (sh *StudyPlanHandler) DeleteStudyPlan(c echo.Context) error {
+	userId := c.Get("user_id").(int64)
+	if err := sh.studyPlanService.DeleteStudyPlan(c.Request().Context(), userId); err != nil {
+		sh.logger.Println("error deleting study plan: ", err)
+		return pkg.ErrorResponse(c, err, studyPlanErrorStatus(err))
+	}
+	return pkg.SuccessResponse(c, nil, http.StatusOK)
+}
+
+// studyPlanErrorStatus maps study plan errors to the matching HTTP status code.
+func studyPlanErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrStudyPlanNotFound), errors.Is(err, pkg.ErrSubjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrInvalidExamDate):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
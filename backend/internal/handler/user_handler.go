@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -398,6 +399,79 @@ func (h *UserHandler) UserDashboard(c echo.Context) error {
 	return pkg.SuccessResponse(c, userDashboard, http.StatusOK)
 }
 
+// GetPublicProfile returns what other users can see of a user
+// @Summary Get a user's public profile
+// @Description Get a user's name, stats, streak and badges
+// @Tags Users
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Success 200 {object} domain.PublicProfile
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /users/{user_id}/profile [get]
+func (h *UserHandler) GetPublicProfile(c echo.Context) error {
+	userId, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Println("error parsing user_id: ", err)
+		return pkg.ErrorResponse(c, pkg.ErrInvalidUserID, http.StatusBadRequest)
+	}
+	profile, err := h.userService.GetPublicProfile(c.Request().Context(), userId)
+	if err != nil {
+		h.logger.Println("error getting public profile: ", err)
+		switch {
+		case errors.Is(err, pkg.ErrUserNotFound):
+			return pkg.ErrorResponse(c, err, http.StatusNotFound)
+		case errors.Is(err, pkg.ErrInvalidUserID):
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+	}
+	return pkg.SuccessResponse(c, profile, http.StatusOK)
+}
+
+// GetXPHistory returns the user's XP, level and XP ledger
+// @Summary Get my XP
+// @Description Get your XP and level, and a page of the ledger of the XP you have earned, newest first
+// @Tags Users
+// @Produce json
+// @Param limit query int false "Number of entries to return" default(20)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} domain.XPHistoryResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /user/xp [get]
+func (h *UserHandler) GetXPHistory(c echo.Context) error {
+	var query domain.XPHistoryQuery
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			h.logger.Println("error parsing limit: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Limit = limit
+	}
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil {
+			h.logger.Println("error parsing offset: ", err)
+			return pkg.ErrorResponse(c, err, http.StatusBadRequest)
+		}
+		query.Offset = offset
+	}
+	if err := c.Validate(&query); err != nil {
+		return err
+	}
+
+	userId := c.Get("user_id").(int64)
+	history, err := h.userService.GetXPHistory(c.Request().Context(), userId, query)
+	if err != nil {
+		h.logger.Println("error getting xp history: ", err)
+		return pkg.ErrorResponse(c, err, http.StatusInternalServerError)
+	}
+	return pkg.SuccessResponse(c, history, http.StatusOK)
+}
+
 // ForgotPassword initiates a password reset by sending an email with a reset link
 // @Summary Request password reset
 // @Tags Auth
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 )
 
@@ -25,6 +26,10 @@ func JWTAuthMiddleware(jwtSecret string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
+			// Browsers cannot set headers on a WebSocket, so the token may come in the query instead
+			if authHeader == "" && c.QueryParam("token") != "" && websocket.IsWebSocketUpgrade(c.Request()) {
+				authHeader = "Bearer " + c.QueryParam("token")
+			}
 			if authHeader == "" {
 				return c.JSON(http.StatusUnauthorized, map[string]interface{}{
 					"success": false,
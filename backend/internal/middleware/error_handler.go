@@ -36,20 +36,43 @@ func CustomHTTPErrorHandler(err error, c echo.Context) {
 		switch err {
 		case pkg.ErrSubjectNotFound, pkg.ErrQuestionNotFound,
 			pkg.ErrQuestionOptionNotFound, pkg.ErrQuizNotFound, pkg.ErrUserNotFound,
-			pkg.ErrUserRankNotFound:
+			pkg.ErrUserRankNotFound, pkg.ErrQuizSessionNotFound, pkg.ErrQuizAttemptNotFound, pkg.ErrChallengeNotFound,
+			pkg.ErrTopicNotFound, pkg.ErrPastPaperNotFound, pkg.ErrMediaNotFound, pkg.ErrLiveRoomNotFound,
+			pkg.ErrContestNotFound, pkg.ErrStudyPlanNotFound:
 			code = http.StatusNotFound
 			message = err.Error()
 		case pkg.ErrInvalidName, pkg.ErrInvalidEmail, pkg.ErrInvalidUserID,
 			pkg.ErrQuestionTextNotFound, pkg.ErrQuestionOptionTextNotFound,
-			pkg.ErrSubjectNameNotFound, pkg.ErrInvalidPasswordLength:
+			pkg.ErrSubjectNameNotFound, pkg.ErrInvalidPasswordLength,
+			pkg.ErrQuestionNotInSession, pkg.ErrOptionNotInSession, pkg.ErrDuplicateQuizAnswer,
+			pkg.ErrExamDurationRequired, pkg.ErrQuestionAnswerNotFound, pkg.ErrAnswerNotInOptions,
+			pkg.ErrInvalidScoringPolicy, pkg.ErrInvalidQuizSubjects, pkg.ErrNotEnoughQuestions,
+			pkg.ErrChallengeModeNotSupported, pkg.ErrInvalidQuestionType, pkg.ErrNotEnoughOptions,
+			pkg.ErrOptionsNotAllowed, pkg.ErrInvalidTrueFalseAnswer, pkg.ErrAnswerDoesNotMatchType,
+			pkg.ErrInvalidMatchingPairs, pkg.ErrDuplicateOptions, pkg.ErrInvalidParentTopic, pkg.ErrMediaFileRequired,
+			pkg.ErrInvalidLiveCommand, pkg.ErrInvalidContestWindow, pkg.ErrContestQuestionsRequired, pkg.ErrInvalidContestQuestions,
+			pkg.ErrInvalidExamDate:
 			code = http.StatusBadRequest
 			message = err.Error()
 		case pkg.ErrInvalidPasswordHash, pkg.ErrUnauthorized, pkg.ErrInvalidRole:
 			code = http.StatusUnauthorized
 			message = err.Error()
-		case pkg.ErrSubjectWithNameExists, pkg.ErrUserAlreadyExists:
+		case pkg.ErrSubjectWithNameExists, pkg.ErrUserAlreadyExists, pkg.ErrQuizSessionAlreadySubmitted,
+			pkg.ErrChallengeAlreadyTaken, pkg.ErrTopicWithNameExists, pkg.ErrPastPaperExists, pkg.ErrPaperQuestionNumberTaken,
+			pkg.ErrLiveRoomStarted, pkg.ErrLiveRoomFull, pkg.ErrLiveRoomNoPlayers, pkg.ErrLiveCommandNotAllowed,
+			pkg.ErrLiveQuestionClosed, pkg.ErrLiveQuestionAnswered, pkg.ErrContestNotOpen, pkg.ErrContestAlreadyEntered,
+			pkg.ErrContestLeaderboardNotReady, pkg.ErrDailyChallengeTaken:
 			code = http.StatusConflict
 			message = err.Error()
+		case pkg.ErrMediaLinkExpired, pkg.ErrNotLiveRoomHost, pkg.ErrNotLiveRoomPlayer:
+			code = http.StatusForbidden
+			message = err.Error()
+		case pkg.ErrMediaTooLarge:
+			code = http.StatusRequestEntityTooLarge
+			message = err.Error()
+		case pkg.ErrUnsupportedMediaType:
+			code = http.StatusUnsupportedMediaType
+			message = err.Error()
 		case pkg.ErrInternalServerError:
 			code = http.StatusInternalServerError
 			message = err.Error()
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// UserAchievement is a badge a user has earned, by its code
+type UserAchievement struct {
+	Code     string
+	EarnedAt time.Time
+}
+
+type AchievementRepository interface {
+	GetUserAchievements(ctx context.Context, userId int64) ([]UserAchievement, error)
+	AwardAchievements(ctx context.Context, userId int64, codes []string, earnedAt time.Time) ([]string, error)
+}
+
+type achievementRepository struct {
+	db *sql.DB
+}
+
+func NewAchievementRepository(db *sql.DB) AchievementRepository {
+	return &achievementRepository{db: db}
+}
+
+// GetUserAchievements returns the badges the user has earned, oldest first.
+func (ar *achievementRepository) GetUserAchievements(ctx context.Context, userId int64) ([]UserAchievement, error) {
+	query := "SELECT code, earned_at FROM user_achievements WHERE user_id = $1 ORDER BY earned_at, code"
+	rows, err := ar.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	achievements := []UserAchievement{}
+	for rows.Next() {
+		var achievement UserAchievement
+		if err := rows.Scan(&achievement.Code, &achievement.EarnedAt); err != nil {
+			return nil, err
+		}
+		achievements = append(achievements, achievement)
+	}
+	return achievements, rows.Err()
+}
+
+// AwardAchievements gives the user the badges with the given codes and returns the codes
+// of those they did not already have. A badge is only ever earned once, so its first
+// earned_at is kept.
+func (ar *achievementRepository) AwardAchievements(ctx context.Context, userId int64, codes []string, earnedAt time.Time) ([]string, error) {
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO user_achievements (user_id, code, earned_at) VALUES ($1, $2, $3) ON CONFLICT (user_id, code) DO NOTHING"
+	awarded := []string{}
+	for _, code := range codes {
+		result, err := tx.ExecContext(ctx, query, userId, code, earnedAt)
+		if err != nil {
+			return nil, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if inserted > 0 {
+			awarded = append(awarded, code)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return awarded, nil
+}
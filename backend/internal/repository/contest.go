@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// Contest is a quiz on a fixed set of questions that can be entered once per user while
+// its window is open. The subject's scoring policy is snapshotted when it is created.
+// FrozenAt is set once its leaderboard has been frozen. Entered is only set when listing
+// contests for a user.
+type Contest struct {
+	Id              int64                `json:"id"`
+	Title           string               `json:"title"`
+	Description     string               `json:"description"`
+	SubjectId       int64                `json:"subject_id"`
+	DurationSeconds int64                `json:"duration_seconds"`
+	ScoringPolicy   domain.ScoringPolicy `json:"scoring_policy"`
+	QuestionIds     []int64              `json:"question_ids"`
+	StartsAt        time.Time            `json:"starts_at"`
+	EndsAt          time.Time            `json:"ends_at"`
+	FrozenAt        *time.Time           `json:"frozen_at,omitempty"`
+	CreatedBy       int64                `json:"created_by"`
+	CreatedAt       time.Time            `json:"created_at"`
+	Entered         bool                 `json:"entered"`
+}
+
+type ContestRepository interface {
+	CreateContest(ctx context.Context, contest Contest) (int64, error)
+	GetContestById(ctx context.Context, id int64) (*Contest, error)
+	GetContests(ctx context.Context, userId int64, status string, now time.Time, limit, offset int) ([]Contest, int64, error)
+	HasUserEnteredContest(ctx context.Context, userId int64, contestId int64) (bool, error)
+	FreezeContestStandings(ctx context.Context, contestId int64, closedAt time.Time, frozenAt time.Time) error
+	GetContestStandings(ctx context.Context, contestId int64) ([]domain.ContestStanding, error)
+}
+
+type contestRepository struct {
+	db *sql.DB
+}
+
+func NewContestRepository(db *sql.DB) ContestRepository {
+	return &contestRepository{db: db}
+}
+
+const contestColumns = "c.id, c.title, c.description, c.subject_id, c.duration_seconds, c.scoring_policy, c.question_ids, c.starts_at, c.ends_at, c.frozen_at, c.created_by, c.created_at"
+
+// scanContest reads a row of contestColumns, followed by any extra destinations.
+func scanContest(row interface{ Scan(dest ...any) error }, extra ...any) (*Contest, error) {
+	var contest Contest
+	var scoringPolicy, questionIds string
+	var frozenAt sql.NullTime
+	dest := append([]any{&contest.Id, &contest.Title, &contest.Description, &contest.SubjectId, &contest.DurationSeconds,
+		&scoringPolicy, &questionIds, &contest.StartsAt, &contest.EndsAt, &frozenAt, &contest.CreatedBy, &contest.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if frozenAt.Valid {
+		contest.FrozenAt = &frozenAt.Time
+	}
+	if err := json.Unmarshal([]byte(scoringPolicy), &contest.ScoringPolicy); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(questionIds), &contest.QuestionIds); err != nil {
+		return nil, err
+	}
+	return &contest, nil
+}
+
+// CreateContest stores a contest. Its scoring policy and question ids are kept as JSON.
+func (cr *contestRepository) CreateContest(ctx context.Context, contest Contest) (int64, error) {
+	scoringPolicy, err := json.Marshal(contest.ScoringPolicy)
+	if err != nil {
+		return 0, err
+	}
+	questionIds, err := json.Marshal(contest.QuestionIds)
+	if err != nil {
+		return 0, err
+	}
+	query := `INSERT INTO contests (title, description, subject_id, duration_seconds, scoring_policy, question_ids, starts_at, ends_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	var id int64
+	err = cr.db.QueryRowContext(ctx, query, contest.Title, contest.Description, contest.SubjectId, contest.DurationSeconds,
+		string(scoringPolicy), string(questionIds), contest.StartsAt, contest.EndsAt, contest.CreatedBy, contest.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetContestById returns a contest, or pkg.ErrContestNotFound.
+func (cr *contestRepository) GetContestById(ctx context.Context, id int64) (*Contest, error) {
+	query := "SELECT " + contestColumns + " FROM contests c WHERE c.id = $1"
+	contest, err := scanContest(cr.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, pkg.ErrContestNotFound
+		}
+		return nil, err
+	}
+	return contest, nil
+}
+
+// GetContests returns a page of the contests of a status at the given time, with whether
+// the user has entered each, along with the number of contests of the status.
+func (cr *contestRepository) GetContests(ctx context.Context, userId int64, status string, now time.Time, limit, offset int) ([]Contest, int64, error) {
+	// where takes the placeholder of now, which is not the first one in the page query
+	var where, order string
+	switch status {
+	case domain.ContestUpcoming:
+		where, order = "c.starts_at > %[1]s", "c.starts_at ASC"
+	case domain.ContestPast:
+		where, order = "c.ends_at <= %[1]s", "c.ends_at DESC"
+	default:
+		where, order = "c.starts_at <= %[1]s AND c.ends_at > %[1]s", "c.ends_at ASC"
+	}
+
+	var total int64
+	if err := cr.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM contests c WHERE "+fmt.Sprintf(where, "$1"), now).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + contestColumns + `,
+			EXISTS (SELECT 1 FROM quiz_sessions qs WHERE qs.contest_id = c.id AND qs.user_id = $1) as entered
+		FROM contests c
+		WHERE ` + fmt.Sprintf(where, "$2") + `
+		ORDER BY ` + order + `, c.id
+		LIMIT $3 OFFSET $4`
+	rows, err := cr.db.QueryContext(ctx, query, userId, now, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	contests := []Contest{}
+	for rows.Next() {
+		var entered bool
+		contest, err := scanContest(rows, &entered)
+		if err != nil {
+			return nil, 0, err
+		}
+		contest.Entered = entered
+		contests = append(contests, *contest)
+	}
+	return contests, total, rows.Err()
+}
+
+// HasUserEnteredContest reports whether a quiz session has already been issued to the user for the contest.
+func (cr *contestRepository) HasUserEnteredContest(ctx context.Context, userId int64, contestId int64) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM quiz_sessions WHERE user_id = $1 AND contest_id = $2)"
+	var entered bool
+	if err := cr.db.QueryRowContext(ctx, query, userId, contestId).Scan(&entered); err != nil {
+		return false, err
+	}
+	return entered, nil
+}
+
+// FreezeContestStandings ranks everyone who submitted an entry to the contest by closedAt,
+// by points and then by who was quickest, and stores the standings for good. It does
+// nothing for a contest that is already frozen, so it is safe to call more than once.
+func (cr *contestRepository) FreezeContestStandings(ctx context.Context, contestId int64, closedAt time.Time, frozenAt time.Time) error {
+	tx, err := cr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Claiming the contest first keeps two concurrent freezes from both writing standings
+	result, err := tx.ExecContext(ctx, "UPDATE contests SET frozen_at = $1 WHERE id = $2 AND frozen_at IS NULL", frozenAt, contestId)
+	if err != nil {
+		return err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT
+			qs.user_id,
+			u.name,
+			COALESCE(SUM(s.score), 0) as score,
+			COALESCE(SUM(s.points), 0) as points,
+			COALESCE(SUM(s.correct_answers), 0) as correct_answers,
+			COALESCE(SUM(s.total_questions), 0) as total_questions,
+			COALESCE(SUM(s.time_taken_seconds), 0) as time_taken_seconds,
+			qs.submitted_at
+		FROM quiz_sessions qs
+		INNER JOIN users u ON u.id = qs.user_id
+		INNER JOIN scores s ON s.session_id = qs.id
+		WHERE qs.contest_id = $1 AND qs.status = $2 AND qs.submitted_at <= $3
+		GROUP BY qs.id, qs.user_id, u.name, qs.submitted_at
+		ORDER BY points DESC, time_taken_seconds ASC, qs.submitted_at ASC
+	`
+	rows, err := tx.QueryContext(ctx, query, contestId, QuizSessionSubmitted, closedAt)
+	if err != nil {
+		return err
+	}
+	var standings []domain.ContestStanding
+	for rows.Next() {
+		var standing domain.ContestStanding
+		err := rows.Scan(
+			&standing.UserID,
+			&standing.UserName,
+			&standing.Score,
+			&standing.Points,
+			&standing.CorrectAnswers,
+			&standing.TotalQuestions,
+			&standing.TimeTakenSeconds,
+			&standing.SubmittedAt,
+		)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		standing.Rank = int64(len(standings) + 1)
+		standings = append(standings, standing)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, standing := range standings {
+		query = `INSERT INTO contest_standings (contest_id, user_id, rank, user_name, score, points, correct_answers, total_questions, time_taken_seconds, submitted_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		_, err := tx.ExecContext(ctx, query, contestId, standing.UserID, standing.Rank, standing.UserName, standing.Score, standing.Points,
+			standing.CorrectAnswers, standing.TotalQuestions, standing.TimeTakenSeconds, standing.SubmittedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetContestStandings returns the frozen standings of a contest, best first.
+func (cr *contestRepository) GetContestStandings(ctx context.Context, contestId int64) ([]domain.ContestStanding, error) {
+	query := `SELECT rank, user_id, user_name, score, points, correct_answers, total_questions, time_taken_seconds, submitted_at
+		FROM contest_standings WHERE contest_id = $1 ORDER BY rank`
+	rows, err := cr.db.QueryContext(ctx, query, contestId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := []domain.ContestStanding{}
+	for rows.Next() {
+		var standing domain.ContestStanding
+		err := rows.Scan(
+			&standing.Rank,
+			&standing.UserID,
+			&standing.UserName,
+			&standing.Score,
+			&standing.Points,
+			&standing.CorrectAnswers,
+			&standing.TotalQuestions,
+			&standing.TimeTakenSeconds,
+			&standing.SubmittedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		standings = append(standings, standing)
+	}
+	return standings, rows.Err()
+}
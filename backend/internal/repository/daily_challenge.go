@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+)
+
+type DailyChallengeRepository interface {
+	HasUserTakenDailyChallenge(ctx context.Context, userId int64, subjectId int64, day time.Time) (bool, error)
+	GetDailyBoard(ctx context.Context, day time.Time, subjectId *int64, limit, offset int) ([]domain.DailyBoardEntry, int64, error)
+	GetUserDailyStanding(ctx context.Context, userId int64, day time.Time, subjectId *int64) (*domain.DailyBoardEntry, error)
+}
+
+type dailyChallengeRepository struct {
+	db *sql.DB
+}
+
+func NewDailyChallengeRepository(db *sql.DB) DailyChallengeRepository {
+	return &dailyChallengeRepository{db: db}
+}
+
+// HasUserTakenDailyChallenge reports whether a subject's daily challenge of the day has
+// already been issued to the user.
+func (dr *dailyChallengeRepository) HasUserTakenDailyChallenge(ctx context.Context, userId int64, subjectId int64, day time.Time) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM quiz_sessions WHERE user_id = $1 AND subject_id = $2 AND daily_date = $3)"
+	var taken bool
+	if err := dr.db.QueryRowContext(ctx, query, userId, subjectId, day.Format(time.DateOnly)).Scan(&taken); err != nil {
+		return false, err
+	}
+	return taken, nil
+}
+
+// dailyBoardQuery ranks everyone who has submitted a daily challenge of the day, of the
+// subject when one is given, on their points summed over the challenges and then on the
+// time they took. It returns the query and its arguments so far.
+func dailyBoardQuery(day time.Time, subjectId *int64) (string, []any) {
+	args := []any{domain.ModeDaily, day.Format(time.DateOnly)}
+	where := "qs.mode = $1 AND qs.daily_date = $2"
+	if subjectId != nil {
+		args = append(args, *subjectId)
+		where += " AND qs.subject_id = $3"
+	}
+	query := `
+		WITH daily AS (
+			SELECT
+				u.id as user_id,
+				u.name as user_name,
+				COUNT(DISTINCT qs.id) as challenges,
+				COALESCE(SUM(s.points), 0) as points,
+				COALESCE(SUM(s.correct_answers), 0) as correct_answers,
+				COALESCE(SUM(s.total_questions), 0) as total_questions,
+				COALESCE(SUM(s.time_taken_seconds), 0) as time_taken_seconds
+			FROM quiz_sessions qs
+			INNER JOIN users u ON u.id = qs.user_id
+			INNER JOIN scores s ON s.session_id = qs.id
+			WHERE ` + where + `
+			GROUP BY u.id, u.name
+		), ranked AS (
+			SELECT *, RANK() OVER (ORDER BY points DESC, time_taken_seconds ASC) as rank FROM daily
+		)
+		SELECT rank, user_id, user_name, challenges, points, correct_answers, total_questions, time_taken_seconds
+		FROM ranked`
+	return query, args
+}
+
+func scanDailyBoardEntry(row interface{ Scan(dest ...any) error }) (*domain.DailyBoardEntry, error) {
+	var entry domain.DailyBoardEntry
+	err := row.Scan(
+		&entry.Rank,
+		&entry.UserID,
+		&entry.UserName,
+		&entry.Challenges,
+		&entry.Points,
+		&entry.CorrectAnswers,
+		&entry.TotalQuestions,
+		&entry.TimeTakenSeconds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetDailyBoard returns a page of the day's daily challenge board, along with the
+// number of participants.
+func (dr *dailyChallengeRepository) GetDailyBoard(ctx context.Context, day time.Time, subjectId *int64, limit, offset int) ([]domain.DailyBoardEntry, int64, error) {
+	query, args := dailyBoardQuery(day, subjectId)
+
+	var participants int64
+	if err := dr.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+query+") board", args...).Scan(&participants); err != nil {
+		return nil, 0, err
+	}
+
+	query += fmt.Sprintf(" ORDER BY rank, user_id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	rows, err := dr.db.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []domain.DailyBoardEntry{}
+	for rows.Next() {
+		entry, err := scanDailyBoardEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, participants, rows.Err()
+}
+
+// GetUserDailyStanding returns the user's place on the day's daily challenge board, or
+// nil if they have not submitted a daily challenge that day.
+func (dr *dailyChallengeRepository) GetUserDailyStanding(ctx context.Context, userId int64, day time.Time, subjectId *int64) (*domain.DailyBoardEntry, error) {
+	query, args := dailyBoardQuery(day, subjectId)
+	query += fmt.Sprintf(" WHERE user_id = $%d", len(args)+1)
+	entry, err := scanDailyBoardEntry(dr.db.QueryRowContext(ctx, query, append(args, userId)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/lawson/otterprep/domain"
@@ -16,6 +17,7 @@ type LeaderboardRepository interface {
 	GetMonthlyLeaderboard(ctx context.Context, limit, offset int) ([]domain.LeaderboardEntry, int64, error)
 	GetUserRank(ctx context.Context, userId int64) (*domain.UserRankResponse, error)
 	GetUserSubjectRank(ctx context.Context, userId, subjectId int64) (*domain.UserRankResponse, error)
+	GetXPLeaderboard(ctx context.Context, subjectId *int64, since *time.Time, limit, offset int) ([]domain.LeaderboardEntry, int64, error)
 }
 
 type leaderboardRepository struct {
@@ -40,13 +42,14 @@ func (lr *leaderboardRepository) GetGlobalLeaderboard(ctx context.Context, limit
 			u.id as user_id,
 			u.name as user_name,
 			COALESCE(SUM(s.score), 0) as total_score,
-			COUNT(s.id) as total_quizzes,
+			COUNT(DISTINCT COALESCE(s.session_id, -s.id)) as total_quizzes,
 			COALESCE(SUM(s.correct_answers), 0) as correct_answers,
-			COALESCE(SUM(s.total_questions), 0) as total_questions
+			COALESCE(SUM(s.total_questions), 0) as total_questions,
+			COALESCE(SUM(s.time_taken_seconds), 0) as total_time_seconds
 		FROM users u
 		INNER JOIN scores s ON u.id = s.user_id
 		GROUP BY u.id, u.name
-		ORDER BY total_score DESC, correct_answers DESC
+		ORDER BY total_score DESC, correct_answers DESC, total_time_seconds ASC
 		LIMIT $1 OFFSET $2
 	`
 
@@ -68,6 +71,7 @@ func (lr *leaderboardRepository) GetGlobalLeaderboard(ctx context.Context, limit
 			&entry.TotalQuizzes,
 			&entry.CorrectAnswers,
 			&entry.TotalQuestions,
+			&entry.TotalTimeSeconds,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -100,14 +104,15 @@ func (lr *leaderboardRepository) GetSubjectLeaderboard(ctx context.Context, subj
 			u.id as user_id,
 			u.name as user_name,
 			COALESCE(SUM(s.score), 0) as total_score,
-			COUNT(s.id) as total_quizzes,
+			COUNT(DISTINCT COALESCE(s.session_id, -s.id)) as total_quizzes,
 			COALESCE(SUM(s.correct_answers), 0) as correct_answers,
-			COALESCE(SUM(s.total_questions), 0) as total_questions
+			COALESCE(SUM(s.total_questions), 0) as total_questions,
+			COALESCE(SUM(s.time_taken_seconds), 0) as total_time_seconds
 		FROM users u
 		INNER JOIN scores s ON u.id = s.user_id
 		WHERE s.subject_id = $1
 		GROUP BY u.id, u.name
-		ORDER BY total_score DESC, correct_answers DESC
+		ORDER BY total_score DESC, correct_answers DESC, total_time_seconds ASC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -129,6 +134,7 @@ func (lr *leaderboardRepository) GetSubjectLeaderboard(ctx context.Context, subj
 			&entry.TotalQuizzes,
 			&entry.CorrectAnswers,
 			&entry.TotalQuestions,
+			&entry.TotalTimeSeconds,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -163,14 +169,15 @@ func (lr *leaderboardRepository) GetWeeklyLeaderboard(ctx context.Context, limit
 			u.id as user_id,
 			u.name as user_name,
 			COALESCE(SUM(s.score), 0) as total_score,
-			COUNT(s.id) as total_quizzes,
+			COUNT(DISTINCT COALESCE(s.session_id, -s.id)) as total_quizzes,
 			COALESCE(SUM(s.correct_answers), 0) as correct_answers,
-			COALESCE(SUM(s.total_questions), 0) as total_questions
+			COALESCE(SUM(s.total_questions), 0) as total_questions,
+			COALESCE(SUM(s.time_taken_seconds), 0) as total_time_seconds
 		FROM users u
 		INNER JOIN scores s ON u.id = s.user_id
 		WHERE s.created_at >= $1
 		GROUP BY u.id, u.name
-		ORDER BY total_score DESC, correct_answers DESC
+		ORDER BY total_score DESC, correct_answers DESC, total_time_seconds ASC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -192,6 +199,7 @@ func (lr *leaderboardRepository) GetWeeklyLeaderboard(ctx context.Context, limit
 			&entry.TotalQuizzes,
 			&entry.CorrectAnswers,
 			&entry.TotalQuestions,
+			&entry.TotalTimeSeconds,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -226,14 +234,15 @@ func (lr *leaderboardRepository) GetMonthlyLeaderboard(ctx context.Context, limi
 			u.id as user_id,
 			u.name as user_name,
 			COALESCE(SUM(s.score), 0) as total_score,
-			COUNT(s.id) as total_quizzes,
+			COUNT(DISTINCT COALESCE(s.session_id, -s.id)) as total_quizzes,
 			COALESCE(SUM(s.correct_answers), 0) as correct_answers,
-			COALESCE(SUM(s.total_questions), 0) as total_questions
+			COALESCE(SUM(s.total_questions), 0) as total_questions,
+			COALESCE(SUM(s.time_taken_seconds), 0) as total_time_seconds
 		FROM users u
 		INNER JOIN scores s ON u.id = s.user_id
 		WHERE s.created_at >= $1
 		GROUP BY u.id, u.name
-		ORDER BY total_score DESC, correct_answers DESC
+		ORDER BY total_score DESC, correct_answers DESC, total_time_seconds ASC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -255,6 +264,7 @@ func (lr *leaderboardRepository) GetMonthlyLeaderboard(ctx context.Context, limi
 			&entry.TotalQuizzes,
 			&entry.CorrectAnswers,
 			&entry.TotalQuestions,
+			&entry.TotalTimeSeconds,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -282,15 +292,16 @@ func (lr *leaderboardRepository) GetUserRank(ctx context.Context, userId int64)
 				u.id as user_id,
 				u.name as user_name,
 				COALESCE(SUM(s.score), 0) as total_score,
-				COUNT(s.id) as total_quizzes,
+				COUNT(DISTINCT COALESCE(s.session_id, -s.id)) as total_quizzes,
 				COALESCE(SUM(s.correct_answers), 0) as correct_answers,
 				COALESCE(SUM(s.total_questions), 0) as total_questions,
-				RANK() OVER (ORDER BY COALESCE(SUM(s.score), 0) DESC, COALESCE(SUM(s.correct_answers), 0) DESC) as rank
+				COALESCE(SUM(s.time_taken_seconds), 0) as total_time_seconds,
+				RANK() OVER (ORDER BY COALESCE(SUM(s.score), 0) DESC, COALESCE(SUM(s.correct_answers), 0) DESC, COALESCE(SUM(s.time_taken_seconds), 0) ASC) as rank
 			FROM users u
 			INNER JOIN scores s ON u.id = s.user_id
 			GROUP BY u.id, u.name
 		)
-		SELECT user_id, user_name, total_score, total_quizzes, correct_answers, total_questions, rank
+		SELECT user_id, user_name, total_score, total_quizzes, correct_answers, total_questions, total_time_seconds, rank
 		FROM ranked_users
 		WHERE user_id = $1
 	`
@@ -303,6 +314,7 @@ func (lr *leaderboardRepository) GetUserRank(ctx context.Context, userId int64)
 		&userRank.TotalQuizzes,
 		&userRank.CorrectAnswers,
 		&userRank.TotalQuestions,
+		&userRank.TotalTimeSeconds,
 		&userRank.Rank,
 	)
 	if err != nil {
@@ -333,16 +345,17 @@ func (lr *leaderboardRepository) GetUserSubjectRank(ctx context.Context, userId,
 				u.id as user_id,
 				u.name as user_name,
 				COALESCE(SUM(s.score), 0) as total_score,
-				COUNT(s.id) as total_quizzes,
+				COUNT(DISTINCT COALESCE(s.session_id, -s.id)) as total_quizzes,
 				COALESCE(SUM(s.correct_answers), 0) as correct_answers,
 				COALESCE(SUM(s.total_questions), 0) as total_questions,
-				RANK() OVER (ORDER BY COALESCE(SUM(s.score), 0) DESC, COALESCE(SUM(s.correct_answers), 0) DESC) as rank
+				COALESCE(SUM(s.time_taken_seconds), 0) as total_time_seconds,
+				RANK() OVER (ORDER BY COALESCE(SUM(s.score), 0) DESC, COALESCE(SUM(s.correct_answers), 0) DESC, COALESCE(SUM(s.time_taken_seconds), 0) ASC) as rank
 			FROM users u
 			INNER JOIN scores s ON u.id = s.user_id
 			WHERE s.subject_id = $1
 			GROUP BY u.id, u.name
 		)
-		SELECT user_id, user_name, total_score, total_quizzes, correct_answers, total_questions, rank
+		SELECT user_id, user_name, total_score, total_quizzes, correct_answers, total_questions, total_time_seconds, rank
 		FROM ranked_users
 		WHERE user_id = $2
 	`
@@ -355,6 +368,7 @@ func (lr *leaderboardRepository) GetUserSubjectRank(ctx context.Context, userId,
 		&userRank.TotalQuizzes,
 		&userRank.CorrectAnswers,
 		&userRank.TotalQuestions,
+		&userRank.TotalTimeSeconds,
 		&userRank.Rank,
 	)
 	if err != nil {
@@ -376,3 +390,64 @@ func (lr *leaderboardRepository) GetUserSubjectRank(ctx context.Context, userId,
 
 	return &userRank, nil
 }
+
+// GetXPLeaderboard ranks users on the XP they have earned, since a time and in a subject
+// when they are given. Only the XP and the number of quizzes it was earned over are
+// filled in on its entries.
+func (lr *leaderboardRepository) GetXPLeaderboard(ctx context.Context, subjectId *int64, since *time.Time, limit, offset int) ([]domain.LeaderboardEntry, int64, error) {
+	where := "1 = 1"
+	var args []any
+	if since != nil {
+		args = append(args, *since)
+		where += fmt.Sprintf(" AND x.created_at >= $%d", len(args))
+	}
+	if subjectId != nil {
+		args = append(args, *subjectId)
+		where += fmt.Sprintf(" AND x.subject_id = $%d", len(args))
+	}
+
+	var totalUsers int64
+	countQuery := "SELECT COUNT(DISTINCT x.user_id) FROM xp_ledger x WHERE " + where
+	if err := lr.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalUsers); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			u.id as user_id,
+			u.name as user_name,
+			COALESCE(SUM(x.amount), 0) as xp,
+			COUNT(DISTINCT x.session_id) as total_quizzes
+		FROM users u
+		INNER JOIN xp_ledger x ON u.id = x.user_id
+		WHERE %s
+		GROUP BY u.id, u.name
+		ORDER BY xp DESC, u.id ASC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+
+	rows, err := lr.db.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []domain.LeaderboardEntry
+	rank := int64(offset + 1)
+
+	for rows.Next() {
+		var entry domain.LeaderboardEntry
+		if err := rows.Scan(&entry.UserID, &entry.UserName, &entry.XP, &entry.TotalQuizzes); err != nil {
+			return nil, 0, err
+		}
+		entry.Rank = rank
+		entries = append(entries, entry)
+		rank++
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, totalUsers, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Media is an image or audio file attached to a question, one of its options or its
+// explanation. StorageKey is where the file is kept in media storage. OptionId is 0
+// unless the file is attached to an option.
+type Media struct {
+	Id          int64     `json:"id"`
+	QuestionId  int64     `json:"question_id"`
+	OptionId    int64     `json:"option_id,omitempty"`
+	AttachTo    string    `json:"attach_to"`
+	Kind        string    `json:"kind"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	StorageKey  string    `json:"storage_key"`
+	AltText     string    `json:"alt_text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type MediaRepository interface {
+	CreateMedia(ctx context.Context, media Media) (int64, error)
+	GetMediaById(ctx context.Context, id int64) (*Media, error)
+	GetQuestionMedia(ctx context.Context, questionIds []int64) (map[int64][]Media, error)
+	DeleteMediaById(ctx context.Context, id int64) error
+}
+
+type mediaRepository struct {
+	db *sql.DB
+}
+
+func NewMediaRepository(db *sql.DB) MediaRepository {
+	return &mediaRepository{db: db}
+}
+
+const mediaColumns = "id, question_id, option_id, attach_to, kind, content_type, size_bytes, storage_key, alt_text, created_at"
+
+func scanMedia(row rowScanner) (Media, error) {
+	var media Media
+	var optionId sql.NullInt64
+	err := row.Scan(&media.Id, &media.QuestionId, &optionId, &media.AttachTo, &media.Kind, &media.ContentType, &media.SizeBytes, &media.StorageKey, &media.AltText, &media.CreatedAt)
+	media.OptionId = optionId.Int64
+	return media, err
+}
+
+// CreateMedia stores a media file attached to a question and returns its id.
+func (mr *mediaRepository) CreateMedia(ctx context.Context, media Media) (int64, error) {
+	optionId := sql.NullInt64{Int64: media.OptionId, Valid: media.OptionId != 0}
+	query := "INSERT INTO question_media (question_id, option_id, attach_to, kind, content_type, size_bytes, storage_key, alt_text, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id"
+	var id int64
+	err := mr.db.QueryRowContext(ctx, query, media.QuestionId, optionId, media.AttachTo, media.Kind, media.ContentType, media.SizeBytes, media.StorageKey, media.AltText, media.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetMediaById returns a media file by id.
+func (mr *mediaRepository) GetMediaById(ctx context.Context, id int64) (*Media, error) {
+	media, err := scanMedia(mr.db.QueryRowContext(ctx, "SELECT "+mediaColumns+" FROM question_media WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// GetQuestionMedia returns the media of every given question in the order it was
+// uploaded, keyed by question id. Questions without media are left out.
+func (mr *mediaRepository) GetQuestionMedia(ctx context.Context, questionIds []int64) (map[int64][]Media, error) {
+	media := make(map[int64][]Media, len(questionIds))
+	if len(questionIds) == 0 {
+		return media, nil
+	}
+	args := make([]any, len(questionIds))
+	for i, id := range questionIds {
+		args[i] = id
+	}
+	query := "SELECT " + mediaColumns + " FROM question_media WHERE question_id IN (" + inPlaceholders(1, len(questionIds)) + ") ORDER BY id"
+	rows, err := mr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		media[item.QuestionId] = append(media[item.QuestionId], item)
+	}
+	return media, rows.Err()
+}
+
+// DeleteMediaById deletes a media file's record. The file itself is left in storage.
+func (mr *mediaRepository) DeleteMediaById(ctx context.Context, id int64) error {
+	_, err := mr.db.ExecContext(ctx, "DELETE FROM question_media WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedia(t *testing.T) {
+	pool := setUP(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	repo := NewMediaRepository(pool)
+	questionRepo := NewQuestionRepository(pool)
+
+	now := time.Now()
+	diagramId, err := repo.CreateMedia(ctx, Media{QuestionId: 1, AttachTo: "question", Kind: "image", ContentType: "image/png", SizeBytes: 2048, StorageKey: "questions/1/diagram.png", AltText: "A right-angled triangle", CreatedAt: now})
+	assert.Nil(t, err)
+	clipId, err := repo.CreateMedia(ctx, Media{QuestionId: 1, OptionId: 3, AttachTo: "option", Kind: "audio", ContentType: "audio/mpeg", SizeBytes: 4096, StorageKey: "questions/1/clip.mp3", CreatedAt: now})
+	assert.Nil(t, err)
+	_, err = repo.CreateMedia(ctx, Media{QuestionId: 2, AttachTo: "explanation", Kind: "image", ContentType: "image/jpeg", SizeBytes: 1024, StorageKey: "questions/2/working.jpg", CreatedAt: now})
+	assert.Nil(t, err)
+	// every file has its own key
+	_, err = repo.CreateMedia(ctx, Media{QuestionId: 2, AttachTo: "question", Kind: "image", ContentType: "image/png", SizeBytes: 1024, StorageKey: "questions/1/diagram.png", CreatedAt: now})
+	assert.Error(t, err)
+
+	clip, err := repo.GetMediaById(ctx, clipId)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), clip.OptionId)
+	assert.Equal(t, "questions/1/clip.mp3", clip.StorageKey)
+	_, err = repo.GetMediaById(ctx, 99)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	media, err := repo.GetQuestionMedia(ctx, []int64{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Len(t, media, 2)
+	assert.Equal(t, []int64{diagramId, clipId}, []int64{media[1][0].Id, media[1][1].Id})
+	assert.Equal(t, int64(0), media[1][0].OptionId)
+	assert.Equal(t, "A right-angled triangle", media[1][0].AltText)
+	assert.Equal(t, "explanation", media[2][0].AttachTo)
+
+	assert.Nil(t, repo.DeleteMediaById(ctx, diagramId))
+	media, err = repo.GetQuestionMedia(ctx, []int64{1})
+	assert.Nil(t, err)
+	assert.Len(t, media[1], 1)
+
+	// deleting a question deletes its media records
+	assert.Nil(t, questionRepo.DeleteQuestionById(ctx, 1))
+	media, err = repo.GetQuestionMedia(ctx, []int64{1, 2})
+	assert.Nil(t, err)
+	assert.Len(t, media, 1)
+}
@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lawson/otterprep/domain"
 	"github.com/lawson/otterprep/pkg"
 )
 
@@ -15,25 +18,84 @@ import (
 type QuestionRepository interface {
 	GetQuestionById(ctx context.Context, id int64) (*Questions, error)
 	GetCorrectQuestionOptionByQuestionID(ctx context.Context, questionId int64) (*QuestionOptions, error)
+	GetCorrectQuestionOptionsByQuestionID(ctx context.Context, questionId int64) ([]QuestionOptions, error)
 	GetRandomQuestion(ctx context.Context, subjectId int64) (*Questions, error)
+	GetRandomQuestions(ctx context.Context, subjectId int64, limit int64, difficulty int, excludeIds []int64, filter QuestionFilter) ([]Questions, error)
+	GetQuestionsByIds(ctx context.Context, ids []int64) ([]Questions, error)
+	GetSubjectQuestionIds(ctx context.Context, subjectId int64, filter QuestionFilter) ([]int64, error)
+	SetQuestionClassification(ctx context.Context, questionId int64, topicId int64, tags []string) error
+	GetQuestionTags(ctx context.Context, questionIds []int64) (map[int64][]string, error)
+	GetSubjectTags(ctx context.Context, subjectId int64) ([]domain.SubjectTag, error)
+	SetQuestionSource(ctx context.Context, questionId int64, paperId int64, questionNumber int) error
+	GetPaperQuestions(ctx context.Context, paperId int64) ([]Questions, error)
+	RecordQuestionAttempts(ctx context.Context, attempts []QuestionAttempt) error
 	CreateQuestion(ctx context.Context, question Questions) (int64, error)
 	CreateQuestionOption(ctx context.Context, option QuestionOptions) (int64, error)
 	GetQuestionOptions(ctx context.Context, questionId int64) ([]QuestionOptions, error)
+	GetQuestionOptionsByQuestionIds(ctx context.Context, questionIds []int64) (map[int64][]QuestionOptions, error)
 	GetQuestionOptionsById(ctx context.Context, id int64) (*QuestionOptions, error)
 	CreateAnswer(ctx context.Context, answer Answers) (int64, error)
 	GetAnswerById(ctx context.Context, id int64) (*Answers, error)
+	GetAnswersByQuestionIds(ctx context.Context, questionIds []int64) (map[int64]Answers, error)
 	UpdateAnswerById(ctx context.Context, answer Answers) (*Answers, error)
 	GetAllQuestions(ctx context.Context) ([]Questions, error)
 	DeleteQuestionById(ctx context.Context, id int64) error
 }
 
+// Questions is a question of any type. NumericAnswer, Tolerance and Unit are only set for
+// numeric questions and AcceptedAnswers only for short text questions; choice and
+// true/false questions keep their answers on their options. TopicId is 0 for a question
+// that is not filed under a topic, and PaperId is 0 for one that was not set in a past paper.
 type Questions struct {
-	Id               int64     `json:"id"`
-	SubjectId        int64     `json:"subject_id"`
-	Question         string    `json:"question"`
-	IsMultipleChoice bool      `json:"is_multiple_choice"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	Id                  int64     `json:"id"`
+	SubjectId           int64     `json:"subject_id"`
+	TopicId             int64     `json:"topic_id,omitempty"`
+	PaperId             int64     `json:"paper_id,omitempty"`
+	PaperQuestionNumber int       `json:"paper_question_number,omitempty"`
+	Type                string    `json:"type"`
+	Question            string    `json:"question"`
+	IsMultipleChoice    bool      `json:"is_multiple_choice"`
+	PartialCredit       bool      `json:"partial_credit"`
+	Weight              float64   `json:"weight"`
+	Difficulty          int       `json:"difficulty"`
+	NumericAnswer       float64   `json:"numeric_answer,omitempty"`
+	Tolerance           float64   `json:"tolerance,omitempty"`
+	Unit                string    `json:"unit,omitempty"`
+	AcceptedAnswers     []string  `json:"accepted_answers,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// QuestionFilter narrows down the questions drawn for a quiz to those filed under any of
+// TopicIds and carrying any of Tags. A filter without either matches every question.
+type QuestionFilter struct {
+	TopicIds []int64
+	Tags     []string
+}
+
+// conditions returns the filter as conditions on a question id column, each starting with
+// AND, and args with the arguments of the conditions appended.
+func (f QuestionFilter) conditions(idColumn string, args []any) (string, []any) {
+	var query string
+	if len(f.TopicIds) > 0 {
+		query += " AND " + idColumn + " IN (SELECT id FROM questions WHERE topic_id IN (" + inPlaceholders(len(args)+1, len(f.TopicIds)) + "))"
+		for _, topicId := range f.TopicIds {
+			args = append(args, topicId)
+		}
+	}
+	if len(f.Tags) > 0 {
+		query += " AND " + idColumn + " IN (SELECT question_id FROM question_tags WHERE tag IN (" + inPlaceholders(len(args)+1, len(f.Tags)) + "))"
+		for _, tag := range f.Tags {
+			args = append(args, tag)
+		}
+	}
+	return query, args
+}
+
+// QuestionAttempt is one graded answer to a question, used to calibrate its difficulty
+type QuestionAttempt struct {
+	QuestionId int64
+	IsCorrect  bool
 }
 
 type Answers struct {
@@ -44,13 +106,18 @@ type Answers struct {
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// QuestionOptions is an option of a question. Position is the correct place of an option
+// of an ordering question, counting from 0. The options of a matching question that are
+// paired have MatchOptionId set to their match, and the matches have none.
 type QuestionOptions struct {
-	Id         int64     `json:"id"`
-	QuestionId int64     `json:"question_id"`
-	Option     string    `json:"option"`
-	IsCorrect  bool      `json:"is_correct"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	Id            int64     `json:"id"`
+	QuestionId    int64     `json:"question_id"`
+	Option        string    `json:"option"`
+	IsCorrect     bool      `json:"is_correct"`
+	Position      int       `json:"position"`
+	MatchOptionId int64     `json:"match_option_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 type questionRepository struct {
@@ -61,10 +128,30 @@ func NewQuestionRepository(db *sql.DB) QuestionRepository {
 	return &questionRepository{db: db}
 }
 
+// CreateQuestion stores a question. The accepted answers of a short text question are kept as JSON.
 func (qr *questionRepository) CreateQuestion(ctx context.Context, question Questions) (int64, error) {
-	query := "INSERT INTO questions (subject_id, question, is_multiple_choice, created_at, updated_at) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	if question.Weight <= 0 {
+		question.Weight = 1
+	}
+	if question.Difficulty == 0 {
+		question.Difficulty = domain.DifficultyMedium
+	}
+	if question.Type == "" {
+		question.Type = domain.QuestionTypeChoice
+	}
+	acceptedAnswers := question.AcceptedAnswers
+	if acceptedAnswers == nil {
+		acceptedAnswers = []string{}
+	}
+	accepted, err := json.Marshal(acceptedAnswers)
+	if err != nil {
+		return 0, err
+	}
+	query := `INSERT INTO questions (subject_id, question_type, question, is_multiple_choice, partial_credit, weight, difficulty, numeric_answer, tolerance, unit, accepted_answers, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`
 	var id int64
-	err := qr.db.QueryRowContext(ctx, query, question.SubjectId, question.Question, question.IsMultipleChoice, question.CreatedAt, question.UpdatedAt).Scan(&id)
+	err = qr.db.QueryRowContext(ctx, query, question.SubjectId, question.Type, question.Question, question.IsMultipleChoice, question.PartialCredit, question.Weight, question.Difficulty,
+		question.NumericAnswer, question.Tolerance, question.Unit, string(accepted), question.CreatedAt, question.UpdatedAt).Scan(&id)
 	if err != nil {
 		fmt.Println(err)
 		return 0, pkg.ErrQuestionAlreadyExist
@@ -72,11 +159,33 @@ func (qr *questionRepository) CreateQuestion(ctx context.Context, question Quest
 	return id, nil
 }
 
-func (qr *questionRepository) GetQuestionById(ctx context.Context, id int64) (*Questions, error) {
-	query := "SELECT id, subject_id, question, is_multiple_choice FROM questions WHERE id = $1"
-	row := qr.db.QueryRowContext(ctx, query, id)
+const questionColumns = "id, subject_id, topic_id, paper_id, paper_question_number, question_type, question, is_multiple_choice, partial_credit, weight, difficulty, numeric_answer, tolerance, unit, accepted_answers"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanQuestion(row rowScanner) (Questions, error) {
 	var question Questions
-	err := row.Scan(&question.Id, &question.SubjectId, &question.Question, &question.IsMultipleChoice)
+	var accepted string
+	var topicId, paperId, paperQuestionNumber sql.NullInt64
+	err := row.Scan(&question.Id, &question.SubjectId, &topicId, &paperId, &paperQuestionNumber, &question.Type, &question.Question, &question.IsMultipleChoice, &question.PartialCredit, &question.Weight, &question.Difficulty,
+		&question.NumericAnswer, &question.Tolerance, &question.Unit, &accepted)
+	if err != nil {
+		return question, err
+	}
+	question.TopicId = topicId.Int64
+	question.PaperId = paperId.Int64
+	question.PaperQuestionNumber = int(paperQuestionNumber.Int64)
+	if err := json.Unmarshal([]byte(accepted), &question.AcceptedAnswers); err != nil {
+		return question, err
+	}
+	return question, nil
+}
+
+func (qr *questionRepository) GetQuestionById(ctx context.Context, id int64) (*Questions, error) {
+	query := "SELECT " + questionColumns + " FROM questions WHERE id = $1"
+	question, err := scanQuestion(qr.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		return nil, err
 	}
@@ -84,20 +193,240 @@ func (qr *questionRepository) GetQuestionById(ctx context.Context, id int64) (*Q
 }
 
 func (qr *questionRepository) GetRandomQuestion(ctx context.Context, subjectId int64) (*Questions, error) {
-	query := "SELECT id, subject_id, question, is_multiple_choice FROM questions WHERE subject_id = $1 ORDER BY random() LIMIT 1"
-	row := qr.db.QueryRowContext(ctx, query, subjectId)
-	var question Questions
-	err := row.Scan(&question.Id, &question.SubjectId, &question.Question, &question.IsMultipleChoice)
+	query := "SELECT " + questionColumns + " FROM questions WHERE subject_id = $1 ORDER BY random() LIMIT 1"
+	question, err := scanQuestion(qr.db.QueryRowContext(ctx, query, subjectId))
 	if err != nil {
 		return nil, err
 	}
 	return &question, nil
 }
 
+// GetRandomQuestions samples up to limit distinct questions from a subject in a single query,
+// at the given difficulty or at any difficulty when it is 0, leaving out excludeIds and
+// questions the filter does not match.
+// Fewer questions are returned when the subject does not have enough of them.
+func (qr *questionRepository) GetRandomQuestions(ctx context.Context, subjectId int64, limit int64, difficulty int, excludeIds []int64, filter QuestionFilter) ([]Questions, error) {
+	query := "SELECT " + questionColumns + " FROM questions WHERE subject_id = $1"
+	args := []any{subjectId}
+	if difficulty != 0 {
+		args = append(args, difficulty)
+		query += fmt.Sprintf(" AND difficulty = $%d", len(args))
+	}
+	conditions, args := filter.conditions("id", args)
+	query += conditions
+	if len(excludeIds) > 0 {
+		query += " AND id NOT IN (" + inPlaceholders(len(args)+1, len(excludeIds)) + ")"
+		for _, id := range excludeIds {
+			args = append(args, id)
+		}
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY random() LIMIT $%d", len(args))
+	return qr.queryQuestions(ctx, query, args...)
+}
+
+// GetQuestionsByIds returns the questions with the given ids, in no particular order.
+// Ids of questions that do not exist are ignored.
+func (qr *questionRepository) GetQuestionsByIds(ctx context.Context, ids []int64) ([]Questions, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := "SELECT " + questionColumns + " FROM questions WHERE id IN (" + inPlaceholders(1, len(ids)) + ")"
+	return qr.queryQuestions(ctx, query, args...)
+}
+
+// GetSubjectQuestionIds returns the ids of every question in a subject that the filter
+// matches, in ascending order.
+func (qr *questionRepository) GetSubjectQuestionIds(ctx context.Context, subjectId int64, filter QuestionFilter) ([]int64, error) {
+	conditions, args := filter.conditions("id", []any{subjectId})
+	query := "SELECT id FROM questions WHERE subject_id = $1" + conditions + " ORDER BY id"
+	rows, err := qr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (qr *questionRepository) queryQuestions(ctx context.Context, query string, args ...any) ([]Questions, error) {
+	rows, err := qr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var questions []Questions
+	for rows.Next() {
+		question, err := scanQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+// SetQuestionClassification files a question under a topic, or under none when topicId is
+// 0, and replaces its tags, in a single transaction.
+// It fails with pkg.ErrQuestionNotFound when there is no such question.
+func (qr *questionRepository) SetQuestionClassification(ctx context.Context, questionId int64, topicId int64, tags []string) error {
+	tx, err := qr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	topic := sql.NullInt64{Int64: topicId, Valid: topicId != 0}
+	result, err := tx.ExecContext(ctx, "UPDATE questions SET topic_id = $1, updated_at = $2 WHERE id = $3", topic, time.Now(), questionId)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return pkg.ErrQuestionNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM question_tags WHERE question_id = $1", questionId); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO question_tags (question_id, tag) VALUES ($1, $2)", questionId, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetQuestionTags returns the tags of the given questions in alphabetical order, keyed by
+// question id. Questions without tags are left out.
+func (qr *questionRepository) GetQuestionTags(ctx context.Context, questionIds []int64) (map[int64][]string, error) {
+	tags := make(map[int64][]string, len(questionIds))
+	if len(questionIds) == 0 {
+		return tags, nil
+	}
+	args := make([]any, len(questionIds))
+	for i, id := range questionIds {
+		args[i] = id
+	}
+	query := "SELECT question_id, tag FROM question_tags WHERE question_id IN (" + inPlaceholders(1, len(questionIds)) + ") ORDER BY question_id, tag"
+	rows, err := qr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var questionId int64
+		var tag string
+		if err := rows.Scan(&questionId, &tag); err != nil {
+			return nil, err
+		}
+		tags[questionId] = append(tags[questionId], tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetSubjectTags returns every tag used on the questions of a subject and how many of
+// them carry it, in alphabetical order.
+func (qr *questionRepository) GetSubjectTags(ctx context.Context, subjectId int64) ([]domain.SubjectTag, error) {
+	query := `SELECT t.tag, COUNT(*) FROM question_tags t
+		JOIN questions q ON q.id = t.question_id
+		WHERE q.subject_id = $1
+		GROUP BY t.tag
+		ORDER BY t.tag`
+	rows, err := qr.db.QueryContext(ctx, query, subjectId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []domain.SubjectTag{}
+	for rows.Next() {
+		var tag domain.SubjectTag
+		if err := rows.Scan(&tag.Tag, &tag.Questions); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// SetQuestionSource records the past paper a question was set in and its number on the
+// paper, or clears it when paperId is 0.
+// It fails with pkg.ErrQuestionNotFound when there is no such question.
+func (qr *questionRepository) SetQuestionSource(ctx context.Context, questionId int64, paperId int64, questionNumber int) error {
+	paper := sql.NullInt64{Int64: paperId, Valid: paperId != 0}
+	number := sql.NullInt64{Int64: int64(questionNumber), Valid: paperId != 0}
+	result, err := qr.db.ExecContext(ctx, "UPDATE questions SET paper_id = $1, paper_question_number = $2, updated_at = $3 WHERE id = $4", paper, number, time.Now(), questionId)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return pkg.ErrQuestionNotFound
+	}
+	return nil
+}
+
+// GetPaperQuestions returns the questions of a past paper in the order they were set.
+func (qr *questionRepository) GetPaperQuestions(ctx context.Context, paperId int64) ([]Questions, error) {
+	query := "SELECT " + questionColumns + " FROM questions WHERE paper_id = $1 ORDER BY paper_question_number, id"
+	return qr.queryQuestions(ctx, query, paperId)
+}
+
+// RecordQuestionAttempts counts graded answers against their questions and recalibrates the
+// difficulty of every question that has been answered often enough.
+// The old counter values are used on the right hand side, so the new totals are computed inline.
+func (qr *questionRepository) RecordQuestionAttempts(ctx context.Context, attempts []QuestionAttempt) error {
+	tx, err := qr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE questions SET
+			attempts = attempts + 1,
+			correct_attempts = correct_attempts + $1,
+			difficulty = CASE
+				WHEN attempts + 1 < $2 THEN difficulty
+				WHEN (correct_attempts + $1) * 1.0 / (attempts + 1) >= $3 THEN $4
+				WHEN (correct_attempts + $1) * 1.0 / (attempts + 1) < $5 THEN $6
+				ELSE $7
+			END
+		WHERE id = $8`
+	for _, attempt := range attempts {
+		correct := 0
+		if attempt.IsCorrect {
+			correct = 1
+		}
+		_, err := tx.ExecContext(ctx, query, correct, domain.DifficultyCalibrationMinAttempts,
+			domain.DifficultyEasyAccuracy, domain.DifficultyEasy,
+			domain.DifficultyHardAccuracy, domain.DifficultyHard,
+			domain.DifficultyMedium, attempt.QuestionId)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (qr *questionRepository) CreateQuestionOption(ctx context.Context, option QuestionOptions) (int64, error) {
-	query := "INSERT INTO options (question_id, option, is_correct, created_at, updated_at) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	matchOptionId := sql.NullInt64{Int64: option.MatchOptionId, Valid: option.MatchOptionId != 0}
+	query := "INSERT INTO options (question_id, option, is_correct, position, match_option_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
 	var id int64
-	err := qr.db.QueryRowContext(ctx, query, option.QuestionId, option.Option, option.IsCorrect, option.CreatedAt, option.UpdatedAt).Scan(&id)
+	err := qr.db.QueryRowContext(ctx, query, option.QuestionId, option.Option, option.IsCorrect, option.Position, matchOptionId, option.CreatedAt, option.UpdatedAt).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
@@ -105,7 +434,7 @@ func (qr *questionRepository) CreateQuestionOption(ctx context.Context, option Q
 }
 
 func (qr *questionRepository) GetQuestionOptions(ctx context.Context, questionId int64) ([]QuestionOptions, error) {
-	query := "SELECT id, question_id, option, is_correct FROM options WHERE question_id = $1"
+	query := "SELECT " + optionColumns + " FROM options WHERE question_id = $1"
 	rows, err := qr.db.QueryContext(ctx, query, questionId)
 	if err != nil {
 		return nil, err
@@ -113,8 +442,7 @@ func (qr *questionRepository) GetQuestionOptions(ctx context.Context, questionId
 	defer rows.Close()
 	var options []QuestionOptions
 	for rows.Next() {
-		var option QuestionOptions
-		err := rows.Scan(&option.Id, &option.QuestionId, &option.Option, &option.IsCorrect)
+		option, err := scanOption(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -123,6 +451,51 @@ func (qr *questionRepository) GetQuestionOptions(ctx context.Context, questionId
 	return options, nil
 }
 
+const optionColumns = "id, question_id, option, is_correct, position, match_option_id"
+
+func scanOption(row rowScanner) (QuestionOptions, error) {
+	var option QuestionOptions
+	var matchOptionId sql.NullInt64
+	err := row.Scan(&option.Id, &option.QuestionId, &option.Option, &option.IsCorrect, &option.Position, &matchOptionId)
+	option.MatchOptionId = matchOptionId.Int64
+	return option, err
+}
+
+// GetQuestionOptionsByQuestionIds returns the options of every given question in one query, keyed by question id.
+func (qr *questionRepository) GetQuestionOptionsByQuestionIds(ctx context.Context, questionIds []int64) (map[int64][]QuestionOptions, error) {
+	options := make(map[int64][]QuestionOptions, len(questionIds))
+	if len(questionIds) == 0 {
+		return options, nil
+	}
+	args := make([]any, len(questionIds))
+	for i, id := range questionIds {
+		args[i] = id
+	}
+	query := "SELECT " + optionColumns + " FROM options WHERE question_id IN (" + inPlaceholders(1, len(questionIds)) + ") ORDER BY id"
+	rows, err := qr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		option, err := scanOption(rows)
+		if err != nil {
+			return nil, err
+		}
+		options[option.QuestionId] = append(options[option.QuestionId], option)
+	}
+	return options, rows.Err()
+}
+
+// inPlaceholders returns n comma separated query placeholders numbered from start, for an IN list.
+func inPlaceholders(start, n int) string {
+	placeholders := make([]string, n)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", start+i)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
 // GetCorrectQuestionOptionByQuestionID returns the correct option for a question without returning the entire options with the question id
 func (qr *questionRepository) GetCorrectQuestionOptionByQuestionID(ctx context.Context, questionId int64) (*QuestionOptions, error) {
 	query := "SELECT id, question_id, option, is_correct FROM options WHERE question_id = $1 AND is_correct = true"
@@ -135,6 +508,29 @@ func (qr *questionRepository) GetCorrectQuestionOptionByQuestionID(ctx context.C
 	return &option, nil
 }
 
+// GetCorrectQuestionOptionsByQuestionID returns every correct option for a question.
+// Multi-answer ("select all that apply") questions have more than one.
+func (qr *questionRepository) GetCorrectQuestionOptionsByQuestionID(ctx context.Context, questionId int64) ([]QuestionOptions, error) {
+	query := "SELECT id, question_id, option, is_correct FROM options WHERE question_id = $1 AND is_correct = true ORDER BY id"
+	rows, err := qr.db.QueryContext(ctx, query, questionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var options []QuestionOptions
+	for rows.Next() {
+		var option QuestionOptions
+		if err := rows.Scan(&option.Id, &option.QuestionId, &option.Option, &option.IsCorrect); err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
 func (qr *questionRepository) CreateAnswer(ctx context.Context, answer Answers) (int64, error) {
 	query := "INSERT INTO answers (question_id, answer, created_at, updated_at) VALUES ($1, $2, $3, $4) RETURNING id"
 	var id int64
@@ -166,6 +562,33 @@ func (qr *questionRepository) GetAnswerById(ctx context.Context, id int64) (*Ans
 	return &answer, nil
 }
 
+// GetAnswersByQuestionIds returns the answers of every given question in one query, keyed by question id.
+// Questions without an answer are left out.
+func (qr *questionRepository) GetAnswersByQuestionIds(ctx context.Context, questionIds []int64) (map[int64]Answers, error) {
+	answers := make(map[int64]Answers, len(questionIds))
+	if len(questionIds) == 0 {
+		return answers, nil
+	}
+	args := make([]any, len(questionIds))
+	for i, id := range questionIds {
+		args[i] = id
+	}
+	query := "SELECT id, question_id, answer FROM answers WHERE question_id IN (" + inPlaceholders(1, len(questionIds)) + ")"
+	rows, err := qr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var answer Answers
+		if err := rows.Scan(&answer.Id, &answer.QuestionId, &answer.Answer); err != nil {
+			return nil, err
+		}
+		answers[answer.QuestionId] = answer
+	}
+	return answers, rows.Err()
+}
+
 func (qr *questionRepository) UpdateAnswerById(ctx context.Context, answer Answers) (*Answers, error) {
 	fmt.Printf("DEBUG: Updating Answers ID: %d\n", answer.Id)
 
@@ -213,7 +636,7 @@ func (qr *questionRepository) GetQuestionOptionsById(ctx context.Context, id int
 
 // GetAllQuestions returns all the questions created on the database.
 func (qr *questionRepository) GetAllQuestions(ctx context.Context) ([]Questions, error) {
-	query := "SELECT id, subject_id, question FROM questions"
+	query := "SELECT id, subject_id, question_type, question, difficulty FROM questions"
 	rows, err := qr.db.QueryContext(ctx, query)
 	if err != nil {
 		fmt.Println("errors :", err)
@@ -223,7 +646,7 @@ func (qr *questionRepository) GetAllQuestions(ctx context.Context) ([]Questions,
 	var questions []Questions
 	for rows.Next() {
 		var question Questions
-		err := rows.Scan(&question.Id, &question.SubjectId, &question.Question)
+		err := rows.Scan(&question.Id, &question.SubjectId, &question.Type, &question.Question, &question.Difficulty)
 		if err != nil {
 			fmt.Println("error storing values: ", err)
 			return nil, err
@@ -237,7 +660,8 @@ func (qr *questionRepository) GetAllQuestions(ctx context.Context) ([]Questions,
 	return questions, nil
 }
 
-// DeleteQuestionById deletes a question and its associated options and answers.
+// DeleteQuestionById deletes a question and its associated options, answers and media
+// records. The media files themselves are left in storage.
 func (qr *questionRepository) DeleteQuestionById(ctx context.Context, id int64) error {
 	query := "DELETE FROM questions WHERE id = $1"
 	_, err := qr.db.ExecContext(ctx, query, id)
@@ -252,5 +676,9 @@ func (qr *questionRepository) DeleteQuestionById(ctx context.Context, id int64)
 	if err != nil {
 		return err
 	}
+	_, err = qr.db.ExecContext(ctx, "DELETE FROM question_media WHERE question_id = $1", id)
+	if err != nil {
+		return err
+	}
 	return nil
 }
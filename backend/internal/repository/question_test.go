@@ -3,9 +3,13 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/pkg"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,10 +21,19 @@ func setUP(t *testing.T) *sql.DB {
 		t.Fatal(err)
 	}
 	queries := []string{
-		"CREATE TABLE question_options (id integer primary key autoincrement, question_id integer, option text, is_correct boolean, created_at timestamp, updated_at timestamp)",
-		"CREATE TABLE questions (id integer primary key autoincrement, subject_id integer, question text, is_multiple_choice boolean, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE options (id integer primary key autoincrement, question_id integer, option text, is_correct boolean, position integer default 0, match_option_id integer, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE questions (id integer primary key autoincrement, subject_id integer, question text, is_multiple_choice boolean, partial_credit boolean default false, weight real default 1, difficulty integer default 2, attempts integer default 0, correct_attempts integer default 0, question_type text default 'choice', numeric_answer real default 0, tolerance real default 0, unit text default '', accepted_answers text default '[]', topic_id integer, paper_id integer, paper_question_number integer, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE topics (id integer primary key autoincrement, subject_id integer, parent_id integer, name text, created_at timestamp, updated_at timestamp, unique (subject_id, name))",
+		"CREATE TABLE question_tags (question_id integer, tag text, primary key (question_id, tag))",
+		"CREATE TABLE past_papers (id integer primary key autoincrement, subject_id integer, exam_body text, year integer, paper text, duration_seconds integer, created_at timestamp, updated_at timestamp, unique (subject_id, exam_body, year, paper))",
+		"CREATE TABLE question_media (id integer primary key autoincrement, question_id integer, option_id integer, attach_to text, kind text, content_type text, size_bytes integer, storage_key text unique, alt_text text default '', created_at timestamp)",
 		"CREATE TABLE answers (id integer primary key autoincrement, question_id integer, answer text, created_at timestamp, updated_at timestamp)",
 		"CREATE TABLE subjects (id integer primary key autoincrement, name text, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE subject_scoring_policies (id integer primary key autoincrement, subject_id integer unique, name text, wrong_penalty real, unanswered_penalty real, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE quiz_sessions (id integer primary key autoincrement, user_id integer, subject_id integer, status text, mode text, duration_seconds integer, challenge_id integer, paper_id integer, contest_id integer, daily_date date, expires_at timestamp, submitted_at timestamp, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE quiz_session_questions (id integer primary key autoincrement, session_id integer, question_id integer, subject_id integer, position integer)",
+		"CREATE TABLE quiz_session_subjects (id integer primary key autoincrement, session_id integer, subject_id integer, num_of_questions integer, scoring_policy text)",
+		"CREATE TABLE quiz_session_options (id integer primary key autoincrement, session_id integer, question_id integer, option_id integer, position integer)",
 	}
 	for _, query := range queries {
 		if _, err := db.Exec(query); err != nil {
@@ -82,6 +95,38 @@ func TestCreateQuestion(t *testing.T) {
 	assert.Equal(t, id, int64(1))
 }
 
+func TestCreateTypedQuestion(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	choiceId, err := repo.CreateQuestion(ctx, Questions{SubjectId: 1, Question: "pick one"})
+	assert.Nil(t, err)
+	numericId, err := repo.CreateQuestion(ctx, Questions{SubjectId: 1, Type: domain.QuestionTypeNumeric, Question: "how far", NumericAnswer: 42.5, Tolerance: 0.5, Unit: "km"})
+	assert.Nil(t, err)
+	textId, err := repo.CreateQuestion(ctx, Questions{SubjectId: 1, Type: domain.QuestionTypeShortText, Question: "name it", AcceptedAnswers: []string{"Nile", "River Nile"}})
+	assert.Nil(t, err)
+
+	choice, err := repo.GetQuestionById(ctx, choiceId)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.QuestionTypeChoice, choice.Type)
+	assert.Empty(t, choice.AcceptedAnswers)
+
+	questions, err := repo.GetQuestionsByIds(ctx, []int64{numericId, textId})
+	assert.Nil(t, err)
+	byId := map[int64]Questions{}
+	for _, question := range questions {
+		byId[question.Id] = question
+	}
+	assert.Equal(t, domain.QuestionTypeNumeric, byId[numericId].Type)
+	assert.Equal(t, 42.5, byId[numericId].NumericAnswer)
+	assert.Equal(t, 0.5, byId[numericId].Tolerance)
+	assert.Equal(t, "km", byId[numericId].Unit)
+	assert.Equal(t, domain.QuestionTypeShortText, byId[textId].Type)
+	assert.Equal(t, []string{"Nile", "River Nile"}, byId[textId].AcceptedAnswers)
+}
+
 func TestCreateQuestionOption(t *testing.T) {
 	pool := setUP(t)
 	repo := NewQuestionRepository(pool)
@@ -116,6 +161,28 @@ func TestGetQuestionOptions(t *testing.T) {
 	assert.NotNil(t, createdQuestion, "should have created question")
 }
 
+func TestGetCorrectQuestionOptionsByQuestionID(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i, isCorrect := range []bool{true, false, true, false} {
+		_, err := repo.CreateQuestionOption(ctx, QuestionOptions{
+			QuestionId: 1,
+			Option:     fmt.Sprintf("option %d", i),
+			IsCorrect:  isCorrect,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		})
+		assert.Nil(t, err)
+	}
+	options, err := repo.GetCorrectQuestionOptionsByQuestionID(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, options, 2)
+	assert.Equal(t, "option 0", options[0].Option)
+	assert.Equal(t, "option 2", options[1].Option)
+}
+
 func TestCreateAnswer(t *testing.T) {
 	pool := setUP(t)
 	repo := NewQuestionRepository(pool)
@@ -178,3 +245,214 @@ func TestUpdateAnswerByID(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, updatedAnswer, "should have updated answer")
 }
+
+func TestGetRandomQuestions(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	difficulties := []int{domain.DifficultyEasy, domain.DifficultyHard, domain.DifficultyHard, domain.DifficultyMedium, domain.DifficultyHard}
+	for i, difficulty := range difficulties {
+		_, err := repo.CreateQuestion(ctx, Questions{
+			SubjectId:  1,
+			Question:   fmt.Sprintf("question %d", i),
+			Difficulty: difficulty,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		})
+		assert.Nil(t, err)
+	}
+	_, err := repo.CreateQuestion(ctx, Questions{SubjectId: 2, Question: "other subject", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name       string
+		limit      int64
+		difficulty int
+		excludeIds []int64
+		wantIds    []int64
+	}{
+		{name: "whole subject", limit: 10, wantIds: []int64{1, 2, 3, 4, 5}},
+		{name: "by difficulty", limit: 10, difficulty: domain.DifficultyHard, wantIds: []int64{2, 3, 5}},
+		{name: "excluding ids", limit: 10, difficulty: domain.DifficultyHard, excludeIds: []int64{2, 5}, wantIds: []int64{3}},
+		{name: "difficulty with none left", limit: 10, difficulty: domain.DifficultyMedium, excludeIds: []int64{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions, err := repo.GetRandomQuestions(ctx, 1, tt.limit, tt.difficulty, tt.excludeIds, QuestionFilter{})
+			assert.Nil(t, err)
+			ids := make([]int64, len(questions))
+			for i, question := range questions {
+				ids[i] = question.Id
+			}
+			assert.ElementsMatch(t, tt.wantIds, ids)
+		})
+	}
+
+	// a sample never repeats a question
+	questions, err := repo.GetRandomQuestions(ctx, 1, 3, 0, nil, QuestionFilter{})
+	assert.Nil(t, err)
+	assert.Len(t, questions, 3)
+	assert.NotEqual(t, questions[0].Id, questions[1].Id)
+	assert.NotEqual(t, questions[1].Id, questions[2].Id)
+	assert.NotEqual(t, questions[0].Id, questions[2].Id)
+
+	byIds, err := repo.GetQuestionsByIds(ctx, []int64{2, 6, 99})
+	assert.Nil(t, err)
+	assert.Len(t, byIds, 2)
+}
+
+func TestGetQuestionOptionsByQuestionIds(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i, questionId := range []int64{1, 2, 1, 3, 2, 1} {
+		_, err := repo.CreateQuestionOption(ctx, QuestionOptions{
+			QuestionId: questionId,
+			Option:     fmt.Sprintf("option %d", i),
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		})
+		assert.Nil(t, err)
+	}
+
+	options, err := repo.GetQuestionOptionsByQuestionIds(ctx, []int64{1, 2, 4})
+	assert.Nil(t, err)
+	assert.Len(t, options, 2)
+	assert.Len(t, options[1], 3)
+	assert.Equal(t, "option 0", options[1][0].Option)
+	assert.Len(t, options[2], 2)
+	assert.Empty(t, options[4])
+}
+
+func TestRecordQuestionAttemptsCalibratesDifficulty(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	id, err := repo.CreateQuestion(ctx, Questions{
+		SubjectId: 1,
+		Question:  "test",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	assert.Nil(t, err)
+
+	// a few wrong answers are not enough to recalibrate
+	attempts := make([]QuestionAttempt, 0, domain.DifficultyCalibrationMinAttempts)
+	for i := 0; i < domain.DifficultyCalibrationMinAttempts-1; i++ {
+		attempts = append(attempts, QuestionAttempt{QuestionId: id, IsCorrect: i%5 == 0})
+	}
+	err = repo.RecordQuestionAttempts(ctx, attempts)
+	assert.Nil(t, err)
+	question, err := repo.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.DifficultyMedium, question.Difficulty)
+
+	err = repo.RecordQuestionAttempts(ctx, []QuestionAttempt{{QuestionId: id, IsCorrect: false}})
+	assert.Nil(t, err)
+	question, err = repo.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.DifficultyHard, question.Difficulty)
+}
+
+func TestGetAnswersByQuestionIds(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, questionId := range []int64{1, 3} {
+		_, err := repo.CreateAnswer(ctx, Answers{
+			QuestionId: questionId,
+			Answer:     fmt.Sprintf("explanation %d", questionId),
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		})
+		assert.Nil(t, err)
+	}
+
+	answers, err := repo.GetAnswersByQuestionIds(ctx, []int64{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Len(t, answers, 2)
+	assert.Equal(t, "explanation 3", answers[3].Answer)
+	_, ok := answers[2]
+	assert.False(t, ok)
+}
+
+func TestQuestionClassification(t *testing.T) {
+	pool := setUP(t)
+	defer pool.Close()
+	repo := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// topic 1 has questions 1 and 2, topic 2 has question 3 and question 4 has no topic
+	classifications := []struct {
+		topicId int64
+		tags    []string
+	}{
+		{topicId: 1, tags: []string{"waec 2019", "graphs"}},
+		{topicId: 1},
+		{topicId: 2, tags: []string{"graphs"}},
+		{tags: []string{"waec 2019"}},
+	}
+	for i, classification := range classifications {
+		id, err := repo.CreateQuestion(ctx, Questions{SubjectId: 1, Question: fmt.Sprintf("question %d", i), CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		assert.Nil(t, err)
+		assert.Nil(t, repo.SetQuestionClassification(ctx, id, classification.topicId, classification.tags))
+	}
+	other, err := repo.CreateQuestion(ctx, Questions{SubjectId: 2, Question: "other subject", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.Nil(t, err)
+	assert.Nil(t, repo.SetQuestionClassification(ctx, other, 3, []string{"graphs"}))
+	assert.ErrorIs(t, repo.SetQuestionClassification(ctx, 99, 1, nil), pkg.ErrQuestionNotFound)
+
+	question, err := repo.GetQuestionById(ctx, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), question.TopicId)
+
+	tags, err := repo.GetQuestionTags(ctx, []int64{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int64][]string{1: {"graphs", "waec 2019"}, 3: {"graphs"}}, tags)
+
+	subjectTags, err := repo.GetSubjectTags(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.SubjectTag{{Tag: "graphs", Questions: 2}, {Tag: "waec 2019", Questions: 2}}, subjectTags)
+
+	tests := []struct {
+		name    string
+		filter  QuestionFilter
+		wantIds []int64
+	}{
+		{name: "no filter", wantIds: []int64{1, 2, 3, 4}},
+		{name: "one topic", filter: QuestionFilter{TopicIds: []int64{1}}, wantIds: []int64{1, 2}},
+		{name: "any of the topics", filter: QuestionFilter{TopicIds: []int64{1, 2}}, wantIds: []int64{1, 2, 3}},
+		{name: "any of the tags", filter: QuestionFilter{Tags: []string{"graphs", "waec 2019"}}, wantIds: []int64{1, 3, 4}},
+		{name: "topics and tags", filter: QuestionFilter{TopicIds: []int64{1}, Tags: []string{"graphs"}}, wantIds: []int64{1}},
+		{name: "no match", filter: QuestionFilter{Tags: []string{"jamb 2020"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := repo.GetSubjectQuestionIds(ctx, 1, tt.filter)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantIds, ids)
+
+			questions, err := repo.GetRandomQuestions(ctx, 1, 10, 0, []int64{2}, tt.filter)
+			assert.Nil(t, err)
+			randomIds := make([]int64, len(questions))
+			for i, question := range questions {
+				randomIds[i] = question.Id
+			}
+			assert.ElementsMatch(t, slices.DeleteFunc(slices.Clone(tt.wantIds), func(id int64) bool { return id == 2 }), randomIds)
+		})
+	}
+
+	// classifying again replaces the topic and tags
+	assert.Nil(t, repo.SetQuestionClassification(ctx, 1, 0, []string{"trigonometry"}))
+	question, err = repo.GetQuestionById(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), question.TopicId)
+	tags, err = repo.GetQuestionTags(ctx, []int64{1})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"trigonometry"}, tags[1])
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// QuizChallenge is a quiz shared by its code. The questions are drawn once from Seed when
+// the challenge is created and kept in order, so every quiz session issued from the
+// challenge has the same questions, scored with the same policies.
+// SubjectId is 0 for a challenge that mixes several subjects.
+type QuizChallenge struct {
+	Id              int64                `json:"id"`
+	Code            string               `json:"code"`
+	Seed            int64                `json:"seed"`
+	CreatedBy       int64                `json:"created_by"`
+	SubjectId       int64                `json:"subject_id"`
+	Mode            string               `json:"mode"`
+	DurationSeconds int64                `json:"duration_seconds"`
+	Subjects        []QuizSessionSubject `json:"subjects"`
+	QuestionIds     []int64              `json:"question_ids"`
+	CreatedAt       time.Time            `json:"created_at"`
+}
+
+type QuizChallengeRepository interface {
+	CreateQuizChallenge(ctx context.Context, challenge QuizChallenge) (int64, error)
+	GetQuizChallengeByCode(ctx context.Context, code string) (*QuizChallenge, error)
+	HasUserTakenChallenge(ctx context.Context, userId int64, challengeId int64) (bool, error)
+	GetChallengeResults(ctx context.Context, challengeId int64) ([]domain.ChallengeResult, error)
+}
+
+type quizChallengeRepository struct {
+	db *sql.DB
+}
+
+func NewQuizChallengeRepository(db *sql.DB) QuizChallengeRepository {
+	return &quizChallengeRepository{db: db}
+}
+
+// CreateQuizChallenge stores a challenge. Its subjects and question ids are kept as JSON.
+func (cr *quizChallengeRepository) CreateQuizChallenge(ctx context.Context, challenge QuizChallenge) (int64, error) {
+	subjects, err := json.Marshal(challenge.Subjects)
+	if err != nil {
+		return 0, err
+	}
+	questionIds, err := json.Marshal(challenge.QuestionIds)
+	if err != nil {
+		return 0, err
+	}
+	subjectId := sql.NullInt64{Int64: challenge.SubjectId, Valid: challenge.SubjectId != 0}
+	query := `INSERT INTO quiz_challenges (code, seed, created_by, subject_id, mode, duration_seconds, subjects, question_ids, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	var id int64
+	err = cr.db.QueryRowContext(ctx, query, challenge.Code, challenge.Seed, challenge.CreatedBy, subjectId, challenge.Mode,
+		challenge.DurationSeconds, string(subjects), string(questionIds), challenge.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetQuizChallengeByCode returns the challenge with the given code, or pkg.ErrChallengeNotFound.
+func (cr *quizChallengeRepository) GetQuizChallengeByCode(ctx context.Context, code string) (*QuizChallenge, error) {
+	query := `SELECT id, code, seed, created_by, subject_id, mode, duration_seconds, subjects, question_ids, created_at
+		FROM quiz_challenges WHERE code = $1`
+	var challenge QuizChallenge
+	var subjectId sql.NullInt64
+	var subjects, questionIds string
+	err := cr.db.QueryRowContext(ctx, query, code).Scan(&challenge.Id, &challenge.Code, &challenge.Seed, &challenge.CreatedBy,
+		&subjectId, &challenge.Mode, &challenge.DurationSeconds, &subjects, &questionIds, &challenge.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, pkg.ErrChallengeNotFound
+		}
+		return nil, err
+	}
+	challenge.SubjectId = subjectId.Int64
+	if err := json.Unmarshal([]byte(subjects), &challenge.Subjects); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(questionIds), &challenge.QuestionIds); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// HasUserTakenChallenge reports whether a quiz session has already been issued to the user from the challenge.
+func (cr *quizChallengeRepository) HasUserTakenChallenge(ctx context.Context, userId int64, challengeId int64) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM quiz_sessions WHERE user_id = $1 AND challenge_id = $2)"
+	var taken bool
+	if err := cr.db.QueryRowContext(ctx, query, userId, challengeId).Scan(&taken); err != nil {
+		return false, err
+	}
+	return taken, nil
+}
+
+// GetChallengeResults ranks everyone who submitted a quiz session issued from the challenge,
+// by points and then by who was quickest.
+func (cr *quizChallengeRepository) GetChallengeResults(ctx context.Context, challengeId int64) ([]domain.ChallengeResult, error) {
+	query := `
+		SELECT
+			qs.user_id,
+			u.name,
+			COALESCE(SUM(s.score), 0) as score,
+			COALESCE(SUM(s.points), 0) as points,
+			COALESCE(SUM(s.correct_answers), 0) as correct_answers,
+			COALESCE(SUM(s.total_questions), 0) as total_questions,
+			COALESCE(SUM(s.time_taken_seconds), 0) as time_taken_seconds,
+			qs.submitted_at
+		FROM quiz_sessions qs
+		INNER JOIN users u ON u.id = qs.user_id
+		INNER JOIN scores s ON s.session_id = qs.id
+		WHERE qs.challenge_id = $1 AND qs.status = $2
+		GROUP BY qs.id, qs.user_id, u.name, qs.submitted_at
+		ORDER BY points DESC, time_taken_seconds ASC, qs.submitted_at ASC
+	`
+	rows, err := cr.db.QueryContext(ctx, query, challengeId, QuizSessionSubmitted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.ChallengeResult
+	rank := int64(1)
+	for rows.Next() {
+		var result domain.ChallengeResult
+		err := rows.Scan(
+			&result.UserID,
+			&result.UserName,
+			&result.Score,
+			&result.Points,
+			&result.CorrectAnswers,
+			&result.TotalQuestions,
+			&result.TimeTakenSeconds,
+			&result.SubmittedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result.Rank = rank
+		results = append(results, result)
+		rank++
+	}
+	return results, rows.Err()
+}
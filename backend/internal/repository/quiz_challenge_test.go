@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuizChallenge(t *testing.T) {
+	pool := setUpDB(t)
+	cr := NewQuizChallengeRepository(pool)
+	sr := NewQuizSessionRepository(pool)
+	ss := NewScoreRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	challengeId, err := cr.CreateQuizChallenge(ctx, QuizChallenge{
+		Code:        "7K3M9Q2X",
+		Seed:        42,
+		CreatedBy:   1,
+		SubjectId:   1,
+		Mode:        domain.ModePractice,
+		Subjects:    []QuizSessionSubject{{SubjectId: 1, NumOfQuestions: 2, ScoringPolicy: domain.DefaultScoringPolicy()}},
+		QuestionIds: []int64{8, 3},
+		CreatedAt:   time.Now(),
+	})
+	assert.NoError(t, err)
+
+	challenge, err := cr.GetQuizChallengeByCode(ctx, "7K3M9Q2X")
+	assert.NoError(t, err)
+	assert.Equal(t, challengeId, challenge.Id)
+	assert.Equal(t, int64(42), challenge.Seed)
+	assert.Equal(t, []int64{8, 3}, challenge.QuestionIds)
+	assert.Equal(t, domain.ScoringStandard, challenge.Subjects[0].ScoringPolicy.Name)
+
+	_, err = cr.GetQuizChallengeByCode(ctx, "00000000")
+	assert.ErrorIs(t, err, pkg.ErrChallengeNotFound)
+
+	for _, name := range []string{"ada", "grace"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.NoError(t, err)
+	}
+	taken, err := cr.HasUserTakenChallenge(ctx, 1, challengeId)
+	assert.NoError(t, err)
+	assert.False(t, taken)
+
+	for userId, correct := range map[int64]int64{1: 1, 2: 2} {
+		sessionId, err := sr.CreateQuizSession(ctx, QuizSession{UserId: userId, SubjectId: 1, Mode: domain.ModePractice, ChallengeId: challengeId, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		assert.NoError(t, err)
+		assert.NoError(t, sr.MarkQuizSessionSubmitted(ctx, sessionId, time.Now()))
+		_, err = ss.StoreUserScore(ctx, domain.UserScore{UserID: userId, SessionID: sessionId, SubjectID: 1, Score: correct, Points: float64(correct), CorrectAnswers: correct, TotalQuestions: 2, TimeTakenSeconds: 30, Mode: domain.ModePractice, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		assert.NoError(t, err)
+	}
+	// each user is issued one session of a challenge, however close together they ask
+	_, err = sr.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 1, Mode: domain.ModePractice, ChallengeId: challengeId, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.ErrorIs(t, err, pkg.ErrChallengeAlreadyTaken)
+	// a session from outside the challenge does not count
+	_, err = sr.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 1, Mode: domain.ModePractice, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.NoError(t, err)
+
+	taken, err = cr.HasUserTakenChallenge(ctx, 1, challengeId)
+	assert.NoError(t, err)
+	assert.True(t, taken)
+
+	results, err := cr.GetChallengeResults(ctx, challengeId)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "grace", results[0].UserName)
+	assert.Equal(t, int64(1), results[0].Rank)
+	assert.Equal(t, int64(2), results[0].CorrectAnswers)
+	assert.Equal(t, int64(2), results[1].Rank)
+}
@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/lib/pq"
+)
+
+// Quiz session statuses
+const (
+	QuizSessionActive    = "active"
+	QuizSessionSubmitted = "submitted"
+)
+
+// QuizSession is a quiz issued to a user. It records exactly which questions and
+// options were handed out so that a submission can only be graded against them.
+// SubjectId is 0 for a quiz that mixes several subjects, ChallengeId is 0 unless the
+// session was issued from a shared challenge, PaperId is 0 unless it is a mock exam of a past paper,
+// ContestId is 0 unless it is an entry to a contest, and DailyDate is the day of the
+// daily challenge it was issued for, if any.
+type QuizSession struct {
+	Id              int64                 `json:"id"`
+	UserId          int64                 `json:"user_id"`
+	SubjectId       int64                 `json:"subject_id"`
+	Status          string                `json:"status"`
+	Mode            string                `json:"mode"`
+	DurationSeconds int64                 `json:"duration_seconds"`
+	ChallengeId     int64                 `json:"challenge_id,omitempty"`
+	PaperId         int64                 `json:"paper_id,omitempty"`
+	ContestId       int64                 `json:"contest_id,omitempty"`
+	DailyDate       *time.Time            `json:"daily_date,omitempty"`
+	ExpiresAt       *time.Time            `json:"expires_at,omitempty"`
+	Subjects        []QuizSessionSubject  `json:"subjects"`
+	Questions       []QuizSessionQuestion `json:"questions"`
+	SubmittedAt     *time.Time            `json:"submitted_at,omitempty"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+}
+
+// QuizSessionSubject is a subject a quiz session draws questions from, with the
+// scoring policy the subject had when the session was issued.
+type QuizSessionSubject struct {
+	SubjectId      int64                `json:"subject_id"`
+	NumOfQuestions int64                `json:"num_of_questions"`
+	ScoringPolicy  domain.ScoringPolicy `json:"scoring_policy"`
+}
+
+// QuizSessionQuestion is a question issued in a quiz session along with the ids of
+// the options that were shown for it.
+type QuizSessionQuestion struct {
+	QuestionId int64   `json:"question_id"`
+	SubjectId  int64   `json:"subject_id"`
+	Position   int     `json:"position"`
+	OptionIds  []int64 `json:"option_ids"`
+}
+
+// PaperSitting is a submitted mock exam of a past paper and the points it scored
+type PaperSitting struct {
+	SessionId   int64
+	UserId      int64
+	Points      float64
+	ExpiresAt   *time.Time
+	SubmittedAt time.Time
+}
+
+type QuizSessionRepository interface {
+	CreateQuizSession(ctx context.Context, session QuizSession) (int64, error)
+	GetQuizSessionById(ctx context.Context, id int64) (*QuizSession, error)
+	MarkQuizSessionSubmitted(ctx context.Context, id int64, submittedAt time.Time) error
+	ReopenQuizSession(ctx context.Context, id int64) error
+	GetPaperSittings(ctx context.Context, paperId int64) ([]PaperSitting, error)
+}
+
+type quizSessionRepository struct {
+	db *sql.DB
+}
+
+func NewQuizSessionRepository(db *sql.DB) QuizSessionRepository {
+	return &quizSessionRepository{db: db}
+}
+
+// CreateQuizSession stores a quiz session together with its issued questions and options.
+// Everything is written in a single transaction so a session is never left half issued.
+func (qsr *quizSessionRepository) CreateQuizSession(ctx context.Context, session QuizSession) (int64, error) {
+	tx, err := qsr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ids, err := insertQuizSessions(ctx, tx, []QuizSession{session})
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// insertQuizSessions writes quiz sessions with their subjects, questions and options and
+// returns their ids in the order they were given. Each table takes one multi-row INSERT
+// however many sessions, questions and options there are, so issuing a long quiz, or a
+// session to every player of a live room, costs no more round trips than a single short one.
+func insertQuizSessions(ctx context.Context, db dbtx, sessions []QuizSession) ([]int64, error) {
+	sessionRows := make([][]any, len(sessions))
+	for i, session := range sessions {
+		if session.Status == "" {
+			session.Status = QuizSessionActive
+		}
+		subjectId := sql.NullInt64{Int64: session.SubjectId, Valid: session.SubjectId != 0}
+		challengeId := sql.NullInt64{Int64: session.ChallengeId, Valid: session.ChallengeId != 0}
+		paperId := sql.NullInt64{Int64: session.PaperId, Valid: session.PaperId != 0}
+		contestId := sql.NullInt64{Int64: session.ContestId, Valid: session.ContestId != 0}
+		// The day is written as a date so it compares the same whatever the database's time zone
+		var dailyDate sql.NullString
+		if session.DailyDate != nil {
+			dailyDate = sql.NullString{String: session.DailyDate.Format(time.DateOnly), Valid: true}
+		}
+		sessionRows[i] = []any{session.UserId, subjectId, session.Status, session.Mode, session.DurationSeconds, challengeId, paperId, contestId, dailyDate, session.ExpiresAt, session.SubmittedAt, session.CreatedAt, session.UpdatedAt}
+	}
+	ids, err := insertRowsReturningIds(ctx, db, "quiz_sessions", []string{"user_id", "subject_id", "status", "mode", "duration_seconds", "challenge_id", "paper_id", "contest_id", "daily_date", "expires_at", "submitted_at", "created_at", "updated_at"}, sessionRows)
+	if err != nil {
+		// Two takes of a challenge racing past the check for an earlier one meet here
+		if isUniqueViolation(err, "idx_quiz_sessions_challenge_id_user_id", "quiz_sessions.challenge_id, quiz_sessions.user_id") {
+			return nil, pkg.ErrChallengeAlreadyTaken
+		}
+		// and so do two entries to a contest
+		if isUniqueViolation(err, "idx_quiz_sessions_contest_id_user_id", "quiz_sessions.contest_id, quiz_sessions.user_id") {
+			return nil, pkg.ErrContestAlreadyEntered
+		}
+		// or two takes of a subject's daily challenge on the same day
+		if isUniqueViolation(err, "idx_quiz_sessions_user_id_subject_id_daily_date", "quiz_sessions.user_id, quiz_sessions.subject_id, quiz_sessions.daily_date") {
+			return nil, pkg.ErrDailyChallengeTaken
+		}
+		return nil, err
+	}
+
+	var subjectRows, questionRows, optionRows [][]any
+	for i, session := range sessions {
+		for _, subject := range session.Subjects {
+			if subject.ScoringPolicy.Name == "" {
+				subject.ScoringPolicy = domain.DefaultScoringPolicy()
+			}
+			scoringPolicy, err := json.Marshal(subject.ScoringPolicy)
+			if err != nil {
+				return nil, err
+			}
+			subjectRows = append(subjectRows, []any{ids[i], subject.SubjectId, subject.NumOfQuestions, string(scoringPolicy)})
+		}
+		for _, question := range session.Questions {
+			questionRows = append(questionRows, []any{ids[i], question.QuestionId, question.SubjectId, question.Position})
+			for position, optionId := range question.OptionIds {
+				optionRows = append(optionRows, []any{ids[i], question.QuestionId, optionId, position})
+			}
+		}
+	}
+	if err := insertRows(ctx, db, "quiz_session_subjects", []string{"session_id", "subject_id", "num_of_questions", "scoring_policy"}, subjectRows); err != nil {
+		return nil, err
+	}
+	if err := insertRows(ctx, db, "quiz_session_questions", []string{"session_id", "question_id", "subject_id", "position"}, questionRows); err != nil {
+		return nil, err
+	}
+	if err := insertRows(ctx, db, "quiz_session_options", []string{"session_id", "question_id", "option_id", "position"}, optionRows); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetQuizSessionById returns a quiz session with its subjects, and its issued questions and options in the order they were issued.
+func (qsr *quizSessionRepository) GetQuizSessionById(ctx context.Context, id int64) (*QuizSession, error) {
+	query := "SELECT id, user_id, subject_id, status, mode, duration_seconds, challenge_id, paper_id, contest_id, daily_date, expires_at, submitted_at, created_at, updated_at FROM quiz_sessions WHERE id = $1"
+	var session QuizSession
+	var subjectId, challengeId, paperId, contestId sql.NullInt64
+	var dailyDate, expiresAt, submittedAt sql.NullTime
+	err := qsr.db.QueryRowContext(ctx, query, id).Scan(&session.Id, &session.UserId, &subjectId, &session.Status, &session.Mode, &session.DurationSeconds, &challengeId, &paperId, &contestId, &dailyDate, &expiresAt, &submittedAt, &session.CreatedAt, &session.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, pkg.ErrQuizSessionNotFound
+		}
+		return nil, err
+	}
+	session.SubjectId = subjectId.Int64
+	session.ChallengeId = challengeId.Int64
+	session.PaperId = paperId.Int64
+	session.ContestId = contestId.Int64
+	if dailyDate.Valid {
+		session.DailyDate = &dailyDate.Time
+	}
+	if expiresAt.Valid {
+		session.ExpiresAt = &expiresAt.Time
+	}
+	if submittedAt.Valid {
+		session.SubmittedAt = &submittedAt.Time
+	}
+
+	query = "SELECT subject_id, num_of_questions, scoring_policy FROM quiz_session_subjects WHERE session_id = $1 ORDER BY id"
+	subjectRows, err := qsr.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer subjectRows.Close()
+	for subjectRows.Next() {
+		var subject QuizSessionSubject
+		var scoringPolicy string
+		if err := subjectRows.Scan(&subject.SubjectId, &subject.NumOfQuestions, &scoringPolicy); err != nil {
+			return nil, err
+		}
+		subject.ScoringPolicy = domain.DefaultScoringPolicy()
+		if scoringPolicy != "" {
+			if err := json.Unmarshal([]byte(scoringPolicy), &subject.ScoringPolicy); err != nil {
+				return nil, err
+			}
+		}
+		session.Subjects = append(session.Subjects, subject)
+	}
+	if err = subjectRows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = "SELECT question_id, subject_id, position FROM quiz_session_questions WHERE session_id = $1 ORDER BY position"
+	rows, err := qsr.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	questionIndex := make(map[int64]int)
+	for rows.Next() {
+		var question QuizSessionQuestion
+		if err := rows.Scan(&question.QuestionId, &question.SubjectId, &question.Position); err != nil {
+			return nil, err
+		}
+		questionIndex[question.QuestionId] = len(session.Questions)
+		session.Questions = append(session.Questions, question)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = "SELECT question_id, option_id FROM quiz_session_options WHERE session_id = $1 ORDER BY question_id, position"
+	optionRows, err := qsr.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer optionRows.Close()
+	for optionRows.Next() {
+		var questionId, optionId int64
+		if err := optionRows.Scan(&questionId, &optionId); err != nil {
+			return nil, err
+		}
+		if i, ok := questionIndex[questionId]; ok {
+			session.Questions[i].OptionIds = append(session.Questions[i].OptionIds, optionId)
+		}
+	}
+	if err = optionRows.Err(); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// MarkQuizSessionSubmitted flips an active session to submitted.
+// The status check happens in the same statement so two concurrent submissions cannot both succeed.
+func (qsr *quizSessionRepository) MarkQuizSessionSubmitted(ctx context.Context, id int64, submittedAt time.Time) error {
+	query := "UPDATE quiz_sessions SET status = $1, submitted_at = $2, updated_at = $3 WHERE id = $4 AND status = $5"
+	res, err := qsr.db.ExecContext(ctx, query, QuizSessionSubmitted, submittedAt, submittedAt, id, QuizSessionActive)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return pkg.ErrQuizSessionAlreadySubmitted
+	}
+	return nil
+}
+
+// ReopenQuizSession flips a submitted session back to active, for a submission whose
+// scores could not be stored, so it can be submitted again.
+func (qsr *quizSessionRepository) ReopenQuizSession(ctx context.Context, id int64) error {
+	query := "UPDATE quiz_sessions SET status = $1, submitted_at = NULL, updated_at = $2 WHERE id = $3 AND status = $4"
+	_, err := qsr.db.ExecContext(ctx, query, QuizSessionActive, time.Now(), id, QuizSessionSubmitted)
+	return err
+}
+
+// GetPaperSittings returns every submitted mock exam of a past paper with the points it
+// scored, in the order they were submitted.
+func (qsr *quizSessionRepository) GetPaperSittings(ctx context.Context, paperId int64) ([]PaperSitting, error) {
+	query := `
+		SELECT qs.id, qs.user_id, COALESCE(SUM(s.points), 0) as points, qs.expires_at, qs.submitted_at
+		FROM quiz_sessions qs
+		INNER JOIN scores s ON s.session_id = qs.id
+		WHERE qs.paper_id = $1 AND qs.status = $2
+		GROUP BY qs.id, qs.user_id, qs.expires_at, qs.submitted_at
+		ORDER BY qs.submitted_at, qs.id
+	`
+	rows, err := qsr.db.QueryContext(ctx, query, paperId, QuizSessionSubmitted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sittings []PaperSitting
+	for rows.Next() {
+		var sitting PaperSitting
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&sitting.SessionId, &sitting.UserId, &sitting.Points, &expiresAt, &sitting.SubmittedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			sitting.ExpiresAt = &expiresAt.Time
+		}
+		sittings = append(sittings, sitting)
+	}
+	return sittings, rows.Err()
+}
+
+// isUniqueViolation reports whether err is a violation of a unique index. Postgres names
+// the index; sqlite names the table's columns instead.
+func isUniqueViolation(err error, index string, columns string) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" && pqErr.Constraint == index
+	}
+	return strings.Contains(err.Error(), "UNIQUE constraint failed: "+columns)
+}
+
+// maxInsertArgs keeps a multi-row INSERT under the bind parameter limits of both Postgres and sqlite
+const maxInsertArgs = 30000
+
+// insertRows writes rows into the given columns of table with as few multi-row INSERTs as
+// the bind parameter limit allows, which for anything a quiz issues is a single one.
+func insertRows(ctx context.Context, db dbtx, table string, columns []string, rows [][]any) error {
+	for _, chunk := range insertChunks(rows, len(columns)) {
+		query, args := insertQuery(table, columns, chunk)
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertRowsReturningIds is insertRows for a table with a serial id, returning the ids of
+// the new rows in the order the rows were given. Ids are handed out in the order of the
+// VALUES list, but RETURNING does not promise to keep it, so they are put back in order.
+func insertRowsReturningIds(ctx context.Context, db dbtx, table string, columns []string, rows [][]any) ([]int64, error) {
+	ids := make([]int64, 0, len(rows))
+	for _, chunk := range insertChunks(rows, len(columns)) {
+		query, args := insertQuery(table, columns, chunk)
+		returned, err := db.QueryContext(ctx, query+" RETURNING id", args...)
+		if err != nil {
+			return nil, err
+		}
+		chunkIds := make([]int64, 0, len(chunk))
+		for returned.Next() {
+			var id int64
+			if err := returned.Scan(&id); err != nil {
+				returned.Close()
+				return nil, err
+			}
+			chunkIds = append(chunkIds, id)
+		}
+		returned.Close()
+		if err := returned.Err(); err != nil {
+			return nil, err
+		}
+		slices.Sort(chunkIds)
+		ids = append(ids, chunkIds...)
+	}
+	return ids, nil
+}
+
+// insertChunks splits rows into runs small enough for one INSERT each.
+func insertChunks(rows [][]any, columns int) [][][]any {
+	size := maxInsertArgs / columns
+	var chunks [][][]any
+	for start := 0; start < len(rows); start += size {
+		chunks = append(chunks, rows[start:min(start+size, len(rows))])
+	}
+	return chunks
+}
+
+// insertQuery builds a multi-row INSERT of rows into the given columns of table.
+func insertQuery(table string, columns []string, rows [][]any) (string, []any) {
+	values := make([]string, 0, len(rows))
+	args := make([]any, 0, len(rows)*len(columns))
+	for _, row := range rows {
+		values = append(values, "("+inPlaceholders(len(args)+1, len(row))+")")
+		args = append(args, row...)
+	}
+	return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES " + strings.Join(values, ", "), args
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateQuizSession(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuizSessionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionId, err := repo.CreateQuizSession(ctx, QuizSession{
+		UserId:    1,
+		SubjectId: 1,
+		PaperId:   5,
+		Questions: []QuizSessionQuestion{
+			{QuestionId: 7, Position: 0, OptionIds: []int64{21, 22, 23}},
+			{QuestionId: 3, Position: 1, OptionIds: []int64{9, 10}},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), sessionId)
+
+	session, err := repo.GetQuizSessionById(ctx, sessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, QuizSessionActive, session.Status)
+	assert.Equal(t, int64(5), session.PaperId)
+	assert.Nil(t, session.SubmittedAt)
+	assert.Len(t, session.Questions, 2)
+	assert.Equal(t, int64(7), session.Questions[0].QuestionId)
+	assert.Equal(t, []int64{21, 22, 23}, session.Questions[0].OptionIds)
+	assert.Equal(t, int64(3), session.Questions[1].QuestionId)
+	assert.Equal(t, []int64{9, 10}, session.Questions[1].OptionIds)
+}
+
+func TestCreateQuizSessionSplitsLargeInserts(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuizSessionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 2000 questions of 4 options take more bind parameters than one INSERT may carry
+	questions := make([]QuizSessionQuestion, 2000)
+	for i := range questions {
+		questionId := int64(i + 1)
+		questions[i] = QuizSessionQuestion{QuestionId: questionId, SubjectId: 1, Position: i, OptionIds: []int64{questionId * 10, questionId*10 + 1, questionId*10 + 2, questionId*10 + 3}}
+	}
+	sessionId, err := repo.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 1, Questions: questions, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.Nil(t, err)
+
+	session, err := repo.GetQuizSessionById(ctx, sessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, questions, session.Questions)
+}
+
+func TestCreateMixedQuizSession(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuizSessionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionId, err := repo.CreateQuizSession(ctx, QuizSession{
+		UserId: 1,
+		Subjects: []QuizSessionSubject{
+			{SubjectId: 1, NumOfQuestions: 1, ScoringPolicy: domain.ScoringPolicy{Name: domain.ScoringNegativeMarking, WrongPenalty: 0.25}},
+			{SubjectId: 2, NumOfQuestions: 1},
+		},
+		Questions: []QuizSessionQuestion{
+			{QuestionId: 7, SubjectId: 1, Position: 0, OptionIds: []int64{21, 22}},
+			{QuestionId: 3, SubjectId: 2, Position: 1, OptionIds: []int64{9, 10}},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	assert.Nil(t, err)
+
+	session, err := repo.GetQuizSessionById(ctx, sessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), session.SubjectId)
+	assert.Len(t, session.Subjects, 2)
+	assert.Equal(t, domain.ScoringNegativeMarking, session.Subjects[0].ScoringPolicy.Name)
+	assert.Equal(t, 0.25, session.Subjects[0].ScoringPolicy.WrongPenalty)
+	// subjects stored without a policy get the default one
+	assert.Equal(t, domain.DefaultScoringPolicy(), session.Subjects[1].ScoringPolicy)
+	assert.Equal(t, int64(2), session.Questions[1].SubjectId)
+}
+
+func TestGetQuizSessionByIdNotFound(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuizSessionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := repo.GetQuizSessionById(ctx, 42)
+	assert.ErrorIs(t, err, pkg.ErrQuizSessionNotFound)
+}
+
+func TestMarkQuizSessionSubmitted(t *testing.T) {
+	pool := setUP(t)
+	repo := NewQuizSessionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionId, err := repo.CreateQuizSession(ctx, QuizSession{
+		UserId:    1,
+		SubjectId: 1,
+		Questions: []QuizSessionQuestion{{QuestionId: 1, OptionIds: []int64{1, 2}}},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	assert.Nil(t, err)
+
+	err = repo.MarkQuizSessionSubmitted(ctx, sessionId, time.Now())
+	assert.Nil(t, err)
+
+	session, err := repo.GetQuizSessionById(ctx, sessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, QuizSessionSubmitted, session.Status)
+	assert.NotNil(t, session.SubmittedAt)
+
+	err = repo.MarkQuizSessionSubmitted(ctx, sessionId, time.Now())
+	assert.ErrorIs(t, err, pkg.ErrQuizSessionAlreadySubmitted)
+
+	// a reopened session can be submitted again
+	assert.Nil(t, repo.ReopenQuizSession(ctx, sessionId))
+	session, err = repo.GetQuizSessionById(ctx, sessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, QuizSessionActive, session.Status)
+	assert.Nil(t, session.SubmittedAt)
+	assert.Nil(t, repo.MarkQuizSessionSubmitted(ctx, sessionId, time.Now()))
+}
+
+func TestGetPaperSittings(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	sr := NewQuizSessionRepository(pool)
+	ss := NewScoreRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	issuedAt := time.Now().Add(-time.Hour)
+	expiresAt := issuedAt.Add(30 * time.Minute)
+	for i, userId := range []int64{2, 1, 2} {
+		sessionId, err := sr.CreateQuizSession(ctx, QuizSession{UserId: userId, SubjectId: 1, Mode: domain.ModeMock, DurationSeconds: 1800, PaperId: 5, ExpiresAt: &expiresAt, CreatedAt: issuedAt, UpdatedAt: issuedAt})
+		assert.NoError(t, err)
+		assert.NoError(t, sr.MarkQuizSessionSubmitted(ctx, sessionId, issuedAt.Add(time.Duration(i+1)*time.Minute)))
+		_, err = ss.StoreUserScore(ctx, domain.UserScore{UserID: userId, SessionID: sessionId, SubjectID: 1, Points: float64(10 * (i + 1)), TotalQuestions: 4, Mode: domain.ModeMock, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		assert.NoError(t, err)
+	}
+	// neither a sitting still in progress nor another paper counts
+	_, err := sr.CreateQuizSession(ctx, QuizSession{UserId: 3, SubjectId: 1, Mode: domain.ModeMock, PaperId: 5, CreatedAt: issuedAt, UpdatedAt: issuedAt})
+	assert.NoError(t, err)
+	other, err := sr.CreateQuizSession(ctx, QuizSession{UserId: 3, SubjectId: 1, Mode: domain.ModeMock, PaperId: 6, CreatedAt: issuedAt, UpdatedAt: issuedAt})
+	assert.NoError(t, err)
+	assert.NoError(t, sr.MarkQuizSessionSubmitted(ctx, other, time.Now()))
+
+	sittings, err := sr.GetPaperSittings(ctx, 5)
+	assert.NoError(t, err)
+	assert.Len(t, sittings, 3)
+	for i, sitting := range sittings {
+		assert.Equal(t, int64(i+1), sitting.SessionId)
+		assert.Equal(t, float64(10*(i+1)), sitting.Points)
+		assert.NotNil(t, sitting.ExpiresAt)
+	}
+	assert.Equal(t, int64(1), sittings[1].UserId)
+}
+
+func TestCreateQuizSessionContestEnteredTwice(t *testing.T) {
+	pool := setUpDB(t)
+	repo := NewQuizSessionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := repo.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 1, Mode: domain.ModeContest, ContestId: 4, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.NoError(t, err)
+	// each user enters a contest once, however close together they ask
+	_, err = repo.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 1, Mode: domain.ModeContest, ContestId: 4, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.ErrorIs(t, err, pkg.ErrContestAlreadyEntered)
+	// other users still can
+	_, err = repo.CreateQuizSession(ctx, QuizSession{UserId: 2, SubjectId: 1, Mode: domain.ModeContest, ContestId: 4, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.NoError(t, err)
+}
+
+func TestCreateQuizSessionDailyChallengeTakenTwice(t *testing.T) {
+	pool := setUpDB(t)
+	repo := NewQuizSessionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	_, err := repo.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 1, Mode: domain.ModeDaily, DailyDate: &day, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.NoError(t, err)
+	// each user takes a subject's daily challenge once a day, however close together they ask
+	_, err = repo.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 1, Mode: domain.ModeDaily, DailyDate: &day, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.ErrorIs(t, err, pkg.ErrDailyChallengeTaken)
+	// another subject's, or the next day's, is a challenge of its own
+	_, err = repo.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 2, Mode: domain.ModeDaily, DailyDate: &day, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.NoError(t, err)
+	nextDay := day.AddDate(0, 0, 1)
+	_, err = repo.CreateQuizSession(ctx, QuizSession{UserId: 1, SubjectId: 1, Mode: domain.ModeDaily, DailyDate: &nextDay, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+)
+
+// ReviewItem is a question a user missed and is scheduled to see again in review quizzes,
+// along with its spaced repetition state.
+type ReviewItem struct {
+	UserId         int64     `json:"user_id"`
+	QuestionId     int64     `json:"question_id"`
+	SubjectId      int64     `json:"subject_id"`
+	EaseFactor     float64   `json:"ease_factor"`
+	IntervalDays   int       `json:"interval_days"`
+	Repetitions    int       `json:"repetitions"`
+	DueAt          time.Time `json:"due_at"`
+	LastReviewedAt time.Time `json:"last_reviewed_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ReviewQueueRepository interface {
+	GetReviewItems(ctx context.Context, userId int64, questionIds []int64) ([]ReviewItem, error)
+	SaveReviewItems(ctx context.Context, items []ReviewItem) error
+	GetDueReviewItems(ctx context.Context, userId int64, subjectId int64, now time.Time, limit int64, filter QuestionFilter) ([]ReviewItem, error)
+	GetReviewQueue(ctx context.Context, userId int64, now time.Time) ([]domain.ReviewSubjectQueue, error)
+}
+
+type reviewQueueRepository struct {
+	db *sql.DB
+}
+
+func NewReviewQueueRepository(db *sql.DB) ReviewQueueRepository {
+	return &reviewQueueRepository{db: db}
+}
+
+const reviewItemColumns = "user_id, question_id, subject_id, ease_factor, interval_days, repetitions, due_at, last_reviewed_at, created_at, updated_at"
+
+// GetReviewItems returns the review items a user has for any of the given questions.
+func (rr *reviewQueueRepository) GetReviewItems(ctx context.Context, userId int64, questionIds []int64) ([]ReviewItem, error) {
+	if len(questionIds) == 0 {
+		return nil, nil
+	}
+	args := make([]any, 0, len(questionIds)+1)
+	args = append(args, userId)
+	for _, questionId := range questionIds {
+		args = append(args, questionId)
+	}
+	query := "SELECT " + reviewItemColumns + " FROM review_queue WHERE user_id = $1 AND question_id IN (" + inPlaceholders(2, len(questionIds)) + ")"
+	rows, err := rr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanReviewItems(rows)
+}
+
+// SaveReviewItems creates or updates the review items in a single transaction.
+func (rr *reviewQueueRepository) SaveReviewItems(ctx context.Context, items []ReviewItem) error {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO review_queue (` + reviewItemColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id, question_id) DO UPDATE SET ease_factor = excluded.ease_factor,
+			interval_days = excluded.interval_days, repetitions = excluded.repetitions,
+			due_at = excluded.due_at, last_reviewed_at = excluded.last_reviewed_at, updated_at = excluded.updated_at`
+	for _, item := range items {
+		_, err := tx.ExecContext(ctx, query, item.UserId, item.QuestionId, item.SubjectId, item.EaseFactor, item.IntervalDays,
+			item.Repetitions, item.DueAt, item.LastReviewedAt, item.CreatedAt, item.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDueReviewItems returns up to limit of a user's review items in a subject that are due
+// at now and whose questions the filter matches, the most overdue first.
+func (rr *reviewQueueRepository) GetDueReviewItems(ctx context.Context, userId int64, subjectId int64, now time.Time, limit int64, filter QuestionFilter) ([]ReviewItem, error) {
+	conditions, args := filter.conditions("question_id", []any{userId, subjectId, now})
+	args = append(args, limit)
+	query := "SELECT " + reviewItemColumns + " FROM review_queue WHERE user_id = $1 AND subject_id = $2 AND due_at <= $3" + conditions +
+		fmt.Sprintf(" ORDER BY due_at, question_id LIMIT $%d", len(args))
+	rows, err := rr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanReviewItems(rows)
+}
+
+// GetReviewQueue counts a user's review items per subject, and how many of them are due at now.
+func (rr *reviewQueueRepository) GetReviewQueue(ctx context.Context, userId int64, now time.Time) ([]domain.ReviewSubjectQueue, error) {
+	query := `SELECT subject_id, COUNT(*), COALESCE(SUM(CASE WHEN due_at <= $1 THEN 1 ELSE 0 END), 0)
+		FROM review_queue WHERE user_id = $2 GROUP BY subject_id ORDER BY subject_id`
+	rows, err := rr.db.QueryContext(ctx, query, now, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subjects []domain.ReviewSubjectQueue
+	for rows.Next() {
+		var subject domain.ReviewSubjectQueue
+		if err := rows.Scan(&subject.SubjectId, &subject.Queued, &subject.Due); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, rows.Err()
+}
+
+func scanReviewItems(rows *sql.Rows) ([]ReviewItem, error) {
+	var items []ReviewItem
+	for rows.Next() {
+		var item ReviewItem
+		err := rows.Scan(&item.UserId, &item.QuestionId, &item.SubjectId, &item.EaseFactor, &item.IntervalDays,
+			&item.Repetitions, &item.DueAt, &item.LastReviewedAt, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewQueue(t *testing.T) {
+	pool := setUpDB(t)
+	rr := NewReviewQueueRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	items := []ReviewItem{
+		{UserId: 1, QuestionId: 1, SubjectId: 1, EaseFactor: 2.5, IntervalDays: 1, DueAt: now.Add(-2 * time.Hour), LastReviewedAt: now, CreatedAt: now, UpdatedAt: now},
+		{UserId: 1, QuestionId: 2, SubjectId: 1, EaseFactor: 2.5, IntervalDays: 1, DueAt: now.Add(-4 * time.Hour), LastReviewedAt: now, CreatedAt: now, UpdatedAt: now},
+		{UserId: 1, QuestionId: 3, SubjectId: 1, EaseFactor: 2.5, IntervalDays: 1, DueAt: now.Add(24 * time.Hour), LastReviewedAt: now, CreatedAt: now, UpdatedAt: now},
+		{UserId: 1, QuestionId: 4, SubjectId: 2, EaseFactor: 2.5, IntervalDays: 1, DueAt: now.Add(-time.Hour), LastReviewedAt: now, CreatedAt: now, UpdatedAt: now},
+		{UserId: 2, QuestionId: 1, SubjectId: 1, EaseFactor: 2.5, IntervalDays: 1, DueAt: now.Add(-time.Hour), LastReviewedAt: now, CreatedAt: now, UpdatedAt: now},
+	}
+	assert.NoError(t, rr.SaveReviewItems(ctx, items))
+
+	// saving an item again reschedules it
+	items[0].Repetitions = 1
+	items[0].DueAt = now.Add(48 * time.Hour)
+	assert.NoError(t, rr.SaveReviewItems(ctx, items[:1]))
+
+	found, err := rr.GetReviewItems(ctx, 1, []int64{1, 3, 9})
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	due, err := rr.GetDueReviewItems(ctx, 1, 1, now, 10, QuestionFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, int64(2), due[0].QuestionId)
+
+	queue, err := rr.GetReviewQueue(ctx, 1, now)
+	assert.NoError(t, err)
+	assert.Len(t, queue, 2)
+	assert.Equal(t, int64(3), queue[0].Queued)
+	assert.Equal(t, int64(1), queue[0].Due)
+	assert.Equal(t, int64(1), queue[1].Queued)
+	assert.Equal(t, int64(1), queue[1].Due)
+
+	// only the due items whose questions the filter matches
+	_, err = pool.ExecContext(ctx, "INSERT INTO question_tags (question_id, tag) VALUES (1, 'graphs'), (2, 'graphs')")
+	assert.NoError(t, err)
+	items[0].DueAt = now.Add(-2 * time.Hour)
+	assert.NoError(t, rr.SaveReviewItems(ctx, items[:1]))
+	due, err = rr.GetDueReviewItems(ctx, 1, 1, now, 10, QuestionFilter{Tags: []string{"graphs"}})
+	assert.NoError(t, err)
+	assert.Len(t, due, 2)
+	due, err = rr.GetDueReviewItems(ctx, 1, 1, now, 10, QuestionFilter{Tags: []string{"graphs"}, TopicIds: []int64{1}})
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+}
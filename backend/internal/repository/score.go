@@ -3,14 +3,45 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
 
 	"github.com/lawson/otterprep/domain"
 )
 
 type ScoreRepository interface {
 	StoreUserScore(ctx context.Context, userScore domain.UserScore) (*domain.UserScore, error)
+	StoreUserScores(ctx context.Context, userScores []domain.UserScore) ([]domain.UserScore, error)
+	StoreSessionScores(ctx context.Context, sessions []QuizSession, userScores []domain.UserScore) ([]domain.UserScore, error)
 	GetUserScoreById(ctx context.Context, id int64) (*domain.UserScore, error)
 	GetUserOverallScoreStats(ctx context.Context, userID int64) (*domain.UserStats, error)
+	GetUserRecentSubjectAccuracy(ctx context.Context, userID, subjectID int64, limit int) (correct int64, total int64, err error)
+	GetUserTopicAccuracy(ctx context.Context, userID int64) ([]domain.TopicAccuracy, error)
+	GetUserQuizHistory(ctx context.Context, userID int64, limit, offset int) ([]domain.QuizHistoryEntry, int64, error)
+	GetSessionScores(ctx context.Context, sessionID int64) ([]domain.UserScore, error)
+	GetSessionAnswers(ctx context.Context, sessionID int64) ([]domain.QuizAnswer, error)
+	GetUserAnsweredQuestionsSince(ctx context.Context, userID int64, since time.Time) ([]AnsweredQuestions, error)
+	GetUserSubjectStats(ctx context.Context, userID int64) ([]domain.SubjectAnalytics, error)
+	GetUserAccuracyWindows(ctx context.Context, userID int64, quizzes int) ([]AccuracyWindows, error)
+}
+
+// AnsweredQuestions is how many questions of a subject a user answered in one quiz, and
+// how many of them correctly.
+type AnsweredQuestions struct {
+	SubjectId      int64
+	CorrectAnswers int64
+	TotalQuestions int64
+	AnsweredAt     time.Time
+}
+
+// AccuracyWindows is how a user did in a subject over their most recent quizzes in it and
+// over the same number of quizzes before those. A SubjectId of 0 is every subject together.
+type AccuracyWindows struct {
+	SubjectId       int64
+	RecentCorrect   int64
+	RecentTotal     int64
+	PreviousCorrect int64
+	PreviousTotal   int64
 }
 
 type scoreRepository struct {
@@ -23,30 +54,135 @@ func NewScoreRepository(db *sql.DB) ScoreRepository {
 
 // StoreUserScore stores a user's score.
 func (sr *scoreRepository) StoreUserScore(ctx context.Context, userScore domain.UserScore) (*domain.UserScore, error) {
-	query := "INSERT INTO scores (user_id, score, mode, correct_answers, incorrect_answers, total_questions, time_taken_seconds, subject_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id"
-	err := sr.db.QueryRowContext(ctx, query, userScore.UserID, userScore.Score, userScore.Mode, userScore.CorrectAnswers, userScore.IncorrectAnswers, userScore.TotalQuestions, userScore.TimeTakenSeconds, userScore.SubjectID, userScore.CreatedAt, userScore.UpdatedAt).Scan(&userScore.ID)
-	if err != nil {
+	if err := storeUserScore(ctx, sr.db, &userScore); err != nil {
 		return nil, err
 	}
 	return &userScore, nil
 }
 
+// StoreUserScores stores the per-subject scores of one quiz in a single transaction.
+func (sr *scoreRepository) StoreUserScores(ctx context.Context, userScores []domain.UserScore) ([]domain.UserScore, error) {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stored := make([]domain.UserScore, len(userScores))
+	for i, userScore := range userScores {
+		if err := storeUserScore(ctx, tx, &userScore); err != nil {
+			return nil, err
+		}
+		stored[i] = userScore
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// StoreSessionScores stores quiz sessions that are over by the time they are stored, such
+// as those of a live room, each together with its score. userScores[i] is the
+// score of sessions[i]. Sessions and scores go in a single transaction, so a failure leaves
+// neither behind and the whole lot can be stored again.
+func (sr *scoreRepository) StoreSessionScores(ctx context.Context, sessions []QuizSession, userScores []domain.UserScore) ([]domain.UserScore, error) {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	sessionIds, err := insertQuizSessions(ctx, tx, sessions)
+	if err != nil {
+		return nil, err
+	}
+	stored := make([]domain.UserScore, len(userScores))
+	for i, userScore := range userScores {
+		userScore.SessionID = sessionIds[i]
+		if err := storeUserScore(ctx, tx, &userScore); err != nil {
+			return nil, err
+		}
+		stored[i] = userScore
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// storeUserScore inserts a score row along with its answers and sets its id.
+func storeUserScore(ctx context.Context, db dbtx, userScore *domain.UserScore) error {
+	if userScore.ScoringPolicy == "" {
+		userScore.ScoringPolicy = domain.ScoringStandard
+	}
+	sessionId := sql.NullInt64{Int64: userScore.SessionID, Valid: userScore.SessionID != 0}
+	query := "INSERT INTO scores (user_id, session_id, score, points, scoring_policy, mode, correct_answers, incorrect_answers, total_questions, time_taken_seconds, subject_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id"
+	err := db.QueryRowContext(ctx, query, userScore.UserID, sessionId, userScore.Score, userScore.Points, userScore.ScoringPolicy, userScore.Mode, userScore.CorrectAnswers, userScore.IncorrectAnswers, userScore.TotalQuestions, userScore.TimeTakenSeconds, userScore.SubjectID, userScore.CreatedAt, userScore.UpdatedAt).Scan(&userScore.ID)
+	if err != nil {
+		return err
+	}
+
+	// Answers can only be reviewed through their session
+	if userScore.SessionID == 0 {
+		return nil
+	}
+	query = "INSERT INTO quiz_answers (score_id, session_id, user_id, question_id, subject_id, position, selected_option_ids, answer_value, answer_unit, answer_text, answer_pairs, is_correct, credit, points, answered_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)"
+	for _, answer := range userScore.Answers {
+		selected := answer.SelectedOptionIds
+		if selected == nil {
+			selected = []int64{}
+		}
+		selectedOptionIds, err := json.Marshal(selected)
+		if err != nil {
+			return err
+		}
+		pairs := answer.Pairs
+		if pairs == nil {
+			pairs = []domain.MatchPair{}
+		}
+		answerPairs, err := json.Marshal(pairs)
+		if err != nil {
+			return err
+		}
+		var value sql.NullFloat64
+		if answer.Value != nil {
+			value = sql.NullFloat64{Float64: *answer.Value, Valid: true}
+		}
+		_, err = db.ExecContext(ctx, query, userScore.ID, userScore.SessionID, userScore.UserID, answer.QuestionId, answer.SubjectId, answer.Position, string(selectedOptionIds),
+			value, answer.Unit, answer.Text, string(answerPairs), answer.IsCorrect, answer.Credit, answer.Points, answer.AnsweredAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetUserScoreById returns a user's score by id.
 func (sr *scoreRepository) GetUserScoreById(ctx context.Context, id int64) (*domain.UserScore, error) {
-	query := "SELECT id, user_id, score, mode, correct_answers, incorrect_answers, total_questions, time_taken_seconds, subject_id, created_at, updated_at FROM scores WHERE id = $1"
+	query := "SELECT id, user_id, session_id, score, points, scoring_policy, mode, correct_answers, incorrect_answers, total_questions, time_taken_seconds, subject_id, created_at, updated_at FROM scores WHERE id = $1"
 	row := sr.db.QueryRowContext(ctx, query, id)
 	var userScore domain.UserScore
-	err := row.Scan(&userScore.ID, &userScore.UserID, &userScore.Score, &userScore.Mode, &userScore.CorrectAnswers, &userScore.IncorrectAnswers, &userScore.TotalQuestions, &userScore.TimeTakenSeconds, &userScore.SubjectID, &userScore.CreatedAt, &userScore.UpdatedAt)
+	var sessionId sql.NullInt64
+	err := row.Scan(&userScore.ID, &userScore.UserID, &sessionId, &userScore.Score, &userScore.Points, &userScore.ScoringPolicy, &userScore.Mode, &userScore.CorrectAnswers, &userScore.IncorrectAnswers, &userScore.TotalQuestions, &userScore.TimeTakenSeconds, &userScore.SubjectID, &userScore.CreatedAt, &userScore.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
+	userScore.SessionID = sessionId.Int64
 	return &userScore, nil
 }
 
 // GetUserOverallScoreStats returns the overall score stats for a user.
-// It returns the total number of quizzes taken, total correct answers, total incorrect answers, and total questions answered inside a UserStats struct.
+// It returns the total number of quizzes taken, total correct answers, total incorrect answers, total questions answered and total time taken inside a UserStats struct.
+// The score rows of a mixed quiz share a session and count as one quiz.
 func (sr *scoreRepository) GetUserOverallScoreStats(ctx context.Context, userID int64) (*domain.UserStats, error) {
-	query := "SELECT user_id, total_questions, correct_answers, incorrect_answers FROM scores WHERE user_id = $1"
+	query := "SELECT user_id, session_id, total_questions, correct_answers, incorrect_answers, time_taken_seconds FROM scores WHERE user_id = $1"
 	rows, err := sr.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
@@ -59,16 +195,25 @@ func (sr *scoreRepository) GetUserOverallScoreStats(ctx context.Context, userID
 		totalCorrectAnswers   int64
 		totalIncorrectAnswers int64
 		totalQuestions        int64
+		timeTakenSeconds      int64
+		sessionId             sql.NullInt64
 	)
+	seenSessions := make(map[int64]bool)
 	for rows.Next() {
-		err = rows.Scan(&userStats.UserID, &totalQuestions, &totalCorrectAnswers, &totalIncorrectAnswers)
+		err = rows.Scan(&userStats.UserID, &sessionId, &totalQuestions, &totalCorrectAnswers, &totalIncorrectAnswers, &timeTakenSeconds)
 		if err != nil {
 			return nil, err
 		}
-		totalQuizzesTaken++
+		if !sessionId.Valid || !seenSessions[sessionId.Int64] {
+			totalQuizzesTaken++
+		}
+		if sessionId.Valid {
+			seenSessions[sessionId.Int64] = true
+		}
 		userStats.TotalCorrectAnswers += totalCorrectAnswers
 		userStats.TotalIncorrectAnswers += totalIncorrectAnswers
 		userStats.TotalQuestionsAnswered += totalQuestions
+		userStats.TotalTimeTakenSeconds += timeTakenSeconds
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
@@ -76,3 +221,268 @@ func (sr *scoreRepository) GetUserOverallScoreStats(ctx context.Context, userID
 	userStats.TotalQuizzesTaken = totalQuizzesTaken
 	return &userStats, nil
 }
+
+// GetUserRecentSubjectAccuracy returns how many questions a user answered correctly, out of
+// the questions in their most recent limit quizzes for a subject.
+func (sr *scoreRepository) GetUserRecentSubjectAccuracy(ctx context.Context, userID, subjectID int64, limit int) (int64, int64, error) {
+	query := `SELECT COALESCE(SUM(correct_answers), 0), COALESCE(SUM(total_questions), 0) FROM (
+			SELECT correct_answers, total_questions FROM scores
+			WHERE user_id = $1 AND subject_id = $2
+			ORDER BY created_at DESC, id DESC
+			LIMIT $3
+		) recent`
+	var correct, total int64
+	if err := sr.db.QueryRowContext(ctx, query, userID, subjectID, limit).Scan(&correct, &total); err != nil {
+		return 0, 0, err
+	}
+	return correct, total, nil
+}
+
+// GetUserTopicAccuracy returns how a user has done on the questions of every topic they
+// have had questions from in a submitted quiz, by the topic the questions are filed under now. Topics are
+// ordered by subject and name.
+func (sr *scoreRepository) GetUserTopicAccuracy(ctx context.Context, userID int64) ([]domain.TopicAccuracy, error) {
+	query := `SELECT t.id, t.subject_id, t.parent_id, t.name, COUNT(*), SUM(CASE WHEN a.is_correct THEN 1 ELSE 0 END)
+		FROM quiz_answers a
+		JOIN questions q ON q.id = a.question_id
+		JOIN topics t ON t.id = q.topic_id
+		WHERE a.user_id = $1
+		GROUP BY t.id, t.subject_id, t.parent_id, t.name
+		ORDER BY t.subject_id, t.name`
+	rows, err := sr.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	topics := []domain.TopicAccuracy{}
+	for rows.Next() {
+		var topic domain.TopicAccuracy
+		var parentId sql.NullInt64
+		if err := rows.Scan(&topic.TopicId, &topic.SubjectId, &parentId, &topic.Topic, &topic.TotalQuestions, &topic.CorrectAnswers); err != nil {
+			return nil, err
+		}
+		topic.ParentId = parentId.Int64
+		topic.AccuracyPercent = float64(topic.CorrectAnswers) / float64(topic.TotalQuestions) * 100
+		topics = append(topics, topic)
+	}
+	return topics, rows.Err()
+}
+
+// GetUserQuizHistory returns a page of the quizzes a user submitted, most recent first,
+// along with the total number of them. The score rows of a mixed quiz are added up.
+func (sr *scoreRepository) GetUserQuizHistory(ctx context.Context, userID int64, limit, offset int) ([]domain.QuizHistoryEntry, int64, error) {
+	var total int64
+	countQuery := "SELECT COUNT(DISTINCT session_id) FROM scores WHERE user_id = $1 AND session_id IS NOT NULL"
+	if err := sr.db.QueryRowContext(ctx, countQuery, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT
+			s.session_id,
+			CASE WHEN COUNT(*) = 1 THEN MIN(s.subject_id) ELSE 0 END as subject_id,
+			qs.mode,
+			SUM(s.total_questions) as total_questions,
+			SUM(s.correct_answers) as correct_answers,
+			SUM(s.incorrect_answers) as incorrect_answers,
+			SUM(s.score) as score,
+			SUM(s.points) as points,
+			SUM(s.time_taken_seconds) as time_taken_seconds,
+			qs.submitted_at
+		FROM scores s
+		INNER JOIN quiz_sessions qs ON qs.id = s.session_id
+		WHERE s.user_id = $1
+		GROUP BY s.session_id, qs.mode, qs.submitted_at
+		ORDER BY qs.submitted_at DESC, s.session_id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := sr.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := make([]domain.QuizHistoryEntry, 0)
+	for rows.Next() {
+		var entry domain.QuizHistoryEntry
+		err := rows.Scan(&entry.SessionId, &entry.SubjectId, &entry.Mode, &entry.TotalQuestions, &entry.CorrectAnswers, &entry.IncorrectAnswers, &entry.Score, &entry.Points, &entry.TimeTakenSeconds, &entry.SubmittedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// GetSessionScores returns the score rows stored for a quiz session, one per subject.
+func (sr *scoreRepository) GetSessionScores(ctx context.Context, sessionID int64) ([]domain.UserScore, error) {
+	query := "SELECT id, user_id, session_id, score, points, scoring_policy, mode, correct_answers, incorrect_answers, total_questions, time_taken_seconds, subject_id, created_at, updated_at FROM scores WHERE session_id = $1 ORDER BY id"
+	rows, err := sr.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userScores []domain.UserScore
+	for rows.Next() {
+		var userScore domain.UserScore
+		err := rows.Scan(&userScore.ID, &userScore.UserID, &userScore.SessionID, &userScore.Score, &userScore.Points, &userScore.ScoringPolicy, &userScore.Mode, &userScore.CorrectAnswers, &userScore.IncorrectAnswers, &userScore.TotalQuestions, &userScore.TimeTakenSeconds, &userScore.SubjectID, &userScore.CreatedAt, &userScore.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		userScores = append(userScores, userScore)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return userScores, nil
+}
+
+// GetSessionAnswers returns the stored answers of a quiz session in the order the questions were issued.
+func (sr *scoreRepository) GetSessionAnswers(ctx context.Context, sessionID int64) ([]domain.QuizAnswer, error) {
+	query := "SELECT question_id, subject_id, position, selected_option_ids, answer_value, answer_unit, answer_text, answer_pairs, is_correct, credit, points, answered_at FROM quiz_answers WHERE session_id = $1 ORDER BY position"
+	rows, err := sr.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var answers []domain.QuizAnswer
+	for rows.Next() {
+		var answer domain.QuizAnswer
+		var selectedOptionIds, answerPairs string
+		var value sql.NullFloat64
+		err := rows.Scan(&answer.QuestionId, &answer.SubjectId, &answer.Position, &selectedOptionIds, &value, &answer.Unit, &answer.Text, &answerPairs, &answer.IsCorrect, &answer.Credit, &answer.Points, &answer.AnsweredAt)
+		if err != nil {
+			return nil, err
+		}
+		if value.Valid {
+			answer.Value = &value.Float64
+		}
+		if err := json.Unmarshal([]byte(selectedOptionIds), &answer.SelectedOptionIds); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(answerPairs), &answer.Pairs); err != nil {
+			return nil, err
+		}
+		answers = append(answers, answer)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return answers, nil
+}
+
+// GetUserAnsweredQuestionsSince returns how many questions of each subject the user
+// answered in each quiz they submitted since a time, oldest first.
+func (sr *scoreRepository) GetUserAnsweredQuestionsSince(ctx context.Context, userID int64, since time.Time) ([]AnsweredQuestions, error) {
+	query := "SELECT subject_id, correct_answers, total_questions, created_at FROM scores WHERE user_id = $1 AND created_at >= $2 ORDER BY created_at, id"
+	rows, err := sr.db.QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answered := []AnsweredQuestions{}
+	for rows.Next() {
+		var quiz AnsweredQuestions
+		if err := rows.Scan(&quiz.SubjectId, &quiz.CorrectAnswers, &quiz.TotalQuestions, &quiz.AnsweredAt); err != nil {
+			return nil, err
+		}
+		answered = append(answered, quiz)
+	}
+	return answered, rows.Err()
+}
+
+// GetUserSubjectStats returns the user's totals in every subject they have answered
+// questions in, ordered by subject, with where their accuracy in it places them among
+// every user who has. Percentile is PERCENT_RANK over the users' accuracy in the subject,
+// so it is the share of the other users whose accuracy is lower.
+func (sr *scoreRepository) GetUserSubjectStats(ctx context.Context, userID int64) ([]domain.SubjectAnalytics, error) {
+	query := `WITH per_user AS (
+			SELECT user_id, subject_id, COUNT(*) AS quizzes, SUM(correct_answers) AS correct,
+				SUM(total_questions) AS total, SUM(time_taken_seconds) AS time_taken
+			FROM scores
+			WHERE subject_id IN (SELECT DISTINCT subject_id FROM scores WHERE user_id = $1)
+			GROUP BY user_id, subject_id
+			HAVING SUM(total_questions) > 0
+		), ranked AS (
+			SELECT user_id, subject_id, quizzes, correct, total, time_taken,
+				PERCENT_RANK() OVER (PARTITION BY subject_id ORDER BY correct * 1.0 / total) AS percent_rank,
+				COUNT(*) OVER (PARTITION BY subject_id) AS users
+			FROM per_user
+		)
+		SELECT subject_id, quizzes, correct, total, time_taken, percent_rank, users
+		FROM ranked
+		WHERE user_id = $1
+		ORDER BY subject_id`
+	rows, err := sr.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := []domain.SubjectAnalytics{}
+	for rows.Next() {
+		var subject domain.SubjectAnalytics
+		var percentRank float64
+		err := rows.Scan(&subject.SubjectId, &subject.QuizzesTaken, &subject.CorrectAnswers, &subject.QuestionsAnswered,
+			&subject.TimeTakenSeconds, &percentRank, &subject.UsersRanked)
+		if err != nil {
+			return nil, err
+		}
+		subject.Accuracy = float64(subject.CorrectAnswers) / float64(subject.QuestionsAnswered) * 100
+		subject.AvgSecondsPerQuestion = float64(subject.TimeTakenSeconds) / float64(subject.QuestionsAnswered)
+		subject.Percentile = percentRank * 100
+		subjects = append(subjects, subject)
+	}
+	return subjects, rows.Err()
+}
+
+// GetUserAccuracyWindows returns, for every subject the user has taken quizzes in and for
+// all of them together, how they did over their last quizzes and the quizzes before
+// those, numbering the quizzes newest first. A subject's quizzes are its score rows; all
+// of them together are numbered by session, so the rows of a mixed quiz count once.
+func (sr *scoreRepository) GetUserAccuracyWindows(ctx context.Context, userID int64, quizzes int) ([]AccuracyWindows, error) {
+	query := `WITH recent AS (
+			SELECT subject_id, correct_answers, total_questions,
+				ROW_NUMBER() OVER (PARTITION BY subject_id ORDER BY created_at DESC, id DESC) AS subject_position,
+				DENSE_RANK() OVER (ORDER BY created_at DESC, COALESCE(session_id, -id) DESC) AS position
+			FROM scores
+			WHERE user_id = $1
+		)
+		SELECT subject_id,
+			SUM(CASE WHEN subject_position <= $2 THEN correct_answers ELSE 0 END),
+			SUM(CASE WHEN subject_position <= $2 THEN total_questions ELSE 0 END),
+			SUM(CASE WHEN subject_position > $2 THEN correct_answers ELSE 0 END),
+			SUM(CASE WHEN subject_position > $2 THEN total_questions ELSE 0 END)
+		FROM recent
+		WHERE subject_position <= $3
+		GROUP BY subject_id
+		UNION ALL
+		SELECT 0,
+			COALESCE(SUM(CASE WHEN position <= $2 THEN correct_answers ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN position <= $2 THEN total_questions ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN position > $2 THEN correct_answers ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN position > $2 THEN total_questions ELSE 0 END), 0)
+		FROM recent
+		WHERE position <= $3`
+	rows, err := sr.db.QueryContext(ctx, query, userID, quizzes, quizzes*2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []AccuracyWindows{}
+	for rows.Next() {
+		var window AccuracyWindows
+		if err := rows.Scan(&window.SubjectId, &window.RecentCorrect, &window.RecentTotal, &window.PreviousCorrect, &window.PreviousTotal); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, rows.Err()
+}
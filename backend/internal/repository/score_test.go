@@ -34,6 +34,44 @@ func TestStoreUserScore(t *testing.T) {
 	assert.NotNil(t, newScore)
 }
 
+func TestStoreSessionScores(t *testing.T) {
+	pool := setUpDB(t)
+	ss := NewScoreRepository(pool)
+	qsr := NewQuizSessionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var sessions []QuizSession
+	var userScores []domain.UserScore
+	for userId := int64(1); userId <= 3; userId++ {
+		sessions = append(sessions, QuizSession{
+			UserId:      userId,
+			SubjectId:   1,
+			Status:      QuizSessionSubmitted,
+			Mode:        domain.ModeLive,
+			Questions:   []QuizSessionQuestion{{QuestionId: 7, SubjectId: 1, Position: 0, OptionIds: []int64{21, 22}}},
+			SubmittedAt: &now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		userScores = append(userScores, domain.UserScore{UserID: userId, SubjectID: 1, Score: userId, Mode: domain.ModeLive, TotalQuestions: 1, CreatedAt: now, UpdatedAt: now})
+	}
+
+	stored, err := ss.StoreSessionScores(ctx, sessions, userScores)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 3)
+	// each score is stored against the session of its own user
+	for _, userScore := range stored {
+		session, err := qsr.GetQuizSessionById(ctx, userScore.SessionID)
+		assert.NoError(t, err)
+		assert.Equal(t, userScore.UserID, session.UserId)
+		assert.Equal(t, QuizSessionSubmitted, session.Status)
+		assert.NotNil(t, session.SubmittedAt)
+		assert.Equal(t, []int64{21, 22}, session.Questions[0].OptionIds)
+	}
+}
+
 func TestGetUserScoreById(t *testing.T) {
 	pool := setUpDB(t)
 	ss := NewScoreRepository(pool)
@@ -108,3 +146,211 @@ func TestGetOverallScoreByUserID(t *testing.T) {
 	assert.Equal(t, stats.TotalQuestionsAnswered, int64(20))
 	fmt.Printf("user stats: %+v\n", stats)
 }
+
+func TestStoreUserScoresCountsSessionOnce(t *testing.T) {
+	pool := setUpDB(t)
+	ss := NewScoreRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a mixed quiz stores one row per subject under the same session
+	stored, err := ss.StoreUserScores(ctx, []domain.UserScore{
+		{UserID: 1, SessionID: 5, SubjectID: 1, Score: 3, CorrectAnswers: 3, IncorrectAnswers: 1, TotalQuestions: 4, TimeTakenSeconds: 40, Mode: domain.ModePractice, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{UserID: 1, SessionID: 5, SubjectID: 2, Score: 1, CorrectAnswers: 1, IncorrectAnswers: 1, TotalQuestions: 2, TimeTakenSeconds: 20, Mode: domain.ModePractice, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, stored, 2)
+	assert.NotEqual(t, stored[0].ID, stored[1].ID)
+
+	_, err = ss.StoreUserScore(ctx, domain.UserScore{UserID: 1, SubjectID: 1, Score: 2, CorrectAnswers: 2, TotalQuestions: 2, TimeTakenSeconds: 10, Mode: domain.ModePractice, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	assert.NoError(t, err)
+
+	userScore, err := ss.GetUserScoreById(ctx, stored[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), userScore.SessionID)
+
+	stats, err := ss.GetUserOverallScoreStats(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalQuizzesTaken)
+	assert.Equal(t, int64(8), stats.TotalQuestionsAnswered)
+	assert.Equal(t, int64(70), stats.TotalTimeTakenSeconds)
+}
+
+func TestGetSessionAnswers(t *testing.T) {
+	pool := setUpDB(t)
+	ss := NewScoreRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := pool.Exec("INSERT INTO quiz_sessions (user_id, subject_id, status, mode, submitted_at, created_at, updated_at) VALUES (1, 1, 'submitted', 'practice', $1, $1, $1)", time.Now())
+	assert.NoError(t, err)
+
+	value := 9.8
+	stored, err := ss.StoreUserScores(ctx, []domain.UserScore{
+		{
+			UserID: 1, SessionID: 1, SubjectID: 1, Score: 1, CorrectAnswers: 1, IncorrectAnswers: 1, TotalQuestions: 4, TimeTakenSeconds: 30, Mode: domain.ModePractice, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+			Answers: []domain.QuizAnswer{
+				{QuestionId: 7, SubjectId: 1, Position: 1, SelectedOptionIds: []int64{30}, Credit: 0, Points: 0},
+				{QuestionId: 4, SubjectId: 1, Position: 0, SelectedOptionIds: []int64{12, 13}, IsCorrect: true, Credit: 1, Points: 1},
+				{QuestionId: 9, SubjectId: 1, Position: 2, Value: &value, Unit: "m/s^2"},
+				{QuestionId: 11, SubjectId: 1, Position: 3, Text: "newtons"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, stored, 1)
+
+	answers, err := ss.GetSessionAnswers(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, answers, 4)
+	assert.Equal(t, int64(4), answers[0].QuestionId)
+	assert.Equal(t, []int64{12, 13}, answers[0].SelectedOptionIds)
+	assert.Nil(t, answers[0].Value)
+	assert.True(t, answers[0].IsCorrect)
+	assert.Equal(t, int64(7), answers[1].QuestionId)
+	assert.False(t, answers[1].IsCorrect)
+	assert.Equal(t, &value, answers[2].Value)
+	assert.Equal(t, "m/s^2", answers[2].Unit)
+	assert.Empty(t, answers[2].SelectedOptionIds)
+	assert.Equal(t, "newtons", answers[3].Text)
+
+	scores, err := ss.GetSessionScores(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, scores, 1)
+	assert.Equal(t, stored[0].ID, scores[0].ID)
+
+	history, total, err := ss.GetUserQuizHistory(ctx, 1, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, history, 1)
+	assert.Equal(t, int64(1), history[0].SessionId)
+	assert.Equal(t, domain.ModePractice, history[0].Mode)
+	assert.Equal(t, int64(4), history[0].TotalQuestions)
+}
+
+func TestGetUserTopicAccuracy(t *testing.T) {
+	pool := setUpDB(t)
+	ss := NewScoreRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// questions 1 and 2 are on algebra, 3 on its quadratics subtopic and 4 has no topic
+	for _, query := range []string{
+		"INSERT INTO topics (subject_id, parent_id, name) VALUES (1, NULL, 'Algebra'), (1, 1, 'Quadratics')",
+		"INSERT INTO questions (subject_id, topic_id, question) VALUES (1, 1, 'a'), (1, 1, 'b'), (1, 2, 'c'), (1, NULL, 'd')",
+	} {
+		_, err := pool.ExecContext(ctx, query)
+		assert.NoError(t, err)
+	}
+	answers := []domain.QuizAnswer{
+		{QuestionId: 1, SubjectId: 1, Position: 0, IsCorrect: true, Credit: 1},
+		{QuestionId: 2, SubjectId: 1, Position: 1},
+		{QuestionId: 3, SubjectId: 1, Position: 2, IsCorrect: true, Credit: 1},
+		{QuestionId: 4, SubjectId: 1, Position: 3, IsCorrect: true, Credit: 1},
+	}
+	for sessionId, userId := range []int64{1, 1, 2} {
+		_, err := ss.StoreUserScore(ctx, domain.UserScore{UserID: userId, SessionID: int64(sessionId + 1), SubjectID: 1, TotalQuestions: 4, Answers: answers, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		assert.NoError(t, err)
+	}
+
+	topics, err := ss.GetUserTopicAccuracy(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.TopicAccuracy{
+		{TopicId: 1, SubjectId: 1, Topic: "Algebra", TotalQuestions: 4, CorrectAnswers: 2, AccuracyPercent: 50},
+		{TopicId: 2, SubjectId: 1, ParentId: 1, Topic: "Quadratics", TotalQuestions: 2, CorrectAnswers: 2, AccuracyPercent: 100},
+	}, topics)
+
+	topics, err = ss.GetUserTopicAccuracy(ctx, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, topics)
+}
+
+func TestGetUserSubjectStats(t *testing.T) {
+	pool := setUpDB(t)
+	ss := NewScoreRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// users 1, 2 and 3 get 5, 8 and 10 of 10 right in subject 1; only user 1 has taken subject 2
+	for _, score := range []domain.UserScore{
+		{UserID: 1, SubjectID: 1, CorrectAnswers: 2, TotalQuestions: 4, TimeTakenSeconds: 40},
+		{UserID: 1, SubjectID: 1, CorrectAnswers: 3, TotalQuestions: 6, TimeTakenSeconds: 20},
+		{UserID: 2, SubjectID: 1, CorrectAnswers: 8, TotalQuestions: 10, TimeTakenSeconds: 50},
+		{UserID: 3, SubjectID: 1, CorrectAnswers: 10, TotalQuestions: 10, TimeTakenSeconds: 100},
+		{UserID: 1, SubjectID: 2, CorrectAnswers: 1, TotalQuestions: 2, TimeTakenSeconds: 30},
+	} {
+		score.Mode, score.CreatedAt, score.UpdatedAt = domain.ModePractice, time.Now(), time.Now()
+		_, err := ss.StoreUserScore(ctx, score)
+		assert.NoError(t, err)
+	}
+
+	subjects, err := ss.GetUserSubjectStats(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.SubjectAnalytics{
+		{SubjectId: 1, QuizzesTaken: 2, QuestionsAnswered: 10, CorrectAnswers: 5, Accuracy: 50, TimeTakenSeconds: 60, AvgSecondsPerQuestion: 6, Percentile: 0, UsersRanked: 3},
+		{SubjectId: 2, QuizzesTaken: 1, QuestionsAnswered: 2, CorrectAnswers: 1, Accuracy: 50, TimeTakenSeconds: 30, AvgSecondsPerQuestion: 15, Percentile: 0, UsersRanked: 1},
+	}, subjects)
+
+	subjects, err = ss.GetUserSubjectStats(ctx, 2)
+	assert.NoError(t, err)
+	assert.Len(t, subjects, 1)
+	assert.Equal(t, float64(50), subjects[0].Percentile)
+	subjects, err = ss.GetUserSubjectStats(ctx, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), subjects[0].Percentile)
+
+	subjects, err = ss.GetUserSubjectStats(ctx, 4)
+	assert.NoError(t, err)
+	assert.Empty(t, subjects)
+}
+
+func TestGetUserAccuracyWindows(t *testing.T) {
+	pool := setUpDB(t)
+	ss := NewScoreRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	windows, err := ss.GetUserAccuracyWindows(ctx, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []AccuracyWindows{{}}, windows)
+
+	// oldest first: the oldest subject 1 quiz falls outside both windows
+	start := time.Now().Add(-time.Hour)
+	for i, score := range []domain.UserScore{
+		{SubjectID: 1, CorrectAnswers: 0, TotalQuestions: 4},
+		{SubjectID: 1, CorrectAnswers: 1, TotalQuestions: 4},
+		{SubjectID: 2, CorrectAnswers: 4, TotalQuestions: 4},
+		{SubjectID: 1, CorrectAnswers: 2, TotalQuestions: 4},
+		{SubjectID: 1, CorrectAnswers: 3, TotalQuestions: 4},
+		{SubjectID: 1, CorrectAnswers: 4, TotalQuestions: 4},
+	} {
+		score.UserID, score.Mode = 1, domain.ModePractice
+		score.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		score.UpdatedAt = score.CreatedAt
+		_, err := ss.StoreUserScore(ctx, score)
+		assert.NoError(t, err)
+	}
+
+	windows, err = ss.GetUserAccuracyWindows(ctx, 1, 2)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []AccuracyWindows{
+		{SubjectId: 1, RecentCorrect: 7, RecentTotal: 8, PreviousCorrect: 3, PreviousTotal: 8},
+		{SubjectId: 2, RecentCorrect: 4, RecentTotal: 4},
+		{SubjectId: 0, RecentCorrect: 7, RecentTotal: 8, PreviousCorrect: 6, PreviousTotal: 8},
+	}, windows)
+
+	// the score rows of a mixed quiz count as one quiz overall, and as one in each subject
+	mixedAt := start.Add(time.Hour)
+	_, err = ss.StoreUserScores(ctx, []domain.UserScore{
+		{UserID: 1, SessionID: 7, SubjectID: 1, Mode: domain.ModePractice, CorrectAnswers: 1, TotalQuestions: 2, CreatedAt: mixedAt, UpdatedAt: mixedAt},
+		{UserID: 1, SessionID: 7, SubjectID: 2, Mode: domain.ModePractice, CorrectAnswers: 2, TotalQuestions: 2, CreatedAt: mixedAt, UpdatedAt: mixedAt},
+	})
+	assert.NoError(t, err)
+	windows, err = ss.GetUserAccuracyWindows(ctx, 1, 2)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []AccuracyWindows{
+		{SubjectId: 1, RecentCorrect: 5, RecentTotal: 6, PreviousCorrect: 5, PreviousTotal: 8},
+		{SubjectId: 2, RecentCorrect: 6, RecentTotal: 6},
+		{SubjectId: 0, RecentCorrect: 7, RecentTotal: 8, PreviousCorrect: 5, PreviousTotal: 8},
+	}, windows)
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+)
+
+type StreakRepository interface {
+	GetUserStreak(ctx context.Context, userId int64) (*domain.Streak, error)
+	SaveUserStreak(ctx context.Context, userId int64, streak domain.Streak, updatedAt time.Time) error
+	UpdateUserStreak(ctx context.Context, userId int64, update func(domain.Streak) domain.Streak, updatedAt time.Time) error
+}
+
+type streakRepository struct {
+	db *sql.DB
+}
+
+func NewStreakRepository(db *sql.DB) StreakRepository {
+	return &streakRepository{db: db}
+}
+
+// GetUserStreak returns the user's streak as it was last saved, or an empty streak for a
+// user who has never taken a daily challenge.
+func (sr *streakRepository) GetUserStreak(ctx context.Context, userId int64) (*domain.Streak, error) {
+	query := "SELECT current_streak, longest_streak, freezes, last_active_on FROM user_streaks WHERE user_id = $1"
+	var streak domain.Streak
+	var lastActiveOn sql.NullTime
+	err := sr.db.QueryRowContext(ctx, query, userId).Scan(&streak.Current, &streak.Longest, &streak.Freezes, &lastActiveOn)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &domain.Streak{}, nil
+		}
+		return nil, err
+	}
+	if lastActiveOn.Valid {
+		streak.LastActiveOn = &lastActiveOn.Time
+	}
+	return &streak, nil
+}
+
+// SaveUserStreak stores the user's streak, replacing the one saved before.
+func (sr *streakRepository) SaveUserStreak(ctx context.Context, userId int64, streak domain.Streak, updatedAt time.Time) error {
+	var lastActiveOn sql.NullString
+	if streak.LastActiveOn != nil {
+		lastActiveOn = sql.NullString{String: streak.LastActiveOn.Format(time.DateOnly), Valid: true}
+	}
+	query := `INSERT INTO user_streaks (user_id, current_streak, longest_streak, freezes, last_active_on, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET current_streak = excluded.current_streak, longest_streak = excluded.longest_streak,
+			freezes = excluded.freezes, last_active_on = excluded.last_active_on, updated_at = excluded.updated_at`
+	_, err := sr.db.ExecContext(ctx, query, userId, streak.Current, streak.Longest, streak.Freezes, lastActiveOn, updatedAt)
+	return err
+}
+
+// UpdateUserStreak saves the streak update makes of the user's current one. The streak is
+// read with its row locked, creating it if the user has none, and saved in the same
+// transaction, so two updates at once are applied one after the other rather than one
+// overwriting the other.
+func (sr *streakRepository) UpdateUserStreak(ctx context.Context, userId int64, update func(domain.Streak) domain.Streak, updatedAt time.Time) error {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The upsert takes the row's lock, or waits for it, and returns it as the last update left it
+	query := `INSERT INTO user_streaks (user_id, current_streak, longest_streak, freezes, updated_at) VALUES ($1, 0, 0, 0, $2)
+		ON CONFLICT (user_id) DO UPDATE SET updated_at = excluded.updated_at
+		RETURNING current_streak, longest_streak, freezes, last_active_on`
+	var streak domain.Streak
+	var lastActiveOn sql.NullTime
+	if err := tx.QueryRowContext(ctx, query, userId, updatedAt).Scan(&streak.Current, &streak.Longest, &streak.Freezes, &lastActiveOn); err != nil {
+		return err
+	}
+	if lastActiveOn.Valid {
+		streak.LastActiveOn = &lastActiveOn.Time
+	}
+
+	streak = update(streak)
+	var newLastActiveOn sql.NullString
+	if streak.LastActiveOn != nil {
+		newLastActiveOn = sql.NullString{String: streak.LastActiveOn.Format(time.DateOnly), Valid: true}
+	}
+	query = "UPDATE user_streaks SET current_streak = $1, longest_streak = $2, freezes = $3, last_active_on = $4, updated_at = $5 WHERE user_id = $6"
+	if _, err := tx.ExecContext(ctx, query, streak.Current, streak.Longest, streak.Freezes, newLastActiveOn, updatedAt, userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// StudyPlan is a user's study goal. A user has at most one.
+type StudyPlan struct {
+	Id             int64     `json:"id"`
+	UserId         int64     `json:"user_id"`
+	ExamDate       time.Time `json:"exam_date"`
+	SubjectIds     []int64   `json:"subject_ids"`
+	TargetAccuracy float64   `json:"target_accuracy"`
+	DailyQuestions int64     `json:"daily_questions"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// StudyPlanDay is the schedule of a day of a study plan, fixed the first time it was
+// worked out.
+type StudyPlanDay struct {
+	Day   time.Time          `json:"day"`
+	Tasks []domain.StudyTask `json:"tasks"`
+}
+
+type StudyPlanRepository interface {
+	SaveStudyPlan(ctx context.Context, plan StudyPlan) (int64, error)
+	GetUserStudyPlan(ctx context.Context, userId int64) (*StudyPlan, error)
+	DeleteUserStudyPlan(ctx context.Context, userId int64) error
+	GetStudyPlanDays(ctx context.Context, planId int64) ([]StudyPlanDay, error)
+	SaveStudyPlanDay(ctx context.Context, planId int64, day StudyPlanDay, createdAt time.Time) (*StudyPlanDay, error)
+}
+
+type studyPlanRepository struct {
+	db *sql.DB
+}
+
+func NewStudyPlanRepository(db *sql.DB) StudyPlanRepository {
+	return &studyPlanRepository{db: db}
+}
+
+// SaveStudyPlan stores a user's study plan, replacing the one they had along with its
+// schedule. Its subject ids are kept as JSON.
+func (sr *studyPlanRepository) SaveStudyPlan(ctx context.Context, plan StudyPlan) (int64, error) {
+	subjectIds, err := json.Marshal(plan.SubjectIds)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := deleteUserStudyPlan(ctx, tx, plan.UserId); err != nil {
+		return 0, err
+	}
+	query := `INSERT INTO study_plans (user_id, exam_date, subject_ids, target_accuracy, daily_questions, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	var id int64
+	err = tx.QueryRowContext(ctx, query, plan.UserId, plan.ExamDate.Format(time.DateOnly), string(subjectIds),
+		plan.TargetAccuracy, plan.DailyQuestions, plan.CreatedAt, plan.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetUserStudyPlan returns the user's study plan, or ErrStudyPlanNotFound if they have none.
+func (sr *studyPlanRepository) GetUserStudyPlan(ctx context.Context, userId int64) (*StudyPlan, error) {
+	query := `SELECT id, user_id, exam_date, subject_ids, target_accuracy, daily_questions, created_at, updated_at
+		FROM study_plans WHERE user_id = $1`
+	var plan StudyPlan
+	var subjectIds string
+	err := sr.db.QueryRowContext(ctx, query, userId).Scan(&plan.Id, &plan.UserId, &plan.ExamDate, &subjectIds,
+		&plan.TargetAccuracy, &plan.DailyQuestions, &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, pkg.ErrStudyPlanNotFound
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(subjectIds), &plan.SubjectIds); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// DeleteUserStudyPlan removes the user's study plan and its schedule, or returns
+// ErrStudyPlanNotFound if they have none.
+func (sr *studyPlanRepository) DeleteUserStudyPlan(ctx context.Context, userId int64) error {
+	if _, err := sr.GetUserStudyPlan(ctx, userId); err != nil {
+		return err
+	}
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := deleteUserStudyPlan(ctx, tx, userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteUserStudyPlan(ctx context.Context, tx *sql.Tx, userId int64) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM study_plan_days WHERE plan_id IN (SELECT id FROM study_plans WHERE user_id = $1)", userId); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "DELETE FROM study_plans WHERE user_id = $1", userId)
+	return err
+}
+
+// GetStudyPlanDays returns the days of a study plan whose schedule has been worked out,
+// oldest first.
+func (sr *studyPlanRepository) GetStudyPlanDays(ctx context.Context, planId int64) ([]StudyPlanDay, error) {
+	rows, err := sr.db.QueryContext(ctx, "SELECT day, tasks FROM study_plan_days WHERE plan_id = $1 ORDER BY day", planId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []StudyPlanDay{}
+	for rows.Next() {
+		var day StudyPlanDay
+		var tasks string
+		if err := rows.Scan(&day.Day, &tasks); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tasks), &day.Tasks); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// SaveStudyPlanDay fixes the schedule of a day of a study plan, unless it has been fixed
+// already, and returns the schedule the day has. Its tasks are kept as JSON.
+func (sr *studyPlanRepository) SaveStudyPlanDay(ctx context.Context, planId int64, day StudyPlanDay, createdAt time.Time) (*StudyPlanDay, error) {
+	tasks, err := json.Marshal(day.Tasks)
+	if err != nil {
+		return nil, err
+	}
+	date := day.Day.Format(time.DateOnly)
+	query := "INSERT INTO study_plan_days (plan_id, day, tasks, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (plan_id, day) DO NOTHING"
+	if _, err := sr.db.ExecContext(ctx, query, planId, date, string(tasks), createdAt); err != nil {
+		return nil, err
+	}
+
+	var saved StudyPlanDay
+	var savedTasks string
+	err = sr.db.QueryRowContext(ctx, "SELECT day, tasks FROM study_plan_days WHERE plan_id = $1 AND day = $2", planId, date).Scan(&saved.Day, &savedTasks)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(savedTasks), &saved.Tasks); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/lawson/otterprep/domain"
 )
 
 type SubjectRepository interface {
@@ -15,6 +17,16 @@ type SubjectRepository interface {
 	GetSubjects(ctx context.Context) ([]Subject, error)
 	CreateSubject(ctx context.Context, subject Subject) (int64, error)
 	UpdateSubjectById(ctx context.Context, id int64, subject Subject) (*Subject, error)
+	GetSubjectScoringPolicy(ctx context.Context, subjectId int64) (*domain.ScoringPolicy, error)
+	SetSubjectScoringPolicy(ctx context.Context, subjectId int64, policy domain.ScoringPolicy) error
+	CreateTopic(ctx context.Context, topic Topic) (int64, error)
+	GetTopicById(ctx context.Context, id int64) (*Topic, error)
+	GetSubjectTopics(ctx context.Context, subjectId int64) ([]Topic, error)
+	UpdateTopicName(ctx context.Context, id int64, name string) error
+	DeleteTopicById(ctx context.Context, id int64) error
+	CreatePastPaper(ctx context.Context, paper PastPaper) (int64, error)
+	GetPastPaperById(ctx context.Context, id int64) (*PastPaper, error)
+	GetSubjectPastPapers(ctx context.Context, subjectId int64) ([]PastPaper, error)
 }
 
 type Subject struct {
@@ -24,6 +36,33 @@ type Subject struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Topic is a topic within a subject. ParentId is 0 for a topic at the top of its subject.
+// Questions counts the questions filed directly under the topic, and is only set by
+// GetSubjectTopics.
+type Topic struct {
+	Id        int64     `json:"id"`
+	SubjectId int64     `json:"subject_id"`
+	ParentId  int64     `json:"parent_id"`
+	Name      string    `json:"name"`
+	Questions int64     `json:"questions"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PastPaper is a paper of a subject set by an exam body in a year. Questions counts the
+// questions of the paper, and is only set by GetSubjectPastPapers.
+type PastPaper struct {
+	Id              int64     `json:"id"`
+	SubjectId       int64     `json:"subject_id"`
+	ExamBody        string    `json:"exam_body"`
+	Year            int       `json:"year"`
+	Paper           string    `json:"paper"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	Questions       int64     `json:"questions"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
 func NewSubjectRepository(db *sql.DB) *subjectRepository {
 	return &subjectRepository{db: db}
 }
@@ -117,3 +156,144 @@ func (sr *subjectRepository) GetSubjects(ctx context.Context) ([]Subject, error)
 	}
 	return subjects, nil
 }
+
+// GetSubjectScoringPolicy returns the scoring policy configured for a subject.
+// Subjects without a configured policy use domain.DefaultScoringPolicy.
+func (sr *subjectRepository) GetSubjectScoringPolicy(ctx context.Context, subjectId int64) (*domain.ScoringPolicy, error) {
+	query := "SELECT name, wrong_penalty, unanswered_penalty FROM subject_scoring_policies WHERE subject_id = $1"
+	var policy domain.ScoringPolicy
+	err := sr.db.QueryRowContext(ctx, query, subjectId).Scan(&policy.Name, &policy.WrongPenalty, &policy.UnansweredPenalty)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			policy = domain.DefaultScoringPolicy()
+			return &policy, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SetSubjectScoringPolicy creates or replaces the scoring policy of a subject.
+func (sr *subjectRepository) SetSubjectScoringPolicy(ctx context.Context, subjectId int64, policy domain.ScoringPolicy) error {
+	now := time.Now()
+	query := `INSERT INTO subject_scoring_policies (subject_id, name, wrong_penalty, unanswered_penalty, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (subject_id) DO UPDATE SET name = excluded.name, wrong_penalty = excluded.wrong_penalty,
+			unanswered_penalty = excluded.unanswered_penalty, updated_at = excluded.updated_at`
+	_, err := sr.db.ExecContext(ctx, query, subjectId, policy.Name, policy.WrongPenalty, policy.UnansweredPenalty, now, now)
+	return err
+}
+
+// CreateTopic stores a topic and returns its id.
+func (sr *subjectRepository) CreateTopic(ctx context.Context, topic Topic) (int64, error) {
+	parentId := sql.NullInt64{Int64: topic.ParentId, Valid: topic.ParentId != 0}
+	query := "INSERT INTO topics (subject_id, parent_id, name, created_at, updated_at) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	var id int64
+	err := sr.db.QueryRowContext(ctx, query, topic.SubjectId, parentId, topic.Name, topic.CreatedAt, topic.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetTopicById returns a topic by id.
+func (sr *subjectRepository) GetTopicById(ctx context.Context, id int64) (*Topic, error) {
+	query := "SELECT id, subject_id, parent_id, name, created_at, updated_at FROM topics WHERE id = $1"
+	var topic Topic
+	var parentId sql.NullInt64
+	err := sr.db.QueryRowContext(ctx, query, id).Scan(&topic.Id, &topic.SubjectId, &parentId, &topic.Name, &topic.CreatedAt, &topic.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	topic.ParentId = parentId.Int64
+	return &topic, nil
+}
+
+// GetSubjectTopics returns every topic of a subject, nested or not, in order of name,
+// along with how many questions are filed directly under each.
+func (sr *subjectRepository) GetSubjectTopics(ctx context.Context, subjectId int64) ([]Topic, error) {
+	query := `SELECT t.id, t.subject_id, t.parent_id, t.name, COUNT(q.id), t.created_at, t.updated_at
+		FROM topics t
+		LEFT JOIN questions q ON q.topic_id = t.id
+		WHERE t.subject_id = $1
+		GROUP BY t.id, t.subject_id, t.parent_id, t.name, t.created_at, t.updated_at
+		ORDER BY t.name, t.id`
+	rows, err := sr.db.QueryContext(ctx, query, subjectId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var topics []Topic
+	for rows.Next() {
+		var topic Topic
+		var parentId sql.NullInt64
+		if err := rows.Scan(&topic.Id, &topic.SubjectId, &parentId, &topic.Name, &topic.Questions, &topic.CreatedAt, &topic.UpdatedAt); err != nil {
+			return nil, err
+		}
+		topic.ParentId = parentId.Int64
+		topics = append(topics, topic)
+	}
+	return topics, rows.Err()
+}
+
+// UpdateTopicName renames a topic.
+func (sr *subjectRepository) UpdateTopicName(ctx context.Context, id int64, name string) error {
+	query := "UPDATE topics SET name = $1, updated_at = $2 WHERE id = $3"
+	_, err := sr.db.ExecContext(ctx, query, name, time.Now(), id)
+	return err
+}
+
+// DeleteTopicById deletes a topic. The topics below it are deleted along with it, and
+// the questions filed under any of them are left without a topic.
+func (sr *subjectRepository) DeleteTopicById(ctx context.Context, id int64) error {
+	query := "DELETE FROM topics WHERE id = $1"
+	_, err := sr.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// CreatePastPaper stores a past paper and returns its id.
+func (sr *subjectRepository) CreatePastPaper(ctx context.Context, paper PastPaper) (int64, error) {
+	query := "INSERT INTO past_papers (subject_id, exam_body, year, paper, duration_seconds, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+	var id int64
+	err := sr.db.QueryRowContext(ctx, query, paper.SubjectId, paper.ExamBody, paper.Year, paper.Paper, paper.DurationSeconds, paper.CreatedAt, paper.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetPastPaperById returns a past paper by id.
+func (sr *subjectRepository) GetPastPaperById(ctx context.Context, id int64) (*PastPaper, error) {
+	query := "SELECT id, subject_id, exam_body, year, paper, duration_seconds, created_at, updated_at FROM past_papers WHERE id = $1"
+	var paper PastPaper
+	err := sr.db.QueryRowContext(ctx, query, id).Scan(&paper.Id, &paper.SubjectId, &paper.ExamBody, &paper.Year, &paper.Paper, &paper.DurationSeconds, &paper.CreatedAt, &paper.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &paper, nil
+}
+
+// GetSubjectPastPapers returns the past papers of a subject, most recent year first,
+// along with how many of their questions have been added.
+func (sr *subjectRepository) GetSubjectPastPapers(ctx context.Context, subjectId int64) ([]PastPaper, error) {
+	query := `SELECT p.id, p.subject_id, p.exam_body, p.year, p.paper, p.duration_seconds, COUNT(q.id), p.created_at, p.updated_at
+		FROM past_papers p
+		LEFT JOIN questions q ON q.paper_id = p.id
+		WHERE p.subject_id = $1
+		GROUP BY p.id, p.subject_id, p.exam_body, p.year, p.paper, p.duration_seconds, p.created_at, p.updated_at
+		ORDER BY p.year DESC, p.exam_body, p.paper, p.id`
+	rows, err := sr.db.QueryContext(ctx, query, subjectId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var papers []PastPaper
+	for rows.Next() {
+		var paper PastPaper
+		if err := rows.Scan(&paper.Id, &paper.SubjectId, &paper.ExamBody, &paper.Year, &paper.Paper, &paper.DurationSeconds, &paper.Questions, &paper.CreatedAt, &paper.UpdatedAt); err != nil {
+			return nil, err
+		}
+		papers = append(papers, paper)
+	}
+	return papers, rows.Err()
+}
@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/pkg"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -61,3 +63,132 @@ func TestUpdateSubjectById(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, updatedSubject.Name, "updated")
 }
+
+func TestSubjectScoringPolicy(t *testing.T) {
+	pool := setUP(t)
+	repo := NewSubjectRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	id, err := repo.CreateSubject(ctx, Subject{
+		Name:      "test",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	assert.Nil(t, err)
+
+	// subjects without a policy get the default one
+	policy, err := repo.GetSubjectScoringPolicy(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.DefaultScoringPolicy(), *policy)
+
+	err = repo.SetSubjectScoringPolicy(ctx, id, domain.ScoringPolicy{Name: domain.ScoringNegativeMarking, WrongPenalty: 0.25})
+	assert.Nil(t, err)
+	// setting it again replaces the existing policy
+	err = repo.SetSubjectScoringPolicy(ctx, id, domain.ScoringPolicy{Name: domain.ScoringNegativeMarking, WrongPenalty: 0.5, UnansweredPenalty: 0.1})
+	assert.Nil(t, err)
+
+	policy, err = repo.GetSubjectScoringPolicy(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ScoringNegativeMarking, policy.Name)
+	assert.Equal(t, 0.5, policy.WrongPenalty)
+	assert.Equal(t, 0.1, policy.UnansweredPenalty)
+}
+
+func TestTopics(t *testing.T) {
+	pool := setUP(t)
+	defer pool.Close()
+	sr := NewSubjectRepository(pool)
+	qr := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	algebra, err := sr.CreateTopic(ctx, Topic{SubjectId: 1, Name: "Algebra", CreatedAt: now, UpdatedAt: now})
+	assert.Nil(t, err)
+	quadratics, err := sr.CreateTopic(ctx, Topic{SubjectId: 1, ParentId: algebra, Name: "Quadratics", CreatedAt: now, UpdatedAt: now})
+	assert.Nil(t, err)
+	_, err = sr.CreateTopic(ctx, Topic{SubjectId: 2, Name: "Mechanics", CreatedAt: now, UpdatedAt: now})
+	assert.Nil(t, err)
+
+	topic, err := sr.GetTopicById(ctx, quadratics)
+	assert.Nil(t, err)
+	assert.Equal(t, algebra, topic.ParentId)
+	assert.Equal(t, "Quadratics", topic.Name)
+
+	for i := 0; i < 2; i++ {
+		id, err := qr.CreateQuestion(ctx, Questions{SubjectId: 1, Question: "Solve the quadratic.", CreatedAt: now, UpdatedAt: now})
+		assert.Nil(t, err)
+		assert.Nil(t, qr.SetQuestionClassification(ctx, id, quadratics, nil))
+	}
+
+	topics, err := sr.GetSubjectTopics(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, topics, 2)
+	assert.Equal(t, "Algebra", topics[0].Name)
+	assert.Equal(t, int64(0), topics[0].ParentId)
+	assert.Equal(t, int64(0), topics[0].Questions)
+	assert.Equal(t, "Quadratics", topics[1].Name)
+	assert.Equal(t, int64(2), topics[1].Questions)
+
+	assert.Nil(t, sr.UpdateTopicName(ctx, quadratics, "Quadratic equations"))
+	topic, err = sr.GetTopicById(ctx, quadratics)
+	assert.Nil(t, err)
+	assert.Equal(t, "Quadratic equations", topic.Name)
+
+	assert.Nil(t, sr.DeleteTopicById(ctx, algebra))
+	_, err = sr.GetTopicById(ctx, algebra)
+	assert.NotNil(t, err)
+}
+
+func TestPastPapers(t *testing.T) {
+	pool := setUP(t)
+	defer pool.Close()
+	sr := NewSubjectRepository(pool)
+	qr := NewQuestionRepository(pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	older, err := sr.CreatePastPaper(ctx, PastPaper{SubjectId: 1, ExamBody: "WAEC", Year: 2018, Paper: "Paper 1", DurationSeconds: 5400, CreatedAt: now, UpdatedAt: now})
+	assert.Nil(t, err)
+	newer, err := sr.CreatePastPaper(ctx, PastPaper{SubjectId: 1, ExamBody: "WAEC", Year: 2019, Paper: "Paper 1", DurationSeconds: 7200, CreatedAt: now, UpdatedAt: now})
+	assert.Nil(t, err)
+	_, err = sr.CreatePastPaper(ctx, PastPaper{SubjectId: 2, ExamBody: "NECO", Year: 2019, Paper: "Paper 2", DurationSeconds: 3600, CreatedAt: now, UpdatedAt: now})
+	assert.Nil(t, err)
+
+	paper, err := sr.GetPastPaperById(ctx, newer)
+	assert.Nil(t, err)
+	assert.Equal(t, "WAEC", paper.ExamBody)
+	assert.Equal(t, 2019, paper.Year)
+	assert.Equal(t, int64(7200), paper.DurationSeconds)
+
+	// questions are returned in the order they were set, whatever order they were added in
+	for _, number := range []int{3, 1, 2} {
+		id, err := qr.CreateQuestion(ctx, Questions{SubjectId: 1, Question: "Question from the 2019 paper.", CreatedAt: now, UpdatedAt: now})
+		assert.Nil(t, err)
+		assert.Nil(t, qr.SetQuestionSource(ctx, id, newer, number))
+	}
+	assert.ErrorIs(t, qr.SetQuestionSource(ctx, 99, newer, 4), pkg.ErrQuestionNotFound)
+	questions, err := qr.GetPaperQuestions(ctx, newer)
+	assert.Nil(t, err)
+	assert.Len(t, questions, 3)
+	for i, question := range questions {
+		assert.Equal(t, newer, question.PaperId)
+		assert.Equal(t, i+1, question.PaperQuestionNumber)
+	}
+
+	papers, err := sr.GetSubjectPastPapers(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, papers, 2)
+	assert.Equal(t, newer, papers[0].Id)
+	assert.Equal(t, int64(3), papers[0].Questions)
+	assert.Equal(t, older, papers[1].Id)
+	assert.Equal(t, int64(0), papers[1].Questions)
+
+	// clearing the source takes the question off the paper
+	assert.Nil(t, qr.SetQuestionSource(ctx, questions[0].Id, 0, 0))
+	question, err := qr.GetQuestionById(ctx, questions[0].Id)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), question.PaperId)
+	assert.Equal(t, 0, question.PaperQuestionNumber)
+}
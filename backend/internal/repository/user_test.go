@@ -20,7 +20,20 @@ func setUpDB(t *testing.T) *sql.DB {
 	}
 	queries := []string{
 		"CREATE TABLE users (id integer primary key autoincrement, name text, email text, password_hash text, created_at timestamp, updated_at timestamp)",
-		"CREATE TABLE IF NOT EXISTS scores (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id BIGINT, score BIGINT, mode VARCHAR(255), correct_answers BIGINT, incorrect_answers BIGINT, total_questions BIGINT, time_taken_seconds BIGINT, subject_id BIGINT, created_at TIMESTAMP, updated_at TIMESTAMP)",
+		"CREATE TABLE IF NOT EXISTS scores (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id BIGINT, session_id BIGINT, score BIGINT, mode VARCHAR(255), correct_answers BIGINT, incorrect_answers BIGINT, total_questions BIGINT, time_taken_seconds BIGINT, subject_id BIGINT, points REAL DEFAULT 0, scoring_policy VARCHAR(64) DEFAULT 'standard', created_at TIMESTAMP, updated_at TIMESTAMP)",
+		"CREATE TABLE IF NOT EXISTS quiz_sessions (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id BIGINT, subject_id BIGINT, status VARCHAR(32), mode VARCHAR(32), duration_seconds BIGINT, challenge_id BIGINT, paper_id BIGINT, contest_id BIGINT, daily_date DATE, expires_at TIMESTAMP, submitted_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_quiz_sessions_challenge_id_user_id ON quiz_sessions (challenge_id, user_id)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_quiz_sessions_contest_id_user_id ON quiz_sessions (contest_id, user_id)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_quiz_sessions_user_id_subject_id_daily_date ON quiz_sessions (user_id, subject_id, daily_date)",
+		"CREATE TABLE IF NOT EXISTS quiz_session_subjects (id INTEGER PRIMARY KEY AUTOINCREMENT, session_id BIGINT, subject_id BIGINT, num_of_questions BIGINT, scoring_policy TEXT)",
+		"CREATE TABLE IF NOT EXISTS quiz_session_questions (id INTEGER PRIMARY KEY AUTOINCREMENT, session_id BIGINT, question_id BIGINT, subject_id BIGINT, position INT)",
+		"CREATE TABLE IF NOT EXISTS quiz_session_options (id INTEGER PRIMARY KEY AUTOINCREMENT, session_id BIGINT, question_id BIGINT, option_id BIGINT, position INT)",
+		"CREATE TABLE IF NOT EXISTS quiz_answers (id INTEGER PRIMARY KEY AUTOINCREMENT, score_id BIGINT, session_id BIGINT, user_id BIGINT, question_id BIGINT, subject_id BIGINT, position INT, selected_option_ids TEXT, answer_value REAL, answer_unit TEXT DEFAULT '', answer_text TEXT DEFAULT '', answer_pairs TEXT DEFAULT '[]', is_correct BOOLEAN, credit REAL, points REAL, answered_at TIMESTAMP)",
+		"CREATE TABLE IF NOT EXISTS questions (id INTEGER PRIMARY KEY AUTOINCREMENT, subject_id BIGINT, topic_id BIGINT, question TEXT)",
+		"CREATE TABLE IF NOT EXISTS topics (id INTEGER PRIMARY KEY AUTOINCREMENT, subject_id BIGINT, parent_id BIGINT, name VARCHAR(100), created_at TIMESTAMP, updated_at TIMESTAMP, UNIQUE (subject_id, name))",
+		"CREATE TABLE IF NOT EXISTS question_tags (question_id BIGINT, tag VARCHAR(50), PRIMARY KEY (question_id, tag))",
+		"CREATE TABLE IF NOT EXISTS review_queue (user_id BIGINT, question_id BIGINT, subject_id BIGINT, ease_factor REAL, interval_days INT, repetitions INT, due_at TIMESTAMP, last_reviewed_at TIMESTAMP, created_at TIMESTAMP, updated_at TIMESTAMP, PRIMARY KEY (user_id, question_id))",
+		"CREATE TABLE IF NOT EXISTS quiz_challenges (id INTEGER PRIMARY KEY AUTOINCREMENT, code VARCHAR(16) UNIQUE, seed BIGINT, created_by BIGINT, subject_id BIGINT, mode VARCHAR(32), duration_seconds BIGINT, subjects TEXT, question_ids TEXT, created_at TIMESTAMP)",
 	}
 	for _, query := range queries {
 		if _, err := db.Exec(query); err != nil {
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lawson/otterprep/domain"
+)
+
+type XPRepository interface {
+	AppendXP(ctx context.Context, entries []domain.XPEntry) error
+	GetUserXP(ctx context.Context, userId int64) (int64, error)
+	GetUserXPLedger(ctx context.Context, userId int64, limit, offset int) ([]domain.XPEntry, int64, error)
+	HasCompletedSubject(ctx context.Context, userId, subjectId, exceptSessionId int64) (bool, error)
+}
+
+type xpRepository struct {
+	db *sql.DB
+}
+
+func NewXPRepository(db *sql.DB) XPRepository {
+	return &xpRepository{db: db}
+}
+
+// AppendXP adds entries to the XP ledger in a single transaction. Entries are never
+// changed or removed once added.
+func (xr *xpRepository) AppendXP(ctx context.Context, entries []domain.XPEntry) error {
+	tx, err := xr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO xp_ledger (user_id, session_id, subject_id, reason, amount, created_at) VALUES ($1, $2, $3, $4, $5, $6)"
+	for _, entry := range entries {
+		sessionId := sql.NullInt64{Int64: entry.SessionID, Valid: entry.SessionID != 0}
+		subjectId := sql.NullInt64{Int64: entry.SubjectID, Valid: entry.SubjectID != 0}
+		if _, err := tx.ExecContext(ctx, query, entry.UserID, sessionId, subjectId, entry.Reason, entry.Amount, entry.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetUserXP returns the user's total XP, the sum of their ledger.
+func (xr *xpRepository) GetUserXP(ctx context.Context, userId int64) (int64, error) {
+	var total int64
+	query := "SELECT COALESCE(SUM(amount), 0) FROM xp_ledger WHERE user_id = $1"
+	if err := xr.db.QueryRowContext(ctx, query, userId).Scan(&total); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// GetUserXPLedger returns a page of the user's XP ledger, newest first, along with the
+// number of entries it has.
+func (xr *xpRepository) GetUserXPLedger(ctx context.Context, userId int64, limit, offset int) ([]domain.XPEntry, int64, error) {
+	var totalEntries int64
+	if err := xr.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM xp_ledger WHERE user_id = $1", userId).Scan(&totalEntries); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, user_id, session_id, subject_id, reason, amount, created_at FROM xp_ledger
+		WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`
+	rows, err := xr.db.QueryContext(ctx, query, userId, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []domain.XPEntry{}
+	for rows.Next() {
+		var entry domain.XPEntry
+		var sessionId, subjectId sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.UserID, &sessionId, &subjectId, &entry.Reason, &entry.Amount, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entry.SessionID = sessionId.Int64
+		entry.SubjectID = subjectId.Int64
+		entries = append(entries, entry)
+	}
+	return entries, totalEntries, rows.Err()
+}
+
+// HasCompletedSubject reports whether the user has a score in the subject other than one
+// of the given session, counting scores from before there were quiz sessions.
+func (xr *xpRepository) HasCompletedSubject(ctx context.Context, userId, subjectId, exceptSessionId int64) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM scores WHERE user_id = $1 AND subject_id = $2 AND (session_id IS NULL OR session_id <> $3))"
+	var completed bool
+	if err := xr.db.QueryRowContext(ctx, query, userId, subjectId, exceptSessionId).Scan(&completed); err != nil {
+		return false, err
+	}
+	return completed, nil
+}
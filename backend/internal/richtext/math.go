@@ -0,0 +1,177 @@
+package richtext
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindMath is the NodeKind of math segments.
+var KindMath = ast.NewNodeKind("Math")
+
+// Math is a LaTeX math segment, written $...$ inline or $$...$$ for display math.
+type Math struct {
+	ast.BaseInline
+	TeX     string
+	Display bool
+}
+
+func (m *Math) Kind() ast.NodeKind {
+	return KindMath
+}
+
+func (m *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(m, source, level, map[string]string{"TeX": m.TeX, "Display": fmt.Sprint(m.Display)}, nil)
+}
+
+// mathParser parses math segments. Following Pandoc, an inline $ only opens math when it
+// is followed by a non-space and only closes it when it follows a non-space and is not
+// followed by a digit, so "$5 and $10" is not math. Display math has no such rules and
+// may run over several lines. A \$ within math does not close it.
+type mathParser struct{}
+
+func (mp *mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mp *mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	display := len(line) > 1 && line[1] == '$'
+	opener := 1
+	if display {
+		opener = 2
+	}
+	if !display && (len(line) < 2 || isSpace(line[1])) {
+		return nil
+	}
+	startLine, startPos := block.Position()
+	block.Advance(opener)
+	var tex strings.Builder
+	for {
+		line, _ := block.PeekLine()
+		if line == nil {
+			block.SetPosition(startLine, startPos)
+			return nil
+		}
+		for i := 0; i < len(line); i++ {
+			switch {
+			case line[i] == '\\' && i+1 < len(line):
+				i++
+			case line[i] == '$' && display:
+				if i+1 < len(line) && line[i+1] == '$' {
+					tex.Write(line[:i])
+					block.Advance(i + 2)
+					return &Math{TeX: strings.TrimSpace(tex.String()), Display: true}
+				}
+			case line[i] == '$':
+				if i > 0 && !isSpace(line[i-1]) && (i+1 >= len(line) || !isDigit(line[i+1])) {
+					tex.Write(line[:i])
+					block.Advance(i + 1)
+					return &Math{TeX: tex.String()}
+				}
+			}
+		}
+		if !display {
+			// inline math stays on one line
+			block.SetPosition(startLine, startPos)
+			return nil
+		}
+		tex.Write(line)
+		block.AdvanceLine()
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// mathRenderer renders math as its escaped TeX between \( \) or \[ \], in a span of class
+// "math inline" or "math display" for KaTeX or MathJax to typeset in the browser.
+type mathRenderer struct{}
+
+func (mr *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMath, mr.renderMath)
+}
+
+func (mr *mathRenderer) renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	math := node.(*Math)
+	if math.Display {
+		_, _ = w.WriteString(`<span class="math display">\[` + html.EscapeString(math.TeX) + `\]</span>`)
+	} else {
+		_, _ = w.WriteString(`<span class="math inline">\(` + html.EscapeString(math.TeX) + `\)</span>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// mathExtension adds math segments to Markdown.
+type mathExtension struct{}
+
+func (me *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&mathParser{}, 50)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&mathRenderer{}, 50)))
+}
+
+// blockedCommands are TeX commands math may not use: ones that link out, load images or
+// add HTML attributes when the typesetter trusts its input, and macro definitions, which
+// can be made to expand without end.
+var blockedCommands = map[string]bool{
+	"href": true, "url": true, "includegraphics": true,
+	"htmlClass": true, "htmlId": true, "htmlStyle": true, "htmlData": true,
+	"def": true, "gdef": true, "edef": true, "xdef": true, "let": true,
+	"newcommand": true, "renewcommand": true, "providecommand": true, "DeclareMathOperator": true,
+}
+
+// checkTeX checks that a math segment is not empty, that its braces are balanced and that
+// it uses none of the blocked commands.
+func checkTeX(tex string) error {
+	if strings.TrimSpace(tex) == "" {
+		return ErrEmptyMath
+	}
+	depth := 0
+	for i := 0; i < len(tex); i++ {
+		switch tex[i] {
+		case '\\':
+			j := i + 1
+			for j < len(tex) && isLetter(tex[j]) {
+				j++
+			}
+			if command := tex[i+1 : j]; blockedCommands[command] {
+				return fmt.Errorf("%w: \\%s", ErrMathCommandNotAllowed, command)
+			}
+			if j == i+1 {
+				// an escaped character such as \{ or \$
+				j++
+			}
+			i = j - 1
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return ErrUnbalancedBraces
+			}
+		}
+	}
+	if depth != 0 {
+		return ErrUnbalancedBraces
+	}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,143 @@
+// Package richtext handles the rich text of questions, options and explanations: Markdown
+// with LaTeX math between $ signs. Text is checked and put in canonical form when it is
+// authored, and turned into sanitised HTML when it is shown.
+package richtext
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrInvalidEncoding       = errors.New("text is not valid UTF-8")
+	ErrControlCharacter      = errors.New("text contains a control character")
+	ErrHTMLNotAllowed        = errors.New("HTML is not allowed, use Markdown instead")
+	ErrUnsafeLink            = errors.New("links and images must use http or https")
+	ErrStrayDollar           = errors.New("math is not closed; write \\$ for a dollar sign")
+	ErrEmptyMath             = errors.New("math is empty")
+	ErrUnbalancedBraces      = errors.New("braces in math are not balanced")
+	ErrMathCommandNotAllowed = errors.New("math uses a command that is not allowed")
+)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.Table, extension.Strikethrough, &mathExtension{}))
+
+// policy keeps the HTML Markdown can produce, and the spans math is rendered in.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^math (inline|display)$`)).OnElements("span")
+	return p
+}()
+
+// blankLines matches runs of more than one blank line.
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// Canonical checks that text is valid rich text and returns it in canonical form: NFC
+// normalised, with \n line endings, no trailing spaces on a line, no more than one blank
+// line in a row and no leading or trailing blank space. A hard line break is therefore
+// written with a backslash at the end of the line rather than two spaces.
+func Canonical(src string) (string, error) {
+	if !utf8.ValidString(src) {
+		return "", ErrInvalidEncoding
+	}
+	src = norm.NFC.String(src)
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	for _, r := range src {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return "", ErrControlCharacter
+		}
+	}
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	src = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	src = strings.TrimSpace(src)
+	if err := check(src); err != nil {
+		return "", err
+	}
+	return src, nil
+}
+
+// check parses text and checks what it is made of: no raw HTML, links and images only to
+// http or https addresses, math that is well formed and no $ left that did not start math.
+func check(src string) error {
+	source := []byte(src)
+	document := markdown.Parser().Parse(text.NewReader(source))
+	return ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.RawHTML, *ast.HTMLBlock:
+			return ast.WalkStop, ErrHTMLNotAllowed
+		case *ast.Link:
+			if !safeURL(n.Destination, true) {
+				return ast.WalkStop, ErrUnsafeLink
+			}
+		case *ast.AutoLink:
+			if !safeURL(n.URL(source), true) {
+				return ast.WalkStop, ErrUnsafeLink
+			}
+		case *ast.Image:
+			if !safeURL(n.Destination, false) {
+				return ast.WalkStop, ErrUnsafeLink
+			}
+		case *Math:
+			if err := checkTeX(n.TeX); err != nil {
+				return ast.WalkStop, err
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan, *ast.CodeBlock, *ast.FencedCodeBlock:
+			// a $ in code is just a $
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if strayDollar(n.Segment.Value(source)) {
+				return ast.WalkStop, ErrStrayDollar
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// safeURL reports whether a link or image points at an http or https address, or for a
+// link, a mailto one.
+func safeURL(destination []byte, link bool) bool {
+	url := strings.ToLower(string(destination))
+	return strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") || link && strings.HasPrefix(url, "mailto:")
+}
+
+// strayDollar reports whether text has a $ that is not escaped.
+func strayDollar(text []byte) bool {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '$':
+			return true
+		}
+	}
+	return false
+}
+
+// HTML renders rich text as sanitised HTML. Math is left for the browser to typeset. It
+// never fails: text stored before it was checked is rendered as well as it can be, and
+// anything unsafe in it is dropped.
+func HTML(src string) string {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(src), &buf); err != nil {
+		return policy.Sanitize(src)
+	}
+	return strings.TrimSpace(policy.Sanitize(buf.String()))
+}
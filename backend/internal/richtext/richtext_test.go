@@ -0,0 +1,68 @@
+package richtext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+		err  error
+	}{
+		{name: "inline math", src: "Solve $x^2 + 1 = 0$ for **x**.", want: "Solve $x^2 + 1 = 0$ for **x**."},
+		{name: "display math", src: "$$\n\\frac{a}{b}\n$$", want: "$$\n\\frac{a}{b}\n$$"},
+		{name: "escaped dollars", src: `It costs \$5 and \$10`, want: `It costs \$5 and \$10`},
+		{name: "dollar in code", src: "`$HOME` is set", want: "`$HOME` is set"},
+		{name: "escaped dollar in math", src: `$a\$b$`, want: `$a\$b$`},
+		{name: "spacing", src: "  line one  \r\nline two\n\n\n\nend   ", want: "line one\nline two\n\nend"},
+		{name: "decomposed accent", src: "cafe\u0301", want: "caf\u00e9"},
+		{name: "unescaped dollars", src: "It costs $5 and $10", err: ErrStrayDollar},
+		{name: "unclosed math", src: "Solve $x^2", err: ErrStrayDollar},
+		{name: "unclosed display math", src: "$$\\frac{a}{b}", err: ErrStrayDollar},
+		{name: "empty math", src: "$$ $$", err: ErrEmptyMath},
+		{name: "unbalanced braces", src: `$\frac{1}{2$`, err: ErrUnbalancedBraces},
+		{name: "blocked command", src: `$\href{javascript:alert(1)}{x}$`, err: ErrMathCommandNotAllowed},
+		{name: "macro definition", src: `$\def\x{\x\x}\x$`, err: ErrMathCommandNotAllowed},
+		{name: "html block", src: "<script>alert(1)</script>", err: ErrHTMLNotAllowed},
+		{name: "inline html", src: "hi <b>there</b>", err: ErrHTMLNotAllowed},
+		{name: "javascript link", src: "[x](javascript:alert(1))", err: ErrUnsafeLink},
+		{name: "data image", src: "![x](data:image/png;base64,AAAA)", err: ErrUnsafeLink},
+		{name: "control character", src: "a\x00b", err: ErrControlCharacter},
+		{name: "invalid utf-8", src: "a\xffb", err: ErrInvalidEncoding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonical(tt.src)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "markdown", src: "Solve for **x**", want: "<p>Solve for <strong>x</strong></p>"},
+		{name: "inline math", src: "$x^2 < 1$", want: `<p><span class="math inline">\(x^2 &lt; 1\)</span></p>`},
+		{name: "display math", src: "$$\n\\sqrt{2}\n$$", want: `<p><span class="math display">\[\sqrt{2}\]</span></p>`},
+		{name: "escaped dollar", src: `\$5`, want: "<p>$5</p>"},
+		{name: "html in math", src: "$</span><script>alert(1)</script>$", want: `<p><span class="math inline">\(&lt;/span&gt;&lt;script&gt;alert(1)&lt;/script&gt;\)</span></p>`},
+		// text stored before it was checked is still made safe
+		{name: "script", src: "<script>alert(1)</script>Hi", want: ""},
+		{name: "inline html", src: `<img src=x onerror="alert(1)"> hi`, want: "<p> hi</p>"},
+		{name: "javascript link", src: "[x](javascript:alert(1))", want: "<p>x</p>"},
+		{name: "link", src: "[notes](https://example.com)", want: `<p><a href="https://example.com" rel="nofollow">notes</a></p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HTML(tt.src))
+		})
+	}
+}
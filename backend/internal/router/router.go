@@ -15,6 +15,13 @@ func NewRouter(
 	userHandler *handler.UserHandler,
 	quizHandler *handler.QuizHandler,
 	leaderboardHandler *handler.LeaderboardHandler,
+	mediaHandler *handler.MediaHandler,
+	liveHandler *handler.LiveHandler,
+	contestHandler *handler.ContestHandler,
+	dailyHandler *handler.DailyHandler,
+	achievementHandler *handler.AchievementHandler,
+	studyPlanHandler *handler.StudyPlanHandler,
+	analyticsHandler *handler.AnalyticsHandler,
 	cfg *config.Config,
 ) {
 	// Set up error handlers
@@ -44,6 +51,9 @@ func NewRouter(
 	e.GET("/health", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, "OK")
 	}, middleware.RateLimitMiddleware(middleware.HealthCheckLimiter))
+	// Media in local storage, reached through signed URLs rather than a token
+	e.GET("/media/*", mediaHandler.ServeMedia, middleware.RateLimitMiddleware(middleware.APIRateLimiter))
+
 	// Public routes with rate limiting
 	// Auth routes - stricter rate limits
 	authGroup := e.Group("")
@@ -75,6 +85,15 @@ func NewRouter(
 	api.PUT("/user/email", userHandler.UpdateEmail)
 	api.PUT("/user/password", userHandler.UpdatePassword)
 	api.DELETE("/user/account", userHandler.DeleteUserAccount)
+	api.GET("/user/xp", userHandler.GetXPHistory)
+	api.GET("/user/analytics", analyticsHandler.GetLearnerAnalytics)
+	api.GET("/users/:user_id/profile", userHandler.GetPublicProfile)
+
+	// Study plan routes
+	api.POST("/study-plan", studyPlanHandler.CreateStudyPlan)
+	api.GET("/study-plan", studyPlanHandler.GetStudyPlan)
+	api.GET("/study-plan/today", studyPlanHandler.GetStudyPlanToday)
+	api.DELETE("/study-plan", studyPlanHandler.DeleteStudyPlan)
 
 	// Admin routes
 	api.POST("/admin/questions/bulk/:subject_id", adminHandler.CreateBulkQuestions)
@@ -82,15 +101,56 @@ func NewRouter(
 	api.GET("/admin/questions", adminHandler.GetAllQuestions)
 	api.GET("/admin/questions/:id", adminHandler.GetQuestionById)
 	api.DELETE("/admin/questions/:id", adminHandler.DeleteQuestionById)
+	api.PUT("/admin/questions/:id/classification", adminHandler.ClassifyQuestion)
+	api.PUT("/admin/questions/:id/source", adminHandler.SetQuestionSource)
+	api.POST("/admin/questions/:id/media", adminHandler.UploadQuestionMedia)
+	api.DELETE("/admin/media/:id", adminHandler.DeleteMedia)
+	api.POST("/admin/contests", contestHandler.CreateContest)
 
 	// Subject routes
 	api.GET("/admin/subject", adminHandler.GetAllSubjects)
 	api.GET("/admin/subject/:id", adminHandler.GetSubjectById)
 	api.POST("/admin/subject", adminHandler.CreateSubject)
+	api.GET("/admin/subject/:id/scoring-policy", adminHandler.GetSubjectScoringPolicy)
+	api.PUT("/admin/subject/:id/scoring-policy", adminHandler.SetSubjectScoringPolicy)
+	api.GET("/admin/subject/:id/topics", adminHandler.GetSubjectTopics)
+	api.POST("/admin/subject/:id/topics", adminHandler.CreateTopic)
+	api.GET("/admin/subject/:id/tags", adminHandler.GetSubjectTags)
+	api.GET("/admin/subject/:id/papers", adminHandler.GetSubjectPastPapers)
+	api.POST("/admin/subject/:id/papers", adminHandler.CreatePastPaper)
+	api.PUT("/admin/topics/:id", adminHandler.UpdateTopic)
+	api.DELETE("/admin/topics/:id", adminHandler.DeleteTopic)
 
 	// Quiz routes
 	api.POST("/quiz/create", quizHandler.CreateQuiz)
 	api.POST("/quiz/submit", quizHandler.SubmitQuiz)
+	api.GET("/quiz/history", quizHandler.GetQuizHistory)
+	api.GET("/quiz/history/:session_id", quizHandler.GetQuizReview)
+	api.GET("/quiz/review", quizHandler.GetReviewQueue)
+	api.POST("/quiz/review", quizHandler.CreateReviewQuiz)
+	api.POST("/quiz/challenge", quizHandler.CreateChallenge)
+	api.POST("/quiz/challenge/:code", quizHandler.TakeChallenge)
+	api.GET("/quiz/challenge/:code/results", quizHandler.GetChallengeResults)
+	api.POST("/quiz/mock", quizHandler.CreateMockExam)
+
+	// Live room routes
+	api.POST("/live/rooms", liveHandler.CreateRoom)
+	api.GET("/live/rooms/:pin", liveHandler.GetRoom)
+	api.GET("/live/rooms/:pin/ws", liveHandler.Connect)
+
+	// Contest routes
+	api.GET("/contests", contestHandler.GetContests)
+	api.GET("/contests/:id", contestHandler.GetContest)
+	api.POST("/contests/:id/enter", contestHandler.EnterContest)
+	api.GET("/contests/:id/leaderboard", contestHandler.GetContestLeaderboard)
+
+	// Daily challenge routes
+	api.GET("/daily/board", dailyHandler.GetDailyBoard)
+	api.GET("/daily/:subject_id", dailyHandler.GetDailyChallenge)
+	api.POST("/daily/:subject_id", dailyHandler.TakeDailyChallenge)
+
+	// Achievement routes
+	api.GET("/achievements", achievementHandler.GetBadges)
 
 	// Leaderboard routes
 	api.GET("/leaderboard", leaderboardHandler.GetLeaderboard)
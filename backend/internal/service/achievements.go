@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// DefaultBadges are the badges used when no achievements file is configured.
+var DefaultBadges = []domain.BadgeDefinition{
+	{
+		Code:        "first_perfect_score",
+		Name:        "Flawless",
+		Description: "Answer every question of a quiz correctly",
+		Event:       domain.EventQuizSubmitted,
+		Rules:       []domain.BadgeRule{{Metric: "quiz_accuracy", Op: "eq", Value: 100}, {Metric: "quiz_questions", Op: "gte", Value: 5}},
+	},
+	{
+		Code:        "quizzes_100",
+		Name:        "Centurion",
+		Description: "Take 100 quizzes",
+		Event:       domain.EventQuizSubmitted,
+		Rules:       []domain.BadgeRule{{Metric: "quizzes_taken", Op: "gte", Value: 100}},
+	},
+	{
+		Code:        "streak_7",
+		Name:        "On a Roll",
+		Description: "Take a daily challenge 7 days in a row",
+		Event:       domain.EventQuizSubmitted,
+		Modes:       []string{domain.ModeDaily},
+		Rules:       []domain.BadgeRule{{Metric: "current_streak", Op: "gte", Value: 7}},
+	},
+	{
+		Code:        "weekly_top_10",
+		Name:        "Top Ten",
+		Description: "Reach the top 10 of the weekly leaderboard",
+		Event:       domain.EventLeaderboardSnapshot,
+		Rules:       []domain.BadgeRule{{Metric: "weekly_rank", Op: "lte", Value: 10}},
+	},
+}
+
+// badgeMetrics are the metrics the rules of each event's badges can use.
+var badgeMetrics = map[string][]string{
+	domain.EventQuizSubmitted: {
+		// the submitted quiz
+		"quiz_accuracy", "quiz_points", "quiz_score", "quiz_questions", "quiz_correct_answers", "quiz_time_seconds",
+		// the user's totals, including the submitted quiz
+		"quizzes_taken", "total_correct_answers", "total_questions_answered", "current_streak", "longest_streak",
+	},
+	// places on the leaderboards; a user further down a board than any rule asks about
+	// has no rank on it
+	domain.EventLeaderboardSnapshot: {"weekly_rank", "monthly_rank", "all_time_rank"},
+}
+
+// LoadBadges reads badge definitions from a JSON file holding an array of them, or
+// returns DefaultBadges when no file is given.
+func LoadBadges(path string) ([]domain.BadgeDefinition, error) {
+	if path == "" {
+		return DefaultBadges, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var badges []domain.BadgeDefinition
+	if err := json.Unmarshal(data, &badges); err != nil {
+		return nil, fmt.Errorf("%w: %v", pkg.ErrInvalidBadge, err)
+	}
+	if err := ValidateBadges(badges); err != nil {
+		return nil, err
+	}
+	return badges, nil
+}
+
+// ValidateBadges checks that every badge has a unique code, a name, a known event and
+// rules on the metrics of that event.
+func ValidateBadges(badges []domain.BadgeDefinition) error {
+	codes := make(map[string]bool, len(badges))
+	for _, badge := range badges {
+		if badge.Code == "" || badge.Name == "" {
+			return fmt.Errorf("%w: every badge needs a code and a name", pkg.ErrInvalidBadge)
+		}
+		if codes[badge.Code] {
+			return fmt.Errorf("%w: code %q is used more than once", pkg.ErrInvalidBadge, badge.Code)
+		}
+		codes[badge.Code] = true
+		metrics, ok := badgeMetrics[badge.Event]
+		if !ok {
+			return fmt.Errorf("%w: badge %q has unknown event %q", pkg.ErrInvalidBadge, badge.Code, badge.Event)
+		}
+		if len(badge.Modes) > 0 && badge.Event != domain.EventQuizSubmitted {
+			return fmt.Errorf("%w: badge %q can only have modes on %s", pkg.ErrInvalidBadge, badge.Code, domain.EventQuizSubmitted)
+		}
+		if len(badge.Rules) == 0 {
+			return fmt.Errorf("%w: badge %q has no rules", pkg.ErrInvalidBadge, badge.Code)
+		}
+		for _, rule := range badge.Rules {
+			if !slices.Contains(metrics, rule.Metric) {
+				return fmt.Errorf("%w: badge %q has unknown metric %q for %s", pkg.ErrInvalidBadge, badge.Code, rule.Metric, badge.Event)
+			}
+			if rule.Op != "gte" && rule.Op != "lte" && rule.Op != "eq" {
+				return fmt.Errorf("%w: badge %q has unknown op %q, expected gte, lte or eq", pkg.ErrInvalidBadge, badge.Code, rule.Op)
+			}
+		}
+	}
+	return nil
+}
+
+// badgeEarned reports whether every rule of a badge holds for the metrics. A rule on a
+// metric that is missing does not hold.
+func badgeEarned(badge domain.BadgeDefinition, metrics map[string]float64) bool {
+	for _, rule := range badge.Rules {
+		value, ok := metrics[rule.Metric]
+		if !ok {
+			return false
+		}
+		switch rule.Op {
+		case "gte":
+			ok = value >= rule.Value
+		case "lte":
+			ok = value <= rule.Value
+		case "eq":
+			ok = value == rule.Value
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type AchievementService interface {
+	GetBadges() []domain.BadgeDefinition
+	GetUserBadges(ctx context.Context, userID int64) ([]domain.Badge, error)
+	SnapshotLeaderboards(ctx context.Context, now time.Time) error
+}
+
+// achievementService awards badges. Badges earned by quiz submissions are awarded by
+// RecordSubmission, and those earned by places on the leaderboards by
+// SnapshotLeaderboards, which is run every so often.
+type achievementService struct {
+	badges                []domain.BadgeDefinition
+	achievementRepository repository.AchievementRepository
+	scoreRepository       repository.ScoreRepository
+	streakRepository      repository.StreakRepository
+	leaderboardRepository repository.LeaderboardRepository
+}
+
+func NewAchievementService(badges []domain.BadgeDefinition, achievementRepository repository.AchievementRepository, scoreRepository repository.ScoreRepository, streakRepository repository.StreakRepository, leaderboardRepository repository.LeaderboardRepository) *achievementService {
+	return &achievementService{
+		badges:                badges,
+		achievementRepository: achievementRepository,
+		scoreRepository:       scoreRepository,
+		streakRepository:      streakRepository,
+		leaderboardRepository: leaderboardRepository,
+	}
+}
+
+// GetBadges returns every badge that can be earned.
+func (as *achievementService) GetBadges() []domain.BadgeDefinition {
+	return as.badges
+}
+
+// GetUserBadges returns the badges the user has earned, oldest first. A badge earned
+// before it was taken out of the configuration is still shown, by its code.
+func (as *achievementService) GetUserBadges(ctx context.Context, userID int64) ([]domain.Badge, error) {
+	achievements, err := as.achievementRepository.GetUserAchievements(ctx, userID)
+	if err != nil {
+		fmt.Println("error getting user achievements: ", err)
+		return nil, err
+	}
+	badges := make([]domain.Badge, len(achievements))
+	for i, achievement := range achievements {
+		badges[i] = domain.Badge{Code: achievement.Code, Name: achievement.Code, EarnedAt: achievement.EarnedAt}
+		if j := slices.IndexFunc(as.badges, func(badge domain.BadgeDefinition) bool { return badge.Code == achievement.Code }); j >= 0 {
+			badges[i].Name = as.badges[j].Name
+			badges[i].Description = as.badges[j].Description
+		}
+	}
+	return badges, nil
+}
+
+// RecordSubmission is a SubmitHook that awards the badges the submission earns and adds
+// them to the result. It reads the user's streak, so it is added after the daily
+// challenge's hook.
+func (as *achievementService) RecordSubmission(ctx context.Context, session *repository.QuizSession, result *domain.QuizSubmitResponse) error {
+	candidates, err := as.unearnedBadges(ctx, session.UserId, domain.EventQuizSubmitted)
+	if err != nil {
+		return err
+	}
+	candidates = slices.DeleteFunc(candidates, func(badge domain.BadgeDefinition) bool {
+		return len(badge.Modes) > 0 && !slices.Contains(badge.Modes, session.Mode)
+	})
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	stats, err := as.scoreRepository.GetUserOverallScoreStats(ctx, session.UserId)
+	if err != nil {
+		return err
+	}
+	streak, err := as.streakRepository.GetUserStreak(ctx, session.UserId)
+	if err != nil {
+		return err
+	}
+	metrics := map[string]float64{
+		"quiz_accuracy":            0,
+		"quiz_points":              result.Points,
+		"quiz_score":               float64(result.Score),
+		"quiz_questions":           float64(result.TotalQuestions),
+		"quiz_correct_answers":     float64(result.CorrectAnswers),
+		"quiz_time_seconds":        float64(result.TimeTakenSeconds),
+		"quizzes_taken":            float64(stats.TotalQuizzesTaken),
+		"total_correct_answers":    float64(stats.TotalCorrectAnswers),
+		"total_questions_answered": float64(stats.TotalQuestionsAnswered),
+		"current_streak":           float64(streak.Current),
+		"longest_streak":           float64(streak.Longest),
+	}
+	if result.TotalQuestions > 0 {
+		metrics["quiz_accuracy"] = float64(result.CorrectAnswers) / float64(result.TotalQuestions) * 100
+	}
+
+	badges, err := as.award(ctx, session.UserId, candidates, metrics, time.Now())
+	if err != nil {
+		return err
+	}
+	result.Badges = badges
+	return nil
+}
+
+// SnapshotLeaderboards awards the badges earned by places on the weekly, monthly and
+// all-time leaderboards as they stand. Each board is read only as deep as the largest
+// rank a rule asks about.
+func (as *achievementService) SnapshotLeaderboards(ctx context.Context, now time.Time) error {
+	depths := map[string]int{}
+	for _, badge := range as.badges {
+		if badge.Event != domain.EventLeaderboardSnapshot {
+			continue
+		}
+		for _, rule := range badge.Rules {
+			depths[rule.Metric] = max(depths[rule.Metric], int(math.Ceil(rule.Value)))
+		}
+	}
+	boards := map[string]func(ctx context.Context, limit, offset int) ([]domain.LeaderboardEntry, int64, error){
+		"weekly_rank":   as.leaderboardRepository.GetWeeklyLeaderboard,
+		"monthly_rank":  as.leaderboardRepository.GetMonthlyLeaderboard,
+		"all_time_rank": as.leaderboardRepository.GetGlobalLeaderboard,
+	}
+
+	metrics := map[int64]map[string]float64{}
+	for metric, depth := range depths {
+		if depth <= 0 {
+			continue
+		}
+		entries, _, err := boards[metric](ctx, depth, 0)
+		if err != nil {
+			fmt.Println("error getting leaderboard for achievements: ", err)
+			return err
+		}
+		for _, entry := range entries {
+			if metrics[entry.UserID] == nil {
+				metrics[entry.UserID] = map[string]float64{}
+			}
+			metrics[entry.UserID][metric] = float64(entry.Rank)
+		}
+	}
+
+	for userID, userMetrics := range metrics {
+		candidates, err := as.unearnedBadges(ctx, userID, domain.EventLeaderboardSnapshot)
+		if err != nil {
+			return err
+		}
+		if _, err := as.award(ctx, userID, candidates, userMetrics, now); err != nil {
+			fmt.Println("error awarding leaderboard badges: ", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// RunSnapshots runs SnapshotLeaderboards every interval until the context is done.
+func (as *achievementService) RunSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := as.SnapshotLeaderboards(ctx, now); err != nil {
+				fmt.Println("error taking leaderboard snapshot: ", err)
+			}
+		}
+	}
+}
+
+// unearnedBadges returns the badges of an event the user has not earned yet.
+func (as *achievementService) unearnedBadges(ctx context.Context, userID int64, event string) ([]domain.BadgeDefinition, error) {
+	achievements, err := as.achievementRepository.GetUserAchievements(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	earned := make(map[string]bool, len(achievements))
+	for _, achievement := range achievements {
+		earned[achievement.Code] = true
+	}
+	var badges []domain.BadgeDefinition
+	for _, badge := range as.badges {
+		if badge.Event == event && !earned[badge.Code] {
+			badges = append(badges, badge)
+		}
+	}
+	return badges, nil
+}
+
+// award gives the user the candidate badges whose rules hold for the metrics, and
+// returns those that were newly earned.
+func (as *achievementService) award(ctx context.Context, userID int64, candidates []domain.BadgeDefinition, metrics map[string]float64, now time.Time) ([]domain.Badge, error) {
+	var codes []string
+	byCode := map[string]domain.BadgeDefinition{}
+	for _, badge := range candidates {
+		if badgeEarned(badge, metrics) {
+			codes = append(codes, badge.Code)
+			byCode[badge.Code] = badge
+		}
+	}
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	awarded, err := as.achievementRepository.AwardAchievements(ctx, userID, codes, now)
+	if err != nil {
+		return nil, err
+	}
+	badges := make([]domain.Badge, len(awarded))
+	for i, code := range awarded {
+		badge := byCode[code]
+		badges[i] = domain.Badge{Code: code, Name: badge.Name, Description: badge.Description, EarnedAt: now}
+	}
+	return badges, nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAchievementService(pool *sql.DB) *achievementService {
+	return NewAchievementService(DefaultBadges, repository.NewAchievementRepository(pool), repository.NewScoreRepository(pool), repository.NewStreakRepository(pool), repository.NewLeaderboardRepository(pool))
+}
+
+func TestLoadBadges(t *testing.T) {
+	badges, err := LoadBadges("")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultBadges, badges)
+	assert.Nil(t, ValidateBadges(DefaultBadges))
+
+	path := filepath.Join(t.TempDir(), "badges.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`[{"code": "night_owl", "name": "Night Owl", "event": "quiz_submitted", "rules": [{"metric": "quiz_time_seconds", "op": "gte", "value": 3600}]}]`), 0o644))
+	badges, err = LoadBadges(path)
+	assert.Nil(t, err)
+	assert.Len(t, badges, 1)
+	assert.Equal(t, "night_owl", badges[0].Code)
+
+	_, err = LoadBadges(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+
+	rule := domain.BadgeRule{Metric: "quizzes_taken", Op: "gte", Value: 1}
+	for name, badge := range map[string]domain.BadgeDefinition{
+		"no code":        {Name: "A", Event: domain.EventQuizSubmitted, Rules: []domain.BadgeRule{rule}},
+		"unknown event":  {Code: "a", Name: "A", Event: "signed_up", Rules: []domain.BadgeRule{rule}},
+		"no rules":       {Code: "a", Name: "A", Event: domain.EventQuizSubmitted},
+		"unknown op":     {Code: "a", Name: "A", Event: domain.EventQuizSubmitted, Rules: []domain.BadgeRule{{Metric: "quizzes_taken", Op: "gt", Value: 1}}},
+		"wrong event":    {Code: "a", Name: "A", Event: domain.EventLeaderboardSnapshot, Rules: []domain.BadgeRule{rule}},
+		"modes on ranks": {Code: "a", Name: "A", Event: domain.EventLeaderboardSnapshot, Modes: []string{domain.ModeDaily}, Rules: []domain.BadgeRule{{Metric: "weekly_rank", Op: "lte", Value: 1}}},
+	} {
+		assert.ErrorIs(t, ValidateBadges([]domain.BadgeDefinition{badge}), pkg.ErrInvalidBadge, name)
+	}
+	duplicate := domain.BadgeDefinition{Code: "a", Name: "A", Event: domain.EventQuizSubmitted, Rules: []domain.BadgeRule{rule}}
+	assert.ErrorIs(t, ValidateBadges([]domain.BadgeDefinition{duplicate, duplicate}), pkg.ErrInvalidBadge)
+}
+
+func TestBadgeEarned(t *testing.T) {
+	badge := domain.BadgeDefinition{Rules: []domain.BadgeRule{
+		{Metric: "quiz_accuracy", Op: "eq", Value: 100},
+		{Metric: "quiz_questions", Op: "gte", Value: 5},
+		{Metric: "quiz_time_seconds", Op: "lte", Value: 60},
+	}}
+	assert.True(t, badgeEarned(badge, map[string]float64{"quiz_accuracy": 100, "quiz_questions": 5, "quiz_time_seconds": 60}))
+	assert.False(t, badgeEarned(badge, map[string]float64{"quiz_accuracy": 80, "quiz_questions": 5, "quiz_time_seconds": 60}))
+	assert.False(t, badgeEarned(badge, map[string]float64{"quiz_accuracy": 100, "quiz_questions": 4, "quiz_time_seconds": 60}))
+	assert.False(t, badgeEarned(badge, map[string]float64{"quiz_accuracy": 100, "quiz_questions": 5, "quiz_time_seconds": 61}))
+	assert.False(t, badgeEarned(badge, map[string]float64{"quiz_accuracy": 100, "quiz_questions": 5}), "a missing metric does not hold")
+}
+
+func TestAchievements(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	streakRepo := repository.NewStreakRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, repository.NewScoreRepository(pool), repository.NewQuizSessionRepository(pool), repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	badges := []domain.BadgeDefinition{
+		{Code: "perfect", Name: "Perfect", Description: "Answer every question correctly", Event: domain.EventQuizSubmitted, Rules: []domain.BadgeRule{{Metric: "quiz_accuracy", Op: "eq", Value: 100}}},
+		{Code: "two_quizzes", Name: "Two Down", Event: domain.EventQuizSubmitted, Rules: []domain.BadgeRule{{Metric: "quizzes_taken", Op: "gte", Value: 2}}},
+		{Code: "exam_taker", Name: "Exam Taker", Event: domain.EventQuizSubmitted, Modes: []string{domain.ModeExam}, Rules: []domain.BadgeRule{{Metric: "quizzes_taken", Op: "gte", Value: 1}}},
+		{Code: "weekly_top", Name: "Top of the Week", Event: domain.EventLeaderboardSnapshot, Rules: []domain.BadgeRule{{Metric: "weekly_rank", Op: "lte", Value: 1}}},
+	}
+	as := NewAchievementService(badges, repository.NewAchievementRepository(pool), repository.NewScoreRepository(pool), streakRepo, repository.NewLeaderboardRepository(pool))
+	qs.OnSubmit(as.RecordSubmission)
+
+	for _, name := range []string{"ada", "grace"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.Nil(t, err)
+	}
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	takeQuiz := func(userID int64, numCorrect int) *domain.QuizSubmitResponse {
+		t.Helper()
+		quiz, err := qs.GenerateQuizBySubjectID(ctx, userID, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2})
+		assert.Nil(t, err)
+		result, err := qs.SubmitQuiz(ctx, userID, answerQuiz(t, ctx, questionRepo, quiz, numCorrect))
+		assert.Nil(t, err)
+		return result
+	}
+
+	// a perfect quiz earns its badge, and only the badges of the quiz's mode are considered
+	result := takeQuiz(1, 2)
+	assert.Len(t, result.Badges, 1)
+	assert.Equal(t, "perfect", result.Badges[0].Code)
+	assert.Equal(t, "Perfect", result.Badges[0].Name)
+	earnedAt := result.Badges[0].EarnedAt
+
+	// badges are earned once
+	result = takeQuiz(1, 2)
+	assert.Len(t, result.Badges, 1)
+	assert.Equal(t, "two_quizzes", result.Badges[0].Code)
+	result = takeQuiz(2, 0)
+	assert.Empty(t, result.Badges)
+
+	userBadges, err := as.GetUserBadges(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, userBadges, 2)
+	assert.Equal(t, "perfect", userBadges[0].Code)
+	assert.WithinDuration(t, earnedAt, userBadges[0].EarnedAt, time.Second)
+
+	// leaderboard snapshots award the badges of places on the boards
+	assert.Nil(t, as.SnapshotLeaderboards(ctx, time.Now()))
+	userBadges, err = as.GetUserBadges(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, userBadges, 3)
+	assert.Equal(t, "weekly_top", userBadges[2].Code)
+	userBadges, err = as.GetUserBadges(ctx, 2)
+	assert.Nil(t, err)
+	assert.Empty(t, userBadges)
+	assert.Nil(t, as.SnapshotLeaderboards(ctx, time.Now()))
+
+	// badges show on the dashboard and the public profile
+	userService := NewUserService(*repository.NewUserRepository(pool), repository.NewScoreRepository(pool), streakRepo, repository.NewXPRepository(pool), as, log.New(os.Stdout, "", 0))
+	dashboard, err := userService.UserDashboard(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, dashboard.Badges, 3)
+	profile, err := userService.GetPublicProfile(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "ada", profile.Name)
+	assert.Equal(t, int64(2), profile.TotalQuizzesTaken)
+	assert.Equal(t, dashboard.Badges, profile.Badges)
+	_, err = userService.GetPublicProfile(ctx, 99)
+	assert.ErrorIs(t, err, pkg.ErrUserNotFound)
+
+	// a badge taken out of the configuration is still shown by its code
+	as.badges = as.badges[1:]
+	userBadges, err = as.GetUserBadges(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "perfect", userBadges[0].Name)
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// AnalyticsTrendQuizzes is how many of the user's most recent quizzes their trend
+	// compares with the ones before them
+	AnalyticsTrendQuizzes = 5
+	// AnalyticsTrendThreshold is how many percentage points accuracy has to move by for
+	// the trend to be improving or declining rather than steady
+	AnalyticsTrendThreshold = 5
+	// DefaultAnalyticsDays and DefaultAnalyticsWeeks are how many buckets the series
+	// covers when no number is given
+	DefaultAnalyticsDays  = 30
+	DefaultAnalyticsWeeks = 12
+)
+
+type AnalyticsService interface {
+	GetLearnerAnalytics(ctx context.Context, userID int64, query domain.AnalyticsQuery) (*domain.LearnerAnalytics, error)
+}
+
+// analyticsService reports how learners are doing in their subjects. The totals,
+// percentiles and trends are worked out by the database; the series is bucketed here
+// into calendar days or weeks, starting Monday, in UTC. Reports are cached in Redis for
+// cacheTTL, in a hash per user that is dropped whenever they submit a quiz; a cacheTTL of
+// 0 turns caching off.
+type analyticsService struct {
+	redisClient       *redis.Client
+	scoreRepository   repository.ScoreRepository
+	subjectRepository repository.SubjectRepository
+	cacheTTL          time.Duration
+}
+
+func NewAnalyticsService(redisClient *redis.Client, scoreRepository repository.ScoreRepository, subjectRepository repository.SubjectRepository, cacheTTL time.Duration) *analyticsService {
+	return &analyticsService{
+		redisClient:       redisClient,
+		scoreRepository:   scoreRepository,
+		subjectRepository: subjectRepository,
+		cacheTTL:          cacheTTL,
+	}
+}
+
+// GetLearnerAnalytics returns how the user is doing overall and in each subject they
+// have answered questions in, how they compare with other users and how their accuracy
+// has changed over the series' days or weeks.
+func (as *analyticsService) GetLearnerAnalytics(ctx context.Context, userID int64, query domain.AnalyticsQuery) (*domain.LearnerAnalytics, error) {
+	if query.Interval == "" {
+		query.Interval = "weekly"
+	}
+	if query.Periods == 0 {
+		query.Periods = DefaultAnalyticsWeeks
+		if query.Interval == "daily" {
+			query.Periods = DefaultAnalyticsDays
+		}
+	}
+	field := fmt.Sprintf("%s:%d", query.Interval, query.Periods)
+	if analytics := as.cachedAnalytics(ctx, userID, field); analytics != nil {
+		return analytics, nil
+	}
+
+	now := time.Now()
+	subjects, err := as.scoreRepository.GetUserSubjectStats(ctx, userID)
+	if err != nil {
+		fmt.Println("error getting subject stats: ", err)
+		return nil, err
+	}
+	windows, err := as.scoreRepository.GetUserAccuracyWindows(ctx, userID, AnalyticsTrendQuizzes)
+	if err != nil {
+		fmt.Println("error getting accuracy windows: ", err)
+		return nil, err
+	}
+	trends := make(map[int64]domain.AccuracyTrend, len(windows))
+	for _, window := range windows {
+		trends[window.SubjectId] = accuracyTrend(window)
+	}
+	from := analyticsBucket(now, query.Interval)
+	if query.Interval == "daily" {
+		from = from.AddDate(0, 0, 1-query.Periods)
+	} else {
+		from = from.AddDate(0, 0, 7*(1-query.Periods))
+	}
+	quizzes, err := as.scoreRepository.GetUserAnsweredQuestionsSince(ctx, userID, from.Local())
+	if err != nil {
+		fmt.Println("error getting answered questions: ", err)
+		return nil, err
+	}
+	series := accuracySeries(quizzes, query.Interval)
+
+	analytics := &domain.LearnerAnalytics{
+		Interval:    query.Interval,
+		From:        from.Format(time.DateOnly),
+		Overall:     domain.AnalyticsSummary{Trend: trends[0]},
+		Subjects:    subjects,
+		GeneratedAt: now,
+	}
+	var timeTaken int64
+	for i := range subjects {
+		subject := &subjects[i]
+		if s, err := as.subjectRepository.GetSubjectById(ctx, subject.SubjectId); err == nil {
+			subject.SubjectName = s.Name
+		}
+		subject.Trend = trends[subject.SubjectId]
+		subject.Series = series[subject.SubjectId]
+		if subject.Series == nil {
+			subject.Series = []domain.AccuracyPoint{}
+		}
+		analytics.Overall.QuestionsAnswered += subject.QuestionsAnswered
+		analytics.Overall.CorrectAnswers += subject.CorrectAnswers
+		timeTaken += subject.TimeTakenSeconds
+	}
+	if analytics.Overall.QuestionsAnswered > 0 {
+		analytics.Overall.Accuracy = float64(analytics.Overall.CorrectAnswers) / float64(analytics.Overall.QuestionsAnswered) * 100
+		analytics.Overall.AvgSecondsPerQuestion = float64(timeTaken) / float64(analytics.Overall.QuestionsAnswered)
+	}
+	analytics.BestSubject, analytics.WorstSubject = highlightSubjects(subjects)
+
+	as.cacheAnalytics(ctx, userID, field, analytics)
+	return analytics, nil
+}
+
+// RecordSubmission drops the user's cached analytics, so their next report includes the
+// quiz they have just submitted.
+func (as *analyticsService) RecordSubmission(ctx context.Context, session *repository.QuizSession, result *domain.QuizSubmitResponse) error {
+	if as.cacheTTL <= 0 {
+		return nil
+	}
+	return as.redisClient.Del(ctx, analyticsKey(session.UserId)).Err()
+}
+
+// cachedAnalytics returns the user's cached report for a query, or nil if there is none
+// younger than cacheTTL.
+func (as *analyticsService) cachedAnalytics(ctx context.Context, userID int64, field string) *domain.LearnerAnalytics {
+	if as.cacheTTL <= 0 {
+		return nil
+	}
+	cached, err := as.redisClient.HGet(ctx, analyticsKey(userID), field).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			fmt.Println("error getting analytics from redis: ", err)
+		}
+		return nil
+	}
+	var analytics domain.LearnerAnalytics
+	if err := json.Unmarshal(cached, &analytics); err != nil || time.Since(analytics.GeneratedAt) >= as.cacheTTL {
+		return nil
+	}
+	return &analytics
+}
+
+// cacheAnalytics caches the user's report for a query. The hash expires cacheTTL after
+// the last report was cached in it, so each report's own age is checked when it is read.
+func (as *analyticsService) cacheAnalytics(ctx context.Context, userID int64, field string, analytics *domain.LearnerAnalytics) {
+	if as.cacheTTL <= 0 {
+		return
+	}
+	data, err := json.Marshal(analytics)
+	if err != nil {
+		return
+	}
+	key := analyticsKey(userID)
+	pipe := as.redisClient.TxPipeline()
+	pipe.HSet(ctx, key, field, data)
+	pipe.Expire(ctx, key, as.cacheTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Println("error caching analytics in redis: ", err)
+	}
+}
+
+func analyticsKey(userID int64) string {
+	return fmt.Sprintf("analytics:%d", userID)
+}
+
+// analyticsBucket returns the first day of the day or week, in UTC, a time falls in.
+func analyticsBucket(t time.Time, interval string) time.Time {
+	day := dailyDate(t)
+	if interval == "daily" {
+		return day
+	}
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// accuracySeries adds up the questions of each subject answered in each day or week,
+// oldest first.
+func accuracySeries(quizzes []repository.AnsweredQuestions, interval string) map[int64][]domain.AccuracyPoint {
+	series := map[int64][]domain.AccuracyPoint{}
+	for _, quiz := range quizzes {
+		start := analyticsBucket(quiz.AnsweredAt, interval).Format(time.DateOnly)
+		points := series[quiz.SubjectId]
+		if len(points) == 0 || points[len(points)-1].Start != start {
+			points = append(points, domain.AccuracyPoint{Start: start})
+		}
+		point := &points[len(points)-1]
+		point.QuestionsAnswered += quiz.TotalQuestions
+		point.CorrectAnswers += quiz.CorrectAnswers
+		if point.QuestionsAnswered > 0 {
+			point.Accuracy = float64(point.CorrectAnswers) / float64(point.QuestionsAnswered) * 100
+		}
+		series[quiz.SubjectId] = points
+	}
+	return series
+}
+
+// accuracyTrend compares the accuracy of the user's most recent quizzes with the quizzes
+// before them.
+func accuracyTrend(window repository.AccuracyWindows) domain.AccuracyTrend {
+	trend := domain.AccuracyTrend{Direction: domain.TrendInsufficientData}
+	if window.RecentTotal > 0 {
+		trend.RecentAccuracy = float64(window.RecentCorrect) / float64(window.RecentTotal) * 100
+	}
+	if window.PreviousTotal > 0 {
+		trend.PreviousAccuracy = float64(window.PreviousCorrect) / float64(window.PreviousTotal) * 100
+	}
+	if window.RecentTotal == 0 || window.PreviousTotal == 0 {
+		return trend
+	}
+	trend.Change = trend.RecentAccuracy - trend.PreviousAccuracy
+	switch {
+	case trend.Change >= AnalyticsTrendThreshold:
+		trend.Direction = domain.TrendImproving
+	case trend.Change <= -AnalyticsTrendThreshold:
+		trend.Direction = domain.TrendDeclining
+	default:
+		trend.Direction = domain.TrendSteady
+	}
+	return trend
+}
+
+// highlightSubjects returns the subjects the user is most and least accurate in, of those
+// they have answered at least AdaptiveMinQuestions questions in. There are none unless
+// there are two such subjects to compare.
+func highlightSubjects(subjects []domain.SubjectAnalytics) (best, worst *domain.SubjectHighlight) {
+	var ranked []domain.SubjectAnalytics
+	for _, subject := range subjects {
+		if subject.QuestionsAnswered >= AdaptiveMinQuestions {
+			ranked = append(ranked, subject)
+		}
+	}
+	if len(ranked) < 2 {
+		return nil, nil
+	}
+	bestSubject, worstSubject := ranked[0], ranked[0]
+	for _, subject := range ranked[1:] {
+		if subject.Accuracy > bestSubject.Accuracy {
+			bestSubject = subject
+		}
+		if subject.Accuracy < worstSubject.Accuracy {
+			worstSubject = subject
+		}
+	}
+	if bestSubject.SubjectId == worstSubject.SubjectId {
+		worstSubject = ranked[1]
+	}
+	return &domain.SubjectHighlight{SubjectId: bestSubject.SubjectId, SubjectName: bestSubject.SubjectName, Accuracy: bestSubject.Accuracy},
+		&domain.SubjectHighlight{SubjectId: worstSubject.SubjectId, SubjectName: worstSubject.SubjectName, Accuracy: worstSubject.Accuracy}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccuracyTrend(t *testing.T) {
+	tests := []struct {
+		window    repository.AccuracyWindows
+		direction string
+		change    float64
+	}{
+		{window: repository.AccuracyWindows{}, direction: domain.TrendInsufficientData},
+		{window: repository.AccuracyWindows{RecentCorrect: 4, RecentTotal: 5}, direction: domain.TrendInsufficientData},
+		{window: repository.AccuracyWindows{RecentCorrect: 8, RecentTotal: 10, PreviousCorrect: 6, PreviousTotal: 10}, direction: domain.TrendImproving, change: 20},
+		{window: repository.AccuracyWindows{RecentCorrect: 6, RecentTotal: 10, PreviousCorrect: 8, PreviousTotal: 10}, direction: domain.TrendDeclining, change: -20},
+		{window: repository.AccuracyWindows{RecentCorrect: 41, RecentTotal: 100, PreviousCorrect: 40, PreviousTotal: 100}, direction: domain.TrendSteady, change: 1},
+	}
+	for _, test := range tests {
+		trend := accuracyTrend(test.window)
+		assert.Equal(t, test.direction, trend.Direction, test.window)
+		assert.InDelta(t, test.change, trend.Change, 1e-9, test.window)
+	}
+}
+
+func TestAccuracySeries(t *testing.T) {
+	// 2025-03-05 is a Wednesday
+	wednesday := time.Date(2025, 3, 5, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), analyticsBucket(wednesday, "weekly"))
+	assert.Equal(t, time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC), analyticsBucket(wednesday, "daily"))
+	sunday := time.Date(2025, 3, 9, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), analyticsBucket(sunday, "weekly"))
+
+	quizzes := []repository.AnsweredQuestions{
+		{SubjectId: 1, CorrectAnswers: 1, TotalQuestions: 4, AnsweredAt: wednesday},
+		{SubjectId: 2, CorrectAnswers: 2, TotalQuestions: 2, AnsweredAt: wednesday},
+		{SubjectId: 1, CorrectAnswers: 3, TotalQuestions: 4, AnsweredAt: sunday},
+		{SubjectId: 1, CorrectAnswers: 2, TotalQuestions: 2, AnsweredAt: sunday.Add(2 * time.Hour)},
+	}
+	series := accuracySeries(quizzes, "weekly")
+	assert.Equal(t, []domain.AccuracyPoint{
+		{Start: "2025-03-03", QuestionsAnswered: 8, CorrectAnswers: 4, Accuracy: 50},
+		{Start: "2025-03-10", QuestionsAnswered: 2, CorrectAnswers: 2, Accuracy: 100},
+	}, series[1])
+	assert.Len(t, series[2], 1)
+	assert.Len(t, accuracySeries(quizzes, "daily")[1], 3)
+}
+
+func TestHighlightSubjects(t *testing.T) {
+	subjects := []domain.SubjectAnalytics{
+		{SubjectId: 1, SubjectName: "english", QuestionsAnswered: 20, Accuracy: 60},
+		{SubjectId: 2, SubjectName: "maths", QuestionsAnswered: 20, Accuracy: 90},
+		{SubjectId: 3, SubjectName: "physics", QuestionsAnswered: 4, Accuracy: 10},
+	}
+	best, worst := highlightSubjects(subjects)
+	assert.Equal(t, &domain.SubjectHighlight{SubjectId: 2, SubjectName: "maths", Accuracy: 90}, best)
+	assert.Equal(t, &domain.SubjectHighlight{SubjectId: 1, SubjectName: "english", Accuracy: 60}, worst)
+
+	// a subject with too few questions answered is not compared
+	best, worst = highlightSubjects(subjects[1:])
+	assert.Nil(t, best)
+	assert.Nil(t, worst)
+}
+
+func TestLearnerAnalytics(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, repository.NewQuizSessionRepository(pool), repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	as := NewAnalyticsService(redisClient, scoreRepo, subjectRepo, time.Minute)
+	qs.OnSubmit(as.RecordSubmission)
+
+	for _, name := range []string{"ada", "grace"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.Nil(t, err)
+	}
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+	takeQuiz := func(userID int64, numCorrect int) {
+		t.Helper()
+		quiz, err := qs.GenerateQuizBySubjectID(ctx, userID, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 4})
+		assert.Nil(t, err)
+		_, err = qs.SubmitQuiz(ctx, userID, answerQuiz(t, ctx, questionRepo, quiz, numCorrect))
+		assert.Nil(t, err)
+	}
+
+	analytics, err := as.GetLearnerAnalytics(ctx, 1, domain.AnalyticsQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, "weekly", analytics.Interval)
+	assert.Empty(t, analytics.Subjects)
+	assert.Equal(t, domain.TrendInsufficientData, analytics.Overall.Trend.Direction)
+
+	takeQuiz(1, 1)
+	takeQuiz(1, 4)
+	takeQuiz(2, 2)
+	analytics, err = as.GetLearnerAnalytics(ctx, 1, domain.AnalyticsQuery{Interval: "daily", Periods: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, "daily", analytics.Interval)
+	assert.Equal(t, dailyDate(time.Now()).AddDate(0, 0, -6).Format(time.DateOnly), analytics.From)
+	assert.Equal(t, int64(8), analytics.Overall.QuestionsAnswered)
+	assert.Equal(t, float64(5)/8*100, analytics.Overall.Accuracy)
+	assert.Len(t, analytics.Subjects, 1)
+	subject := analytics.Subjects[0]
+	assert.Equal(t, "use of english", subject.SubjectName)
+	assert.Equal(t, int64(2), subject.QuizzesTaken)
+	assert.Equal(t, int64(2), subject.UsersRanked)
+	assert.Equal(t, float64(100), subject.Percentile)
+	assert.Equal(t, domain.TrendInsufficientData, subject.Trend.Direction)
+	assert.Equal(t, float64(5)/8*100, subject.Trend.RecentAccuracy)
+	assert.Len(t, subject.Series, 1)
+	assert.Equal(t, int64(8), subject.Series[0].QuestionsAnswered)
+	assert.Nil(t, analytics.BestSubject)
+
+	// reports are cached until the user submits another quiz
+	_, err = pool.Exec("INSERT INTO scores (user_id, subject_id, mode, correct_answers, total_questions, time_taken_seconds, created_at) VALUES (1, $1, 'practice', 0, 4, 10, $2)", subjectId, time.Now())
+	assert.Nil(t, err)
+	cached, err := as.GetLearnerAnalytics(ctx, 1, domain.AnalyticsQuery{Interval: "daily", Periods: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), cached.Overall.QuestionsAnswered)
+	assert.True(t, mr.Exists(analyticsKey(1)))
+	takeQuiz(1, 0)
+	analytics, err = as.GetLearnerAnalytics(ctx, 1, domain.AnalyticsQuery{Interval: "daily", Periods: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(16), analytics.Overall.QuestionsAnswered)
+
+	// with caching off every report is worked out afresh
+	uncached := NewAnalyticsService(redisClient, scoreRepo, subjectRepo, 0)
+	mr.FlushAll()
+	analytics, err = uncached.GetLearnerAnalytics(ctx, 2, domain.AnalyticsQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(100), analytics.Subjects[0].Percentile, "ada has dropped below grace")
+	assert.False(t, mr.Exists(analyticsKey(2)))
+}
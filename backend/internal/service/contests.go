@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/internal/storage"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// DefaultContestLimit is how many contests are listed when no limit is given
+const DefaultContestLimit = 20
+
+type ContestService interface {
+	CreateContest(ctx context.Context, userID int64, contest domain.ContestData) (*domain.Contest, error)
+	GetContests(ctx context.Context, userID int64, query domain.ContestQuery) (*domain.ContestListResponse, error)
+	GetContest(ctx context.Context, userID int64, id int64) (*domain.Contest, error)
+	EnterContest(ctx context.Context, userID int64, id int64) (*domain.GeneratedQuizResponse, error)
+	GetContestLeaderboard(ctx context.Context, userID int64, id int64) (*domain.ContestLeaderboardResponse, error)
+}
+
+// contestService runs scheduled contests. Entries are issued and submitted as quiz
+// sessions in contest mode, so they are graded like any other quiz.
+type contestService struct {
+	contestRepository repository.ContestRepository
+	quiz              *quizService
+}
+
+func NewContestService(contestRepository repository.ContestRepository, subjectRepository repository.SubjectRepository, questionRepository repository.QuestionRepository, quizSessionRepository repository.QuizSessionRepository, mediaRepository repository.MediaRepository, storage storage.Storage) *contestService {
+	return &contestService{
+		contestRepository: contestRepository,
+		quiz:              &quizService{subjectRepository: subjectRepository, questionRepository: questionRepository, quizSessionRepository: quizSessionRepository, mediaRepository: mediaRepository, storage: storage},
+	}
+}
+
+// CreateContest schedules a contest on a subject. The questions are fixed when it is
+// created: the ones given, in order, or a random draw from the subject. The subject's
+// scoring policy is snapshotted so every entry is scored the same.
+func (cs *contestService) CreateContest(ctx context.Context, userID int64, contest domain.ContestData) (*domain.Contest, error) {
+	now := time.Now()
+	if !contest.EndsAt.After(contest.StartsAt) || !contest.EndsAt.After(now) {
+		return nil, pkg.ErrInvalidContestWindow
+	}
+	if len(contest.QuestionIds) == 0 && contest.NumOfQuestions == 0 {
+		return nil, pkg.ErrContestQuestionsRequired
+	}
+	if _, err := cs.quiz.subjectRepository.GetSubjectById(ctx, contest.SubjectId); err != nil {
+		fmt.Println("error getting subject: ", err)
+		return nil, pkg.ErrSubjectNotFound
+	}
+	scoringPolicy, err := cs.quiz.subjectRepository.GetSubjectScoringPolicy(ctx, contest.SubjectId)
+	if err != nil {
+		fmt.Println("error getting scoring policy: ", err)
+		return nil, err
+	}
+	questionIds, err := cs.contestQuestions(ctx, contest)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := repository.Contest{
+		Title:           contest.Title,
+		Description:     contest.Description,
+		SubjectId:       contest.SubjectId,
+		DurationSeconds: contest.DurationSeconds,
+		ScoringPolicy:   *scoringPolicy,
+		QuestionIds:     questionIds,
+		StartsAt:        contest.StartsAt,
+		EndsAt:          contest.EndsAt,
+		CreatedBy:       userID,
+		CreatedAt:       now,
+	}
+	stored.Id, err = cs.contestRepository.CreateContest(ctx, stored)
+	if err != nil {
+		fmt.Println("error storing contest: ", err)
+		return nil, err
+	}
+	result := contestResponse(stored, now)
+	return &result, nil
+}
+
+// contestQuestions returns the questions of a new contest: the ones given, which must all
+// be questions of its subject, or NumOfQuestions drawn at random from the subject.
+func (cs *contestService) contestQuestions(ctx context.Context, contest domain.ContestData) ([]int64, error) {
+	if len(contest.QuestionIds) == 0 {
+		candidates, err := cs.quiz.questionRepository.GetSubjectQuestionIds(ctx, contest.SubjectId, repository.QuestionFilter{})
+		if err != nil {
+			fmt.Println("error getting subject questions: ", err)
+			return nil, err
+		}
+		if int64(len(candidates)) < contest.NumOfQuestions {
+			return nil, fmt.Errorf("%w: subject %d has %d questions, %d were requested", pkg.ErrNotEnoughQuestions, contest.SubjectId, len(candidates), contest.NumOfQuestions)
+		}
+		rand.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+		return candidates[:contest.NumOfQuestions], nil
+	}
+
+	questions, err := cs.quiz.questionRepository.GetQuestionsByIds(ctx, contest.QuestionIds)
+	if err != nil {
+		fmt.Println("error getting contest questions: ", err)
+		return nil, err
+	}
+	seen := make(map[int64]bool, len(contest.QuestionIds))
+	for _, questionId := range contest.QuestionIds {
+		inSubject := slices.ContainsFunc(questions, func(question repository.Questions) bool {
+			return question.Id == questionId && question.SubjectId == contest.SubjectId
+		})
+		if !inSubject || seen[questionId] {
+			return nil, fmt.Errorf("%w: question %d", pkg.ErrInvalidContestQuestions, questionId)
+		}
+		seen[questionId] = true
+	}
+	return contest.QuestionIds, nil
+}
+
+// GetContests lists the contests of a status, live ones by default.
+func (cs *contestService) GetContests(ctx context.Context, userID int64, query domain.ContestQuery) (*domain.ContestListResponse, error) {
+	if query.Status == "" {
+		query.Status = domain.ContestLive
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultContestLimit
+	}
+	now := time.Now()
+	contests, total, err := cs.contestRepository.GetContests(ctx, userID, query.Status, now, query.Limit, query.Offset)
+	if err != nil {
+		fmt.Println("error getting contests: ", err)
+		return nil, err
+	}
+	response := &domain.ContestListResponse{Status: query.Status, Total: total, Contests: make([]domain.Contest, len(contests))}
+	for i, contest := range contests {
+		response.Contests[i] = contestResponse(contest, now)
+	}
+	return response, nil
+}
+
+// GetContest returns a contest and whether the user has entered it.
+func (cs *contestService) GetContest(ctx context.Context, userID int64, id int64) (*domain.Contest, error) {
+	contest, err := cs.getContest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	contest.Entered, err = cs.contestRepository.HasUserEnteredContest(ctx, userID, contest.Id)
+	if err != nil {
+		fmt.Println("error checking contest entries: ", err)
+		return nil, err
+	}
+	result := contestResponse(*contest, time.Now())
+	return &result, nil
+}
+
+func (cs *contestService) getContest(ctx context.Context, id int64) (*repository.Contest, error) {
+	contest, err := cs.contestRepository.GetContestById(ctx, id)
+	if err != nil && !errors.Is(err, pkg.ErrContestNotFound) {
+		fmt.Println("error getting contest: ", err)
+	}
+	return contest, err
+}
+
+// EnterContest issues the user a quiz session with the contest's questions, in order.
+// A contest can only be entered once per user, while it is open. The entry is due when
+// the contest ends, or after the contest's duration if that comes first. Questions
+// deleted since the contest was created are left out.
+func (cs *contestService) EnterContest(ctx context.Context, userID int64, id int64) (*domain.GeneratedQuizResponse, error) {
+	contest, err := cs.getContest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if contestStatus(*contest, now) != domain.ContestLive {
+		return nil, pkg.ErrContestNotOpen
+	}
+	entered, err := cs.contestRepository.HasUserEnteredContest(ctx, userID, contest.Id)
+	if err != nil {
+		fmt.Println("error checking contest entries: ", err)
+		return nil, err
+	}
+	if entered {
+		return nil, pkg.ErrContestAlreadyEntered
+	}
+
+	questions, err := cs.quiz.questionRepository.GetQuestionsByIds(ctx, contest.QuestionIds)
+	if err != nil {
+		fmt.Println("error getting contest questions: ", err)
+		return nil, err
+	}
+	byId := make(map[int64]repository.Questions, len(questions))
+	for _, question := range questions {
+		byId[question.Id] = question
+	}
+	picked := make([]pickedQuestion, 0, len(contest.QuestionIds))
+	for _, questionId := range contest.QuestionIds {
+		if question, ok := byId[questionId]; ok {
+			picked = append(picked, pickedQuestion{Questions: question})
+		}
+	}
+
+	numOfQuestions := int64(len(picked))
+	return cs.quiz.issueQuiz(ctx, userID, quizPlan{
+		mode:            domain.ModeContest,
+		durationSeconds: contest.DurationSeconds,
+		breakdown:       []domain.QuizSubjectRequest{{SubjectId: contest.SubjectId, NumOfQuestions: numOfQuestions}},
+		subjects: []repository.QuizSessionSubject{{
+			SubjectId:      contest.SubjectId,
+			NumOfQuestions: numOfQuestions,
+			ScoringPolicy:  contest.ScoringPolicy,
+		}},
+		questions: picked,
+		contest:   contest,
+	}, now)
+}
+
+// GetContestLeaderboard returns the leaderboard of a closed contest. It is frozen the
+// first time it is asked for once the contest has closed and the grace period for
+// submitting entries due at its end has passed, and never changes after that.
+func (cs *contestService) GetContestLeaderboard(ctx context.Context, userID int64, id int64) (*domain.ContestLeaderboardResponse, error) {
+	contest, err := cs.getContest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	closedAt := contest.EndsAt.Add(ExamSubmissionGracePeriod)
+	if now.Before(closedAt) {
+		return nil, pkg.ErrContestLeaderboardNotReady
+	}
+	if contest.FrozenAt == nil {
+		if err := cs.contestRepository.FreezeContestStandings(ctx, contest.Id, closedAt, now); err != nil {
+			fmt.Println("error freezing contest standings: ", err)
+			return nil, err
+		}
+		// Another request may have frozen it first
+		if contest, err = cs.getContest(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	standings, err := cs.contestRepository.GetContestStandings(ctx, contest.Id)
+	if err != nil {
+		fmt.Println("error getting contest standings: ", err)
+		return nil, err
+	}
+
+	leaderboard := &domain.ContestLeaderboardResponse{
+		ContestId: contest.Id,
+		FrozenAt:  *contest.FrozenAt,
+		Entrants:  int64(len(standings)),
+		Standings: standings,
+	}
+	for i := range standings {
+		if standings[i].UserID == userID {
+			leaderboard.Me = &standings[i]
+		}
+	}
+	return leaderboard, nil
+}
+
+// contestStatus returns whether a contest is upcoming, live or past at the given time.
+func contestStatus(contest repository.Contest, now time.Time) string {
+	switch {
+	case now.Before(contest.StartsAt):
+		return domain.ContestUpcoming
+	case now.Before(contest.EndsAt):
+		return domain.ContestLive
+	default:
+		return domain.ContestPast
+	}
+}
+
+func contestResponse(contest repository.Contest, now time.Time) domain.Contest {
+	return domain.Contest{
+		Id:              contest.Id,
+		Title:           contest.Title,
+		Description:     contest.Description,
+		SubjectId:       contest.SubjectId,
+		TotalQuestions:  len(contest.QuestionIds),
+		DurationSeconds: contest.DurationSeconds,
+		ScoringPolicy:   contest.ScoringPolicy.Name,
+		StartsAt:        contest.StartsAt,
+		EndsAt:          contest.EndsAt,
+		Status:          contestStatus(contest, now),
+		Entered:         contest.Entered,
+		CreatedAt:       contest.CreatedAt,
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContest(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	contestRepo := repository.NewContestRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, repository.NewScoreRepository(pool), sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+	cs := NewContestService(contestRepo, subjectRepo, questionRepo, sessionRepo, repository.NewMediaRepository(pool), testStorage(t))
+
+	for _, name := range []string{"ada", "grace", "linus"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.Nil(t, err)
+	}
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	otherSubjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(otherSubjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+	questionIds, err := questionRepo.GetSubjectQuestionIds(ctx, subjectId, repository.QuestionFilter{})
+	assert.Nil(t, err)
+	otherQuestionIds, err := questionRepo.GetSubjectQuestionIds(ctx, otherSubjectId, repository.QuestionFilter{})
+	assert.Nil(t, err)
+
+	now := time.Now()
+	live := domain.ContestData{Title: "Friday sprint", SubjectId: subjectId, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}
+
+	invalid := live
+	invalid.QuestionIds = []int64{questionIds[0]}
+	invalid.StartsAt, invalid.EndsAt = now.Add(-2*time.Hour), now.Add(-time.Hour)
+	_, err = cs.CreateContest(ctx, 1, invalid)
+	assert.ErrorIs(t, err, pkg.ErrInvalidContestWindow)
+	_, err = cs.CreateContest(ctx, 1, live)
+	assert.ErrorIs(t, err, pkg.ErrContestQuestionsRequired)
+	invalid = live
+	invalid.NumOfQuestions = int64(len(questionIds) + 1)
+	_, err = cs.CreateContest(ctx, 1, invalid)
+	assert.ErrorIs(t, err, pkg.ErrNotEnoughQuestions)
+	invalid.NumOfQuestions, invalid.QuestionIds = 0, []int64{questionIds[0], otherQuestionIds[0]}
+	_, err = cs.CreateContest(ctx, 1, invalid)
+	assert.ErrorIs(t, err, pkg.ErrInvalidContestQuestions)
+	invalid.QuestionIds = []int64{questionIds[0], questionIds[0]}
+	_, err = cs.CreateContest(ctx, 1, invalid)
+	assert.ErrorIs(t, err, pkg.ErrInvalidContestQuestions)
+
+	upcoming := live
+	upcoming.StartsAt, upcoming.EndsAt, upcoming.NumOfQuestions = now.Add(time.Hour), now.Add(2*time.Hour), 2
+	created, err := cs.CreateContest(ctx, 1, upcoming)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ContestUpcoming, created.Status)
+	assert.Equal(t, 2, created.TotalQuestions)
+	_, err = cs.EnterContest(ctx, 2, created.Id)
+	assert.ErrorIs(t, err, pkg.ErrContestNotOpen)
+
+	// the contest's questions are asked in the order given
+	live.QuestionIds = []int64{questionIds[2], questionIds[0], questionIds[1]}
+	live.DurationSeconds = 600
+	contest, err := cs.CreateContest(ctx, 1, live)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ContestLive, contest.Status)
+	assert.Equal(t, "standard", contest.ScoringPolicy)
+
+	first, err := cs.EnterContest(ctx, 2, contest.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ModeContest, first.Mode)
+	assert.Equal(t, contest.Id, first.ContestId)
+	assert.InDelta(t, 600, first.DurationSeconds, 1)
+	assert.Len(t, first.Questions, 3)
+	for i, question := range first.Questions {
+		assert.Equal(t, live.QuestionIds[i], question.QuestionId)
+	}
+	_, err = cs.EnterContest(ctx, 2, contest.Id)
+	assert.ErrorIs(t, err, pkg.ErrContestAlreadyEntered)
+	second, err := cs.EnterContest(ctx, 3, contest.Id)
+	assert.Nil(t, err)
+	_, err = cs.EnterContest(ctx, 1, contest.Id)
+	assert.Nil(t, err)
+	_, err = cs.EnterContest(ctx, 2, 999)
+	assert.ErrorIs(t, err, pkg.ErrContestNotFound)
+
+	got, err := cs.GetContest(ctx, 2, contest.Id)
+	assert.Nil(t, err)
+	assert.True(t, got.Entered)
+
+	listed, err := cs.GetContests(ctx, 2, domain.ContestQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ContestLive, listed.Status)
+	assert.Equal(t, int64(1), listed.Total)
+	assert.Equal(t, contest.Id, listed.Contests[0].Id)
+	assert.True(t, listed.Contests[0].Entered)
+	listed, err = cs.GetContests(ctx, 3, domain.ContestQuery{Status: domain.ContestUpcoming})
+	assert.Nil(t, err)
+	assert.Equal(t, created.Id, listed.Contests[0].Id)
+	assert.False(t, listed.Contests[0].Entered)
+
+	_, err = qs.SubmitQuiz(ctx, 2, answerQuiz(t, ctx, questionRepo, first, 1))
+	assert.Nil(t, err)
+	_, err = qs.SubmitQuiz(ctx, 3, answerQuiz(t, ctx, questionRepo, second, 3))
+	assert.Nil(t, err)
+	_, err = cs.GetContestLeaderboard(ctx, 2, contest.Id)
+	assert.ErrorIs(t, err, pkg.ErrContestLeaderboardNotReady)
+
+	// close the contest, with the entries submitted before it ended
+	_, err = pool.Exec("UPDATE contests SET starts_at = $1, ends_at = $2 WHERE id = $3", now.Add(-2*time.Hour), now.Add(-time.Hour), contest.Id)
+	assert.Nil(t, err)
+	_, err = pool.Exec("UPDATE quiz_sessions SET submitted_at = $1 WHERE contest_id = $2 AND submitted_at IS NOT NULL", now.Add(-90*time.Minute), contest.Id)
+	assert.Nil(t, err)
+	_, err = cs.EnterContest(ctx, 3, contest.Id)
+	assert.ErrorIs(t, err, pkg.ErrContestNotOpen)
+
+	leaderboard, err := cs.GetContestLeaderboard(ctx, 2, contest.Id)
+	assert.Nil(t, err)
+	// the entry that was never submitted is not on the leaderboard
+	assert.Equal(t, int64(2), leaderboard.Entrants)
+	assert.Equal(t, int64(3), leaderboard.Standings[0].UserID)
+	assert.Equal(t, "linus", leaderboard.Standings[0].UserName)
+	assert.Equal(t, int64(3), leaderboard.Standings[0].CorrectAnswers)
+	assert.Equal(t, int64(2), leaderboard.Standings[1].Rank)
+	assert.Equal(t, &leaderboard.Standings[1], leaderboard.Me)
+
+	// the leaderboard is frozen the first time it is asked for
+	again, err := cs.GetContestLeaderboard(ctx, 1, contest.Id)
+	assert.Nil(t, err)
+	assert.True(t, leaderboard.FrozenAt.Equal(again.FrozenAt))
+	assert.Equal(t, leaderboard.Standings, again.Standings)
+	assert.Nil(t, again.Me)
+
+	listed, err = cs.GetContests(ctx, 2, domain.ContestQuery{Status: domain.ContestPast})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), listed.Total)
+	assert.Equal(t, domain.ContestPast, listed.Contests[0].Status)
+}
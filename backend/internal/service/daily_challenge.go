@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/internal/storage"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DailyChallengeQuestions is how many questions a subject's daily challenge has, or
+	// every question of a subject with fewer
+	DailyChallengeQuestions = 5
+	// dailyChallengeTTL keeps a day's question set cached until the day is well over
+	dailyChallengeTTL = 48 * time.Hour
+	// A streak earns a freeze every StreakFreezeEvery days, and up to MaxStreakFreezes
+	// can be banked.
+	StreakFreezeEvery = 7
+	MaxStreakFreezes  = 2
+)
+
+type DailyChallengeService interface {
+	GetDailyChallenge(ctx context.Context, userID int64, subjectId int64) (*domain.DailyChallenge, error)
+	TakeDailyChallenge(ctx context.Context, userID int64, subjectId int64) (*domain.GeneratedQuizResponse, error)
+	GetDailyBoard(ctx context.Context, userID int64, query domain.DailyBoardQuery) (*domain.DailyBoardResponse, error)
+}
+
+// dailyChallengeService runs the question set of the day. Days are calendar days in UTC.
+// A subject's set is drawn from a seed fixed by the day, so every user gets the same
+// questions, and is cached in Redis so it stays the same when questions are added or
+// removed during the day.
+type dailyChallengeService struct {
+	redisClient              *redis.Client
+	dailyChallengeRepository repository.DailyChallengeRepository
+	streakRepository         repository.StreakRepository
+	quiz                     *quizService
+}
+
+func NewDailyChallengeService(redisClient *redis.Client, dailyChallengeRepository repository.DailyChallengeRepository, streakRepository repository.StreakRepository, subjectRepository repository.SubjectRepository, questionRepository repository.QuestionRepository, quizSessionRepository repository.QuizSessionRepository, mediaRepository repository.MediaRepository, storage storage.Storage) *dailyChallengeService {
+	return &dailyChallengeService{
+		redisClient:              redisClient,
+		dailyChallengeRepository: dailyChallengeRepository,
+		streakRepository:         streakRepository,
+		quiz:                     &quizService{subjectRepository: subjectRepository, questionRepository: questionRepository, quizSessionRepository: quizSessionRepository, mediaRepository: mediaRepository, storage: storage},
+	}
+}
+
+// GetDailyChallenge returns today's daily challenge of a subject, whether the user has
+// taken it, and the user's streak.
+func (ds *dailyChallengeService) GetDailyChallenge(ctx context.Context, userID int64, subjectId int64) (*domain.DailyChallenge, error) {
+	now := time.Now()
+	day := dailyDate(now)
+	questionIds, err := ds.dailyQuestionIds(ctx, subjectId, day)
+	if err != nil {
+		return nil, err
+	}
+	taken, err := ds.dailyChallengeRepository.HasUserTakenDailyChallenge(ctx, userID, subjectId, day)
+	if err != nil {
+		fmt.Println("error checking daily challenge sessions: ", err)
+		return nil, err
+	}
+	streak, err := ds.streakRepository.GetUserStreak(ctx, userID)
+	if err != nil {
+		fmt.Println("error getting streak: ", err)
+		return nil, err
+	}
+	return &domain.DailyChallenge{
+		Date:           day.Format(time.DateOnly),
+		SubjectId:      subjectId,
+		TotalQuestions: len(questionIds),
+		Taken:          taken,
+		Streak:         streakOn(*streak, now),
+	}, nil
+}
+
+// TakeDailyChallenge issues the user today's daily challenge of a subject, in the set's
+// order. Each user can take a subject's daily challenge once a day.
+func (ds *dailyChallengeService) TakeDailyChallenge(ctx context.Context, userID int64, subjectId int64) (*domain.GeneratedQuizResponse, error) {
+	now := time.Now()
+	day := dailyDate(now)
+	questionIds, err := ds.dailyQuestionIds(ctx, subjectId, day)
+	if err != nil {
+		return nil, err
+	}
+	taken, err := ds.dailyChallengeRepository.HasUserTakenDailyChallenge(ctx, userID, subjectId, day)
+	if err != nil {
+		fmt.Println("error checking daily challenge sessions: ", err)
+		return nil, err
+	}
+	if taken {
+		return nil, pkg.ErrDailyChallengeTaken
+	}
+	scoringPolicy, err := ds.quiz.subjectRepository.GetSubjectScoringPolicy(ctx, subjectId)
+	if err != nil {
+		fmt.Println("error getting scoring policy: ", err)
+		return nil, err
+	}
+
+	questions, err := ds.quiz.questionRepository.GetQuestionsByIds(ctx, questionIds)
+	if err != nil {
+		fmt.Println("error getting daily challenge questions: ", err)
+		return nil, err
+	}
+	byId := make(map[int64]repository.Questions, len(questions))
+	for _, question := range questions {
+		byId[question.Id] = question
+	}
+	picked := make([]pickedQuestion, 0, len(questionIds))
+	for _, questionId := range questionIds {
+		if question, ok := byId[questionId]; ok {
+			picked = append(picked, pickedQuestion{Questions: question})
+		}
+	}
+
+	numOfQuestions := int64(len(picked))
+	return ds.quiz.issueQuiz(ctx, userID, quizPlan{
+		mode:      domain.ModeDaily,
+		breakdown: []domain.QuizSubjectRequest{{SubjectId: subjectId, NumOfQuestions: numOfQuestions}},
+		subjects: []repository.QuizSessionSubject{{
+			SubjectId:      subjectId,
+			NumOfQuestions: numOfQuestions,
+			ScoringPolicy:  *scoringPolicy,
+		}},
+		questions: picked,
+		dailyDate: &day,
+	}, now)
+}
+
+// GetDailyBoard ranks today's daily challenge participants, of one subject or of every
+// subject, by points and then by time taken.
+func (ds *dailyChallengeService) GetDailyBoard(ctx context.Context, userID int64, query domain.DailyBoardQuery) (*domain.DailyBoardResponse, error) {
+	if query.Limit == 0 {
+		query.Limit = 10
+	}
+	if query.SubjectId != nil {
+		if _, err := ds.quiz.subjectRepository.GetSubjectById(ctx, *query.SubjectId); err != nil {
+			fmt.Println("error getting subject: ", err)
+			return nil, pkg.ErrSubjectNotFound
+		}
+	}
+	day := dailyDate(time.Now())
+	entries, participants, err := ds.dailyChallengeRepository.GetDailyBoard(ctx, day, query.SubjectId, query.Limit, query.Offset)
+	if err != nil {
+		fmt.Println("error getting daily board: ", err)
+		return nil, err
+	}
+	me, err := ds.dailyChallengeRepository.GetUserDailyStanding(ctx, userID, day, query.SubjectId)
+	if err != nil {
+		fmt.Println("error getting daily standing: ", err)
+		return nil, err
+	}
+	return &domain.DailyBoardResponse{
+		Date:         day.Format(time.DateOnly),
+		SubjectId:    query.SubjectId,
+		Participants: participants,
+		Entries:      entries,
+		Me:           me,
+	}, nil
+}
+
+// RecordSubmission is a SubmitHook that moves the user's streak on when they submit a
+// daily challenge. The streak counts the day of the challenge, not of the submission.
+func (ds *dailyChallengeService) RecordSubmission(ctx context.Context, session *repository.QuizSession, result *domain.QuizSubmitResponse) error {
+	if session.Mode != domain.ModeDaily || session.DailyDate == nil {
+		return nil
+	}
+	day := asDate(*session.DailyDate)
+	return ds.streakRepository.UpdateUserStreak(ctx, session.UserId, func(streak domain.Streak) domain.Streak {
+		return advanceStreak(streak, day)
+	}, time.Now())
+}
+
+// dailyQuestionIds returns a subject's daily challenge questions of the day, drawing and
+// caching them the first time they are asked for. When two instances draw the set at
+// once the first one cached is used. Without Redis the set is drawn every time.
+func (ds *dailyChallengeService) dailyQuestionIds(ctx context.Context, subjectId int64, day time.Time) ([]int64, error) {
+	if _, err := ds.quiz.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+		fmt.Println("error getting subject: ", err)
+		return nil, pkg.ErrSubjectNotFound
+	}
+	key := fmt.Sprintf("daily:%s:%d", day.Format(time.DateOnly), subjectId)
+	cached, err := ds.redisClient.Get(ctx, key).Result()
+	if err == nil {
+		var questionIds []int64
+		if err := json.Unmarshal([]byte(cached), &questionIds); err == nil {
+			return questionIds, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		fmt.Println("error getting daily challenge from redis: ", err)
+	}
+
+	candidates, err := ds.quiz.questionRepository.GetSubjectQuestionIds(ctx, subjectId, repository.QuestionFilter{})
+	if err != nil {
+		fmt.Println("error getting subject questions: ", err)
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: subject %d has no questions", pkg.ErrNotEnoughQuestions, subjectId)
+	}
+	questionIds := seededQuestionIds(candidates, dailySeed(day), subjectId, min(DailyChallengeQuestions, int64(len(candidates))))
+
+	data, err := json.Marshal(questionIds)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := ds.redisClient.SetNX(ctx, key, data, dailyChallengeTTL).Result()
+	if err != nil {
+		fmt.Println("error caching daily challenge in redis: ", err)
+		return questionIds, nil
+	}
+	if !stored {
+		// Another request cached the day's set first
+		var cachedIds []int64
+		cached, err := ds.redisClient.Get(ctx, key).Bytes()
+		if err == nil && json.Unmarshal(cached, &cachedIds) == nil {
+			return cachedIds, nil
+		}
+	}
+	return questionIds, nil
+}
+
+// dailyDate returns the calendar day, in UTC, of a time.
+func dailyDate(t time.Time) time.Time {
+	return asDate(t.UTC())
+}
+
+// asDate returns midnight UTC of the day a time falls on in its own location.
+func asDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// dailySeed is the seed of a day's daily challenges: the date as YYYYMMDD.
+func dailySeed(day time.Time) int64 {
+	return int64(day.Year()*10000 + int(day.Month())*100 + day.Day())
+}
+
+// daysBetween returns how many calendar days after from to is.
+func daysBetween(from, to time.Time) int64 {
+	return int64(asDate(to).Sub(asDate(from)).Hours() / 24)
+}
+
+// advanceStreak moves a streak on to a day a daily challenge was taken. A day after the
+// last one carries the streak on, and a freeze is spent on each day missed in between; a
+// gap longer than the freezes banked starts a new streak. Days already counted change
+// nothing. Every StreakFreezeEvery days of a streak earn a freeze.
+func advanceStreak(streak domain.Streak, day time.Time) domain.Streak {
+	if streak.LastActiveOn != nil {
+		missed := daysBetween(*streak.LastActiveOn, day) - 1
+		if missed < 0 {
+			return streak
+		}
+		if missed > streak.Freezes {
+			streak.Current = 1
+		} else {
+			streak.Freezes -= missed
+			streak.Current++
+		}
+	} else {
+		streak.Current = 1
+	}
+	if streak.Current%StreakFreezeEvery == 0 && streak.Freezes < MaxStreakFreezes {
+		streak.Freezes++
+	}
+	streak.Longest = max(streak.Longest, streak.Current)
+	streak.LastActiveOn = &day
+	return streak
+}
+
+// streakOn returns a streak as it stands at a time: lapsed, with Current 0, once more days
+// have been missed since the last one than there are freezes to cover them. Today does
+// not count as missed until it is over.
+func streakOn(streak domain.Streak, now time.Time) domain.Streak {
+	if streak.LastActiveOn != nil && daysBetween(*streak.LastActiveOn, dailyDate(now))-1 > streak.Freezes {
+		streak.Current = 0
+	}
+	return streak
+}
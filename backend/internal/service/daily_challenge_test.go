@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdvanceStreak(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(days int) time.Time { return day.AddDate(0, 0, days) }
+
+	streak := advanceStreak(domain.Streak{}, at(0))
+	assert.Equal(t, int64(1), streak.Current)
+	assert.Equal(t, int64(1), streak.Longest)
+	assert.Equal(t, streak, advanceStreak(streak, at(0)), "a day already counted changes nothing")
+
+	for i := 1; i < StreakFreezeEvery; i++ {
+		streak = advanceStreak(streak, at(i))
+	}
+	assert.Equal(t, int64(StreakFreezeEvery), streak.Current)
+	assert.Equal(t, int64(1), streak.Freezes)
+
+	// a missed day spends a freeze
+	streak = advanceStreak(streak, at(StreakFreezeEvery+1))
+	assert.Equal(t, int64(StreakFreezeEvery+1), streak.Current)
+	assert.Equal(t, int64(0), streak.Freezes)
+
+	// with no freeze left a missed day starts over, keeping the longest streak
+	streak = advanceStreak(streak, at(StreakFreezeEvery+3))
+	assert.Equal(t, int64(1), streak.Current)
+	assert.Equal(t, int64(StreakFreezeEvery+1), streak.Longest)
+
+	// freezes are banked up to the limit
+	streak = domain.Streak{Current: 6*StreakFreezeEvery - 1, Freezes: MaxStreakFreezes, LastActiveOn: &day}
+	assert.Equal(t, int64(MaxStreakFreezes), advanceStreak(streak, at(1)).Freezes)
+}
+
+func TestStreakOn(t *testing.T) {
+	lastActiveOn := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	streak := domain.Streak{Current: 4, Longest: 4, Freezes: 1, LastActiveOn: &lastActiveOn}
+
+	assert.Equal(t, int64(4), streakOn(streak, lastActiveOn.Add(23*time.Hour)).Current)
+	assert.Equal(t, int64(4), streakOn(streak, lastActiveOn.Add(47*time.Hour)).Current, "today is not missed until it is over")
+	assert.Equal(t, int64(4), streakOn(streak, lastActiveOn.Add(71*time.Hour)).Current, "a freeze covers a missed day")
+	lapsed := streakOn(streak, lastActiveOn.Add(95*time.Hour))
+	assert.Equal(t, int64(0), lapsed.Current)
+	assert.Equal(t, int64(4), lapsed.Longest)
+}
+
+func TestDailyChallenge(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	streakRepo := repository.NewStreakRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, repository.NewScoreRepository(pool), sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	ds := NewDailyChallengeService(redisClient, repository.NewDailyChallengeRepository(pool), streakRepo, subjectRepo, questionRepo, sessionRepo, repository.NewMediaRepository(pool), testStorage(t))
+	qs.OnSubmit(ds.RecordSubmission)
+
+	for _, name := range []string{"ada", "grace", "linus"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.Nil(t, err)
+	}
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	emptySubjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+	candidates, err := questionRepo.GetSubjectQuestionIds(ctx, subjectId, repository.QuestionFilter{})
+	assert.Nil(t, err)
+
+	_, err = ds.GetDailyChallenge(ctx, 1, 999)
+	assert.ErrorIs(t, err, pkg.ErrSubjectNotFound)
+	_, err = ds.TakeDailyChallenge(ctx, 1, emptySubjectId)
+	assert.ErrorIs(t, err, pkg.ErrNotEnoughQuestions)
+
+	challenge, err := ds.GetDailyChallenge(ctx, 1, subjectId)
+	assert.Nil(t, err)
+	today := dailyDate(time.Now())
+	assert.Equal(t, today.Format(time.DateOnly), challenge.Date)
+	assert.Equal(t, min(DailyChallengeQuestions, len(candidates)), challenge.TotalQuestions)
+	assert.False(t, challenge.Taken)
+	assert.Equal(t, domain.Streak{}, challenge.Streak)
+
+	// everyone gets the same questions, in the same order, drawn from the day
+	first, err := ds.TakeDailyChallenge(ctx, 1, subjectId)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ModeDaily, first.Mode)
+	assert.Equal(t, challenge.Date, first.DailyDate)
+	second, err := ds.TakeDailyChallenge(ctx, 2, subjectId)
+	assert.Nil(t, err)
+	expected := seededQuestionIds(candidates, dailySeed(today), subjectId, int64(challenge.TotalQuestions))
+	for i := range expected {
+		assert.Equal(t, expected[i], first.Questions[i].QuestionId)
+		assert.Equal(t, expected[i], second.Questions[i].QuestionId)
+	}
+	cacheKey := fmt.Sprintf("daily:%s:%d", challenge.Date, subjectId)
+	assert.True(t, mr.Exists(cacheKey))
+	_, err = ds.TakeDailyChallenge(ctx, 1, subjectId)
+	assert.ErrorIs(t, err, pkg.ErrDailyChallengeTaken)
+
+	// the cached set is kept for the rest of the day
+	assert.Nil(t, mr.Set(cacheKey, fmt.Sprintf("[%d]", expected[0])))
+	third, err := ds.TakeDailyChallenge(ctx, 3, subjectId)
+	assert.Nil(t, err)
+	assert.Len(t, third.Questions, 1)
+
+	_, err = qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, first, 1))
+	assert.Nil(t, err)
+	_, err = qs.SubmitQuiz(ctx, 2, answerQuiz(t, ctx, questionRepo, second, 2))
+	assert.Nil(t, err)
+
+	challenge, err = ds.GetDailyChallenge(ctx, 1, subjectId)
+	assert.Nil(t, err)
+	assert.True(t, challenge.Taken)
+	assert.Equal(t, int64(1), challenge.Streak.Current)
+	assert.Equal(t, today, *challenge.Streak.LastActiveOn)
+
+	board, err := ds.GetDailyBoard(ctx, 1, domain.DailyBoardQuery{SubjectId: &subjectId})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), board.Participants)
+	assert.Equal(t, int64(2), board.Entries[0].UserID)
+	assert.Equal(t, "grace", board.Entries[0].UserName)
+	assert.Equal(t, int64(1), board.Entries[0].Rank)
+	assert.Equal(t, int64(2), board.Me.Rank)
+	assert.Equal(t, int64(1), board.Me.CorrectAnswers)
+	board, err = ds.GetDailyBoard(ctx, 3, domain.DailyBoardQuery{Limit: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), board.Participants)
+	assert.Len(t, board.Entries, 1)
+	assert.Nil(t, board.Me)
+	_, err = ds.GetDailyBoard(ctx, 1, domain.DailyBoardQuery{SubjectId: &emptySubjectId})
+	assert.Nil(t, err)
+
+	// the dashboard shows the streak, lapsed once too many days have been missed
+	userService := NewUserService(*repository.NewUserRepository(pool), repository.NewScoreRepository(pool), streakRepo, repository.NewXPRepository(pool), testAchievementService(pool), log.New(os.Stdout, "", 0))
+	dashboard, err := userService.UserDashboard(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), dashboard.Streak.Current)
+	assert.Equal(t, int64(1), dashboard.Streak.Longest)
+	lastActiveOn := today.AddDate(0, 0, -3)
+	assert.Nil(t, streakRepo.SaveUserStreak(ctx, 2, domain.Streak{Current: 5, Longest: 5, LastActiveOn: &lastActiveOn}, time.Now()))
+	dashboard, err = userService.UserDashboard(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), dashboard.Streak.Current)
+	assert.Equal(t, int64(5), dashboard.Streak.Longest)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/lawson/otterprep/domain"
 	"github.com/lawson/otterprep/internal/repository"
@@ -36,14 +37,45 @@ func (ls *leaderboardService) GetLeaderboard(ctx context.Context, query domain.L
 	if period == "" {
 		period = "all_time"
 	}
+	metric := query.Metric
+	if metric == "" {
+		metric = "score"
+	}
 
 	var entries []domain.LeaderboardEntry
 	var totalUsers int64
 	var err error
 	var subjectName string
 
-	// If subject_id is provided, get subject-specific leaderboard
-	if query.SubjectId != nil && *query.SubjectId > 0 {
+	if metric == "xp" {
+		var subjectId *int64
+		if query.SubjectId != nil && *query.SubjectId > 0 {
+			subject, err := ls.subjectRepo.GetSubjectById(ctx, *query.SubjectId)
+			if err != nil {
+				return nil, err
+			}
+			subjectName = subject.Name
+			subjectId = query.SubjectId
+		}
+		// XP can be ranked over a period of a subject as well as of every subject
+		var since *time.Time
+		switch period {
+		case "weekly":
+			startOfWeek := time.Now().AddDate(0, 0, -7)
+			since = &startOfWeek
+		case "monthly":
+			startOfMonth := time.Now().AddDate(0, -1, 0)
+			since = &startOfMonth
+		}
+		entries, totalUsers, err = ls.leaderboardRepo.GetXPLeaderboard(ctx, subjectId, since, limit, offset)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entries[i].Level = xpProgress(entries[i].XP).Level
+		}
+	} else if query.SubjectId != nil && *query.SubjectId > 0 {
+		// If subject_id is provided, get subject-specific leaderboard
 		// Get subject name
 		subject, err := ls.subjectRepo.GetSubjectById(ctx, *query.SubjectId)
 		if err != nil {
@@ -74,6 +106,7 @@ func (ls *leaderboardService) GetLeaderboard(ctx context.Context, query domain.L
 		SubjectId:   query.SubjectId,
 		SubjectName: subjectName,
 		Period:      period,
+		Metric:      metric,
 		TotalUsers:  totalUsers,
 		Entries:     entries,
 	}, nil
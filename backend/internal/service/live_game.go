@@ -0,0 +1,545 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/redis/go-redis/v9"
+)
+
+// LiveMaxPoints is what a fully correct answer given the moment a question opens earns.
+// It falls off linearly to half that at the deadline.
+const LiveMaxPoints = 1000
+
+// liveAnswerGrace is how long after the deadline an answer is still taken, to allow for
+// the time it takes to reach the server.
+const liveAnswerGrace = 2 * time.Second
+
+// liveAnswer is a player's answer to a question of a live room, as kept in Redis.
+type liveAnswer struct {
+	Credit    float64 `json:"credit"`
+	Points    int64   `json:"points"`
+	ElapsedMs int64   `json:"elapsed_ms"`
+}
+
+// Handle runs a command from a client of a live room.
+func (m *liveRoomManager) Handle(ctx context.Context, client *LiveClient, command domain.LiveCommand) error {
+	state, err := m.loadRoom(ctx, client.Pin)
+	if err != nil {
+		return err
+	}
+	switch command.Type {
+	case domain.LiveCommandStart, domain.LiveCommandNext, domain.LiveCommandEnd:
+		if !client.Host {
+			return pkg.ErrNotLiveRoomHost
+		}
+	case domain.LiveCommandAnswer:
+		if client.Host {
+			return pkg.ErrNotLiveRoomPlayer
+		}
+		return m.answer(ctx, client, state, command)
+	default:
+		return pkg.ErrInvalidLiveCommand
+	}
+
+	switch {
+	case command.Type == domain.LiveCommandStart && state.Status == domain.LiveRoomLobby:
+		players, err := m.redis.HLen(ctx, livePlayersKey(state.Pin)).Result()
+		if err != nil {
+			fmt.Println("error counting live room players: ", err)
+			return err
+		}
+		if players == 0 {
+			return pkg.ErrLiveRoomNoPlayers
+		}
+		return m.openQuestion(ctx, state, 0)
+	case command.Type == domain.LiveCommandNext && state.Status == domain.LiveRoomQuestion:
+		return m.closeQuestion(ctx, state, false)
+	case command.Type == domain.LiveCommandNext && state.Status == domain.LiveRoomScoreboard:
+		return m.openQuestion(ctx, state, state.QuestionIndex+1)
+	case command.Type == domain.LiveCommandEnd && state.Status == domain.LiveRoomQuestion:
+		return m.closeQuestion(ctx, state, true)
+	case command.Type == domain.LiveCommandEnd && state.Status != domain.LiveRoomFinished:
+		return m.finish(ctx, state, nil)
+	}
+	return pkg.ErrLiveCommandNotAllowed
+}
+
+// openQuestion opens a question of a live room and pushes it to its clients. The question
+// is closed when its time is up, unless every player has answered it before then.
+func (m *liveRoomManager) openQuestion(ctx context.Context, state *liveRoomState, index int) error {
+	if index >= state.TotalQuestions {
+		return pkg.ErrLiveCommandNotAllowed
+	}
+	claimed, err := m.claim(ctx, state.Pin, "opened:"+strconv.Itoa(index))
+	if err != nil || !claimed {
+		return err
+	}
+	now := time.Now()
+	fields := map[string]any{
+		"status":         domain.LiveRoomQuestion,
+		"question_index": index,
+		"opened_at":      now.UnixMilli(),
+	}
+	if index == 0 {
+		fields["started_at"] = now.UnixMilli()
+		state.StartedAt = time.UnixMilli(now.UnixMilli())
+	}
+	if err := m.redis.HSet(ctx, liveRoomKey(state.Pin), fields).Err(); err != nil {
+		fmt.Println("error opening live room question: ", err)
+		return err
+	}
+	state.Status = domain.LiveRoomQuestion
+	state.QuestionIndex = index
+	state.OpenedAt = time.UnixMilli(now.UnixMilli())
+
+	timeLimit := time.Duration(state.QuestionSeconds)*time.Second + liveAnswerGrace
+	m.mu.Lock()
+	m.timers[state.Pin] = time.AfterFunc(timeLimit, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		current, err := m.loadRoom(ctx, state.Pin)
+		if err != nil || current.Status != domain.LiveRoomQuestion || current.QuestionIndex != index {
+			return
+		}
+		if err := m.closeQuestion(ctx, current, false); err != nil {
+			fmt.Println("error closing live room question: ", err)
+		}
+	})
+	m.mu.Unlock()
+
+	m.publish(ctx, state.Pin, domain.LiveEvent{Type: domain.LiveEventQuestion, Question: liveQuestion(state, index)})
+	return nil
+}
+
+// liveQuestion returns the open question of a live room as pushed to its clients.
+func liveQuestion(state *liveRoomState, index int) *domain.LiveQuestion {
+	return &domain.LiveQuestion{
+		Index:          index,
+		TotalQuestions: state.TotalQuestions,
+		Seconds:        state.QuestionSeconds,
+		Deadline:       state.OpenedAt.Add(time.Duration(state.QuestionSeconds) * time.Second),
+		Question:       state.Questions[index].Question,
+	}
+}
+
+// claim sets one of a live room's markers, reporting whether it was not set already.
+func (m *liveRoomManager) claim(ctx context.Context, pin string, marker string) (bool, error) {
+	claimed, err := m.redis.SetNX(ctx, liveMarkerKey(pin, marker), 1, LiveRoomTTL).Result()
+	if err != nil {
+		fmt.Println("error claiming live room marker: ", err)
+		return false, err
+	}
+	return claimed, nil
+}
+
+// answer grades a player's answer to the open question. Points are earned for being
+// right and for being quick; a player only gets one answer per question.
+func (m *liveRoomManager) answer(ctx context.Context, client *LiveClient, state *liveRoomState, command domain.LiveCommand) error {
+	if command.Answer == nil {
+		return pkg.ErrInvalidLiveCommand
+	}
+	answeredAt := time.Now()
+	if state.Status != domain.LiveRoomQuestion || command.QuestionIndex != state.QuestionIndex {
+		return pkg.ErrLiveQuestionClosed
+	}
+	timeLimit := time.Duration(state.QuestionSeconds) * time.Second
+	elapsed := answeredAt.Sub(state.OpenedAt)
+	if elapsed > timeLimit+liveAnswerGrace {
+		return pkg.ErrLiveQuestionClosed
+	}
+	isPlayer, err := m.redis.HExists(ctx, livePlayersKey(state.Pin), strconv.FormatInt(client.UserId, 10)).Result()
+	if err != nil {
+		fmt.Println("error getting live room player: ", err)
+		return err
+	}
+	if !isPlayer {
+		return pkg.ErrNotLiveRoomPlayer
+	}
+
+	issued := state.Questions[state.QuestionIndex]
+	submitted := *command.Answer
+	submitted.QuestionId = issued.Question.QuestionId
+	keys, err := m.answerKeys(ctx, state)
+	if err != nil {
+		return err
+	}
+	// The question is checked as if it were the only one issued in a quiz session
+	session := &repository.QuizSession{Questions: []repository.QuizSessionQuestion{{QuestionId: submitted.QuestionId, OptionIds: issued.OptionIds}}}
+	if _, err := validateSessionAnswers(session, keys, []domain.SubmitQuizRequest{submitted}); err != nil {
+		return err
+	}
+	credit := float64(0)
+	if key := keys[submitted.QuestionId]; key.err == nil {
+		credit = gradeAnswer(key, submitted)
+	}
+	answer := liveAnswer{
+		Credit:    credit,
+		Points:    livePoints(credit, elapsed, timeLimit),
+		ElapsedMs: min(elapsed, timeLimit).Milliseconds(),
+	}
+	encoded, err := json.Marshal(answer)
+	if err != nil {
+		return err
+	}
+	answersKey := liveAnswersKey(state.Pin, state.QuestionIndex)
+	stored, err := m.redis.HSetNX(ctx, answersKey, strconv.FormatInt(client.UserId, 10), encoded).Result()
+	if err != nil {
+		fmt.Println("error storing live room answer: ", err)
+		return err
+	}
+	if !stored {
+		return pkg.ErrLiveQuestionAnswered
+	}
+	m.redis.Expire(ctx, answersKey, LiveRoomTTL)
+
+	progress, err := m.progress(ctx, state)
+	if err != nil {
+		return err
+	}
+	m.send(client, domain.LiveEvent{Type: domain.LiveEventAnswered, Progress: progress})
+	m.publish(ctx, state.Pin, domain.LiveEvent{Type: domain.LiveEventProgress, Progress: progress})
+	if progress.Answered >= progress.Players {
+		return m.closeQuestion(ctx, state, false)
+	}
+	return nil
+}
+
+// livePoints is what an answer earns: its credit, scaled from LiveMaxPoints for an
+// instant answer down to half that for one given at the deadline.
+func livePoints(credit float64, elapsed time.Duration, timeLimit time.Duration) int64 {
+	speed := 1 - min(max(elapsed.Seconds()/timeLimit.Seconds(), 0), 1)/2
+	return int64(math.Round(credit * LiveMaxPoints * speed))
+}
+
+// progress returns how many players have answered the open question of a live room.
+func (m *liveRoomManager) progress(ctx context.Context, state *liveRoomState) (*domain.LiveProgress, error) {
+	var answered, players *redis.IntCmd
+	_, err := m.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		answered = pipe.HLen(ctx, liveAnswersKey(state.Pin, state.QuestionIndex))
+		players = pipe.HLen(ctx, livePlayersKey(state.Pin))
+		return nil
+	})
+	if err != nil {
+		fmt.Println("error counting live room answers: ", err)
+		return nil, err
+	}
+	return &domain.LiveProgress{QuestionIndex: state.QuestionIndex, Answered: int(answered.Val()), Players: int(players.Val())}, nil
+}
+
+// answerKeys returns the answer keys of a live room's questions. They are kept for as
+// long as the room has clients on this instance.
+func (m *liveRoomManager) answerKeys(ctx context.Context, state *liveRoomState) (map[int64]*answerKey, error) {
+	m.mu.Lock()
+	keys, ok := m.keys[state.Pin]
+	m.mu.Unlock()
+	if ok {
+		return keys, nil
+	}
+	questionIds := make([]int64, len(state.Questions))
+	for i, issued := range state.Questions {
+		questionIds[i] = issued.Question.QuestionId
+	}
+	keys, err := m.quiz.loadAnswerKeys(ctx, questionIds)
+	if err != nil {
+		fmt.Println("error loading answer keys: ", err)
+		return nil, err
+	}
+	m.mu.Lock()
+	if m.clients[state.Pin] != nil {
+		m.keys[state.Pin] = keys
+	}
+	m.mu.Unlock()
+	return keys, nil
+}
+
+// closeQuestion closes the open question of a live room and shows its answer along with
+// the scoreboard. The room finishes after its last question, or when it is ended.
+func (m *liveRoomManager) closeQuestion(ctx context.Context, state *liveRoomState, end bool) error {
+	claimed, err := m.claim(ctx, state.Pin, "closed:"+strconv.Itoa(state.QuestionIndex))
+	if err != nil || !claimed {
+		return err
+	}
+	m.mu.Lock()
+	if timer, ok := m.timers[state.Pin]; ok {
+		timer.Stop()
+		delete(m.timers, state.Pin)
+	}
+	m.mu.Unlock()
+
+	keys, err := m.answerKeys(ctx, state)
+	if err != nil {
+		return err
+	}
+	issued := state.Questions[state.QuestionIndex]
+	result := quizResult(keys[issued.Question.QuestionId], issued.OptionIds, domain.QuizAnswer{})
+	if end || state.QuestionIndex == state.TotalQuestions-1 {
+		return m.finish(ctx, state, &result)
+	}
+
+	if err := m.redis.HSet(ctx, liveRoomKey(state.Pin), "status", domain.LiveRoomScoreboard).Err(); err != nil {
+		fmt.Println("error closing live room question: ", err)
+		return err
+	}
+	_, scoreboard, err := m.scoreboard(ctx, state)
+	if err != nil {
+		return err
+	}
+	m.publish(ctx, state.Pin, domain.LiveEvent{Type: domain.LiveEventScoreboard, Result: &result, Scoreboard: scoreboard})
+	return nil
+}
+
+// scoreboard adds up the answers of every question of a live room so far. It returns the
+// answers to each question along with the players ranked by points, then correct answers;
+// players level on both share a rank.
+func (m *liveRoomManager) scoreboard(ctx context.Context, state *liveRoomState) ([]map[int64]liveAnswer, []domain.LiveScore, error) {
+	players, err := m.loadPlayers(ctx, state.Pin)
+	if err != nil {
+		return nil, nil, err
+	}
+	asked := state.QuestionIndex + 1
+	commands := make([]*redis.MapStringStringCmd, asked)
+	_, err = m.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range asked {
+			commands[i] = pipe.HGetAll(ctx, liveAnswersKey(state.Pin, i))
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Println("error getting live room answers: ", err)
+		return nil, nil, err
+	}
+	answers := make([]map[int64]liveAnswer, asked)
+	for i, command := range commands {
+		answers[i] = make(map[int64]liveAnswer, len(command.Val()))
+		for id, encoded := range command.Val() {
+			userId, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				continue
+			}
+			var answer liveAnswer
+			if err := json.Unmarshal([]byte(encoded), &answer); err != nil {
+				fmt.Println("error decoding live room answer: ", err)
+				continue
+			}
+			answers[i][userId] = answer
+		}
+	}
+
+	scoreboard := make([]domain.LiveScore, len(players))
+	for i, player := range players {
+		scoreboard[i] = domain.LiveScore{UserId: player.UserId, Name: player.Name}
+		for question, questionAnswers := range answers {
+			answer, ok := questionAnswers[player.UserId]
+			if !ok {
+				continue
+			}
+			scoreboard[i].Points += answer.Points
+			if answer.Credit == 1 {
+				scoreboard[i].CorrectAnswers++
+			}
+			if question == state.QuestionIndex {
+				scoreboard[i].Gained = answer.Points
+			}
+		}
+	}
+	slices.SortStableFunc(scoreboard, func(a, b domain.LiveScore) int {
+		if a.Points != b.Points {
+			return int(b.Points - a.Points)
+		}
+		if a.CorrectAnswers != b.CorrectAnswers {
+			return b.CorrectAnswers - a.CorrectAnswers
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	for i := range scoreboard {
+		scoreboard[i].Rank = i + 1
+		if i > 0 && scoreboard[i].Points == scoreboard[i-1].Points && scoreboard[i].CorrectAnswers == scoreboard[i-1].CorrectAnswers {
+			scoreboard[i].Rank = scoreboard[i-1].Rank
+		}
+	}
+	return answers, scoreboard, nil
+}
+
+// finish ends a live room and stores a score for each of its players over the questions
+// asked, scored by the subject's scoring policy like any other quiz. Result is the answer
+// to the question just closed, if any.
+func (m *liveRoomManager) finish(ctx context.Context, state *liveRoomState, result *domain.QuizResultResponse) error {
+	claimed, err := m.claim(ctx, state.Pin, "finished")
+	if err != nil || !claimed {
+		return err
+	}
+
+	var scoreboard []domain.LiveScore
+	// A room ended in the lobby has nothing to score
+	if state.QuestionIndex >= 0 {
+		var answers []map[int64]liveAnswer
+		answers, scoreboard, err = m.scoreboard(ctx, state)
+		if err == nil {
+			err = m.storeScores(ctx, state, answers, scoreboard)
+		}
+		if err != nil {
+			fmt.Println("error storing live room scores: ", err)
+			m.unfinish(ctx, state)
+			return err
+		}
+	}
+	if err := m.redis.HSet(ctx, liveRoomKey(state.Pin), "status", domain.LiveRoomFinished).Err(); err != nil {
+		fmt.Println("error finishing live room: ", err)
+		return err
+	}
+	state.Status = domain.LiveRoomFinished
+	room, err := m.GetRoom(ctx, state.Pin)
+	if err != nil {
+		return err
+	}
+	m.publish(ctx, state.Pin, domain.LiveEvent{Type: domain.LiveEventFinished, Room: room, Result: result, Scoreboard: scoreboard})
+	return nil
+}
+
+// unfinish lets a live room whose scores could not be stored be ended again. Its question,
+// if one was open, is already closed, so the room is left on the scoreboard where the
+// host's next end finishes it.
+func (m *liveRoomManager) unfinish(ctx context.Context, state *liveRoomState) {
+	if state.Status == domain.LiveRoomQuestion {
+		if err := m.redis.HSet(ctx, liveRoomKey(state.Pin), "status", domain.LiveRoomScoreboard).Err(); err != nil {
+			fmt.Println("error reopening live room: ", err)
+		}
+		state.Status = domain.LiveRoomScoreboard
+	}
+	if err := m.redis.Del(ctx, liveMarkerKey(state.Pin, "finished")).Err(); err != nil {
+		fmt.Println("error reopening live room: ", err)
+	}
+}
+
+// storeScores stores the scores of a finished live room, each against a submitted live
+// session of its player so the submit hooks can run for it. The sessions and scores are
+// stored together or not at all. It also records its answers
+// against the difficulty of its questions. A player's time taken is how long they took to
+// answer each question, counting the whole of its time for those they did not answer.
+func (m *liveRoomManager) storeScores(ctx context.Context, state *liveRoomState, answers []map[int64]liveAnswer, scoreboard []domain.LiveScore) error {
+	scorer, err := NewScorer(state.ScoringPolicy)
+	if err != nil {
+		return err
+	}
+	keys, err := m.answerKeys(ctx, state)
+	if err != nil {
+		return err
+	}
+	finishedAt := time.Now()
+	asked := int64(len(answers))
+	sessionQuestions := make([]repository.QuizSessionQuestion, len(answers))
+	for question := range answers {
+		sessionQuestions[question] = repository.QuizSessionQuestion{
+			QuestionId: state.Questions[question].Question.QuestionId,
+			SubjectId:  state.SubjectId,
+			Position:   question,
+			OptionIds:  state.Questions[question].OptionIds,
+		}
+	}
+
+	sessions := make([]repository.QuizSession, len(scoreboard))
+	results := make([]*domain.QuizSubmitResponse, len(scoreboard))
+	userScores := make([]domain.UserScore, len(scoreboard))
+	attempts := make([]repository.QuestionAttempt, 0)
+	for i, player := range scoreboard {
+		sessions[i] = repository.QuizSession{
+			UserId:      player.UserId,
+			SubjectId:   state.SubjectId,
+			Status:      repository.QuizSessionSubmitted,
+			Mode:        domain.ModeLive,
+			Subjects:    []repository.QuizSessionSubject{{SubjectId: state.SubjectId, NumOfQuestions: asked, ScoringPolicy: state.ScoringPolicy}},
+			Questions:   sessionQuestions,
+			SubmittedAt: &finishedAt,
+			CreatedAt:   state.StartedAt,
+			UpdatedAt:   finishedAt,
+		}
+
+		points := float64(0)
+		elapsedMs := int64(0)
+		questionResults := make([]domain.QuizResultResponse, 0, len(answers))
+		for question, questionAnswers := range answers {
+			answer, answered := questionAnswers[player.UserId]
+			if answered {
+				elapsedMs += answer.ElapsedMs
+			} else {
+				elapsedMs += state.QuestionSeconds * 1000
+			}
+			key := keys[state.Questions[question].Question.QuestionId]
+			if key.err != nil {
+				continue
+			}
+			questionPoints := scorer.Points(QuestionOutcome{Answered: answered, Credit: answer.Credit, Weight: key.question.Weight})
+			points += questionPoints
+			questionResults = append(questionResults, domain.QuizResultResponse{
+				QuestionId: key.question.Id,
+				Type:       key.question.Type,
+				IsCorrect:  answer.Credit == 1,
+				Credit:     answer.Credit,
+				Points:     questionPoints,
+			})
+			if answered {
+				attempts = append(attempts, repository.QuestionAttempt{QuestionId: key.question.Id, IsCorrect: answer.Credit == 1})
+			}
+		}
+		subjectResult := domain.QuizSubjectResult{
+			SubjectId:        state.SubjectId,
+			TotalQuestions:   asked,
+			CorrectAnswers:   int64(player.CorrectAnswers),
+			IncorrectAnswers: asked - int64(player.CorrectAnswers),
+			Score:            int64(math.Round(points)),
+			Points:           points,
+			ScoringPolicy:    state.ScoringPolicy.Name,
+		}
+		userScores[i] = domain.UserScore{
+			UserID:           player.UserId,
+			SubjectID:        state.SubjectId,
+			Score:            subjectResult.Score,
+			Points:           points,
+			ScoringPolicy:    state.ScoringPolicy.Name,
+			Mode:             domain.ModeLive,
+			CorrectAnswers:   subjectResult.CorrectAnswers,
+			IncorrectAnswers: subjectResult.IncorrectAnswers,
+			TotalQuestions:   asked,
+			TimeTakenSeconds: elapsedMs / 1000,
+			CreatedAt:        finishedAt,
+			UpdatedAt:        finishedAt,
+		}
+		results[i] = &domain.QuizSubmitResponse{
+			UserId:           player.UserId,
+			SubjectId:        state.SubjectId,
+			TotalQuestions:   asked,
+			CorrectAnswers:   subjectResult.CorrectAnswers,
+			IncorrectAnswers: subjectResult.IncorrectAnswers,
+			Score:            subjectResult.Score,
+			Points:           points,
+			ScoringPolicy:    state.ScoringPolicy.Name,
+			Mode:             domain.ModeLive,
+			TimeTakenSeconds: userScores[i].TimeTakenSeconds,
+			Subjects:         []domain.QuizSubjectResult{subjectResult},
+			Results:          questionResults,
+		}
+	}
+	stored, err := m.quiz.scoreRepository.StoreSessionScores(ctx, sessions, userScores)
+	if err != nil {
+		return err
+	}
+	if err := m.quiz.questionRepository.RecordQuestionAttempts(ctx, attempts); err != nil {
+		fmt.Println("error recording question attempts: ", err)
+	}
+	for i := range scoreboard {
+		sessions[i].Id = stored[i].SessionID
+		results[i].SessionId = stored[i].SessionID
+		m.quiz.runSubmitHooks(ctx, &sessions[i], results[i])
+	}
+	return nil
+}
@@ -0,0 +1,529 @@
+package service
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/internal/storage"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/redis/go-redis/v9"
+)
+
+// Live room limits. A room and everything kept about it in Redis is dropped LiveRoomTTL
+// after it was last written to.
+const (
+	LiveDefaultQuestionSeconds = 20
+	LiveMaxPlayers             = 200
+	LiveRoomTTL                = 3 * time.Hour
+	LivePinLength              = 6
+	// maxLivePinAttempts is how many fresh pins are tried when a pin is already taken
+	maxLivePinAttempts = 5
+	// liveClientBuffer is how many events a client can fall behind by before it is dropped
+	liveClientBuffer = 64
+)
+
+type LiveService interface {
+	CreateRoom(ctx context.Context, hostId int64, request domain.LiveRoomRequest) (*domain.LiveRoom, error)
+	GetRoom(ctx context.Context, pin string) (*domain.LiveRoom, error)
+	Join(ctx context.Context, pin string, userId int64) (*LiveClient, error)
+	Leave(client *LiveClient)
+	Handle(ctx context.Context, client *LiveClient, command domain.LiveCommand) error
+	Close() error
+}
+
+// LiveClient is a connection to a live room on this instance. Events for it arrive on
+// Events, which is closed when the client leaves or falls too far behind.
+type LiveClient struct {
+	Pin    string
+	UserId int64
+	Host   bool
+	events chan domain.LiveEvent
+}
+
+// Events returns the channel the client's events arrive on
+func (lc *LiveClient) Events() <-chan domain.LiveEvent {
+	return lc.events
+}
+
+// liveRoomManager runs live rooms. The state of a room is kept in Redis and every change
+// to it is published on the room's channel, so any instance can serve the clients of a
+// room: each one subscribes to the rooms it has clients in and passes the events on.
+// Questions close on a timer run by the instance that opened them, or as soon as every
+// player has answered, whichever comes first.
+type liveRoomManager struct {
+	redis          *redis.Client
+	quiz           *quizService
+	userRepository *repository.UserRepository
+
+	mu            sync.Mutex
+	clients       map[string]map[*LiveClient]bool
+	subscriptions map[string]*redis.PubSub
+	keys          map[string]map[int64]*answerKey
+	timers        map[string]*time.Timer
+}
+
+func NewLiveRoomManager(redisClient *redis.Client, questionRepository repository.QuestionRepository, subjectRepository repository.SubjectRepository, scoreRepository repository.ScoreRepository, quizSessionRepository repository.QuizSessionRepository, userRepository *repository.UserRepository, mediaRepository repository.MediaRepository, storage storage.Storage) *liveRoomManager {
+	return &liveRoomManager{
+		redis:          redisClient,
+		quiz:           &quizService{questionRepository: questionRepository, subjectRepository: subjectRepository, scoreRepository: scoreRepository, quizSessionRepository: quizSessionRepository, mediaRepository: mediaRepository, storage: storage},
+		userRepository: userRepository,
+		clients:        make(map[string]map[*LiveClient]bool),
+		subscriptions:  make(map[string]*redis.PubSub),
+		keys:           make(map[string]map[int64]*answerKey),
+		timers:         make(map[string]*time.Timer),
+	}
+}
+
+// OnSubmit adds a hook run for each player's result once a finished room's scores are
+// stored, in the order they were added.
+func (m *liveRoomManager) OnSubmit(hook SubmitHook) {
+	m.quiz.OnSubmit(hook)
+}
+
+// Close stops the manager's question timers and disconnects its clients.
+func (m *liveRoomManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for pin, timer := range m.timers {
+		timer.Stop()
+		delete(m.timers, pin)
+	}
+	for _, clients := range m.clients {
+		for client := range clients {
+			m.dropClient(client)
+		}
+	}
+	return nil
+}
+
+// liveIssuedQuestion is a question of a live room as shown to players, and the ids of its
+// options in the order they are shown.
+type liveIssuedQuestion struct {
+	Question  domain.QuizQuestionResponse `json:"question"`
+	OptionIds []int64                     `json:"option_ids"`
+}
+
+// liveRoomState is a live room as kept in Redis. OpenedAt is when the question at
+// QuestionIndex was opened.
+type liveRoomState struct {
+	domain.LiveRoom
+	OpenedAt      time.Time
+	StartedAt     time.Time
+	ScoringPolicy domain.ScoringPolicy
+	Questions     []liveIssuedQuestion
+}
+
+// Redis keys of a live room: a hash with the room itself, a hash of its players' names,
+// a hash of the answers to each question and markers set once a question is opened or
+// closed and once the room is finished, so only one instance ever does each.
+func liveRoomKey(pin string) string {
+	return "live:room:" + pin
+}
+
+func liveRoomChannel(pin string) string {
+	return liveRoomKey(pin) + ":events"
+}
+
+func livePlayersKey(pin string) string {
+	return liveRoomKey(pin) + ":players"
+}
+
+func liveAnswersKey(pin string, index int) string {
+	return fmt.Sprintf("%s:answers:%d", liveRoomKey(pin), index)
+}
+
+func liveMarkerKey(pin string, marker string) string {
+	return liveRoomKey(pin) + ":" + marker
+}
+
+// CreateRoom opens a live room in the lobby for the host, with its questions drawn and
+// shuffled up front so every player sees them the same. The subject's scoring policy is
+// snapshotted for the scores stored when the room finishes.
+func (m *liveRoomManager) CreateRoom(ctx context.Context, hostId int64, request domain.LiveRoomRequest) (*domain.LiveRoom, error) {
+	if _, err := m.quiz.subjectRepository.GetSubjectById(ctx, request.SubjectId); err != nil {
+		fmt.Println("error getting subject: ", err)
+		return nil, pkg.ErrSubjectNotFound
+	}
+	scoringPolicy, err := m.quiz.subjectRepository.GetSubjectScoringPolicy(ctx, request.SubjectId)
+	if err != nil {
+		fmt.Println("error getting scoring policy: ", err)
+		return nil, err
+	}
+	now := time.Now()
+	picked, err := m.quiz.pickQuestions(ctx, hostId, domain.QuizSubjectRequest{
+		SubjectId:      request.SubjectId,
+		NumOfQuestions: request.NumOfQuestions,
+		TopicIds:       request.TopicIds,
+		Tags:           request.Tags,
+	}, domain.ModeLive, 0, now)
+	if err != nil {
+		return nil, err
+	}
+	questionIds := make([]int64, len(picked))
+	for i, question := range picked {
+		questionIds[i] = question.Id
+	}
+	questionOptions, err := m.quiz.questionRepository.GetQuestionOptionsByQuestionIds(ctx, questionIds)
+	if err != nil {
+		fmt.Println("error getting question options: ", err)
+		return nil, err
+	}
+	media, err := signedQuestionMedia(ctx, m.quiz.mediaRepository, m.quiz.storage, questionIds)
+	if err != nil {
+		fmt.Println("error getting question media: ", err)
+		return nil, err
+	}
+	questions := make([]liveIssuedQuestion, len(picked))
+	for i, question := range picked {
+		issued, session := issueQuestion(question, questionOptions[question.Id], i, true)
+		attachQuizMedia(&issued, media[question.Id])
+		questions[i] = liveIssuedQuestion{Question: issued, OptionIds: session.OptionIds}
+	}
+	encodedQuestions, err := json.Marshal(questions)
+	if err != nil {
+		return nil, err
+	}
+	encodedPolicy, err := json.Marshal(scoringPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds := request.QuestionSeconds
+	if seconds == 0 {
+		seconds = LiveDefaultQuestionSeconds
+	}
+	for range maxLivePinAttempts {
+		pin, err := newLivePin()
+		if err != nil {
+			return nil, err
+		}
+		// Claiming the host is what reserves the pin
+		claimed, err := m.redis.HSetNX(ctx, liveRoomKey(pin), "host_id", hostId).Result()
+		if err != nil {
+			fmt.Println("error creating live room: ", err)
+			return nil, err
+		}
+		if !claimed {
+			continue
+		}
+		_, err = m.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, liveRoomKey(pin), map[string]any{
+				"subject_id":       request.SubjectId,
+				"status":           domain.LiveRoomLobby,
+				"question_index":   -1,
+				"question_seconds": seconds,
+				"total_questions":  len(questions),
+				"created_at":       now.UnixMilli(),
+				"scoring_policy":   string(encodedPolicy),
+				"questions":        string(encodedQuestions),
+			})
+			pipe.Expire(ctx, liveRoomKey(pin), LiveRoomTTL)
+			return nil
+		})
+		if err != nil {
+			fmt.Println("error creating live room: ", err)
+			return nil, err
+		}
+		return &domain.LiveRoom{
+			Pin:             pin,
+			HostId:          hostId,
+			SubjectId:       request.SubjectId,
+			Status:          domain.LiveRoomLobby,
+			QuestionIndex:   -1,
+			TotalQuestions:  len(questions),
+			QuestionSeconds: seconds,
+			Players:         []domain.LivePlayer{},
+			CreatedAt:       time.UnixMilli(now.UnixMilli()),
+		}, nil
+	}
+	return nil, errors.New("could not find a free live room pin")
+}
+
+// newLivePin returns a random pin of LivePinLength digits, not starting with 0.
+func newLivePin() (string, error) {
+	low := int64(1)
+	for range LivePinLength - 1 {
+		low *= 10
+	}
+	n, err := crand.Int(crand.Reader, big.NewInt(9*low))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(low+n.Int64(), 10), nil
+}
+
+// GetRoom returns a live room with its players.
+func (m *liveRoomManager) GetRoom(ctx context.Context, pin string) (*domain.LiveRoom, error) {
+	state, err := m.loadRoom(ctx, pin)
+	if err != nil {
+		return nil, err
+	}
+	players, err := m.loadPlayers(ctx, pin)
+	if err != nil {
+		return nil, err
+	}
+	room := state.LiveRoom
+	room.Players = players
+	return &room, nil
+}
+
+// loadRoom reads a live room from Redis.
+func (m *liveRoomManager) loadRoom(ctx context.Context, pin string) (*liveRoomState, error) {
+	fields, err := m.redis.HGetAll(ctx, liveRoomKey(pin)).Result()
+	if err != nil {
+		fmt.Println("error getting live room: ", err)
+		return nil, err
+	}
+	if fields["status"] == "" {
+		return nil, pkg.ErrLiveRoomNotFound
+	}
+	state := &liveRoomState{LiveRoom: domain.LiveRoom{Pin: pin, Status: fields["status"]}}
+	state.HostId, _ = strconv.ParseInt(fields["host_id"], 10, 64)
+	state.SubjectId, _ = strconv.ParseInt(fields["subject_id"], 10, 64)
+	state.QuestionIndex, _ = strconv.Atoi(fields["question_index"])
+	state.TotalQuestions, _ = strconv.Atoi(fields["total_questions"])
+	state.QuestionSeconds, _ = strconv.ParseInt(fields["question_seconds"], 10, 64)
+	state.CreatedAt = unixMilli(fields["created_at"])
+	state.OpenedAt = unixMilli(fields["opened_at"])
+	state.StartedAt = unixMilli(fields["started_at"])
+	if err := json.Unmarshal([]byte(fields["scoring_policy"]), &state.ScoringPolicy); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(fields["questions"]), &state.Questions); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func unixMilli(value string) time.Time {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// loadPlayers returns the players of a live room ordered by name.
+func (m *liveRoomManager) loadPlayers(ctx context.Context, pin string) ([]domain.LivePlayer, error) {
+	names, err := m.redis.HGetAll(ctx, livePlayersKey(pin)).Result()
+	if err != nil {
+		fmt.Println("error getting live room players: ", err)
+		return nil, err
+	}
+	players := make([]domain.LivePlayer, 0, len(names))
+	for id, name := range names {
+		userId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		players = append(players, domain.LivePlayer{UserId: userId, Name: name})
+	}
+	slices.SortFunc(players, func(a, b domain.LivePlayer) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return int(a.UserId - b.UserId)
+	})
+	return players, nil
+}
+
+// Join connects a user to a live room. The host can always connect; anyone else joins as
+// a player while the room is in the lobby, and can reconnect once it has started. The
+// client is sent the room, and the open question if there is one.
+func (m *liveRoomManager) Join(ctx context.Context, pin string, userId int64) (*LiveClient, error) {
+	state, err := m.loadRoom(ctx, pin)
+	if err != nil {
+		return nil, err
+	}
+	client := &LiveClient{Pin: pin, UserId: userId, Host: state.HostId == userId, events: make(chan domain.LiveEvent, liveClientBuffer)}
+	joined := false
+	if !client.Host {
+		joined, err = m.addPlayer(ctx, state, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Subscribe before reading the room, so no change is missed in between
+	if err := m.register(ctx, client); err != nil {
+		return nil, err
+	}
+	room, err := m.GetRoom(ctx, pin)
+	if err != nil {
+		m.Leave(client)
+		return nil, err
+	}
+	m.send(client, domain.LiveEvent{Type: domain.LiveEventRoom, Room: room})
+	if room.Status == domain.LiveRoomQuestion {
+		m.send(client, domain.LiveEvent{Type: domain.LiveEventQuestion, Question: liveQuestion(state, room.QuestionIndex)})
+	}
+	if joined {
+		m.publish(ctx, pin, domain.LiveEvent{Type: domain.LiveEventRoom, Room: room})
+	}
+	return client, nil
+}
+
+// addPlayer adds a user to the players of a live room, unless they are one already.
+// It reports whether the user was added.
+func (m *liveRoomManager) addPlayer(ctx context.Context, state *liveRoomState, userId int64) (bool, error) {
+	isPlayer, err := m.redis.HExists(ctx, livePlayersKey(state.Pin), strconv.FormatInt(userId, 10)).Result()
+	if err != nil {
+		fmt.Println("error getting live room player: ", err)
+		return false, err
+	}
+	if isPlayer {
+		return false, nil
+	}
+	if state.Status != domain.LiveRoomLobby {
+		return false, pkg.ErrLiveRoomStarted
+	}
+	count, err := m.redis.HLen(ctx, livePlayersKey(state.Pin)).Result()
+	if err != nil {
+		fmt.Println("error counting live room players: ", err)
+		return false, err
+	}
+	if count >= LiveMaxPlayers {
+		return false, pkg.ErrLiveRoomFull
+	}
+	user, err := m.userRepository.GetUserWithID(ctx, userId)
+	if err != nil {
+		fmt.Println("error getting user: ", err)
+		return false, err
+	}
+	_, err = m.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, livePlayersKey(state.Pin), strconv.FormatInt(userId, 10), user.Name)
+		pipe.Expire(ctx, livePlayersKey(state.Pin), LiveRoomTTL)
+		return nil
+	})
+	if err != nil {
+		fmt.Println("error adding live room player: ", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// Leave disconnects a client from its live room. A player who leaves the lobby leaves
+// the room; once it has started they stay on the scoreboard and can reconnect.
+func (m *liveRoomManager) Leave(client *LiveClient) {
+	if !m.unregister(client) || client.Host {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	state, err := m.loadRoom(ctx, client.Pin)
+	if err != nil || state.Status != domain.LiveRoomLobby {
+		return
+	}
+	if err := m.redis.HDel(ctx, livePlayersKey(client.Pin), strconv.FormatInt(client.UserId, 10)).Err(); err != nil {
+		fmt.Println("error removing live room player: ", err)
+		return
+	}
+	if room, err := m.GetRoom(ctx, client.Pin); err == nil {
+		m.publish(ctx, client.Pin, domain.LiveEvent{Type: domain.LiveEventRoom, Room: room})
+	}
+}
+
+// register adds a client to the clients on this instance, subscribing to its room's
+// channel when it is the first one in the room.
+func (m *liveRoomManager) register(ctx context.Context, client *LiveClient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.clients[client.Pin] == nil {
+		subscription := m.redis.Subscribe(ctx, liveRoomChannel(client.Pin))
+		// Wait for the subscription to be confirmed, so no event published after it is missed
+		if _, err := subscription.Receive(ctx); err != nil {
+			fmt.Println("error subscribing to live room: ", err)
+			subscription.Close()
+			return err
+		}
+		m.clients[client.Pin] = make(map[*LiveClient]bool)
+		m.subscriptions[client.Pin] = subscription
+		go m.listen(client.Pin, subscription)
+	}
+	m.clients[client.Pin][client] = true
+	return nil
+}
+
+// unregister removes a client from the clients on this instance and closes its events,
+// unsubscribing from its room's channel when it was the last one in the room.
+// It reports whether the client was still registered.
+func (m *liveRoomManager) unregister(client *LiveClient) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.clients[client.Pin][client] {
+		return false
+	}
+	m.dropClient(client)
+	return true
+}
+
+// dropClient does the work of unregister. m.mu must be held.
+func (m *liveRoomManager) dropClient(client *LiveClient) {
+	delete(m.clients[client.Pin], client)
+	close(client.events)
+	if len(m.clients[client.Pin]) == 0 {
+		delete(m.clients, client.Pin)
+		delete(m.keys, client.Pin)
+		if err := m.subscriptions[client.Pin].Close(); err != nil {
+			fmt.Println("error unsubscribing from live room: ", err)
+		}
+		delete(m.subscriptions, client.Pin)
+	}
+}
+
+// send queues an event for a client, dropping the client if it has fallen too far behind.
+func (m *liveRoomManager) send(client *LiveClient, event domain.LiveEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sendLocked(client, event)
+}
+
+func (m *liveRoomManager) sendLocked(client *LiveClient, event domain.LiveEvent) {
+	if !m.clients[client.Pin][client] {
+		return
+	}
+	select {
+	case client.events <- event:
+	default:
+		m.dropClient(client)
+	}
+}
+
+// publish sends an event to every client of a live room, on every instance.
+func (m *liveRoomManager) publish(ctx context.Context, pin string, event domain.LiveEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		fmt.Println("error encoding live room event: ", err)
+		return
+	}
+	if err := m.redis.Publish(ctx, liveRoomChannel(pin), payload).Err(); err != nil {
+		fmt.Println("error publishing live room event: ", err)
+	}
+}
+
+// listen passes the events published on a live room's channel on to its clients on this
+// instance, until the subscription is closed.
+func (m *liveRoomManager) listen(pin string, subscription *redis.PubSub) {
+	for message := range subscription.Channel() {
+		var event domain.LiveEvent
+		if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+			fmt.Println("error decoding live room event: ", err)
+			continue
+		}
+		m.mu.Lock()
+		for client := range m.clients[pin] {
+			m.sendLocked(client, event)
+		}
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// nextLiveEvent waits for the next event of a type sent to a live room client, skipping
+// any others.
+func nextLiveEvent(t *testing.T, client *LiveClient, eventType string) domain.LiveEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-client.Events():
+			if !ok {
+				t.Fatalf("client left waiting for a %s event", eventType)
+			}
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", eventType)
+		}
+	}
+}
+
+func TestLivePoints(t *testing.T) {
+	assert.Equal(t, int64(1000), livePoints(1, 0, 20*time.Second))
+	assert.Equal(t, int64(750), livePoints(1, 10*time.Second, 20*time.Second))
+	assert.Equal(t, int64(500), livePoints(1, 25*time.Second, 20*time.Second))
+	assert.Equal(t, int64(375), livePoints(0.5, 10*time.Second, 20*time.Second))
+	assert.Equal(t, int64(0), livePoints(0, 0, 20*time.Second))
+}
+
+func TestLiveRoom(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	userRepo := repository.NewUserRepository(pool)
+
+	for _, name := range []string{"tess", "ada", "grace", "linus"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.Nil(t, err)
+	}
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	// two instances sharing one Redis
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	first := NewLiveRoomManager(redisClient, questionRepo, subjectRepo, scoreRepo, repository.NewQuizSessionRepository(pool), userRepo, repository.NewMediaRepository(pool), testStorage(t))
+	defer first.Close()
+	second := NewLiveRoomManager(redisClient, questionRepo, subjectRepo, scoreRepo, repository.NewQuizSessionRepository(pool), userRepo, repository.NewMediaRepository(pool), testStorage(t))
+	defer second.Close()
+	var submitted []*domain.QuizSubmitResponse
+	recordSubmission := func(ctx context.Context, session *repository.QuizSession, result *domain.QuizSubmitResponse) error {
+		assert.Equal(t, domain.ModeLive, session.Mode)
+		assert.Equal(t, session.Id, result.SessionId)
+		submitted = append(submitted, result)
+		return nil
+	}
+	first.OnSubmit(recordSubmission)
+	second.OnSubmit(recordSubmission)
+
+	_, err = first.CreateRoom(ctx, 1, domain.LiveRoomRequest{SubjectId: subjectId, NumOfQuestions: 10})
+	assert.ErrorIs(t, err, pkg.ErrNotEnoughQuestions)
+	room, err := first.CreateRoom(ctx, 1, domain.LiveRoomRequest{SubjectId: subjectId, NumOfQuestions: 2})
+	assert.Nil(t, err)
+	assert.Len(t, room.Pin, LivePinLength)
+	assert.Equal(t, domain.LiveRoomLobby, room.Status)
+	assert.Equal(t, int64(LiveDefaultQuestionSeconds), room.QuestionSeconds)
+	_, err = second.GetRoom(ctx, "000000")
+	assert.ErrorIs(t, err, pkg.ErrLiveRoomNotFound)
+
+	host, err := first.Join(ctx, room.Pin, 1)
+	assert.Nil(t, err)
+	assert.True(t, host.Host)
+	assert.ErrorIs(t, first.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandStart}), pkg.ErrLiveRoomNoPlayers)
+	ada, err := first.Join(ctx, room.Pin, 2)
+	assert.Nil(t, err)
+	grace, err := second.Join(ctx, room.Pin, 3)
+	assert.Nil(t, err)
+	// the host hears about players joining on either instance
+	lobby := nextLiveEvent(t, host, domain.LiveEventRoom)
+	for len(lobby.Room.Players) < 2 {
+		lobby = nextLiveEvent(t, host, domain.LiveEventRoom)
+	}
+	assert.Equal(t, []domain.LivePlayer{{UserId: 2, Name: "ada"}, {UserId: 3, Name: "grace"}}, lobby.Room.Players)
+
+	assert.ErrorIs(t, second.Handle(ctx, grace, domain.LiveCommand{Type: domain.LiveCommandStart}), pkg.ErrNotLiveRoomHost)
+	assert.ErrorIs(t, first.Handle(ctx, host, domain.LiveCommand{Type: "skip"}), pkg.ErrInvalidLiveCommand)
+	assert.Nil(t, first.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandStart}))
+	_, err = second.Join(ctx, room.Pin, 4)
+	assert.ErrorIs(t, err, pkg.ErrLiveRoomStarted)
+
+	// the question reaches every player, whichever instance they are on
+	question := nextLiveEvent(t, grace, domain.LiveEventQuestion).Question
+	assert.Equal(t, 0, question.Index)
+	assert.Equal(t, 2, question.TotalQuestions)
+	assert.Equal(t, question.Question, nextLiveEvent(t, ada, domain.LiveEventQuestion).Question.Question)
+	keys, err := first.quiz.loadAnswerKeys(ctx, []int64{question.Question.QuestionId})
+	assert.Nil(t, err)
+	correctId := keys[question.Question.QuestionId].correctIds[0]
+	var wrongId int64
+	for _, option := range question.Question.Options {
+		if option.Id != correctId {
+			wrongId = option.Id
+		}
+	}
+
+	assert.ErrorIs(t, first.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandAnswer, Answer: &domain.SubmitQuizRequest{OptionIds: []int64{correctId}}}), pkg.ErrNotLiveRoomPlayer)
+	assert.ErrorIs(t, first.Handle(ctx, ada, domain.LiveCommand{Type: domain.LiveCommandAnswer, QuestionIndex: 1, Answer: &domain.SubmitQuizRequest{OptionIds: []int64{correctId}}}), pkg.ErrLiveQuestionClosed)
+	assert.ErrorIs(t, first.Handle(ctx, ada, domain.LiveCommand{Type: domain.LiveCommandAnswer, Answer: &domain.SubmitQuizRequest{OptionIds: []int64{-1}}}), pkg.ErrOptionNotInSession)
+	assert.Nil(t, first.Handle(ctx, ada, domain.LiveCommand{Type: domain.LiveCommandAnswer, Answer: &domain.SubmitQuizRequest{OptionIds: []int64{correctId}}}))
+	answered := nextLiveEvent(t, ada, domain.LiveEventAnswered)
+	assert.Equal(t, &domain.LiveProgress{QuestionIndex: 0, Answered: 1, Players: 2}, answered.Progress)
+	assert.ErrorIs(t, first.Handle(ctx, ada, domain.LiveCommand{Type: domain.LiveCommandAnswer, Answer: &domain.SubmitQuizRequest{OptionIds: []int64{wrongId}}}), pkg.ErrLiveQuestionAnswered)
+	// the question closes as soon as everyone has answered
+	assert.Nil(t, second.Handle(ctx, grace, domain.LiveCommand{Type: domain.LiveCommandAnswer, Answer: &domain.SubmitQuizRequest{OptionIds: []int64{wrongId}}}))
+	scoreboard := nextLiveEvent(t, host, domain.LiveEventScoreboard)
+	assert.Equal(t, question.Question.QuestionId, scoreboard.Result.QuestionId)
+	assert.Len(t, scoreboard.Scoreboard, 2)
+	assert.Equal(t, int64(2), scoreboard.Scoreboard[0].UserId)
+	assert.Equal(t, 1, scoreboard.Scoreboard[0].Rank)
+	assert.Greater(t, scoreboard.Scoreboard[0].Points, int64(LiveMaxPoints/2))
+	assert.Equal(t, scoreboard.Scoreboard[0].Points, scoreboard.Scoreboard[0].Gained)
+	assert.Equal(t, domain.LiveScore{Rank: 2, UserId: 3, Name: "grace"}, scoreboard.Scoreboard[1])
+	assert.Equal(t, scoreboard.Scoreboard, nextLiveEvent(t, grace, domain.LiveEventScoreboard).Scoreboard)
+
+	// the host moves on and ends the room early, leaving a question open
+	assert.ErrorIs(t, second.Handle(ctx, grace, domain.LiveCommand{Type: domain.LiveCommandAnswer, Answer: &domain.SubmitQuizRequest{OptionIds: []int64{wrongId}}}), pkg.ErrLiveQuestionClosed)
+	assert.Nil(t, first.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandNext}))
+	question = nextLiveEvent(t, grace, domain.LiveEventQuestion).Question
+	assert.Equal(t, 1, question.Index)
+	assert.Nil(t, first.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandEnd}))
+	finished := nextLiveEvent(t, grace, domain.LiveEventFinished)
+	assert.Equal(t, domain.LiveRoomFinished, finished.Room.Status)
+	assert.NotNil(t, finished.Result)
+	assert.Equal(t, int64(0), finished.Scoreboard[0].Gained)
+	assert.ErrorIs(t, first.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandNext}), pkg.ErrLiveCommandNotAllowed)
+
+	// each player's result is stored as a live score over the questions asked, against a
+	// session of its own, and the submit hooks are told about it
+	rows, err := pool.Query("SELECT user_id, score, correct_answers, incorrect_answers, total_questions FROM scores WHERE mode = $1 ORDER BY user_id", domain.ModeLive)
+	assert.Nil(t, err)
+	defer rows.Close()
+	var stored [][5]int64
+	for rows.Next() {
+		var row [5]int64
+		assert.Nil(t, rows.Scan(&row[0], &row[1], &row[2], &row[3], &row[4]))
+		stored = append(stored, row)
+	}
+	assert.Equal(t, [][5]int64{{2, 1, 1, 1, 2}, {3, 0, 0, 2, 2}}, stored)
+	assert.Len(t, submitted, 2)
+	for _, result := range submitted {
+		var sessionUserId, timeTaken int64
+		assert.Nil(t, pool.QueryRow("SELECT s.user_id, sc.time_taken_seconds FROM quiz_sessions s JOIN scores sc ON sc.session_id = s.id WHERE s.id = $1 AND s.status = $2", result.SessionId, repository.QuizSessionSubmitted).Scan(&sessionUserId, &timeTaken))
+		assert.Equal(t, result.UserId, sessionUserId)
+		assert.Equal(t, result.TimeTakenSeconds, timeTaken)
+		assert.Len(t, result.Results, 2)
+		// the question nobody answered counts its whole time, the one answered at once next to none
+		assert.Equal(t, room.QuestionSeconds, result.TimeTakenSeconds)
+	}
+
+	// the lobby is left by leaving, a started room is not
+	first.Leave(ada)
+	players, err := first.loadPlayers(ctx, room.Pin)
+	assert.Nil(t, err)
+	assert.Len(t, players, 2)
+}
+
+func TestLiveRoomEndedAgainWhenScoresFailToStore(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subjectRepo := repository.NewSubjectRepository(pool)
+	for _, name := range []string{"tess", "ada"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.Nil(t, err)
+	}
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := repository.NewQuizRepository(pool).CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	manager := NewLiveRoomManager(redisClient, repository.NewQuestionRepository(pool), subjectRepo, repository.NewScoreRepository(pool), repository.NewQuizSessionRepository(pool), repository.NewUserRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+	defer manager.Close()
+
+	room, err := manager.CreateRoom(ctx, 1, domain.LiveRoomRequest{SubjectId: subjectId, NumOfQuestions: 2})
+	assert.Nil(t, err)
+	host, err := manager.Join(ctx, room.Pin, 1)
+	assert.Nil(t, err)
+	ada, err := manager.Join(ctx, room.Pin, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, manager.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandStart}))
+	nextLiveEvent(t, ada, domain.LiveEventQuestion)
+
+	// the scores cannot be stored, so the room is not finished and the host hears why
+	_, err = pool.Exec("ALTER TABLE scores RENAME TO scores_unavailable")
+	assert.Nil(t, err)
+	assert.NotNil(t, manager.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandEnd}))
+	current, err := manager.GetRoom(ctx, room.Pin)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.LiveRoomScoreboard, current.Status)
+	var sessions int64
+	assert.Nil(t, pool.QueryRow("SELECT COUNT(*) FROM quiz_sessions WHERE mode = $1", domain.ModeLive).Scan(&sessions))
+	assert.Equal(t, int64(0), sessions)
+
+	// ending it again once they can be stores them
+	_, err = pool.Exec("ALTER TABLE scores_unavailable RENAME TO scores")
+	assert.Nil(t, err)
+	assert.Nil(t, manager.Handle(ctx, host, domain.LiveCommand{Type: domain.LiveCommandEnd}))
+	assert.Equal(t, domain.LiveRoomFinished, nextLiveEvent(t, ada, domain.LiveEventFinished).Room.Status)
+	var scores int64
+	assert.Nil(t, pool.QueryRow("SELECT COUNT(*) FROM scores s JOIN quiz_sessions qs ON qs.id = s.session_id WHERE s.mode = $1", domain.ModeLive).Scan(&scores))
+	assert.Equal(t, int64(1), scores)
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/internal/storage"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// Media limits. MediaURLExpiry is how long the media URLs handed out with questions,
+// quizzes and results work for; long enough to outlast the longest exam.
+const (
+	MaxImageBytes  = 5 << 20
+	MaxAudioBytes  = 20 << 20
+	MediaURLExpiry = 6 * time.Hour
+)
+
+// mediaType is a kind of media that can be uploaded, how it is stored and how big it can be
+type mediaType struct {
+	kind        string
+	contentType string
+	extension   string
+	maxBytes    int64
+}
+
+// mediaTypes are the media types that can be uploaded, keyed by the content type sniffed
+// from the start of the file. SVG is left out, as it can carry scripts.
+var mediaTypes = map[string]mediaType{
+	"image/png":       {kind: domain.MediaKindImage, contentType: "image/png", extension: ".png", maxBytes: MaxImageBytes},
+	"image/jpeg":      {kind: domain.MediaKindImage, contentType: "image/jpeg", extension: ".jpg", maxBytes: MaxImageBytes},
+	"image/gif":       {kind: domain.MediaKindImage, contentType: "image/gif", extension: ".gif", maxBytes: MaxImageBytes},
+	"image/webp":      {kind: domain.MediaKindImage, contentType: "image/webp", extension: ".webp", maxBytes: MaxImageBytes},
+	"audio/mpeg":      {kind: domain.MediaKindAudio, contentType: "audio/mpeg", extension: ".mp3", maxBytes: MaxAudioBytes},
+	"audio/wave":      {kind: domain.MediaKindAudio, contentType: "audio/wav", extension: ".wav", maxBytes: MaxAudioBytes},
+	"application/ogg": {kind: domain.MediaKindAudio, contentType: "audio/ogg", extension: ".ogg", maxBytes: MaxAudioBytes},
+}
+
+// UploadQuestionMedia stores an image or audio file of size bytes read from body and
+// attaches it to a question, one of its options or its explanation. The type of the file
+// is worked out from its content, not its name.
+// It returns the media with a signed URL and an error if any.
+func (qs *questionService) UploadQuestionMedia(ctx context.Context, questionId int64, upload domain.MediaUpload, body io.Reader, size int64) (*domain.Media, error) {
+	if _, err := qs.questionRepository.GetQuestionById(ctx, questionId); err != nil {
+		qs.logger.Println("Failed to get question by id: ", err)
+		return nil, pkg.ErrQuestionNotFound
+	}
+	if upload.AttachTo == domain.MediaOnOption {
+		options, err := qs.questionRepository.GetQuestionOptions(ctx, questionId)
+		if err != nil {
+			qs.logger.Println("Failed to get question options: ", err)
+			return nil, err
+		}
+		if !slices.ContainsFunc(options, func(option repository.QuestionOptions) bool { return option.Id == upload.OptionId }) {
+			qs.logger.Println("Option is not an option of the question. Proceeding to return error.")
+			return nil, pkg.ErrQuestionOptionNotFound
+		}
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		qs.logger.Println("Failed to read media: ", err)
+		return nil, err
+	}
+	head = head[:n]
+	fileType, ok := mediaTypes[http.DetectContentType(head)]
+	if !ok {
+		qs.logger.Println("Media type is not supported. Proceeding to return error.")
+		return nil, pkg.ErrUnsupportedMediaType
+	}
+	if size > fileType.maxBytes {
+		qs.logger.Println("Media is too large. Proceeding to return error.")
+		return nil, pkg.ErrMediaTooLarge
+	}
+
+	media := repository.Media{
+		QuestionId:  questionId,
+		OptionId:    upload.OptionId,
+		AttachTo:    upload.AttachTo,
+		Kind:        fileType.kind,
+		ContentType: fileType.contentType,
+		SizeBytes:   size,
+		StorageKey:  fmt.Sprintf("questions/%d/%s%s", questionId, uuid.NewString(), fileType.extension),
+		AltText:     upload.AltText,
+		CreatedAt:   time.Now(),
+	}
+	if err := qs.storage.Put(ctx, media.StorageKey, io.MultiReader(bytes.NewReader(head), body), size, media.ContentType); err != nil {
+		qs.logger.Println("Failed to store media: ", err)
+		return nil, err
+	}
+	media.Id, err = qs.mediaRepository.CreateMedia(ctx, media)
+	if err != nil {
+		qs.logger.Println("Failed to create media: ", err)
+		qs.removeMediaFiles(ctx, []repository.Media{media})
+		return nil, err
+	}
+	signed, err := signMedia(ctx, qs.storage, media)
+	if err != nil {
+		qs.logger.Println("Failed to sign media URL: ", err)
+		return nil, err
+	}
+	qs.logger.Println("Successfully uploaded media: ", media.Id)
+	return &signed, nil
+}
+
+// DeleteMedia deletes a media file from its question and from storage.
+func (qs *questionService) DeleteMedia(ctx context.Context, id int64) error {
+	media, err := qs.mediaRepository.GetMediaById(ctx, id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			qs.logger.Println("Failed to get media by id: ", err)
+		}
+		return pkg.ErrMediaNotFound
+	}
+	if err := qs.mediaRepository.DeleteMediaById(ctx, id); err != nil {
+		qs.logger.Println("Failed to delete media: ", err)
+		return err
+	}
+	qs.removeMediaFiles(ctx, []repository.Media{*media})
+	qs.logger.Println("Successfully deleted media: ", id)
+	return nil
+}
+
+// removeMediaFiles removes media files from storage once nothing refers to them. A file
+// that cannot be removed is only logged; it is unreachable without its record.
+func (qs *questionService) removeMediaFiles(ctx context.Context, media []repository.Media) {
+	for _, item := range media {
+		if err := qs.storage.Delete(ctx, item.StorageKey); err != nil {
+			qs.logger.Println("Failed to remove media file: ", item.StorageKey, err)
+		}
+	}
+}
+
+// signedQuestionMedia returns the media of the given questions with signed URLs, keyed by question id.
+func signedQuestionMedia(ctx context.Context, mediaRepository repository.MediaRepository, store storage.Storage, questionIds []int64) (map[int64][]domain.Media, error) {
+	media, err := mediaRepository.GetQuestionMedia(ctx, questionIds)
+	if err != nil {
+		return nil, err
+	}
+	signed := make(map[int64][]domain.Media, len(media))
+	for questionId, items := range media {
+		for _, item := range items {
+			signedItem, err := signMedia(ctx, store, item)
+			if err != nil {
+				return nil, err
+			}
+			signed[questionId] = append(signed[questionId], signedItem)
+		}
+	}
+	return signed, nil
+}
+
+// signMedia returns media as shown to users, with a URL that works for MediaURLExpiry.
+func signMedia(ctx context.Context, store storage.Storage, media repository.Media) (domain.Media, error) {
+	expiresAt := time.Now().Add(MediaURLExpiry).Truncate(time.Second)
+	url, err := store.URL(ctx, media.StorageKey, MediaURLExpiry)
+	if err != nil {
+		return domain.Media{}, err
+	}
+	return domain.Media{
+		Id:          media.Id,
+		Kind:        media.Kind,
+		ContentType: media.ContentType,
+		AttachTo:    media.AttachTo,
+		OptionId:    media.OptionId,
+		AltText:     media.AltText,
+		SizeBytes:   media.SizeBytes,
+		URL:         url,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// attachQuizMedia adds the media of a question and its options to the question as issued
+// in a quiz. Media of the explanation is held back for the results.
+func attachQuizMedia(question *domain.QuizQuestionResponse, media []domain.Media) {
+	for _, item := range media {
+		switch item.AttachTo {
+		case domain.MediaOnQuestion:
+			question.Media = append(question.Media, item)
+		case domain.MediaOnOption:
+			for _, options := range [][]domain.QuizOptionResponse{question.Options, question.Matches} {
+				for i := range options {
+					if options[i].Id == item.OptionId {
+						options[i].Media = append(options[i].Media, item)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/internal/storage"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStorage returns local media storage in a directory removed after the test
+func testStorage(t *testing.T) *storage.Local {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir(), "http://localhost:8080/media", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+var (
+	pngFile = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	mp3File = append([]byte("ID3"), bytes.Repeat([]byte{0}, 100)...)
+)
+
+func TestUploadQuestionMedia(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	store, err := storage.NewLocal(dir, "http://localhost:8080/media", []byte("secret"))
+	assert.Nil(t, err)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	questionService := NewQuestionService(repository.NewQuestionRepository(pool), subjectRepo, repository.NewMediaRepository(pool), store, log.New(io.Discard, "", 0))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+	questionId, err := questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Name:        "Which ray is refracted?",
+		Options:     []string{"A", "B"},
+		Answer:      "B",
+		Explanation: "It bends towards the normal.",
+	})
+	assert.Nil(t, err)
+	question, err := questionService.GetQuestionById(ctx, questionId)
+	assert.Nil(t, err)
+	assert.Empty(t, question.Media)
+	optionB := question.OptionIds[1]
+
+	diagram, err := questionService.UploadQuestionMedia(ctx, questionId, domain.MediaUpload{AttachTo: domain.MediaOnQuestion, AltText: "Two rays entering glass"}, bytes.NewReader(pngFile), int64(len(pngFile)))
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MediaKindImage, diagram.Kind)
+	assert.Equal(t, "image/png", diagram.ContentType)
+	assert.Equal(t, int64(len(pngFile)), diagram.SizeBytes)
+	assert.True(t, strings.HasPrefix(diagram.URL, "http://localhost:8080/media/questions/"))
+	assert.WithinDuration(t, time.Now().Add(MediaURLExpiry), diagram.ExpiresAt, time.Minute)
+	clip, err := questionService.UploadQuestionMedia(ctx, questionId, domain.MediaUpload{AttachTo: domain.MediaOnOption, OptionId: optionB}, bytes.NewReader(mp3File), int64(len(mp3File)))
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MediaKindAudio, clip.Kind)
+	assert.Equal(t, optionB, clip.OptionId)
+
+	tests := []struct {
+		name       string
+		questionId int64
+		upload     domain.MediaUpload
+		file       []byte
+		size       int64
+		err        error
+	}{
+		{name: "unknown question", questionId: 99, upload: domain.MediaUpload{AttachTo: domain.MediaOnQuestion}, file: pngFile, size: int64(len(pngFile)), err: pkg.ErrQuestionNotFound},
+		{name: "option of another question", questionId: questionId, upload: domain.MediaUpload{AttachTo: domain.MediaOnOption, OptionId: 99}, file: pngFile, size: int64(len(pngFile)), err: pkg.ErrQuestionOptionNotFound},
+		{name: "svg image", questionId: questionId, upload: domain.MediaUpload{AttachTo: domain.MediaOnQuestion}, file: []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), size: 70, err: pkg.ErrUnsupportedMediaType},
+		{name: "empty file", questionId: questionId, upload: domain.MediaUpload{AttachTo: domain.MediaOnQuestion}, file: nil, size: 0, err: pkg.ErrUnsupportedMediaType},
+		{name: "image over the limit", questionId: questionId, upload: domain.MediaUpload{AttachTo: domain.MediaOnQuestion}, file: pngFile, size: MaxImageBytes + 1, err: pkg.ErrMediaTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := questionService.UploadQuestionMedia(ctx, tt.questionId, tt.upload, bytes.NewReader(tt.file), tt.size)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	question, err = questionService.GetQuestionById(ctx, questionId)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{diagram.Id, clip.Id}, []int64{question.Media[0].Id, question.Media[1].Id})
+	assert.Equal(t, "Two rays entering glass", question.Media[0].AltText)
+	files, err := filepath.Glob(filepath.Join(dir, "questions", "*", "*"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	assert.Nil(t, questionService.DeleteMedia(ctx, diagram.Id))
+	assert.ErrorIs(t, questionService.DeleteMedia(ctx, diagram.Id), pkg.ErrMediaNotFound)
+	files, err = filepath.Glob(filepath.Join(dir, "questions", "*", "*"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	content, err := os.ReadFile(files[0])
+	assert.Nil(t, err)
+	assert.Equal(t, mp3File, content)
+}
+
+func TestQuizMedia(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store := testStorage(t)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	mediaRepo := repository.NewMediaRepository(pool)
+	qs := NewQuizService(repository.NewQuizRepository(pool), subjectRepo, questionRepo, repository.NewScoreRepository(pool), repository.NewQuizSessionRepository(pool), repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), mediaRepo, store)
+	questionService := NewQuestionService(questionRepo, subjectRepo, mediaRepo, store, log.New(io.Discard, "", 0))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+	questionId, err := questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Name:        "Which ray is refracted?",
+		Options:     []string{"A", "B"},
+		Answer:      "B",
+		Explanation: "It bends towards the normal.",
+	})
+	assert.Nil(t, err)
+	question, err := questionService.GetQuestionById(ctx, questionId)
+	assert.Nil(t, err)
+	optionB := question.OptionIds[1]
+	uploads := []domain.MediaUpload{
+		{AttachTo: domain.MediaOnQuestion},
+		{AttachTo: domain.MediaOnOption, OptionId: optionB},
+		{AttachTo: domain.MediaOnExplanation},
+	}
+	media := make([]*domain.Media, len(uploads))
+	for i, upload := range uploads {
+		media[i], err = questionService.UploadQuestionMedia(ctx, questionId, upload, bytes.NewReader(pngFile), int64(len(pngFile)))
+		assert.Nil(t, err)
+	}
+
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 1})
+	assert.Nil(t, err)
+	issued := quiz.Questions[0]
+	assert.Len(t, issued.Media, 1)
+	assert.Equal(t, media[0].Id, issued.Media[0].Id)
+	for _, option := range issued.Options {
+		if option.Id == optionB {
+			assert.Len(t, option.Media, 1)
+			assert.Equal(t, media[1].Id, option.Media[0].Id)
+		} else {
+			assert.Empty(t, option.Media)
+		}
+	}
+
+	// the explanation's media is only shown with the results
+	result, err := qs.SubmitQuiz(ctx, 1, domain.QuizSubmission{SessionId: quiz.SessionId, Answers: []domain.SubmitQuizRequest{{QuestionId: questionId, OptionIds: []int64{optionB}}}})
+	assert.Nil(t, err)
+	assert.Len(t, result.Results[0].Media, 1)
+	assert.Equal(t, media[0].Id, result.Results[0].Media[0].Id)
+	assert.Len(t, result.Results[0].ExplanationMedia, 1)
+	assert.Equal(t, media[2].Id, result.Results[0].ExplanationMedia[0].Id)
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// CreatePastPaper adds a past paper to a subject. A subject has one paper per exam body,
+// year and paper name, ignoring case and spacing.
+// It returns the id of the created paper and an error if any.
+func (qs *questionService) CreatePastPaper(ctx context.Context, subjectId int64, paper domain.PastPaperData) (int64, error) {
+	examBody := strings.Join(strings.Fields(paper.ExamBody), " ")
+	name := strings.Join(strings.Fields(paper.Paper), " ")
+	if examBody == "" || name == "" {
+		qs.logger.Println("Past paper exam body or name is empty. Proceeding to return error.")
+		return 0, pkg.ErrInvalidName
+	}
+	if _, err := qs.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+		qs.logger.Println("Failed to get subject by id: ", err)
+		return 0, pkg.ErrSubjectNotFound
+	}
+	papers, err := qs.subjectRepository.GetSubjectPastPapers(ctx, subjectId)
+	if err != nil {
+		qs.logger.Println("Failed to get subject past papers: ", err)
+		return 0, err
+	}
+	for _, existing := range papers {
+		if existing.Year == paper.Year && normalizeText(existing.ExamBody) == normalizeText(examBody) && normalizeText(existing.Paper) == normalizeText(name) {
+			qs.logger.Println("Past paper already exists. Proceeding to return error.")
+			return 0, pkg.ErrPastPaperExists
+		}
+	}
+	now := time.Now()
+	id, err := qs.subjectRepository.CreatePastPaper(ctx, repository.PastPaper{
+		SubjectId:       subjectId,
+		ExamBody:        examBody,
+		Year:            paper.Year,
+		Paper:           name,
+		DurationSeconds: paper.DurationSeconds,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	if err != nil {
+		qs.logger.Println("Failed to create past paper: ", err)
+		return 0, err
+	}
+	qs.logger.Println("Successfully created past paper. Proceeding to return id.")
+	return id, nil
+}
+
+// GetSubjectPastPapers returns the past papers of a subject, most recent year first.
+func (qs *questionService) GetSubjectPastPapers(ctx context.Context, subjectId int64) ([]domain.PastPaper, error) {
+	if _, err := qs.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+		qs.logger.Println("Failed to get subject by id: ", err)
+		return nil, pkg.ErrSubjectNotFound
+	}
+	papers, err := qs.subjectRepository.GetSubjectPastPapers(ctx, subjectId)
+	if err != nil {
+		qs.logger.Println("Failed to get subject past papers: ", err)
+		return nil, err
+	}
+	result := make([]domain.PastPaper, len(papers))
+	for i, paper := range papers {
+		result[i] = pastPaper(paper)
+	}
+	return result, nil
+}
+
+// SetQuestionSource records the past paper a question was set in and its number on the
+// paper, or clears it when PaperId is 0.
+func (qs *questionService) SetQuestionSource(ctx context.Context, questionId int64, source domain.QuestionSourceData) error {
+	question, err := qs.questionRepository.GetQuestionById(ctx, questionId)
+	if err != nil {
+		qs.logger.Println("Failed to get question by id: ", err)
+		return pkg.ErrQuestionNotFound
+	}
+	if err := qs.checkSource(ctx, question.SubjectId, questionId, &source); err != nil {
+		return err
+	}
+	if err := qs.questionRepository.SetQuestionSource(ctx, questionId, source.PaperId, source.QuestionNumber); err != nil {
+		qs.logger.Println("Failed to set question source: ", err)
+		return err
+	}
+	qs.logger.Println("Successfully set question source: ", questionId)
+	return nil
+}
+
+// checkSource makes sure the past paper a question was set in belongs to the question's
+// subject, and that no other question of the paper has its number. A nil source or one
+// without a paper always passes.
+func (qs *questionService) checkSource(ctx context.Context, subjectId int64, questionId int64, source *domain.QuestionSourceData) error {
+	if source == nil || source.PaperId == 0 {
+		return nil
+	}
+	paper, err := qs.subjectRepository.GetPastPaperById(ctx, source.PaperId)
+	if err != nil || paper.SubjectId != subjectId {
+		qs.logger.Println("Past paper is not in the subject. Proceeding to return error.")
+		return pkg.ErrPastPaperNotFound
+	}
+	questions, err := qs.questionRepository.GetPaperQuestions(ctx, source.PaperId)
+	if err != nil {
+		qs.logger.Println("Failed to get paper questions: ", err)
+		return err
+	}
+	for _, question := range questions {
+		if question.Id != questionId && question.PaperQuestionNumber == source.QuestionNumber {
+			qs.logger.Println("Question number is taken. Proceeding to return error.")
+			return pkg.ErrPaperQuestionNumberTaken
+		}
+	}
+	return nil
+}
+
+// questionSource returns where a question was set, or nil when it was not set in a past paper.
+func (qs *questionService) questionSource(ctx context.Context, question *repository.Questions) (*domain.QuestionSource, error) {
+	if question.PaperId == 0 {
+		return nil, nil
+	}
+	paper, err := qs.subjectRepository.GetPastPaperById(ctx, question.PaperId)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.QuestionSource{
+		PaperId:        paper.Id,
+		ExamBody:       paper.ExamBody,
+		Year:           paper.Year,
+		Paper:          paper.Paper,
+		QuestionNumber: question.PaperQuestionNumber,
+	}, nil
+}
+
+// GenerateMockExam reconstructs a past paper as a timed exam: every question of the paper
+// in its original order, with choice options in the order they were set, and the paper's
+// duration as the deadline. Ordering options and matches are still shuffled, since their
+// original order would give the answer away.
+func (qs *quizService) GenerateMockExam(ctx context.Context, userID int64, request domain.MockExamRequest) (*domain.GeneratedQuizResponse, error) {
+	paper, err := qs.subjectRepository.GetPastPaperById(ctx, request.PaperId)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			fmt.Println("error getting past paper: ", err)
+		}
+		return nil, pkg.ErrPastPaperNotFound
+	}
+	questions, err := qs.questionRepository.GetPaperQuestions(ctx, paper.Id)
+	if err != nil {
+		fmt.Println("error getting paper questions: ", err)
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("%w: paper %d has no questions yet", pkg.ErrNotEnoughQuestions, paper.Id)
+	}
+	scoringPolicy, err := qs.subjectRepository.GetSubjectScoringPolicy(ctx, paper.SubjectId)
+	if err != nil {
+		fmt.Println("error getting scoring policy: ", err)
+		return nil, err
+	}
+
+	picked := make([]pickedQuestion, len(questions))
+	for i, question := range questions {
+		picked[i] = pickedQuestion{Questions: question}
+	}
+	mockPaper := pastPaper(*paper)
+	mockPaper.Questions = int64(len(questions))
+	return qs.issueQuiz(ctx, userID, quizPlan{
+		mode:            domain.ModeMock,
+		durationSeconds: paper.DurationSeconds,
+		breakdown:       []domain.QuizSubjectRequest{{SubjectId: paper.SubjectId, NumOfQuestions: int64(len(questions))}},
+		subjects: []repository.QuizSessionSubject{{
+			SubjectId:      paper.SubjectId,
+			NumOfQuestions: int64(len(questions)),
+			ScoringPolicy:  *scoringPolicy,
+		}},
+		questions:       picked,
+		paper:           &mockPaper,
+		keepOptionOrder: true,
+	}, time.Now())
+}
+
+// paperComparison compares a submitted mock exam with the other sittings of its paper.
+func (qs *quizService) paperComparison(ctx context.Context, session *repository.QuizSession, points float64) (*domain.PaperComparison, error) {
+	sittings, err := qs.quizSessionRepository.GetPaperSittings(ctx, session.PaperId)
+	if err != nil {
+		return nil, err
+	}
+	comparison := comparePaperSitting(session.UserId, points, sittings)
+	comparison.PaperId = session.PaperId
+	return &comparison, nil
+}
+
+// comparePaperSitting compares the points a user scored on a paper with the first sitting
+// of the paper by every other user that was submitted in time.
+func comparePaperSitting(userId int64, points float64, sittings []repository.PaperSitting) domain.PaperComparison {
+	pool := []float64{points}
+	compared := map[int64]bool{userId: true}
+	for _, sitting := range sittings {
+		if compared[sitting.UserId] {
+			continue
+		}
+		if sitting.ExpiresAt != nil && sitting.SubmittedAt.After(sitting.ExpiresAt.Add(ExamSubmissionGracePeriod)) {
+			continue
+		}
+		compared[sitting.UserId] = true
+		pool = append(pool, sitting.Points)
+	}
+
+	comparison := domain.PaperComparison{Candidates: int64(len(pool)), Rank: 1, HighestPoints: points}
+	total := float64(0)
+	atOrBelow := 0
+	for _, other := range pool {
+		total += other
+		if other > points {
+			comparison.Rank++
+		} else {
+			atOrBelow++
+		}
+		if other > comparison.HighestPoints {
+			comparison.HighestPoints = other
+		}
+	}
+	comparison.AveragePoints = total / float64(len(pool))
+	comparison.Percentile = float64(atOrBelow) / float64(len(pool)) * 100
+	return comparison
+}
+
+// pastPaper returns a past paper as shown to users.
+func pastPaper(paper repository.PastPaper) domain.PastPaper {
+	return domain.PastPaper{
+		Id:              paper.Id,
+		SubjectId:       paper.SubjectId,
+		ExamBody:        paper.ExamBody,
+		Year:            paper.Year,
+		Paper:           paper.Paper,
+		DurationSeconds: paper.DurationSeconds,
+		Questions:       paper.Questions,
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPastPapers(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	subjectRepo := repository.NewSubjectRepository(pool)
+	questionService := NewQuestionService(repository.NewQuestionRepository(pool), subjectRepo, repository.NewMediaRepository(pool), testStorage(t), log.New(io.Discard, "", 0))
+
+	maths, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	physics, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+
+	waec2019, err := questionService.CreatePastPaper(ctx, maths, domain.PastPaperData{ExamBody: " WAEC ", Year: 2019, Paper: "Paper  1", DurationSeconds: 3600})
+	assert.Nil(t, err)
+	waec2021, err := questionService.CreatePastPaper(ctx, maths, domain.PastPaperData{ExamBody: "WAEC", Year: 2021, Paper: "Paper 1", DurationSeconds: 5400})
+	assert.Nil(t, err)
+	mechanics, err := questionService.CreatePastPaper(ctx, physics, domain.PastPaperData{ExamBody: "WAEC", Year: 2019, Paper: "Paper 1", DurationSeconds: 3600})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name      string
+		subjectId int64
+		paper     domain.PastPaperData
+		err       error
+	}{
+		{name: "paper already in the subject", subjectId: maths, paper: domain.PastPaperData{ExamBody: "waec", Year: 2019, Paper: "PAPER 1", DurationSeconds: 3600}, err: pkg.ErrPastPaperExists},
+		{name: "unknown subject", subjectId: 99, paper: domain.PastPaperData{ExamBody: "WAEC", Year: 2019, Paper: "Paper 1", DurationSeconds: 3600}, err: pkg.ErrSubjectNotFound},
+		{name: "blank exam body", subjectId: maths, paper: domain.PastPaperData{ExamBody: "  ", Year: 2019, Paper: "Paper 2", DurationSeconds: 3600}, err: pkg.ErrInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := questionService.CreatePastPaper(ctx, tt.subjectId, tt.paper)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	question := domain.QuestionsData{
+		Name:        "What is x if 2x = 6?",
+		Options:     []string{"2", "3"},
+		Answer:      "3",
+		Explanation: "Divide both sides by 2.",
+		Source:      &domain.QuestionSourceData{PaperId: waec2019, QuestionNumber: 4},
+	}
+	id, err := questionService.CreateQuestion(ctx, maths, question)
+	assert.Nil(t, err)
+	created, err := questionService.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, &domain.QuestionSource{PaperId: waec2019, ExamBody: "WAEC", Year: 2019, Paper: "Paper 1", QuestionNumber: 4}, created.Source)
+
+	// a number is used once per paper, and the paper must be in the question's subject
+	question.Name = "What is y if 3y = 6?"
+	_, err = questionService.CreateQuestion(ctx, maths, question)
+	assert.ErrorIs(t, err, pkg.ErrPaperQuestionNumberTaken)
+	question.Source = &domain.QuestionSourceData{PaperId: mechanics, QuestionNumber: 5}
+	_, err = questionService.CreateQuestion(ctx, maths, question)
+	assert.ErrorIs(t, err, pkg.ErrPastPaperNotFound)
+	question.Source = nil
+	other, err := questionService.CreateQuestion(ctx, maths, question)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, questionService.SetQuestionSource(ctx, other, domain.QuestionSourceData{PaperId: waec2019, QuestionNumber: 4}), pkg.ErrPaperQuestionNumberTaken)
+	assert.ErrorIs(t, questionService.SetQuestionSource(ctx, 99, domain.QuestionSourceData{}), pkg.ErrQuestionNotFound)
+
+	// a question keeps its own number when its source is set again
+	assert.Nil(t, questionService.SetQuestionSource(ctx, id, domain.QuestionSourceData{PaperId: waec2019, QuestionNumber: 4}))
+	assert.Nil(t, questionService.SetQuestionSource(ctx, other, domain.QuestionSourceData{PaperId: waec2021, QuestionNumber: 1}))
+	assert.Nil(t, questionService.SetQuestionSource(ctx, id, domain.QuestionSourceData{}))
+	created, err = questionService.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.Nil(t, created.Source)
+
+	papers, err := questionService.GetSubjectPastPapers(ctx, maths)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.PastPaper{
+		{Id: waec2021, SubjectId: maths, ExamBody: "WAEC", Year: 2021, Paper: "Paper 1", DurationSeconds: 5400, Questions: 1},
+		{Id: waec2019, SubjectId: maths, ExamBody: "WAEC", Year: 2019, Paper: "Paper 1", DurationSeconds: 3600, Questions: 0},
+	}, papers)
+	_, err = questionService.GetSubjectPastPapers(ctx, 99)
+	assert.ErrorIs(t, err, pkg.ErrSubjectNotFound)
+}
+
+func TestGenerateMockExam(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+	questionService := NewQuestionService(questionRepo, subjectRepo, repository.NewMediaRepository(pool), testStorage(t), log.New(io.Discard, "", 0))
+
+	maths, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	paperId, err := questionService.CreatePastPaper(ctx, maths, domain.PastPaperData{ExamBody: "WAEC", Year: 2019, Paper: "Paper 1", DurationSeconds: 3600})
+	assert.Nil(t, err)
+	emptyPaper, err := questionService.CreatePastPaper(ctx, maths, domain.PastPaperData{ExamBody: "WAEC", Year: 2020, Paper: "Paper 1", DurationSeconds: 3600})
+	assert.Nil(t, err)
+	// added out of order, so the exam has to follow the paper's numbering
+	numbers := []int{3, 1, 2}
+	questionIds := make(map[int]int64, len(numbers))
+	for _, number := range numbers {
+		id, err := questionService.CreateQuestion(ctx, maths, domain.QuestionsData{
+			Name:        fmt.Sprintf("What is %d + %d?", number, number),
+			Options:     []string{"1", "2", "4", "6"},
+			Answer:      fmt.Sprint(number * 2),
+			Explanation: "Add them up.",
+			Source:      &domain.QuestionSourceData{PaperId: paperId, QuestionNumber: number},
+		})
+		assert.Nil(t, err)
+		questionIds[number] = id
+	}
+	for i := 1; i <= 2; i++ {
+		_, err := pool.ExecContext(ctx, "INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", fmt.Sprint("user", i), fmt.Sprintf("user%d@example.com", i), time.Now())
+		assert.Nil(t, err)
+	}
+
+	_, err = qs.GenerateMockExam(ctx, 1, domain.MockExamRequest{PaperId: 99})
+	assert.ErrorIs(t, err, pkg.ErrPastPaperNotFound)
+	_, err = qs.GenerateMockExam(ctx, 1, domain.MockExamRequest{PaperId: emptyPaper})
+	assert.ErrorIs(t, err, pkg.ErrNotEnoughQuestions)
+
+	quiz, err := qs.GenerateMockExam(ctx, 1, domain.MockExamRequest{PaperId: paperId})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ModeMock, quiz.Mode)
+	assert.Equal(t, int64(3600), quiz.DurationSeconds)
+	assert.NotNil(t, quiz.ExpiresAt)
+	assert.Equal(t, &domain.PastPaper{Id: paperId, SubjectId: maths, ExamBody: "WAEC", Year: 2019, Paper: "Paper 1", DurationSeconds: 3600, Questions: 3}, quiz.Paper)
+	assert.Len(t, quiz.Questions, 3)
+	for i, question := range quiz.Questions {
+		assert.Equal(t, questionIds[i+1], question.QuestionId)
+		for j := 1; j < len(question.Options); j++ {
+			assert.Less(t, question.Options[j-1].Id, question.Options[j].Id, "options are in the order they were set")
+		}
+	}
+
+	result, err := qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, quiz, 3))
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ModeMock, result.Mode)
+	assert.Equal(t, &domain.PaperComparison{PaperId: paperId, Candidates: 1, Rank: 1, Percentile: 100, AveragePoints: 3, HighestPoints: 3}, result.Paper)
+
+	quiz, err = qs.GenerateMockExam(ctx, 2, domain.MockExamRequest{PaperId: paperId})
+	assert.Nil(t, err)
+	result, err = qs.SubmitQuiz(ctx, 2, answerQuiz(t, ctx, questionRepo, quiz, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, &domain.PaperComparison{PaperId: paperId, Candidates: 2, Rank: 2, Percentile: 50, AveragePoints: 2, HighestPoints: 3}, result.Paper)
+
+	review, err := qs.GetQuizReview(ctx, 2, quiz.SessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Paper, review.Paper)
+}
+
+func TestComparePaperSitting(t *testing.T) {
+	deadline := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	onTime := deadline.Add(-time.Minute)
+	late := deadline.Add(ExamSubmissionGracePeriod + time.Minute)
+	tests := []struct {
+		name     string
+		points   float64
+		sittings []repository.PaperSitting
+		want     domain.PaperComparison
+	}{
+		{
+			name:   "only sitting",
+			points: 30,
+			want:   domain.PaperComparison{Candidates: 1, Rank: 1, Percentile: 100, AveragePoints: 30, HighestPoints: 30},
+		},
+		{
+			name:   "ties share a rank",
+			points: 30,
+			sittings: []repository.PaperSitting{
+				{UserId: 2, Points: 40, ExpiresAt: &deadline, SubmittedAt: onTime},
+				{UserId: 3, Points: 30, ExpiresAt: &deadline, SubmittedAt: onTime},
+				{UserId: 4, Points: 10, ExpiresAt: &deadline, SubmittedAt: onTime},
+			},
+			want: domain.PaperComparison{Candidates: 4, Rank: 2, Percentile: 75, AveragePoints: 27.5, HighestPoints: 40},
+		},
+		{
+			name:   "late sittings are left out",
+			points: 20,
+			sittings: []repository.PaperSitting{
+				{UserId: 2, Points: 40, ExpiresAt: &deadline, SubmittedAt: late},
+				{UserId: 3, Points: 10, ExpiresAt: &deadline, SubmittedAt: onTime},
+			},
+			want: domain.PaperComparison{Candidates: 2, Rank: 1, Percentile: 100, AveragePoints: 15, HighestPoints: 20},
+		},
+		{
+			name:   "only the first sitting of each user counts",
+			points: 20,
+			sittings: []repository.PaperSitting{
+				{UserId: 1, Points: 5, ExpiresAt: &deadline, SubmittedAt: onTime},
+				{UserId: 2, Points: 10, ExpiresAt: &deadline, SubmittedAt: onTime},
+				{UserId: 2, Points: 40, ExpiresAt: &deadline, SubmittedAt: onTime},
+			},
+			want: domain.PaperComparison{Candidates: 2, Rank: 1, Percentile: 100, AveragePoints: 15, HighestPoints: 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, comparePaperSitting(1, tt.points, tt.sittings))
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+)
+
+// numericEpsilon absorbs floating point error when a numeric answer lands right on the
+// edge of its tolerance. It is relative to the size of the answer.
+const numericEpsilon = 1e-9
+
+// answerMatchesType reports whether a submitted answer is given the way questions of the
+// type are answered: options for choice, true/false and ordering questions, a value and
+// optionally a unit for numeric questions, text for short text questions and pairs for
+// matching questions.
+func answerMatchesType(questionType string, answer domain.SubmitQuizRequest) bool {
+	hasOptions := len(answer.OptionIds) > 0
+	hasValue := answer.Value != nil || strings.TrimSpace(answer.Unit) != ""
+	hasText := strings.TrimSpace(answer.Text) != ""
+	hasPairs := len(answer.Pairs) > 0
+	switch questionType {
+	case domain.QuestionTypeNumeric:
+		return !hasOptions && !hasText && !hasPairs
+	case domain.QuestionTypeShortText:
+		return !hasOptions && !hasValue && !hasPairs
+	case domain.QuestionTypeMatching:
+		return !hasOptions && !hasValue && !hasText
+	default:
+		return !hasValue && !hasText && !hasPairs
+	}
+}
+
+// gradeAnswer returns the credit, between 0 and 1, earned by an answer to the question of
+// the key. Only choice, ordering and matching questions can earn partial credit.
+func gradeAnswer(key *answerKey, answer domain.SubmitQuizRequest) float64 {
+	switch key.question.Type {
+	case domain.QuestionTypeOrdering:
+		return gradeOrder(key.order, answer.OptionIds, key.question.PartialCredit)
+	case domain.QuestionTypeMatching:
+		return gradeMatching(key.matches, answer.Pairs, key.question.PartialCredit)
+	case domain.QuestionTypeNumeric:
+		return gradeNumeric(key.question, answer.Value, answer.Unit)
+	case domain.QuestionTypeShortText:
+		return gradeText(key.question.AcceptedAnswers, answer.Text)
+	default:
+		return gradeSelection(key.correctIds, answer.OptionIds, key.question.PartialCredit)
+	}
+}
+
+// gradeOrder gives full credit to options given in exactly the correct order. With partial
+// credit each option given at its correct position earns an equal share.
+func gradeOrder(order []int64, given []int64, partialCredit bool) float64 {
+	if len(order) == 0 || len(given) == 0 {
+		return 0
+	}
+	hits := 0
+	for i, optionId := range given {
+		if i < len(order) && order[i] == optionId {
+			hits++
+		}
+	}
+	if hits == len(order) && len(given) == len(order) {
+		return 1
+	}
+	if !partialCredit {
+		return 0
+	}
+	return float64(hits) / float64(len(order))
+}
+
+// gradeMatching gives full credit when every option is paired with its match. With
+// partial credit each correct pair earns an equal share.
+func gradeMatching(matches map[int64]int64, pairs []domain.MatchPair, partialCredit bool) float64 {
+	if len(matches) == 0 || len(pairs) == 0 {
+		return 0
+	}
+	hits := 0
+	for _, pair := range pairs {
+		if matchId, ok := matches[pair.OptionId]; ok && matchId == pair.MatchId {
+			hits++
+		}
+	}
+	if hits == len(matches) {
+		return 1
+	}
+	if !partialCredit {
+		return 0
+	}
+	return float64(hits) / float64(len(matches))
+}
+
+// gradeNumeric gives full credit to a value within the question's tolerance of its answer.
+// The unit may be left out, but when one is given it must be the question's unit. Units
+// are compared as written apart from spacing, since case matters: mA is not MA.
+func gradeNumeric(question repository.Questions, value *float64, unit string) float64 {
+	if value == nil {
+		return 0
+	}
+	if unit = normalizeUnit(unit); unit != "" && unit != normalizeUnit(question.Unit) {
+		return 0
+	}
+	margin := question.Tolerance + numericEpsilon*math.Max(1, math.Abs(question.NumericAnswer))
+	if math.Abs(*value-question.NumericAnswer) > margin {
+		return 0
+	}
+	return 1
+}
+
+// gradeText gives full credit to text matching any of the accepted answers, ignoring case
+// and spacing.
+func gradeText(accepted []string, text string) float64 {
+	text = normalizeText(text)
+	if text == "" {
+		return 0
+	}
+	if slices.ContainsFunc(accepted, func(answer string) bool { return normalizeText(answer) == text }) {
+		return 1
+	}
+	return 0
+}
+
+// normalizeText lower cases text and collapses every run of whitespace into a single space.
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// normalizeUnit removes all whitespace from a unit, so "m / s" and "m/s" are the same unit.
+func normalizeUnit(unit string) string {
+	return strings.Join(strings.Fields(unit), "")
+}
+
+// formatNumericAnswer writes a value with its unit, if it has one.
+func formatNumericAnswer(value float64, unit string) string {
+	formatted := strconv.FormatFloat(value, 'g', -1, 64)
+	if unit = strings.TrimSpace(unit); unit != "" {
+		formatted += " " + unit
+	}
+	return formatted
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGradeOrder(t *testing.T) {
+	order := []int64{3, 1, 4, 2}
+	tests := []struct {
+		name          string
+		given         []int64
+		partialCredit bool
+		want          float64
+	}{
+		{name: "correct order", given: []int64{3, 1, 4, 2}, want: 1},
+		{name: "two swapped", given: []int64{3, 1, 2, 4}, want: 0},
+		{name: "two swapped with partial credit", given: []int64{3, 1, 2, 4}, partialCredit: true, want: 0.5},
+		{name: "reversed with partial credit", given: []int64{2, 4, 1, 3}, partialCredit: true, want: 0},
+		{name: "some options left out", given: []int64{3, 1}, want: 0},
+		{name: "some options left out with partial credit", given: []int64{3, 1}, partialCredit: true, want: 0.5},
+		{name: "unanswered", given: nil, partialCredit: true, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, gradeOrder(order, tt.given, tt.partialCredit))
+		})
+	}
+}
+
+func TestGradeMatching(t *testing.T) {
+	matches := map[int64]int64{1: 11, 2: 12, 3: 13, 4: 14}
+	// pairs takes option and match ids in turn
+	pairs := func(ids ...int64) []domain.MatchPair {
+		var matchPairs []domain.MatchPair
+		for i := 0; i < len(ids); i += 2 {
+			matchPairs = append(matchPairs, domain.MatchPair{OptionId: ids[i], MatchId: ids[i+1]})
+		}
+		return matchPairs
+	}
+	tests := []struct {
+		name          string
+		pairs         []domain.MatchPair
+		partialCredit bool
+		want          float64
+	}{
+		{name: "every pair correct", pairs: pairs(1, 11, 2, 12, 3, 13, 4, 14), want: 1},
+		{name: "pairs in any order", pairs: pairs(4, 14, 2, 12, 3, 13, 1, 11), want: 1},
+		{name: "two crossed", pairs: pairs(1, 12, 2, 11, 3, 13, 4, 14), want: 0},
+		{name: "two crossed with partial credit", pairs: pairs(1, 12, 2, 11, 3, 13, 4, 14), partialCredit: true, want: 0.5},
+		{name: "one pair with partial credit", pairs: pairs(3, 13), partialCredit: true, want: 0.25},
+		{name: "a match used as an option", pairs: pairs(11, 1), partialCredit: true, want: 0},
+		{name: "unanswered", pairs: nil, partialCredit: true, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, gradeMatching(matches, tt.pairs, tt.partialCredit))
+		})
+	}
+}
+
+func TestGradeNumeric(t *testing.T) {
+	gravity := repository.Questions{Type: domain.QuestionTypeNumeric, NumericAnswer: 9.81, Tolerance: 0.05, Unit: "m/s^2"}
+	exact := repository.Questions{Type: domain.QuestionTypeNumeric, NumericAnswer: 0.3}
+	value := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name     string
+		question repository.Questions
+		value    *float64
+		unit     string
+		want     float64
+	}{
+		{name: "exact value", question: gravity, value: value(9.81), want: 1},
+		{name: "within tolerance", question: gravity, value: value(9.78), want: 1},
+		{name: "on the edge of the tolerance", question: gravity, value: value(9.86), want: 1},
+		{name: "outside the tolerance", question: gravity, value: value(9.87), want: 0},
+		{name: "matching unit", question: gravity, value: value(9.8), unit: "m/s^2", want: 1},
+		{name: "unit spacing is ignored", question: gravity, value: value(9.8), unit: " m / s^2 ", want: 1},
+		{name: "wrong unit", question: gravity, value: value(9.8), unit: "km/h", want: 0},
+		{name: "unit case matters", question: gravity, value: value(9.8), unit: "M/S^2", want: 0},
+		{name: "unanswered", question: gravity, value: nil, want: 0},
+		{name: "floating point error is absorbed", question: exact, value: value(0.1 + 0.2), want: 1},
+		{name: "no tolerance", question: exact, value: value(0.31), want: 0},
+		{name: "unit on a unitless question", question: exact, value: value(0.3), unit: "kg", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, gradeNumeric(tt.question, tt.value, tt.unit))
+		})
+	}
+}
+
+func TestGradeText(t *testing.T) {
+	accepted := []string{"Photosynthesis", "photo synthesis"}
+	tests := []struct {
+		name string
+		text string
+		want float64
+	}{
+		{name: "exact match", text: "Photosynthesis", want: 1},
+		{name: "case is ignored", text: "PHOTOSYNTHESIS", want: 1},
+		{name: "surrounding space is ignored", text: "  photosynthesis\n", want: 1},
+		{name: "accepted alternative", text: "Photo   Synthesis", want: 1},
+		{name: "wrong answer", text: "respiration", want: 0},
+		{name: "spacing inside a word matters", text: "photos ynthesis", want: 0},
+		{name: "blank", text: "   ", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, gradeText(accepted, tt.text))
+		})
+	}
+}
+
+func TestAnswerMatchesType(t *testing.T) {
+	value := 4.0
+	options := domain.SubmitQuizRequest{OptionIds: []int64{1}}
+	numeric := domain.SubmitQuizRequest{Value: &value, Unit: "cm"}
+	text := domain.SubmitQuizRequest{Text: "mitochondria"}
+	pairs := domain.SubmitQuizRequest{Pairs: []domain.MatchPair{{OptionId: 1, MatchId: 2}}}
+
+	tests := []struct {
+		questionType string
+		answer       domain.SubmitQuizRequest
+		want         bool
+	}{
+		{questionType: domain.QuestionTypeChoice, answer: options, want: true},
+		{questionType: domain.QuestionTypeChoice, answer: numeric, want: false},
+		{questionType: domain.QuestionTypeTrueFalse, answer: options, want: true},
+		{questionType: domain.QuestionTypeTrueFalse, answer: text, want: false},
+		{questionType: domain.QuestionTypeNumeric, answer: numeric, want: true},
+		{questionType: domain.QuestionTypeNumeric, answer: options, want: false},
+		{questionType: domain.QuestionTypeNumeric, answer: text, want: false},
+		{questionType: domain.QuestionTypeShortText, answer: text, want: true},
+		{questionType: domain.QuestionTypeShortText, answer: numeric, want: false},
+		{questionType: domain.QuestionTypeShortText, answer: options, want: false},
+		{questionType: domain.QuestionTypeOrdering, answer: options, want: true},
+		{questionType: domain.QuestionTypeOrdering, answer: pairs, want: false},
+		{questionType: domain.QuestionTypeMatching, answer: pairs, want: true},
+		{questionType: domain.QuestionTypeMatching, answer: options, want: false},
+		{questionType: domain.QuestionTypeChoice, answer: pairs, want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, answerMatchesType(tt.questionType, tt.answer), "%s answered with %+v", tt.questionType, tt.answer)
+	}
+}
@@ -2,11 +2,18 @@ package service
 
 import (
 	"context"
+	"io"
 	"log"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/lawson/otterprep/domain"
 	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/internal/richtext"
+	"github.com/lawson/otterprep/internal/storage"
 	"github.com/lawson/otterprep/pkg"
 )
 
@@ -21,11 +28,26 @@ type QuestionService interface {
 	CreateSubject(ctx context.Context, subject string) (int64, error)
 	GetSubjectById(ctx context.Context, id int64) (*domain.Subject, error)
 	GetAllSubjects(ctx context.Context) ([]repository.Subject, error)
+	GetSubjectScoringPolicy(ctx context.Context, subjectId int64) (*domain.ScoringPolicy, error)
+	SetSubjectScoringPolicy(ctx context.Context, subjectId int64, policy domain.ScoringPolicy) error
+	CreateTopic(ctx context.Context, subjectId int64, topic domain.TopicData) (int64, error)
+	GetSubjectTopics(ctx context.Context, subjectId int64) ([]domain.Topic, error)
+	UpdateTopic(ctx context.Context, id int64, topic domain.TopicData) error
+	DeleteTopic(ctx context.Context, id int64) error
+	ClassifyQuestion(ctx context.Context, questionId int64, classification domain.QuestionClassification) error
+	GetSubjectTags(ctx context.Context, subjectId int64) ([]domain.SubjectTag, error)
+	CreatePastPaper(ctx context.Context, subjectId int64, paper domain.PastPaperData) (int64, error)
+	GetSubjectPastPapers(ctx context.Context, subjectId int64) ([]domain.PastPaper, error)
+	SetQuestionSource(ctx context.Context, questionId int64, source domain.QuestionSourceData) error
+	UploadQuestionMedia(ctx context.Context, questionId int64, upload domain.MediaUpload, body io.Reader, size int64) (*domain.Media, error)
+	DeleteMedia(ctx context.Context, id int64) error
 }
 
 type questionService struct {
 	questionRepository repository.QuestionRepository
 	subjectRepository  repository.SubjectRepository
+	mediaRepository    repository.MediaRepository
+	storage            storage.Storage
 	logger             *log.Logger
 }
 
@@ -40,13 +62,25 @@ func (qs *questionService) GetAllSubjects(ctx context.Context) ([]repository.Sub
 	return result, nil
 }
 
-func NewQuestionService(questionRepository repository.QuestionRepository, subjectRepository repository.SubjectRepository, logger *log.Logger) *questionService {
-	return &questionService{questionRepository: questionRepository, subjectRepository: subjectRepository, logger: logger}
+func NewQuestionService(questionRepository repository.QuestionRepository, subjectRepository repository.SubjectRepository, mediaRepository repository.MediaRepository, storage storage.Storage, logger *log.Logger) *questionService {
+	return &questionService{questionRepository: questionRepository, subjectRepository: subjectRepository, mediaRepository: mediaRepository, storage: storage, logger: logger}
 }
 
 // CreateQuestion creates a new question and its options and answer.
+// True/false questions are given a True and a False option, numeric and short text
+// questions have none and keep their answers on the question. Both sides of the pairs of
+// a matching question are stored as options. The question is filed under TopicId, which
+// must be a topic of the subject, and given Tags. A Source must be a past paper of the
+// subject, with a question number no other question of the paper has.
+// The question's text, options and explanation are Markdown with LaTeX math, and are
+// stored in canonical form.
 // It returns the id of the created question and an error if any.
 func (qs *questionService) CreateQuestion(ctx context.Context, subjectId int64, question domain.QuestionsData) (int64, error) {
+	question, err := canonicalQuestionText(question)
+	if err != nil {
+		qs.logger.Println("Invalid question text: ", err)
+		return 0, err
+	}
 
 	if question.Name == "" {
 		qs.logger.Println("Question name is empty. Proceeding to return error.")
@@ -57,35 +91,67 @@ func (qs *questionService) CreateQuestion(ctx context.Context, subjectId int64,
 		qs.logger.Println("Subject id is 0. Proceeding to return error.")
 		return 0, pkg.ErrSubjectNotFound
 	}
+
+	options, answers, err := questionAnswers(question)
+	if err != nil {
+		qs.logger.Println("Invalid question answers: ", err)
+		return 0, err
+	}
 	qs.logger.Println("check if subject exists.")
-	_, err := qs.subjectRepository.GetSubjectById(ctx, subjectId)
+	_, err = qs.subjectRepository.GetSubjectById(ctx, subjectId)
 	if err != nil {
 		qs.logger.Println("Failed to get subject by id: ", err)
 		return 0, err
 	}
+	if err := qs.checkTopic(ctx, subjectId, question.TopicId); err != nil {
+		return 0, err
+	}
+	if err := qs.checkSource(ctx, subjectId, 0, question.Source); err != nil {
+		return 0, err
+	}
 	qs.logger.Println("Successfully got subject by id. Proceeding to create question.")
 
-	id, err := qs.questionRepository.CreateQuestion(ctx, repository.Questions{
-		SubjectId: subjectId,
-		Question:  question.Name,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	})
+	newQuestion := repository.Questions{
+		SubjectId:  subjectId,
+		Type:       question.QuestionType(),
+		Question:   question.Name,
+		Weight:     question.Weight,
+		Difficulty: question.Difficulty,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	switch newQuestion.Type {
+	case domain.QuestionTypeChoice:
+		newQuestion.IsMultipleChoice = len(answers) > 1
+		newQuestion.PartialCredit = question.PartialCredit
+	case domain.QuestionTypeOrdering, domain.QuestionTypeMatching:
+		newQuestion.PartialCredit = question.PartialCredit
+	case domain.QuestionTypeNumeric:
+		newQuestion.NumericAnswer = *question.NumericAnswer
+		newQuestion.Tolerance = question.Tolerance
+		newQuestion.Unit = strings.TrimSpace(question.Unit)
+	case domain.QuestionTypeShortText:
+		newQuestion.AcceptedAnswers = answers
+	}
+	id, err := qs.questionRepository.CreateQuestion(ctx, newQuestion)
 	if err != nil {
 		qs.logger.Println("Failed to create question: ", err)
 		return 0, err
 	}
 	qs.logger.Println("Successfully created question. Proceeding to create options.")
-	for _, option := range question.Options {
-		_, err = qs.CreateQuestionOption(ctx, repository.QuestionOptions{
-			QuestionId: id,
-			Option:     option,
-			CreatedAt:  time.Now(),
-			UpdatedAt:  time.Now(),
-			IsCorrect:  option == question.Answer,
-		})
-		if err != nil {
-			qs.logger.Println("Failed to create question option: ", err)
+	if err := qs.createQuestionOptions(ctx, id, question, options, answers); err != nil {
+		qs.logger.Println("Failed to create question option: ", err)
+		return 0, err
+	}
+	if tags := normalizeTags(question.Tags); question.TopicId != 0 || len(tags) > 0 {
+		if err := qs.questionRepository.SetQuestionClassification(ctx, id, question.TopicId, tags); err != nil {
+			qs.logger.Println("Failed to classify question: ", err)
+			return 0, err
+		}
+	}
+	if question.Source != nil && question.Source.PaperId != 0 {
+		if err := qs.questionRepository.SetQuestionSource(ctx, id, question.Source.PaperId, question.Source.QuestionNumber); err != nil {
+			qs.logger.Println("Failed to set question source: ", err)
 			return 0, err
 		}
 	}
@@ -104,6 +170,169 @@ func (qs *questionService) CreateQuestion(ctx context.Context, subjectId int64,
 	return id, nil
 }
 
+// createQuestionOptions creates the options of a new question. The options of an ordering
+// question and both sides of the pairs of a matching question are created in a random
+// order, so the order of their ids gives nothing away.
+func (qs *questionService) createQuestionOptions(ctx context.Context, questionId int64, question domain.QuestionsData, options []string, answers []string) error {
+	now := time.Now()
+	switch question.QuestionType() {
+	case domain.QuestionTypeOrdering:
+		for _, position := range rand.Perm(len(options)) {
+			_, err := qs.CreateQuestionOption(ctx, repository.QuestionOptions{
+				QuestionId: questionId,
+				Option:     options[position],
+				Position:   position,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	case domain.QuestionTypeMatching:
+		// The matches are created first so each option can point at its own
+		matchIds := make([]int64, len(question.Pairs))
+		for _, i := range rand.Perm(len(question.Pairs)) {
+			matchId, err := qs.CreateQuestionOption(ctx, repository.QuestionOptions{
+				QuestionId: questionId,
+				Option:     strings.TrimSpace(question.Pairs[i].Match),
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			})
+			if err != nil {
+				return err
+			}
+			matchIds[i] = matchId
+		}
+		for _, i := range rand.Perm(len(question.Pairs)) {
+			_, err := qs.CreateQuestionOption(ctx, repository.QuestionOptions{
+				QuestionId:    questionId,
+				Option:        strings.TrimSpace(question.Pairs[i].Option),
+				MatchOptionId: matchIds[i],
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		for _, option := range options {
+			_, err := qs.CreateQuestionOption(ctx, repository.QuestionOptions{
+				QuestionId: questionId,
+				Option:     option,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+				IsCorrect:  slices.Contains(answers, option),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// questionAnswers checks that an authored question can be answered the way its type is
+// answered, and returns the options to create for it and its correct answers: the correct
+// options of a choice or true/false question, or the accepted answers of a short text one.
+// The options of an ordering question are returned in order; a matching question's options
+// come from its pairs.
+func questionAnswers(question domain.QuestionsData) ([]string, []string, error) {
+	questionType := question.QuestionType()
+	if questionType != domain.QuestionTypeChoice && questionType != domain.QuestionTypeOrdering && len(question.Options) > 0 {
+		return nil, nil, pkg.ErrOptionsNotAllowed
+	}
+	answers := question.CorrectAnswers()
+	switch questionType {
+	case domain.QuestionTypeChoice:
+		if len(answers) == 0 {
+			return nil, nil, pkg.ErrQuestionAnswerNotFound
+		}
+		if len(question.Options) < 2 {
+			return nil, nil, pkg.ErrNotEnoughOptions
+		}
+		for _, answer := range answers {
+			if !slices.Contains(question.Options, answer) {
+				return nil, nil, pkg.ErrAnswerNotInOptions
+			}
+		}
+		return question.Options, answers, nil
+	case domain.QuestionTypeTrueFalse:
+		options := []string{domain.TrueOption, domain.FalseOption}
+		if len(answers) != 1 {
+			return nil, nil, pkg.ErrInvalidTrueFalseAnswer
+		}
+		switch strings.ToLower(strings.TrimSpace(answers[0])) {
+		case "true":
+			return options, []string{domain.TrueOption}, nil
+		case "false":
+			return options, []string{domain.FalseOption}, nil
+		}
+		return nil, nil, pkg.ErrInvalidTrueFalseAnswer
+	case domain.QuestionTypeNumeric:
+		if question.NumericAnswer == nil || math.IsNaN(*question.NumericAnswer) || math.IsInf(*question.NumericAnswer, 0) {
+			return nil, nil, pkg.ErrQuestionAnswerNotFound
+		}
+		if question.Tolerance < 0 || math.IsNaN(question.Tolerance) {
+			return nil, nil, pkg.ErrQuestionAnswerNotFound
+		}
+		return nil, nil, nil
+	case domain.QuestionTypeShortText:
+		accepted := make([]string, 0, len(answers))
+		for _, answer := range answers {
+			if answer = strings.TrimSpace(answer); answer != "" {
+				accepted = append(accepted, answer)
+			}
+		}
+		if len(accepted) == 0 {
+			return nil, nil, pkg.ErrQuestionAnswerNotFound
+		}
+		return nil, accepted, nil
+	case domain.QuestionTypeOrdering:
+		if len(question.Options) < 2 {
+			return nil, nil, pkg.ErrNotEnoughOptions
+		}
+		// Two options that read the same could be put in either order
+		if hasDuplicates(question.Options) {
+			return nil, nil, pkg.ErrDuplicateOptions
+		}
+		return question.Options, nil, nil
+	case domain.QuestionTypeMatching:
+		if len(question.Pairs) < 2 {
+			return nil, nil, pkg.ErrInvalidMatchingPairs
+		}
+		pairOptions := make([]string, len(question.Pairs))
+		pairMatches := make([]string, len(question.Pairs))
+		for i, pair := range question.Pairs {
+			pairOptions[i] = strings.TrimSpace(pair.Option)
+			pairMatches[i] = strings.TrimSpace(pair.Match)
+			if pairOptions[i] == "" || pairMatches[i] == "" {
+				return nil, nil, pkg.ErrInvalidMatchingPairs
+			}
+		}
+		if hasDuplicates(pairOptions) || hasDuplicates(pairMatches) {
+			return nil, nil, pkg.ErrInvalidMatchingPairs
+		}
+		return nil, nil, nil
+	default:
+		return nil, nil, pkg.ErrInvalidQuestionType
+	}
+}
+
+// hasDuplicates reports whether any two of the texts are the same, ignoring case and spacing.
+func hasDuplicates(texts []string) bool {
+	seen := make(map[string]bool, len(texts))
+	for _, text := range texts {
+		text = normalizeText(text)
+		if seen[text] {
+			return true
+		}
+		seen[text] = true
+	}
+	return false
+}
+
 // CreateQuestionOption creates a question option.
 // It returns the id of the created question option and an error if any.
 func (qs *questionService) CreateQuestionOption(ctx context.Context, questionOption repository.QuestionOptions) (int64, error) {
@@ -147,15 +376,49 @@ func (qs *questionService) GetQuestionById(ctx context.Context, id int64) (*doma
 		return nil, err
 	}
 	options := make([]string, len(questionOptions))
+	optionHTML := make([]string, len(questionOptions))
+	optionIds := make([]int64, len(questionOptions))
 	for i, option := range questionOptions {
 		options[i] = option.Option
+		optionHTML[i] = richtext.HTML(option.Option)
+		optionIds[i] = option.Id
+	}
+	tags, err := qs.questionRepository.GetQuestionTags(ctx, []int64{id})
+	if err != nil {
+		qs.logger.Println("Failed to get question tags: ", err)
+		return nil, err
+	}
+	source, err := qs.questionSource(ctx, result)
+	if err != nil {
+		qs.logger.Println("Failed to get question source: ", err)
+		return nil, err
+	}
+	media, err := signedQuestionMedia(ctx, qs.mediaRepository, qs.storage, []int64{id})
+	if err != nil {
+		qs.logger.Println("Failed to get question media: ", err)
+		return nil, err
 	}
 	domainQuestion := domain.Question{
 		ID:          result.Id,
+		Type:        result.Type,
 		Text:        result.Question,
+		TextHTML:    richtext.HTML(result.Question),
 		Option:      options,
+		OptionHTML:  optionHTML,
+		OptionIds:   optionIds,
 		Answer:      "",
 		Explanation: "",
+		Difficulty:  result.Difficulty,
+		TopicId:     result.TopicId,
+		Tags:        tags[id],
+		Source:      source,
+		Media:       media[id],
+	}
+	if domainQuestion.Tags == nil {
+		domainQuestion.Tags = []string{}
+	}
+	if domainQuestion.Media == nil {
+		domainQuestion.Media = []domain.Media{}
 	}
 	qs.logger.Println("Successfully got question options. Proceeding to return result.")
 	return &domainQuestion, nil
@@ -195,13 +458,22 @@ func (qs *questionService) CreateMultipleQuestionBySubjectID(ctx context.Context
 	return nil
 }
 
-// DeleteQuestionById deletes a question by id.
+// DeleteQuestionById deletes a question by id, along with its media files.
 func (qs *questionService) DeleteQuestionById(ctx context.Context, id int64) error {
 	if id > 1 {
 		qs.logger.Println("Question id is greater than 1. Proceeding to delete question.")
 		return pkg.ErrQuestionNotFound
 	}
-	return qs.questionRepository.DeleteQuestionById(ctx, id)
+	media, err := qs.mediaRepository.GetQuestionMedia(ctx, []int64{id})
+	if err != nil {
+		qs.logger.Println("Failed to get question media: ", err)
+		return err
+	}
+	if err := qs.questionRepository.DeleteQuestionById(ctx, id); err != nil {
+		return err
+	}
+	qs.removeMediaFiles(ctx, media[id])
+	return nil
 }
 
 // CreateSubject creates a subject.
@@ -277,3 +549,36 @@ func (qs *questionService) GetSubjects(ctx context.Context) ([]repository.Subjec
 	qs.logger.Println("Successfully got subjects. Proceeding to return result.")
 	return result, nil
 }
+
+// GetSubjectScoringPolicy returns the scoring policy applied to new quizzes for a subject.
+func (qs *questionService) GetSubjectScoringPolicy(ctx context.Context, subjectId int64) (*domain.ScoringPolicy, error) {
+	if _, err := qs.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+		qs.logger.Println("Failed to get subject by id: ", err)
+		return nil, pkg.ErrSubjectNotFound
+	}
+	policy, err := qs.subjectRepository.GetSubjectScoringPolicy(ctx, subjectId)
+	if err != nil {
+		qs.logger.Println("Failed to get scoring policy: ", err)
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SetSubjectScoringPolicy sets the scoring policy for a subject.
+// Quizzes that were already issued keep the policy they were issued with.
+func (qs *questionService) SetSubjectScoringPolicy(ctx context.Context, subjectId int64, policy domain.ScoringPolicy) error {
+	if _, err := NewScorer(policy); err != nil {
+		qs.logger.Println("Invalid scoring policy: ", err)
+		return pkg.ErrInvalidScoringPolicy
+	}
+	if _, err := qs.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+		qs.logger.Println("Failed to get subject by id: ", err)
+		return pkg.ErrSubjectNotFound
+	}
+	if err := qs.subjectRepository.SetSubjectScoringPolicy(ctx, subjectId, policy); err != nil {
+		qs.logger.Println("Failed to set scoring policy: ", err)
+		return err
+	}
+	qs.logger.Println("Successfully set scoring policy for subject: ", subjectId)
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/lawson/otterprep/domain"
 	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,7 +23,7 @@ func TestUserServiceCreateMultipleQuestionBySubjectID(t *testing.T) {
 	questionRepository := repository.NewQuestionRepository(pool)
 	subjectRepository := repository.NewSubjectRepository(pool)
 	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
-	questionService := NewQuestionService(questionRepository, subjectRepository, logger)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
 
 	questions := []domain.QuestionsData{
 		{
@@ -62,7 +63,7 @@ func TestCreateSingleQuestion(t *testing.T) {
 	questionRepository := repository.NewQuestionRepository(pool)
 	subjectRepository := repository.NewSubjectRepository(pool)
 	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
-	questionService := NewQuestionService(questionRepository, subjectRepository, logger)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
 
 	subjectId, err := subjectRepository.CreateSubject(ctx, repository.Subject{
 		Name: "General Knowledge",
@@ -87,6 +88,49 @@ func TestCreateSingleQuestion(t *testing.T) {
 	fmt.Printf("question data: %+v\n", question)
 }
 
+func TestCreateMultiAnswerQuestion(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	questionRepository := repository.NewQuestionRepository(pool)
+	subjectRepository := repository.NewSubjectRepository(pool)
+	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
+
+	subjectId, err := subjectRepository.CreateSubject(ctx, repository.Subject{
+		Name: "General Knowledge",
+	})
+	assert.Nil(t, err)
+
+	id, err := questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Name:          "Which of these are prime numbers?",
+		Options:       []string{"2", "3", "4", "9"},
+		Answers:       []string{"2", "3"},
+		PartialCredit: true,
+		Explanation:   "2 and 3 only have themselves and 1 as factors.",
+	})
+	assert.Nil(t, err)
+
+	question, err := questionRepository.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.True(t, question.IsMultipleChoice)
+	assert.True(t, question.PartialCredit)
+
+	correctOptions, err := questionRepository.GetCorrectQuestionOptionsByQuestionID(ctx, id)
+	assert.Nil(t, err)
+	assert.Len(t, correctOptions, 2)
+
+	_, err = questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Name:        "Which of these are even numbers?",
+		Options:     []string{"2", "3", "4", "9"},
+		Answers:     []string{"2", "6"},
+		Explanation: "6 is not an option.",
+	})
+	assert.ErrorIs(t, err, pkg.ErrAnswerNotInOptions)
+}
+
 func TestDeleteQuestionById(t *testing.T) {
 	pool := setUpDB(t)
 	defer pool.Close()
@@ -96,7 +140,7 @@ func TestDeleteQuestionById(t *testing.T) {
 	questionRepository := repository.NewQuestionRepository(pool)
 	subjectRepository := repository.NewSubjectRepository(pool)
 	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
-	questionService := NewQuestionService(questionRepository, subjectRepository, logger)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
 
 	subjectId, err := subjectRepository.CreateSubject(ctx, repository.Subject{
 		Name: "General Knowledge",
@@ -133,7 +177,7 @@ func TestGetSubjectByName(t *testing.T) {
 	subjectRepository := repository.NewSubjectRepository(pool)
 	questionRepository := repository.NewQuestionRepository(pool)
 	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
-	questionService := NewQuestionService(questionRepository, subjectRepository, logger)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
 
 	subjectId, err := subjectRepository.CreateSubject(ctx, repository.Subject{
 		Name: "General Knowledge",
@@ -156,7 +200,7 @@ func TestCreateSubjectWithSameName(t *testing.T) {
 	subjectRepository := repository.NewSubjectRepository(pool)
 	questionRepository := repository.NewQuestionRepository(pool)
 	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
-	questionService := NewQuestionService(questionRepository, subjectRepository, logger)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
 
 	firstSubject, err := questionService.CreateSubject(ctx, "General Knowledge")
 	assert.Nil(t, err)
@@ -181,7 +225,7 @@ func TestGetSubjects(t *testing.T) {
 	subjectRepository := repository.NewSubjectRepository(pool)
 	questionRepository := repository.NewQuestionRepository(pool)
 	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
-	questionService := NewQuestionService(questionRepository, subjectRepository, logger)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
 
 	subjectNames := []string{
 		"General Knowledge",
@@ -208,7 +252,7 @@ func TestGetQuestionById(t *testing.T) {
 	questionRepository := repository.NewQuestionRepository(pool)
 	subjectRepository := repository.NewSubjectRepository(pool)
 	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
-	questionService := NewQuestionService(questionRepository, subjectRepository, logger)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
 
 	subjectId, err := subjectRepository.CreateSubject(ctx, repository.Subject{
 		Name: "General Knowledge",
@@ -235,3 +279,170 @@ func TestGetQuestionById(t *testing.T) {
 	assert.Equal(t, question.Explanation, "")
 	fmt.Printf("question data: %+v\n", question)
 }
+
+func TestCreateTypedQuestions(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	questionRepository := repository.NewQuestionRepository(pool)
+	subjectRepository := repository.NewSubjectRepository(pool)
+	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
+
+	subjectId, err := subjectRepository.CreateSubject(ctx, repository.Subject{Name: "Physics"})
+	assert.Nil(t, err)
+
+	id, err := questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Type:        domain.QuestionTypeTrueFalse,
+		Name:        "Sound travels faster in water than in air.",
+		Answer:      "TRUE",
+		Explanation: "Water is denser than air.",
+	})
+	assert.Nil(t, err)
+	options, err := questionRepository.GetQuestionOptions(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, []repository.QuestionOptions{
+		{Id: options[0].Id, QuestionId: id, Option: domain.TrueOption, IsCorrect: true},
+		{Id: options[1].Id, QuestionId: id, Option: domain.FalseOption},
+	}, options)
+
+	gravity := 9.81
+	id, err = questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Type:          domain.QuestionTypeNumeric,
+		Name:          "What is the acceleration due to gravity?",
+		NumericAnswer: &gravity,
+		Tolerance:     0.05,
+		Unit:          " m/s^2 ",
+		Explanation:   "It is about 9.81 m/s^2 at the surface of the earth.",
+	})
+	assert.Nil(t, err)
+	question, err := questionRepository.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.QuestionTypeNumeric, question.Type)
+	assert.Equal(t, 9.81, question.NumericAnswer)
+	assert.Equal(t, 0.05, question.Tolerance)
+	assert.Equal(t, "m/s^2", question.Unit)
+	options, err = questionRepository.GetQuestionOptions(ctx, id)
+	assert.Nil(t, err)
+	assert.Empty(t, options)
+
+	id, err = questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Type:        domain.QuestionTypeShortText,
+		Name:        "What is the unit of force?",
+		Answer:      "Newton",
+		Answers:     []string{"newtons", " N "},
+		Explanation: "Force is measured in newtons.",
+	})
+	assert.Nil(t, err)
+	question, err = questionRepository.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.QuestionTypeShortText, question.Type)
+	assert.Equal(t, []string{"Newton", "newtons", "N"}, question.AcceptedAnswers)
+
+	tests := []struct {
+		name     string
+		question domain.QuestionsData
+		err      error
+	}{
+		{name: "unknown type", question: domain.QuestionsData{Type: "essay", Answer: "x"}, err: pkg.ErrInvalidQuestionType},
+		{name: "choice with one option", question: domain.QuestionsData{Options: []string{"a"}, Answer: "a"}, err: pkg.ErrNotEnoughOptions},
+		{name: "true/false with options", question: domain.QuestionsData{Type: domain.QuestionTypeTrueFalse, Options: []string{"yes", "no"}, Answer: "yes"}, err: pkg.ErrOptionsNotAllowed},
+		{name: "true/false answered yes", question: domain.QuestionsData{Type: domain.QuestionTypeTrueFalse, Answer: "yes"}, err: pkg.ErrInvalidTrueFalseAnswer},
+		{name: "true/false with two answers", question: domain.QuestionsData{Type: domain.QuestionTypeTrueFalse, Answers: []string{"true", "false"}}, err: pkg.ErrInvalidTrueFalseAnswer},
+		{name: "numeric without an answer", question: domain.QuestionsData{Type: domain.QuestionTypeNumeric, Answer: "9.81"}, err: pkg.ErrQuestionAnswerNotFound},
+		{name: "numeric with options", question: domain.QuestionsData{Type: domain.QuestionTypeNumeric, NumericAnswer: &gravity, Options: []string{"9.81", "10"}}, err: pkg.ErrOptionsNotAllowed},
+		{name: "short text without answers", question: domain.QuestionsData{Type: domain.QuestionTypeShortText, Answers: []string{" "}}, err: pkg.ErrQuestionAnswerNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.question.Name = tt.name
+			tt.question.Explanation = "invalid"
+			_, err := questionService.CreateQuestion(ctx, subjectId, tt.question)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestCreateOrderingAndMatchingQuestions(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	questionRepository := repository.NewQuestionRepository(pool)
+	subjectRepository := repository.NewSubjectRepository(pool)
+	logger := log.New(os.Stdout, "questionService: ", log.LstdFlags)
+	questionService := NewQuestionService(questionRepository, subjectRepository, repository.NewMediaRepository(pool), testStorage(t), logger)
+
+	subjectId, err := subjectRepository.CreateSubject(ctx, repository.Subject{Name: "Biology"})
+	assert.Nil(t, err)
+
+	steps := []string{"Ingestion", "Digestion", "Absorption", "Assimilation", "Egestion"}
+	id, err := questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Type:          domain.QuestionTypeOrdering,
+		Name:          "Put the stages of nutrition in order.",
+		Options:       steps,
+		PartialCredit: true,
+		Explanation:   "Food is taken in, broken down, absorbed, used and the rest removed.",
+	})
+	assert.Nil(t, err)
+	question, err := questionRepository.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.True(t, question.PartialCredit)
+	options, err := questionRepository.GetQuestionOptions(ctx, id)
+	assert.Nil(t, err)
+	assert.Len(t, options, len(steps))
+	for _, option := range options {
+		assert.Equal(t, steps[option.Position], option.Option)
+	}
+
+	pairs := []domain.QuestionPair{
+		{Option: "Heart", Match: "Pumps blood"},
+		{Option: "Lungs", Match: "Exchange gases"},
+		{Option: "Kidneys", Match: "Filter blood"},
+	}
+	id, err = questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Type:        domain.QuestionTypeMatching,
+		Name:        "Match each organ to what it does.",
+		Pairs:       pairs,
+		Explanation: "Each organ has one main job.",
+	})
+	assert.Nil(t, err)
+	options, err = questionRepository.GetQuestionOptions(ctx, id)
+	assert.Nil(t, err)
+	assert.Len(t, options, 2*len(pairs))
+	texts := make(map[int64]string, len(options))
+	for _, option := range options {
+		texts[option.Id] = option.Option
+	}
+	matched := make(map[string]string, len(pairs))
+	for _, option := range options {
+		if option.MatchOptionId != 0 {
+			matched[option.Option] = texts[option.MatchOptionId]
+		}
+	}
+	assert.Equal(t, map[string]string{"Heart": "Pumps blood", "Lungs": "Exchange gases", "Kidneys": "Filter blood"}, matched)
+
+	tests := []struct {
+		name     string
+		question domain.QuestionsData
+		err      error
+	}{
+		{name: "ordering with one option", question: domain.QuestionsData{Type: domain.QuestionTypeOrdering, Options: []string{"first"}}, err: pkg.ErrNotEnoughOptions},
+		{name: "ordering with options that read the same", question: domain.QuestionsData{Type: domain.QuestionTypeOrdering, Options: []string{"Boil", "stir", "boil "}}, err: pkg.ErrDuplicateOptions},
+		{name: "matching with options", question: domain.QuestionsData{Type: domain.QuestionTypeMatching, Options: []string{"a", "b"}, Pairs: pairs}, err: pkg.ErrOptionsNotAllowed},
+		{name: "matching with one pair", question: domain.QuestionsData{Type: domain.QuestionTypeMatching, Pairs: pairs[:1]}, err: pkg.ErrInvalidMatchingPairs},
+		{name: "matching with a blank match", question: domain.QuestionsData{Type: domain.QuestionTypeMatching, Pairs: []domain.QuestionPair{{Option: "a", Match: "1"}, {Option: "b", Match: " "}}}, err: pkg.ErrInvalidMatchingPairs},
+		{name: "matching with a match used twice", question: domain.QuestionsData{Type: domain.QuestionTypeMatching, Pairs: []domain.QuestionPair{{Option: "a", Match: "1"}, {Option: "b", Match: "1"}}}, err: pkg.ErrInvalidMatchingPairs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.question.Name = tt.name
+			tt.question.Explanation = "invalid"
+			_, err := questionService.CreateQuestion(ctx, subjectId, tt.question)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// A challenge code is its 40 bit seed written in Crockford's base32, which leaves out
+// letters that are easily mistaken for digits.
+const (
+	challengeCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	ChallengeCodeLength   = 8
+	challengeSeedBits     = 5 * ChallengeCodeLength
+	// maxChallengeCodeAttempts is how many fresh seeds are tried when a code is already taken
+	maxChallengeCodeAttempts = 5
+)
+
+// challengeCodeReplacer reads back the characters Crockford's base32 treats as typos of digits
+var challengeCodeReplacer = strings.NewReplacer("O", "0", "I", "1", "L", "1", "-", "")
+
+// CreateChallenge draws a quiz from a random seed and stores it as a challenge with a short
+// code derived from the seed. The questions are picked and ordered by the seed alone, and
+// each subject's scoring policy is snapshotted, so everyone who takes the challenge sits
+// and is scored on the same quiz. Only practice and exam quizzes can be challenges.
+func (qs *quizService) CreateChallenge(ctx context.Context, userID int64, quizRequest domain.QuizRequest) (*domain.QuizChallengeResponse, error) {
+	breakdown, err := quizSubjectBreakdown(quizRequest)
+	if err != nil {
+		return nil, err
+	}
+	mode := quizRequest.Mode
+	if mode == "" {
+		mode = domain.ModePractice
+	}
+	if mode != domain.ModePractice && mode != domain.ModeExam {
+		return nil, pkg.ErrChallengeModeNotSupported
+	}
+	if mode == domain.ModeExam && quizRequest.DurationSeconds <= 0 {
+		return nil, pkg.ErrExamDurationRequired
+	}
+
+	seed, code, err := qs.newChallengeCode(ctx)
+	if err != nil {
+		fmt.Println("error creating challenge code: ", err)
+		return nil, err
+	}
+
+	challenge := repository.QuizChallenge{
+		Code:      code,
+		Seed:      seed,
+		CreatedBy: userID,
+		Mode:      mode,
+		Subjects:  make([]repository.QuizSessionSubject, 0, len(breakdown)),
+		CreatedAt: time.Now(),
+	}
+	if mode == domain.ModeExam {
+		challenge.DurationSeconds = quizRequest.DurationSeconds
+	}
+	if len(breakdown) == 1 {
+		challenge.SubjectId = breakdown[0].SubjectId
+	}
+	for _, subject := range breakdown {
+		scoringPolicy, err := qs.subjectRepository.GetSubjectScoringPolicy(ctx, subject.SubjectId)
+		if err != nil {
+			fmt.Println("error getting scoring policy: ", err)
+			return nil, err
+		}
+		challenge.Subjects = append(challenge.Subjects, repository.QuizSessionSubject{
+			SubjectId:      subject.SubjectId,
+			NumOfQuestions: subject.NumOfQuestions,
+			ScoringPolicy:  *scoringPolicy,
+		})
+
+		filter, err := qs.questionFilter(ctx, subject)
+		if err != nil {
+			return nil, err
+		}
+		candidates, err := qs.questionRepository.GetSubjectQuestionIds(ctx, subject.SubjectId, filter)
+		if err != nil {
+			fmt.Println("error getting subject questions: ", err)
+			return nil, err
+		}
+		if int64(len(candidates)) < subject.NumOfQuestions {
+			return nil, fmt.Errorf("%w: subject %d has %d questions, %d were requested", pkg.ErrNotEnoughQuestions, subject.SubjectId, len(candidates), subject.NumOfQuestions)
+		}
+		challenge.QuestionIds = append(challenge.QuestionIds, seededQuestionIds(candidates, seed, subject.SubjectId, subject.NumOfQuestions)...)
+	}
+
+	if _, err := qs.challengeRepository.CreateQuizChallenge(ctx, challenge); err != nil {
+		fmt.Println("error storing challenge: ", err)
+		return nil, err
+	}
+	return &domain.QuizChallengeResponse{
+		Code:            challenge.Code,
+		SubjectId:       challenge.SubjectId,
+		Subjects:        breakdown,
+		Mode:            challenge.Mode,
+		DurationSeconds: challenge.DurationSeconds,
+		TotalCount:      len(challenge.QuestionIds),
+		CreatedBy:       userID,
+		CreatedAt:       challenge.CreatedAt,
+	}, nil
+}
+
+// TakeChallenge issues the user a quiz session with the challenge's questions, in the
+// challenge's order. Each user can take a challenge once. Questions deleted since the
+// challenge was created are left out.
+func (qs *quizService) TakeChallenge(ctx context.Context, userID int64, code string) (*domain.GeneratedQuizResponse, error) {
+	challenge, err := qs.challengeRepository.GetQuizChallengeByCode(ctx, normalizeChallengeCode(code))
+	if err != nil {
+		if !errors.Is(err, pkg.ErrChallengeNotFound) {
+			fmt.Println("error getting challenge: ", err)
+		}
+		return nil, err
+	}
+	taken, err := qs.challengeRepository.HasUserTakenChallenge(ctx, userID, challenge.Id)
+	if err != nil {
+		fmt.Println("error checking challenge sessions: ", err)
+		return nil, err
+	}
+	if taken {
+		return nil, pkg.ErrChallengeAlreadyTaken
+	}
+
+	questions, err := qs.questionRepository.GetQuestionsByIds(ctx, challenge.QuestionIds)
+	if err != nil {
+		fmt.Println("error getting challenge questions: ", err)
+		return nil, err
+	}
+	byId := make(map[int64]repository.Questions, len(questions))
+	for _, question := range questions {
+		byId[question.Id] = question
+	}
+	picked := make([]pickedQuestion, 0, len(challenge.QuestionIds))
+	for _, questionId := range challenge.QuestionIds {
+		if question, ok := byId[questionId]; ok {
+			picked = append(picked, pickedQuestion{Questions: question})
+		}
+	}
+
+	breakdown := make([]domain.QuizSubjectRequest, len(challenge.Subjects))
+	for i, subject := range challenge.Subjects {
+		breakdown[i] = domain.QuizSubjectRequest{SubjectId: subject.SubjectId, NumOfQuestions: subject.NumOfQuestions}
+	}
+	return qs.issueQuiz(ctx, userID, quizPlan{
+		mode:            challenge.Mode,
+		durationSeconds: challenge.DurationSeconds,
+		breakdown:       breakdown,
+		subjects:        challenge.Subjects,
+		questions:       picked,
+		challengeId:     challenge.Id,
+		challengeCode:   challenge.Code,
+	}, time.Now())
+}
+
+// GetChallengeResults ranks everyone who has submitted the challenge by points, then by time taken.
+func (qs *quizService) GetChallengeResults(ctx context.Context, code string) (*domain.ChallengeResultsResponse, error) {
+	challenge, err := qs.challengeRepository.GetQuizChallengeByCode(ctx, normalizeChallengeCode(code))
+	if err != nil {
+		if !errors.Is(err, pkg.ErrChallengeNotFound) {
+			fmt.Println("error getting challenge: ", err)
+		}
+		return nil, err
+	}
+	results, err := qs.challengeRepository.GetChallengeResults(ctx, challenge.Id)
+	if err != nil {
+		fmt.Println("error getting challenge results: ", err)
+		return nil, err
+	}
+	if results == nil {
+		results = []domain.ChallengeResult{}
+	}
+	return &domain.ChallengeResultsResponse{Code: challenge.Code, Takers: int64(len(results)), Results: results}, nil
+}
+
+// newChallengeCode draws a random seed whose code is not in use yet.
+func (qs *quizService) newChallengeCode(ctx context.Context) (int64, string, error) {
+	for attempt := 0; attempt < maxChallengeCodeAttempts; attempt++ {
+		var buf [8]byte
+		if _, err := crand.Read(buf[:]); err != nil {
+			return 0, "", err
+		}
+		seed := int64(binary.BigEndian.Uint64(buf[:]) >> (64 - challengeSeedBits))
+		code := challengeCode(seed)
+		_, err := qs.challengeRepository.GetQuizChallengeByCode(ctx, code)
+		if errors.Is(err, pkg.ErrChallengeNotFound) {
+			return seed, code, nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+	}
+	return 0, "", errors.New("no unused challenge code found")
+}
+
+// challengeCode writes a seed as a ChallengeCodeLength character code.
+func challengeCode(seed int64) string {
+	code := make([]byte, ChallengeCodeLength)
+	for i := ChallengeCodeLength - 1; i >= 0; i-- {
+		code[i] = challengeCodeAlphabet[seed&31]
+		seed >>= 5
+	}
+	return string(code)
+}
+
+// normalizeChallengeCode accepts a code typed in lower case, with dashes, or with letters
+// mistaken for the digits they resemble.
+func normalizeChallengeCode(code string) string {
+	return challengeCodeReplacer.Replace(strings.ToUpper(strings.TrimSpace(code)))
+}
+
+// seededQuestionIds picks n of a subject's question ids in an order fixed by the seed, so
+// the same seed always picks the same questions from the same candidates.
+func seededQuestionIds(candidates []int64, seed int64, subjectId int64, n int64) []int64 {
+	shuffled := slices.Clone(candidates)
+	rng := rand.New(rand.NewPCG(uint64(seed), uint64(subjectId)))
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled[:n]
+}
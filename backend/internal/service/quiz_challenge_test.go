@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChallengeCode(t *testing.T) {
+	assert.Equal(t, "00000000", challengeCode(0))
+	assert.Equal(t, "0000001Z", challengeCode(63))
+	assert.Equal(t, "ZZZZZZZZ", challengeCode(1<<challengeSeedBits-1))
+
+	assert.Equal(t, "0000001Z", normalizeChallengeCode(" 0000-001z "))
+	assert.Equal(t, "10000000", normalizeChallengeCode("Ioooooo0"))
+}
+
+func TestSeededQuestionIds(t *testing.T) {
+	candidates := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	picked := seededQuestionIds(candidates, 42, 1, 5)
+	assert.Len(t, picked, 5)
+	assert.Equal(t, picked, seededQuestionIds(candidates, 42, 1, 5))
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, candidates, "candidates must not be reordered")
+
+	seen := make(map[int64]bool)
+	for _, id := range picked {
+		assert.False(t, seen[id])
+		seen[id] = true
+	}
+
+	// another seed draws the questions in another order
+	assert.NotEqual(t, seededQuestionIds(candidates, 42, 1, 10), seededQuestionIds(candidates, 43, 1, 10))
+}
+
+func TestQuizChallenge(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	for _, name := range []string{"ada", "grace"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.Nil(t, err)
+	}
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	_, err = qs.CreateChallenge(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 3, Mode: domain.ModeAdaptive})
+	assert.ErrorIs(t, err, pkg.ErrChallengeModeNotSupported)
+	_, err = qs.CreateChallenge(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 5})
+	assert.ErrorIs(t, err, pkg.ErrNotEnoughQuestions)
+
+	challenge, err := qs.CreateChallenge(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 3})
+	assert.Nil(t, err)
+	assert.Len(t, challenge.Code, ChallengeCodeLength)
+	assert.Equal(t, 3, challenge.TotalCount)
+	assert.Equal(t, domain.ModePractice, challenge.Mode)
+
+	first, err := qs.TakeChallenge(ctx, 1, challenge.Code)
+	assert.Nil(t, err)
+	assert.Equal(t, challenge.Code, first.ChallengeCode)
+	second, err := qs.TakeChallenge(ctx, 2, strings.ToLower(challenge.Code))
+	assert.Nil(t, err)
+	assert.Len(t, second.Questions, 3)
+	for i := range first.Questions {
+		assert.Equal(t, first.Questions[i].QuestionId, second.Questions[i].QuestionId)
+		// options are shuffled for every taker
+		assert.ElementsMatch(t, first.Questions[i].Options, second.Questions[i].Options)
+	}
+
+	_, err = qs.TakeChallenge(ctx, 1, challenge.Code)
+	assert.ErrorIs(t, err, pkg.ErrChallengeAlreadyTaken)
+	_, err = qs.TakeChallenge(ctx, 1, "ZZZZZZZZ")
+	assert.ErrorIs(t, err, pkg.ErrChallengeNotFound)
+
+	results, err := qs.GetChallengeResults(ctx, challenge.Code)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), results.Takers)
+	assert.Empty(t, results.Results)
+
+	_, err = qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, first, 1))
+	assert.Nil(t, err)
+	_, err = qs.SubmitQuiz(ctx, 2, answerQuiz(t, ctx, questionRepo, second, 3))
+	assert.Nil(t, err)
+
+	results, err = qs.GetChallengeResults(ctx, challenge.Code)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), results.Takers)
+	assert.Equal(t, int64(1), results.Results[0].Rank)
+	assert.Equal(t, int64(2), results.Results[0].UserID)
+	assert.Equal(t, "grace", results.Results[0].UserName)
+	assert.Equal(t, int64(3), results.Results[0].CorrectAnswers)
+	assert.Equal(t, int64(2), results.Results[1].Rank)
+	assert.Equal(t, int64(1), results.Results[1].Score)
+}
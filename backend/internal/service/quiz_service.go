@@ -2,180 +2,1161 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/lawson/otterprep/domain"
 	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/internal/richtext"
+	"github.com/lawson/otterprep/internal/storage"
 	"github.com/lawson/otterprep/pkg"
 )
 
+// ExamSubmissionGracePeriod is how long after an exam's deadline a submission is still
+// accepted, to absorb network latency between the client timer running out and the request landing.
+const ExamSubmissionGracePeriod = 30 * time.Second
+
+// Adaptive quizzes pick a difficulty from the user's accuracy over their last
+// AdaptiveHistoryQuizzes quizzes in a subject. Until they have answered
+// AdaptiveMinQuestions questions there, medium questions are served.
+const (
+	AdaptiveHistoryQuizzes = 10
+	AdaptiveMinQuestions   = 10
+)
+
+// MaxQuizQuestions caps the total number of questions in one quiz, across all of its subjects.
+const MaxQuizQuestions = 200
+
 type quizService struct {
-	quizRepository     repository.QuizRepository
-	subjectRepository  repository.SubjectRepository
-	questionRepository repository.QuestionRepository
-	scoreRepository    repository.ScoreRepository
+	quizRepository        repository.QuizRepository
+	subjectRepository     repository.SubjectRepository
+	questionRepository    repository.QuestionRepository
+	scoreRepository       repository.ScoreRepository
+	quizSessionRepository repository.QuizSessionRepository
+	reviewQueueRepository repository.ReviewQueueRepository
+	challengeRepository   repository.QuizChallengeRepository
+	mediaRepository       repository.MediaRepository
+	storage               storage.Storage
+	submitHooks           []SubmitHook
 }
 
+// SubmitHook is told about every quiz submission once it has been scored and stored. A
+// hook failing is logged and does not fail the submission.
+type SubmitHook func(ctx context.Context, session *repository.QuizSession, result *domain.QuizSubmitResponse) error
+
 type QuizService interface {
-	GenerateQuizBySubjectID(ctx context.Context, subjectId int64, numOfQuestions int64) (*domain.GeneratedQuizResponse, error)
-	SubmitQuiz(ctx context.Context, userID int64, quizRequest []domain.SubmitQuizRequest) (*domain.QuizSubmitResponse, error)
+	GenerateQuizBySubjectID(ctx context.Context, userID int64, quizRequest domain.QuizRequest) (*domain.GeneratedQuizResponse, error)
+	GenerateReviewQuiz(ctx context.Context, userID int64, quizRequest domain.QuizRequest) (*domain.GeneratedQuizResponse, error)
+	GetReviewQueue(ctx context.Context, userID int64) (*domain.ReviewQueueResponse, error)
+	CreateChallenge(ctx context.Context, userID int64, quizRequest domain.QuizRequest) (*domain.QuizChallengeResponse, error)
+	TakeChallenge(ctx context.Context, userID int64, code string) (*domain.GeneratedQuizResponse, error)
+	GetChallengeResults(ctx context.Context, code string) (*domain.ChallengeResultsResponse, error)
+	GenerateMockExam(ctx context.Context, userID int64, request domain.MockExamRequest) (*domain.GeneratedQuizResponse, error)
+	SubmitQuiz(ctx context.Context, userID int64, submission domain.QuizSubmission) (*domain.QuizSubmitResponse, error)
 	CalculateQuizScore(ctx context.Context, numOfQuestions int64, score int64) int64
+	GetQuizHistory(ctx context.Context, userID int64, query domain.QuizHistoryQuery) (*domain.QuizHistoryResponse, error)
+	GetQuizReview(ctx context.Context, userID int64, sessionID int64) (*domain.QuizSubmitResponse, error)
 }
 
-func NewQuizService(quizRepository repository.QuizRepository, subjectRepository repository.SubjectRepository, questionRepository repository.QuestionRepository, scoreRepository repository.ScoreRepository) *quizService {
-	return &quizService{quizRepository: quizRepository, subjectRepository: subjectRepository, questionRepository: questionRepository, scoreRepository: scoreRepository}
+func NewQuizService(quizRepository repository.QuizRepository, subjectRepository repository.SubjectRepository, questionRepository repository.QuestionRepository, scoreRepository repository.ScoreRepository, quizSessionRepository repository.QuizSessionRepository, reviewQueueRepository repository.ReviewQueueRepository, challengeRepository repository.QuizChallengeRepository, mediaRepository repository.MediaRepository, storage storage.Storage) *quizService {
+	return &quizService{quizRepository: quizRepository, subjectRepository: subjectRepository, questionRepository: questionRepository, scoreRepository: scoreRepository, quizSessionRepository: quizSessionRepository, reviewQueueRepository: reviewQueueRepository, challengeRepository: challengeRepository, mediaRepository: mediaRepository, storage: storage}
+}
+
+// OnSubmit adds a hook run after every quiz submission, in the order they were added.
+func (qs *quizService) OnSubmit(hook SubmitHook) {
+	qs.submitHooks = append(qs.submitHooks, hook)
 }
 
 // GenerateQuizBySubjectID generates a quiz based on the subject ID and number of questions
 // if subject is found then it returns the number of questions based on numOfQuestions.
 // if subject is not found then it returns an error.
-func (qs *quizService) GenerateQuizBySubjectID(ctx context.Context, subjectId int64, numOfQuestions int64) (*domain.GeneratedQuizResponse, error) {
-	var questions []domain.QuizQuestionResponse
-	usedQuestionIds := make(map[int64]bool)
-
-	for i := 0; i < int(numOfQuestions); i++ {
-		// Keep trying to get a unique question
-		var question *repository.Questions
-		var err error
-		maxRetries := 10
-		for retry := 0; retry < maxRetries; retry++ {
-			question, err = qs.questionRepository.GetRandomQuestion(ctx, subjectId)
+// A mixed quiz lists several subjects with a number of questions for each instead.
+// The issued questions and options are stored as a quiz session for the user, which
+// is the only thing SubmitQuiz will grade against.
+// In exam mode the session gets a deadline of DurationSeconds from the moment it is issued.
+// In adaptive mode questions are drawn at a difficulty matching the user's recent accuracy
+// in each subject, topping up with other questions when there are not enough of it.
+// In review mode the user's due review items are served first, see GenerateReviewQuiz.
+func (qs *quizService) GenerateQuizBySubjectID(ctx context.Context, userID int64, quizRequest domain.QuizRequest) (*domain.GeneratedQuizResponse, error) {
+	breakdown, err := quizSubjectBreakdown(quizRequest)
+	if err != nil {
+		return nil, err
+	}
+	mode := quizRequest.Mode
+	if mode == "" {
+		mode = domain.ModePractice
+	}
+	if mode == domain.ModeExam && quizRequest.DurationSeconds <= 0 {
+		return nil, pkg.ErrExamDurationRequired
+	}
+
+	var picked []pickedQuestion
+	sessionSubjects := make([]repository.QuizSessionSubject, 0, len(breakdown))
+	now := time.Now()
+
+	for _, subject := range breakdown {
+		// Snapshot the subject's scoring policy so changing it does not affect quizzes already issued
+		scoringPolicy, err := qs.subjectRepository.GetSubjectScoringPolicy(ctx, subject.SubjectId)
+		if err != nil {
+			fmt.Println("error getting scoring policy: ", err)
+			return nil, err
+		}
+		sessionSubjects = append(sessionSubjects, repository.QuizSessionSubject{
+			SubjectId:      subject.SubjectId,
+			NumOfQuestions: subject.NumOfQuestions,
+			ScoringPolicy:  *scoringPolicy,
+		})
+
+		difficulty := 0
+		if mode == domain.ModeAdaptive {
+			correct, total, err := qs.scoreRepository.GetUserRecentSubjectAccuracy(ctx, userID, subject.SubjectId, AdaptiveHistoryQuizzes)
 			if err != nil {
-				fmt.Println("error getting quiz: ", err)
-				return nil, pkg.ErrSubjectNotFound
-			}
-			if !usedQuestionIds[question.Id] {
-				usedQuestionIds[question.Id] = true
-				break
+				fmt.Println("error getting recent accuracy: ", err)
+				return nil, err
 			}
+			difficulty = adaptiveDifficulty(correct, total)
 		}
 
-		questionOptions, err := qs.questionRepository.GetQuestionOptions(ctx, question.Id)
+		subjectQuestions, err := qs.pickQuestions(ctx, userID, subject, mode, difficulty, now)
 		if err != nil {
-			return nil, pkg.ErrQuestionOptionNotFound
+			return nil, err
 		}
+		picked = append(picked, subjectQuestions...)
+	}
 
-		// Convert options without exposing is_correct
-		options := make([]domain.QuizOptionResponse, len(questionOptions))
-		for j, opt := range questionOptions {
-			options[j] = domain.QuizOptionResponse{
-				Id:     opt.Id,
-				Option: opt.Option,
-			}
-		}
+	return qs.issueQuiz(ctx, userID, quizPlan{
+		mode:             mode,
+		durationSeconds:  quizRequest.DurationSeconds,
+		breakdown:        breakdown,
+		subjects:         sessionSubjects,
+		questions:        picked,
+		shuffleQuestions: quizRequest.ShuffleQuestions,
+	}, now)
+}
+
+// quizPlan is everything needed to issue a quiz session once its questions are drawn
+type quizPlan struct {
+	mode            string
+	durationSeconds int64
+	breakdown       []domain.QuizSubjectRequest
+	subjects        []repository.QuizSessionSubject
+	questions       []pickedQuestion
+	challengeId     int64
+	challengeCode   string
+	paper           *domain.PastPaper
+	contest         *repository.Contest
+	dailyDate       *time.Time
+	// keepOptionOrder issues choice options in the order they were set instead of shuffled
+	keepOptionOrder bool
+	// shuffleQuestions mixes the questions up instead of issuing them subject by subject in the order drawn
+	shuffleQuestions bool
+}
 
-		questions = append(questions, domain.QuizQuestionResponse{
-			QuestionId:       question.Id,
-			Question:         question.Question,
-			SubjectId:        question.SubjectId,
-			IsMultipleChoice: question.IsMultipleChoice,
-			Options:          options,
+// issueQuiz stores the quiz session for a plan, fetching the options and media of every
+// question at once, and returns the quiz as shown to the user. Options are shuffled for
+// every session; the session keeps the order they were shown in. Exams and mock exams get
+// a deadline of their duration from now, and contest entries are due when the contest
+// ends or after its duration, whichever comes first.
+func (qs *quizService) issueQuiz(ctx context.Context, userID int64, plan quizPlan, now time.Time) (*domain.GeneratedQuizResponse, error) {
+	if plan.shuffleQuestions {
+		plan.questions = slices.Clone(plan.questions)
+		rand.Shuffle(len(plan.questions), func(i, j int) {
+			plan.questions[i], plan.questions[j] = plan.questions[j], plan.questions[i]
 		})
 	}
 
-	return &domain.GeneratedQuizResponse{
-		SubjectId:  subjectId,
-		TotalCount: len(questions),
-		Questions:  questions,
-	}, nil
+	questionIds := make([]int64, len(plan.questions))
+	for i, question := range plan.questions {
+		questionIds[i] = question.Id
+	}
+	questionOptions, err := qs.questionRepository.GetQuestionOptionsByQuestionIds(ctx, questionIds)
+	if err != nil {
+		fmt.Println("error getting question options: ", err)
+		return nil, pkg.ErrQuestionOptionNotFound
+	}
+	media, err := signedQuestionMedia(ctx, qs.mediaRepository, qs.storage, questionIds)
+	if err != nil {
+		fmt.Println("error getting question media: ", err)
+		return nil, err
+	}
+
+	questions := make([]domain.QuizQuestionResponse, len(plan.questions))
+	sessionQuestions := make([]repository.QuizSessionQuestion, len(plan.questions))
+	for i, question := range plan.questions {
+		questions[i], sessionQuestions[i] = issueQuestion(question, questionOptions[question.Id], i, !plan.keepOptionOrder)
+		attachQuizMedia(&questions[i], media[question.Id])
+	}
+
+	// A single subject quiz keeps its subject on the session, a mixed one has none
+	subjectId := int64(0)
+	if len(plan.breakdown) == 1 {
+		subjectId = plan.breakdown[0].SubjectId
+	}
+
+	session := repository.QuizSession{
+		UserId:      userID,
+		SubjectId:   subjectId,
+		Status:      repository.QuizSessionActive,
+		Mode:        plan.mode,
+		ChallengeId: plan.challengeId,
+		Subjects:    plan.subjects,
+		Questions:   sessionQuestions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if plan.paper != nil {
+		session.PaperId = plan.paper.Id
+	}
+	if plan.mode == domain.ModeExam || plan.mode == domain.ModeMock {
+		expiresAt := now.Add(time.Duration(plan.durationSeconds) * time.Second)
+		session.DurationSeconds = plan.durationSeconds
+		session.ExpiresAt = &expiresAt
+	}
+	if plan.contest != nil {
+		expiresAt := plan.contest.EndsAt
+		if plan.durationSeconds > 0 && now.Add(time.Duration(plan.durationSeconds)*time.Second).Before(expiresAt) {
+			expiresAt = now.Add(time.Duration(plan.durationSeconds) * time.Second)
+		}
+		session.ContestId = plan.contest.Id
+		session.DurationSeconds = int64(expiresAt.Sub(now).Seconds())
+		session.ExpiresAt = &expiresAt
+	}
+	session.DailyDate = plan.dailyDate
+	sessionId, err := qs.quizSessionRepository.CreateQuizSession(ctx, session)
+	if err != nil {
+		fmt.Println("error storing quiz session: ", err)
+		return nil, err
+	}
+
+	quiz := &domain.GeneratedQuizResponse{
+		SessionId:       sessionId,
+		SubjectId:       subjectId,
+		Subjects:        plan.breakdown,
+		Mode:            plan.mode,
+		ChallengeCode:   plan.challengeCode,
+		DurationSeconds: session.DurationSeconds,
+		ExpiresAt:       session.ExpiresAt,
+		Paper:           plan.paper,
+		ContestId:       session.ContestId,
+		TotalCount:      len(questions),
+		Questions:       questions,
+	}
+	if plan.dailyDate != nil {
+		quiz.DailyDate = plan.dailyDate.Format(time.DateOnly)
+	}
+	return quiz, nil
 }
 
-func (qs *quizService) GetQuizById(ctx context.Context, id int64) (*repository.Quiz, error) {
-	return qs.quizRepository.GetQuizById(ctx, id)
+// GenerateReviewQuiz generates a quiz in review mode. Questions the user missed before and
+// that are due for review in each subject come first, most overdue first, and the rest
+// of the quiz is filled with random questions from the subject.
+func (qs *quizService) GenerateReviewQuiz(ctx context.Context, userID int64, quizRequest domain.QuizRequest) (*domain.GeneratedQuizResponse, error) {
+	quizRequest.Mode = domain.ModeReview
+	quizRequest.DurationSeconds = 0
+	return qs.GenerateQuizBySubjectID(ctx, userID, quizRequest)
 }
 
-// SubmitQuiz takes a list of quiz request and checks to see if the request questions
-// has the correct options selected.
-func (qs *quizService) SubmitQuiz(ctx context.Context, userID int64, quizRequest []domain.SubmitQuizRequest) (*domain.QuizSubmitResponse, error) {
-	score := int64(0)
-	correctAnswers := int64(0)
-	incorrectAnswers := int64(0)
-	if len(quizRequest) == 0 {
-		return nil, nil
+// GetReviewQueue returns how many questions the user has queued for review in each
+// subject, and how many of them are due now.
+func (qs *quizService) GetReviewQueue(ctx context.Context, userID int64) (*domain.ReviewQueueResponse, error) {
+	subjects, err := qs.reviewQueueRepository.GetReviewQueue(ctx, userID, time.Now())
+	if err != nil {
+		fmt.Println("error getting review queue: ", err)
+		return nil, err
+	}
+	queue := &domain.ReviewQueueResponse{Subjects: subjects}
+	for _, subject := range subjects {
+		queue.Queued += subject.Queued
+		queue.Due += subject.Due
+	}
+	return queue, nil
+}
+
+// pickedQuestion is a question drawn for a quiz, and whether it came from the review queue
+type pickedQuestion struct {
+	repository.Questions
+	review bool
+}
+
+// pickQuestions draws the questions of one subject of a quiz, without repeats and only from
+// the subject's topics and tags when it has any. Due review items come first in review
+// mode, then questions at the given difficulty (any when it is 0), topped up with
+// questions of any difficulty. Each step is a single query.
+// It fails with pkg.ErrNotEnoughQuestions when the subject cannot fill its share of the quiz.
+func (qs *quizService) pickQuestions(ctx context.Context, userID int64, subject domain.QuizSubjectRequest, mode string, difficulty int, now time.Time) ([]pickedQuestion, error) {
+	filter, err := qs.questionFilter(ctx, subject)
+	if err != nil {
+		return nil, err
 	}
-	results := make([]domain.QuizResultResponse, 0)
+	picked := make([]pickedQuestion, 0, subject.NumOfQuestions)
+	pickedIds := make([]int64, 0, subject.NumOfQuestions)
 
-	var subjectID int64
+	if mode == domain.ModeReview {
+		dueItems, err := qs.reviewQueueRepository.GetDueReviewItems(ctx, userID, subject.SubjectId, now, subject.NumOfQuestions, filter)
+		if err != nil {
+			fmt.Println("error getting due review items: ", err)
+			return nil, err
+		}
+		dueIds := make([]int64, len(dueItems))
+		for i, item := range dueItems {
+			dueIds[i] = item.QuestionId
+		}
+		dueQuestions, err := qs.questionRepository.GetQuestionsByIds(ctx, dueIds)
+		if err != nil {
+			fmt.Println("error getting review questions: ", err)
+			return nil, err
+		}
+		// Keep the most overdue first
+		byId := make(map[int64]repository.Questions, len(dueQuestions))
+		for _, question := range dueQuestions {
+			byId[question.Id] = question
+		}
+		for _, id := range dueIds {
+			if question, ok := byId[id]; ok {
+				picked = append(picked, pickedQuestion{Questions: question, review: true})
+				pickedIds = append(pickedIds, id)
+			}
+		}
+	}
 
-	for _, quiz := range quizRequest {
-		question, err := qs.questionRepository.GetQuestionById(ctx, quiz.QuestionId)
+	difficulties := []int{0}
+	if difficulty != 0 {
+		difficulties = []int{difficulty, 0}
+	}
+	for _, d := range difficulties {
+		needed := subject.NumOfQuestions - int64(len(picked))
+		if needed == 0 {
+			break
+		}
+		questions, err := qs.questionRepository.GetRandomQuestions(ctx, subject.SubjectId, needed, d, pickedIds, filter)
 		if err != nil {
 			fmt.Println("error getting quiz: ", err)
-			continue
+			return nil, err
+		}
+		for _, question := range questions {
+			picked = append(picked, pickedQuestion{Questions: question})
+			pickedIds = append(pickedIds, question.Id)
+		}
+	}
+
+	if int64(len(picked)) < subject.NumOfQuestions {
+		return nil, fmt.Errorf("%w: subject %d has %d questions, %d were requested", pkg.ErrNotEnoughQuestions, subject.SubjectId, len(picked), subject.NumOfQuestions)
+	}
+	return picked, nil
+}
+
+// issueQuestion prepares a question for a quiz session: the question as shown to the user,
+// with its options shuffled and without revealing which are correct, and the record of it
+// kept on the session. True/false options always come as True then False, and ordering
+// options are never issued already in order. The options of a matching question are
+// issued apart from their matches, each shuffled on their own; the session keeps the
+// options followed by the matches. Without shuffle choice options keep the order they
+// were set in, but ordering and matching questions are shuffled all the same.
+func issueQuestion(question pickedQuestion, questionOptions []repository.QuestionOptions, position int, shuffle bool) (domain.QuizQuestionResponse, repository.QuizSessionQuestion) {
+	var matchOptions []repository.QuestionOptions
+	if question.Type == domain.QuestionTypeMatching {
+		questionOptions, matchOptions = splitMatchingOptions(questionOptions)
+	} else {
+		questionOptions = slices.Clone(questionOptions)
+	}
+	if question.Type == domain.QuestionTypeOrdering || question.Type == domain.QuestionTypeMatching {
+		shuffle = true
+	}
+	if shuffle && question.Type != domain.QuestionTypeTrueFalse {
+		shuffleOptions(questionOptions)
+	}
+	if question.Type == domain.QuestionTypeOrdering && inCorrectOrder(questionOptions) {
+		questionOptions[0], questionOptions[1] = questionOptions[1], questionOptions[0]
+	}
+	shuffleOptions(matchOptions)
+
+	options, optionIds := quizOptions(questionOptions)
+	matches, matchIds := quizOptions(matchOptions)
+	return domain.QuizQuestionResponse{
+		QuestionId:       question.Id,
+		Type:             question.Type,
+		Question:         question.Question,
+		QuestionHTML:     richtext.HTML(question.Question),
+		SubjectId:        question.SubjectId,
+		IsMultipleChoice: question.IsMultipleChoice,
+		Difficulty:       question.Difficulty,
+		Review:           question.review,
+		Unit:             question.Unit,
+		Options:          options,
+		Matches:          matches,
+	}, repository.QuizSessionQuestion{
+		QuestionId: question.Id,
+		SubjectId:  question.SubjectId,
+		Position:   position,
+		OptionIds:  append(optionIds, matchIds...),
+	}
+}
+
+func shuffleOptions(options []repository.QuestionOptions) {
+	rand.Shuffle(len(options), func(i, j int) {
+		options[i], options[j] = options[j], options[i]
+	})
+}
+
+// quizOptions returns options as shown to the user, without revealing anything about them, and their ids.
+func quizOptions(questionOptions []repository.QuestionOptions) ([]domain.QuizOptionResponse, []int64) {
+	if questionOptions == nil {
+		return nil, nil
+	}
+	options := make([]domain.QuizOptionResponse, len(questionOptions))
+	optionIds := make([]int64, len(questionOptions))
+	for i, opt := range questionOptions {
+		options[i] = domain.QuizOptionResponse{
+			Id:         opt.Id,
+			Option:     opt.Option,
+			OptionHTML: richtext.HTML(opt.Option),
 		}
+		optionIds[i] = opt.Id
+	}
+	return options, optionIds
+}
 
-		// Capture subjectID from the first question found
-		if subjectID == 0 {
-			subjectID = question.SubjectId
+// inCorrectOrder reports whether more than one option of an ordering question is listed
+// and every one of them is at its correct position.
+func inCorrectOrder(options []repository.QuestionOptions) bool {
+	if len(options) < 2 {
+		return false
+	}
+	for i, option := range options {
+		if option.Position != i {
+			return false
 		}
+	}
+	return true
+}
 
-		answer, err := qs.questionRepository.GetAnswerById(ctx, quiz.QuestionId)
-		if err != nil {
-			fmt.Println("error getting answer: ", err)
+// splitMatchingOptions separates the options of a matching question that are paired with
+// a match from the matches.
+func splitMatchingOptions(questionOptions []repository.QuestionOptions) ([]repository.QuestionOptions, []repository.QuestionOptions) {
+	options := make([]repository.QuestionOptions, 0, len(questionOptions)/2)
+	matches := make([]repository.QuestionOptions, 0, len(questionOptions)/2)
+	for _, option := range questionOptions {
+		if option.MatchOptionId != 0 {
+			options = append(options, option)
+		} else {
+			matches = append(matches, option)
 		}
-		correctOption, err := qs.questionRepository.GetCorrectQuestionOptionByQuestionID(ctx, quiz.QuestionId)
+	}
+	return options, matches
+}
+
+// adaptiveDifficulty picks the difficulty to serve a user who answered correct out of
+// total recent questions: hard questions for strong students and easy ones for weak students.
+func adaptiveDifficulty(correct, total int64) int {
+	if total < AdaptiveMinQuestions {
+		return domain.DifficultyMedium
+	}
+	accuracy := float64(correct) / float64(total)
+	switch {
+	case accuracy >= 0.8:
+		return domain.DifficultyHard
+	case accuracy < 0.5:
+		return domain.DifficultyEasy
+	default:
+		return domain.DifficultyMedium
+	}
+}
+
+// quizSubjectBreakdown returns the subjects a quiz draws questions from and how many
+// from each, for both single subject and mixed quizzes.
+func quizSubjectBreakdown(quizRequest domain.QuizRequest) ([]domain.QuizSubjectRequest, error) {
+	if len(quizRequest.Subjects) == 0 {
+		if quizRequest.SubjectId <= 0 || quizRequest.NumOfQuestions <= 0 {
+			return nil, pkg.ErrInvalidQuizSubjects
+		}
+		return []domain.QuizSubjectRequest{{
+			SubjectId:      quizRequest.SubjectId,
+			NumOfQuestions: quizRequest.NumOfQuestions,
+			TopicIds:       quizRequest.TopicIds,
+			Tags:           quizRequest.Tags,
+		}}, nil
+	}
+	if quizRequest.SubjectId != 0 || quizRequest.NumOfQuestions != 0 || len(quizRequest.TopicIds) > 0 || len(quizRequest.Tags) > 0 {
+		return nil, pkg.ErrInvalidQuizSubjects
+	}
+	seen := make(map[int64]bool, len(quizRequest.Subjects))
+	total := int64(0)
+	for _, subject := range quizRequest.Subjects {
+		if subject.SubjectId <= 0 || subject.NumOfQuestions <= 0 || seen[subject.SubjectId] {
+			return nil, pkg.ErrInvalidQuizSubjects
+		}
+		seen[subject.SubjectId] = true
+		total += subject.NumOfQuestions
+	}
+	if total > MaxQuizQuestions {
+		return nil, pkg.ErrInvalidQuizSubjects
+	}
+	return quizRequest.Subjects, nil
+}
+
+func (qs *quizService) GetQuizById(ctx context.Context, id int64) (*repository.Quiz, error) {
+	return qs.quizRepository.GetQuizById(ctx, id)
+}
+
+// SubmitQuiz grades the answers for a quiz session issued by GenerateQuizBySubjectID.
+// Answers must reference questions and options that were issued in the session and be
+// given the way the question's type is answered, each question may only be answered
+// once, and a session can only be submitted once.
+// Issued questions that were left unanswered are counted as incorrect.
+// Time taken is measured by the server from issue to submission. An exam submitted after
+// its deadline (plus ExamSubmissionGracePeriod) is marked late and all its answers are dropped.
+// Points are awarded by the scoring policy each subject had when the session was issued.
+// Missed questions are added to the user's review queue and queued ones are rescheduled.
+// Questions, options and explanations are loaded in bulk before grading. A question that
+// was deleted or has no correct option since it was issued earns nothing, and its result
+// says why.
+// A score row is stored per subject, with Score being its points rounded to a whole number.
+func (qs *quizService) SubmitQuiz(ctx context.Context, userID int64, submission domain.QuizSubmission) (*domain.QuizSubmitResponse, error) {
+	session, err := qs.quizSessionRepository.GetQuizSessionById(ctx, submission.SessionId)
+	if err != nil {
+		fmt.Println("error getting quiz session: ", err)
+		return nil, err
+	}
+	if session.UserId != userID {
+		return nil, pkg.ErrQuizSessionNotFound
+	}
+	if session.Status != repository.QuizSessionActive {
+		return nil, pkg.ErrQuizSessionAlreadySubmitted
+	}
+
+	// Each subject is scored with the policy it had when the session was issued
+	scorers := make(map[int64]Scorer, len(session.Subjects))
+	subjectResults := make([]domain.QuizSubjectResult, len(session.Subjects))
+	subjectIndex := make(map[int64]int, len(session.Subjects))
+	for i, subject := range session.Subjects {
+		scorer, err := NewScorer(subject.ScoringPolicy)
 		if err != nil {
-			fmt.Println("error getting question options: ", err)
+			fmt.Println("error getting scorer: ", err)
+			return nil, err
 		}
+		scorers[subject.SubjectId] = scorer
+		subjectResults[i] = domain.QuizSubjectResult{SubjectId: subject.SubjectId, ScoringPolicy: subject.ScoringPolicy.Name}
+		subjectIndex[subject.SubjectId] = i
+	}
+
+	// Load everything needed to grade the session up front, so a failure here leaves it open
+	issuedIds := make([]int64, len(session.Questions))
+	for i, issued := range session.Questions {
+		issuedIds[i] = issued.QuestionId
+	}
+	keys, err := qs.loadAnswerKeys(ctx, issuedIds)
+	if err != nil {
+		fmt.Println("error loading answer keys: ", err)
+		return nil, err
+	}
+	answers, err := validateSessionAnswers(session, keys, submission.Answers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the session before grading so a concurrent submission of the same session fails.
+	submittedAt := time.Now()
+	if err := qs.quizSessionRepository.MarkQuizSessionSubmitted(ctx, session.Id, submittedAt); err != nil {
+		fmt.Println("error marking quiz session as submitted: ", err)
+		return nil, err
+	}
+
+	timeTaken := int64(submittedAt.Sub(session.CreatedAt).Seconds())
+	if timeTaken < 0 {
+		timeTaken = 0
+	}
+	isLate := session.ExpiresAt != nil && submittedAt.After(session.ExpiresAt.Add(ExamSubmissionGracePeriod))
+	if isLate {
+		answers = map[int64]domain.SubmitQuizRequest{}
+	}
+
+	points := float64(0)
+	correctAnswers := int64(0)
+	incorrectAnswers := int64(0)
+	results := make([]domain.QuizResultResponse, 0, len(session.Questions))
+	attempts := make([]repository.QuestionAttempt, 0, len(session.Questions))
+	subjectAnswers := make([][]domain.QuizAnswer, len(subjectResults))
+	gradedAnswers := make([]domain.QuizAnswer, 0, len(session.Questions))
 
-		isCorrect := slices.Contains(quiz.OptionIds, correctOption.Id)
+	for _, issued := range session.Questions {
+		subjectResult := &subjectResults[subjectIndex[issued.SubjectId]]
+		subjectResult.TotalQuestions++
+
+		key := keys[issued.QuestionId]
+		submitted := answers[issued.QuestionId]
+		answered := submitted.Answered()
+		credit := float64(0)
+		questionPoints := float64(0)
+		if key.err == nil {
+			credit = gradeAnswer(key, submitted)
+			questionPoints = scorers[issued.SubjectId].Points(QuestionOutcome{
+				Answered: answered,
+				Credit:   credit,
+				Weight:   key.question.Weight,
+			})
+		} else {
+			fmt.Printf("error grading question %d: %v\n", issued.QuestionId, key.err)
+		}
+		isCorrect := credit == 1
+		points += questionPoints
+		subjectResult.Points += questionPoints
+		// A question that cannot be graded says nothing about its difficulty or what the user remembers
+		if answered && key.err == nil {
+			attempts = append(attempts, repository.QuestionAttempt{QuestionId: issued.QuestionId, IsCorrect: isCorrect})
+		}
 		if isCorrect {
-			score++
 			correctAnswers++
+			subjectResult.CorrectAnswers++
 		} else {
 			incorrectAnswers++
+			subjectResult.IncorrectAnswers++
 		}
 
-		// Get selected option texts
-		selectedOpts := make([]string, 0)
-		for _, optionId := range quiz.OptionIds {
-			questionOption, err := qs.questionRepository.GetQuestionOptionsById(ctx, optionId)
-			if err != nil {
-				fmt.Println("error getting question options: ", err)
-				continue
-			}
-			selectedOpts = append(selectedOpts, questionOption.Option)
+		answer := domain.QuizAnswer{
+			QuestionId:        issued.QuestionId,
+			SubjectId:         issued.SubjectId,
+			Position:          issued.Position,
+			SelectedOptionIds: submitted.OptionIds,
+			Value:             submitted.Value,
+			Unit:              strings.TrimSpace(submitted.Unit),
+			Text:              strings.TrimSpace(submitted.Text),
+			Pairs:             submitted.Pairs,
+			IsCorrect:         isCorrect,
+			Credit:            credit,
+			Points:            questionPoints,
+			AnsweredAt:        submittedAt,
 		}
-
-		results = append(results, domain.QuizResultResponse{
-			QuestionId:      question.Id,
-			Question:        question.Question,
-			SelectedOptions: selectedOpts,
-			CorrectAnswer:   correctOption.Option,
-			IsCorrect:       isCorrect,
-			Explanation:     answer.Answer,
-		})
+		// An answer can only be kept for a question that still exists
+		if !errors.Is(key.err, pkg.ErrQuestionNotFound) {
+			subjectAnswers[subjectIndex[issued.SubjectId]] = append(subjectAnswers[subjectIndex[issued.SubjectId]], answer)
+		}
+		if key.err == nil {
+			gradedAnswers = append(gradedAnswers, answer)
+		}
+		results = append(results, quizResult(key, issued.OptionIds, answer))
 	}
 
-	// Persist the score
-	_, err := qs.scoreRepository.StoreUserScore(ctx, domain.UserScore{
-		UserID:           userID,
-		Score:            score,
-		Mode:             "practice", // Default mode
-		CorrectAnswers:   correctAnswers,
-		IncorrectAnswers: incorrectAnswers,
-		TotalQuestions:   int64(len(quizRequest)),
-		TimeTakenSeconds: 0, // Not tracked yet
-		SubjectID:        subjectID,
-		CreatedAt:        time.Now(),
-		UpdatedAt:        time.Now(),
-	})
-	if err != nil {
+	totalQuestions := int64(len(session.Questions))
+
+	// Persist one score row per subject so subject leaderboards and stats stay correct.
+	// The time taken is shared out between the subjects by their number of questions.
+	score := int64(0)
+	scoringPolicy := ""
+	userScores := make([]domain.UserScore, len(subjectResults))
+	timeLeft := timeTaken
+	for i := range subjectResults {
+		subjectResult := &subjectResults[i]
+		subjectResult.Score = int64(math.Round(subjectResult.Points))
+		score += subjectResult.Score
+		if i == 0 {
+			scoringPolicy = subjectResult.ScoringPolicy
+		} else if scoringPolicy != subjectResult.ScoringPolicy {
+			scoringPolicy = ""
+		}
+
+		subjectTime := timeLeft
+		if i < len(subjectResults)-1 && totalQuestions > 0 {
+			subjectTime = timeTaken * subjectResult.TotalQuestions / totalQuestions
+		}
+		timeLeft -= subjectTime
+
+		userScores[i] = domain.UserScore{
+			UserID:           userID,
+			SessionID:        session.Id,
+			SubjectID:        subjectResult.SubjectId,
+			Score:            subjectResult.Score,
+			Points:           subjectResult.Points,
+			ScoringPolicy:    subjectResult.ScoringPolicy,
+			Mode:             session.Mode,
+			CorrectAnswers:   subjectResult.CorrectAnswers,
+			IncorrectAnswers: subjectResult.IncorrectAnswers,
+			TotalQuestions:   subjectResult.TotalQuestions,
+			TimeTakenSeconds: subjectTime,
+			Answers:          subjectAnswers[i],
+			CreatedAt:        submittedAt,
+			UpdatedAt:        submittedAt,
+		}
+	}
+	if _, err := qs.scoreRepository.StoreUserScores(ctx, userScores); err != nil {
 		fmt.Println("error storing score: ", err)
+		// Give the session back so the user can submit it again
+		if err := qs.quizSessionRepository.ReopenQuizSession(ctx, session.Id); err != nil {
+			fmt.Println("error reopening quiz session: ", err)
+		}
 		return nil, err
 	}
 
-	return &domain.QuizSubmitResponse{
+	// Difficulty calibration and review scheduling should not fail a submission that has already been scored
+	if err := qs.questionRepository.RecordQuestionAttempts(ctx, attempts); err != nil {
+		fmt.Println("error recording question attempts: ", err)
+	}
+	// A late exam had its answers dropped, so it says nothing about what the user remembers
+	if !isLate {
+		if err := qs.scheduleReviews(ctx, userID, gradedAnswers, submittedAt); err != nil {
+			fmt.Println("error scheduling reviews: ", err)
+		}
+	}
+
+	result := &domain.QuizSubmitResponse{
+		SessionId:        session.Id,
 		UserId:           userID,
-		SubjectId:        subjectID,
-		TotalQuestions:   int64(len(quizRequest)),
+		SubjectId:        session.SubjectId,
+		TotalQuestions:   totalQuestions,
 		CorrectAnswers:   correctAnswers,
 		IncorrectAnswers: incorrectAnswers,
 		Score:            score,
+		Points:           points,
+		ScoringPolicy:    scoringPolicy,
+		Mode:             session.Mode,
+		TimeTakenSeconds: timeTaken,
+		IsLate:           isLate,
+		Subjects:         subjectResults,
 		Results:          results,
-	}, nil
+	}
+	// The submission stands whether or not it can be compared with others who sat the paper
+	if session.PaperId != 0 {
+		if result.Paper, err = qs.paperComparison(ctx, session, points); err != nil {
+			fmt.Println("error comparing paper sittings: ", err)
+		}
+	}
+	qs.runSubmitHooks(ctx, session, result)
+	return result, nil
+}
+
+// runSubmitHooks tells the submit hooks about a submission, logging any that fail.
+func (qs *quizService) runSubmitHooks(ctx context.Context, session *repository.QuizSession, result *domain.QuizSubmitResponse) {
+	for _, hook := range qs.submitHooks {
+		if err := hook(ctx, session, result); err != nil {
+			fmt.Println("error running submit hook: ", err)
+		}
+	}
+}
+
+// scheduleReviews updates the user's review queue with the answers of a submitted quiz.
+func (qs *quizService) scheduleReviews(ctx context.Context, userID int64, answers []domain.QuizAnswer, now time.Time) error {
+	questionIds := make([]int64, len(answers))
+	for i, answer := range answers {
+		questionIds[i] = answer.QuestionId
+	}
+	queued, err := qs.reviewQueueRepository.GetReviewItems(ctx, userID, questionIds)
+	if err != nil {
+		return err
+	}
+	return qs.reviewQueueRepository.SaveReviewItems(ctx, reviewUpdates(userID, queued, answers, now))
+}
+
+// GetQuizHistory returns a page of the quizzes a user has submitted, most recent first.
+func (qs *quizService) GetQuizHistory(ctx context.Context, userID int64, query domain.QuizHistoryQuery) (*domain.QuizHistoryResponse, error) {
+	if query.Limit <= 0 {
+		query.Limit = 10
+	}
+	entries, total, err := qs.scoreRepository.GetUserQuizHistory(ctx, userID, query.Limit, query.Offset)
+	if err != nil {
+		fmt.Println("error getting quiz history: ", err)
+		return nil, err
+	}
+	return &domain.QuizHistoryResponse{Total: total, Entries: entries}, nil
+}
+
+// GetQuizReview re-opens a quiz the user submitted, with the same breakdown SubmitQuiz
+// returned: every question with the options chosen, the correct answers and explanations.
+func (qs *quizService) GetQuizReview(ctx context.Context, userID int64, sessionID int64) (*domain.QuizSubmitResponse, error) {
+	session, err := qs.quizSessionRepository.GetQuizSessionById(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, pkg.ErrQuizSessionNotFound) {
+			return nil, pkg.ErrQuizAttemptNotFound
+		}
+		fmt.Println("error getting quiz session: ", err)
+		return nil, err
+	}
+	if session.UserId != userID || session.Status != repository.QuizSessionSubmitted {
+		return nil, pkg.ErrQuizAttemptNotFound
+	}
+
+	userScores, err := qs.scoreRepository.GetSessionScores(ctx, sessionID)
+	if err != nil {
+		fmt.Println("error getting quiz scores: ", err)
+		return nil, err
+	}
+	if len(userScores) == 0 {
+		return nil, pkg.ErrQuizAttemptNotFound
+	}
+	answers, err := qs.scoreRepository.GetSessionAnswers(ctx, sessionID)
+	if err != nil {
+		fmt.Println("error getting quiz answers: ", err)
+		return nil, err
+	}
+
+	review := &domain.QuizSubmitResponse{
+		SessionId: session.Id,
+		UserId:    userID,
+		SubjectId: session.SubjectId,
+		Mode:      session.Mode,
+		IsLate:    session.ExpiresAt != nil && session.SubmittedAt != nil && session.SubmittedAt.After(session.ExpiresAt.Add(ExamSubmissionGracePeriod)),
+		Subjects:  make([]domain.QuizSubjectResult, len(userScores)),
+		Results:   make([]domain.QuizResultResponse, 0, len(answers)),
+	}
+	for i, userScore := range userScores {
+		review.TotalQuestions += userScore.TotalQuestions
+		review.CorrectAnswers += userScore.CorrectAnswers
+		review.IncorrectAnswers += userScore.IncorrectAnswers
+		review.Score += userScore.Score
+		review.Points += userScore.Points
+		review.TimeTakenSeconds += userScore.TimeTakenSeconds
+		if i == 0 {
+			review.ScoringPolicy = userScore.ScoringPolicy
+		} else if review.ScoringPolicy != userScore.ScoringPolicy {
+			review.ScoringPolicy = ""
+		}
+		review.Subjects[i] = domain.QuizSubjectResult{
+			SubjectId:        userScore.SubjectID,
+			TotalQuestions:   userScore.TotalQuestions,
+			CorrectAnswers:   userScore.CorrectAnswers,
+			IncorrectAnswers: userScore.IncorrectAnswers,
+			Score:            userScore.Score,
+			Points:           userScore.Points,
+			ScoringPolicy:    userScore.ScoringPolicy,
+		}
+	}
+	questionIds := make([]int64, len(answers))
+	for i, answer := range answers {
+		questionIds[i] = answer.QuestionId
+	}
+	keys, err := qs.loadAnswerKeys(ctx, questionIds)
+	if err != nil {
+		fmt.Println("error loading answer keys: ", err)
+		return nil, err
+	}
+	issuedOptions := make(map[int64][]int64, len(session.Questions))
+	for _, issued := range session.Questions {
+		issuedOptions[issued.QuestionId] = issued.OptionIds
+	}
+	for _, answer := range answers {
+		review.Results = append(review.Results, quizResult(keys[answer.QuestionId], issuedOptions[answer.QuestionId], answer))
+	}
+	if session.PaperId != 0 {
+		if review.Paper, err = qs.paperComparison(ctx, session, review.Points); err != nil {
+			fmt.Println("error comparing paper sittings: ", err)
+			return nil, err
+		}
+	}
+	return review, nil
+}
+
+// answerKey is what is needed to grade a question and explain its answer.
+// order is the option ids of an ordering question in the correct order, and matches maps
+// the options of a matching question to their matches. media is what is attached to the
+// question itself, and explanationMedia what is attached to its explanation.
+// err says why the question cannot be graded, when it cannot.
+type answerKey struct {
+	question         repository.Questions
+	correctIds       []int64
+	correctTexts     []string
+	order            []int64
+	matches          map[int64]int64
+	optionTexts      map[int64]string
+	explanation      string
+	media            []domain.Media
+	explanationMedia []domain.Media
+	err              error
+}
+
+// loadAnswerKeys loads the questions with their options, explanations and media in bulk, and
+// returns an answer key for every question id. A question that no longer exists, a
+// choice or true/false question with no correct option, or an ordering or matching
+// question without options, gets a key carrying the error instead of failing the whole load.
+func (qs *quizService) loadAnswerKeys(ctx context.Context, questionIds []int64) (map[int64]*answerKey, error) {
+	questions, err := qs.questionRepository.GetQuestionsByIds(ctx, questionIds)
+	if err != nil {
+		return nil, err
+	}
+	options, err := qs.questionRepository.GetQuestionOptionsByQuestionIds(ctx, questionIds)
+	if err != nil {
+		return nil, err
+	}
+	explanations, err := qs.questionRepository.GetAnswersByQuestionIds(ctx, questionIds)
+	if err != nil {
+		return nil, err
+	}
+	media, err := signedQuestionMedia(ctx, qs.mediaRepository, qs.storage, questionIds)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[int64]repository.Questions, len(questions))
+	for _, question := range questions {
+		found[question.Id] = question
+	}
+	keys := make(map[int64]*answerKey, len(questionIds))
+	for _, questionId := range questionIds {
+		question, ok := found[questionId]
+		if !ok {
+			keys[questionId] = &answerKey{question: repository.Questions{Id: questionId}, optionTexts: map[int64]string{}, err: pkg.ErrQuestionNotFound}
+			continue
+		}
+		key := &answerKey{
+			question:    question,
+			optionTexts: make(map[int64]string, len(options[questionId])),
+			explanation: explanations[questionId].Answer,
+		}
+		for _, item := range media[questionId] {
+			switch item.AttachTo {
+			case domain.MediaOnQuestion:
+				key.media = append(key.media, item)
+			case domain.MediaOnExplanation:
+				key.explanationMedia = append(key.explanationMedia, item)
+			}
+		}
+		for _, option := range options[questionId] {
+			key.optionTexts[option.Id] = option.Option
+			if option.IsCorrect {
+				key.correctIds = append(key.correctIds, option.Id)
+				key.correctTexts = append(key.correctTexts, option.Option)
+			}
+		}
+		switch question.Type {
+		case domain.QuestionTypeNumeric, domain.QuestionTypeShortText:
+		case domain.QuestionTypeOrdering:
+			ordered := slices.Clone(options[questionId])
+			slices.SortStableFunc(ordered, func(a, b repository.QuestionOptions) int { return a.Position - b.Position })
+			for _, option := range ordered {
+				key.order = append(key.order, option.Id)
+			}
+			if len(key.order) == 0 {
+				key.err = pkg.ErrQuestionHasNoCorrectOption
+			}
+		case domain.QuestionTypeMatching:
+			key.matches = make(map[int64]int64)
+			for _, option := range options[questionId] {
+				if option.MatchOptionId != 0 {
+					key.matches[option.Id] = option.MatchOptionId
+				}
+			}
+			if len(key.matches) == 0 {
+				key.err = pkg.ErrQuestionHasNoCorrectOption
+			}
+		default:
+			if len(key.correctIds) == 0 {
+				key.err = pkg.ErrQuestionHasNoCorrectOption
+			}
+		}
+		keys[questionId] = key
+	}
+	return keys, nil
+}
+
+// quizResult builds the result shown for a graded answer. For choice and true/false
+// questions the text of the selected and correct options are listed in the order the
+// options were shown in the session; numeric and short text questions show the answer
+// given and the answers that would have been accepted. An ordering question lists the
+// options in the order given and in the correct order, and a matching question lists the
+// pairs given and the correct pairs.
+func quizResult(key *answerKey, issuedOptionIds []int64, answer domain.QuizAnswer) domain.QuizResultResponse {
+	result := domain.QuizResultResponse{
+		QuestionId:       key.question.Id,
+		Type:             key.question.Type,
+		Question:         key.question.Question,
+		QuestionHTML:     richtext.HTML(key.question.Question),
+		SelectedOptions:  []string{},
+		IsCorrect:        answer.IsCorrect,
+		Credit:           answer.Credit,
+		Points:           answer.Points,
+		Explanation:      key.explanation,
+		ExplanationHTML:  richtext.HTML(key.explanation),
+		Media:            key.media,
+		ExplanationMedia: key.explanationMedia,
+	}
+	if key.err != nil {
+		result.Error = key.err.Error()
+	}
+	switch key.question.Type {
+	case domain.QuestionTypeNumeric:
+		if answer.Value != nil {
+			result.Response = formatNumericAnswer(*answer.Value, answer.Unit)
+		}
+		result.CorrectAnswer = formatNumericAnswer(key.question.NumericAnswer, key.question.Unit)
+		result.CorrectAnswers = []string{result.CorrectAnswer}
+		result.Tolerance = key.question.Tolerance
+		return result
+	case domain.QuestionTypeShortText:
+		result.Response = answer.Text
+		result.CorrectAnswers = slices.Clone(key.question.AcceptedAnswers)
+		if len(result.CorrectAnswers) > 0 {
+			result.CorrectAnswer = result.CorrectAnswers[0]
+		}
+		return result
+	case domain.QuestionTypeOrdering:
+		for _, optionId := range answer.SelectedOptionIds {
+			result.SelectedOptions = append(result.SelectedOptions, key.optionTexts[optionId])
+		}
+		result.CorrectAnswers = make([]string, len(key.order))
+		for i, optionId := range key.order {
+			result.CorrectAnswers[i] = key.optionTexts[optionId]
+		}
+		result.CorrectAnswer = strings.Join(result.CorrectAnswers, ", ")
+		return result
+	case domain.QuestionTypeMatching:
+		given := make(map[int64]int64, len(answer.Pairs))
+		for _, pair := range answer.Pairs {
+			given[pair.OptionId] = pair.MatchId
+		}
+		result.CorrectAnswers = make([]string, 0, len(key.matches))
+		for _, optionId := range matchingOrder(key, issuedOptionIds, given) {
+			if matchId, ok := given[optionId]; ok {
+				result.SelectedOptions = append(result.SelectedOptions, matchingPairText(key, optionId, matchId))
+			}
+			if matchId, ok := key.matches[optionId]; ok {
+				result.CorrectAnswers = append(result.CorrectAnswers, matchingPairText(key, optionId, matchId))
+			}
+		}
+		result.CorrectAnswer = strings.Join(result.CorrectAnswers, ", ")
+		return result
+	}
+
+	selected := answer.SelectedOptionIds
+	selectedOpts := make([]string, 0, len(selected))
+	correctTexts := make([]string, 0, len(key.correctIds))
+	for _, optionId := range issuedOptionIds {
+		if slices.Contains(selected, optionId) {
+			selectedOpts = append(selectedOpts, key.optionTexts[optionId])
+		}
+		if slices.Contains(key.correctIds, optionId) {
+			correctTexts = append(correctTexts, key.optionTexts[optionId])
+		}
+	}
+	// Correct options added to the question after the session was issued were never shown
+	if len(correctTexts) < len(key.correctIds) {
+		for i, optionId := range key.correctIds {
+			if !slices.Contains(issuedOptionIds, optionId) {
+				correctTexts = append(correctTexts, key.correctTexts[i])
+			}
+		}
+	}
+	result.SelectedOptions = selectedOpts
+	result.CorrectAnswer = strings.Join(correctTexts, ", ")
+	result.CorrectAnswers = correctTexts
+	return result
+}
+
+// matchingOrder returns the options of a matching question in the order they were shown,
+// followed by any other option that was paired or is in the key, in id order.
+func matchingOrder(key *answerKey, issuedOptionIds []int64, given map[int64]int64) []int64 {
+	order := make([]int64, 0, len(key.matches))
+	listed := make(map[int64]bool, len(key.matches))
+	for _, optionId := range issuedOptionIds {
+		_, inKey := key.matches[optionId]
+		_, inAnswer := given[optionId]
+		if inKey || inAnswer {
+			order = append(order, optionId)
+			listed[optionId] = true
+		}
+	}
+	var rest []int64
+	for optionId := range key.matches {
+		if !listed[optionId] {
+			rest = append(rest, optionId)
+		}
+	}
+	for optionId := range given {
+		if !listed[optionId] && !slices.Contains(rest, optionId) {
+			rest = append(rest, optionId)
+		}
+	}
+	slices.Sort(rest)
+	return append(order, rest...)
+}
+
+// matchingPairText writes a pair of a matching question as the option and its match.
+func matchingPairText(key *answerKey, optionId, matchId int64) string {
+	return key.optionTexts[optionId] + " → " + key.optionTexts[matchId]
+}
+
+// gradeSelection returns the credit, between 0 and 1, earned by the selected options.
+// By default the selection must match the correct options exactly, so selecting every
+// option of a multi-answer question earns nothing. With partial credit each correct
+// option selected earns an equal share and each wrong option selected takes one away.
+func gradeSelection(correctIds []int64, selected []int64, partialCredit bool) float64 {
+	if len(correctIds) == 0 || len(selected) == 0 {
+		return 0
+	}
+	hits, misses := 0, 0
+	for _, optionId := range selected {
+		if slices.Contains(correctIds, optionId) {
+			hits++
+		} else {
+			misses++
+		}
+	}
+	if hits == len(correctIds) && misses == 0 {
+		return 1
+	}
+	if !partialCredit {
+		return 0
+	}
+	credit := float64(hits-misses) / float64(len(correctIds))
+	if credit < 0 {
+		return 0
+	}
+	return credit
+}
+
+// validateSessionAnswers checks every submitted answer against the questions and options
+// issued in the session and the answer keys of the questions, and returns the answers
+// keyed by question id.
+func validateSessionAnswers(session *repository.QuizSession, keys map[int64]*answerKey, submitted []domain.SubmitQuizRequest) (map[int64]domain.SubmitQuizRequest, error) {
+	issuedOptions := make(map[int64]map[int64]bool, len(session.Questions))
+	for _, question := range session.Questions {
+		options := make(map[int64]bool, len(question.OptionIds))
+		for _, optionId := range question.OptionIds {
+			options[optionId] = true
+		}
+		issuedOptions[question.QuestionId] = options
+	}
+
+	answers := make(map[int64]domain.SubmitQuizRequest, len(submitted))
+	for _, answer := range submitted {
+		options, ok := issuedOptions[answer.QuestionId]
+		if !ok {
+			return nil, pkg.ErrQuestionNotInSession
+		}
+		if _, ok := answers[answer.QuestionId]; ok {
+			return nil, pkg.ErrDuplicateQuizAnswer
+		}
+		// A deleted question is not graded, however it was answered
+		if key, ok := keys[answer.QuestionId]; ok && !errors.Is(key.err, pkg.ErrQuestionNotFound) && !answerMatchesType(key.question.Type, answer) {
+			return nil, pkg.ErrAnswerDoesNotMatchType
+		}
+		seen := make(map[int64]bool, len(answer.OptionIds))
+		for _, optionId := range answer.OptionIds {
+			if !options[optionId] {
+				return nil, pkg.ErrOptionNotInSession
+			}
+			if seen[optionId] {
+				return nil, pkg.ErrDuplicateQuizAnswer
+			}
+			seen[optionId] = true
+		}
+		// Each option and each match can only be used in one pair
+		pairedOptions := make(map[int64]bool, len(answer.Pairs))
+		pairedMatches := make(map[int64]bool, len(answer.Pairs))
+		for _, pair := range answer.Pairs {
+			if !options[pair.OptionId] || !options[pair.MatchId] {
+				return nil, pkg.ErrOptionNotInSession
+			}
+			if pairedOptions[pair.OptionId] || pairedMatches[pair.MatchId] {
+				return nil, pkg.ErrDuplicateQuizAnswer
+			}
+			pairedOptions[pair.OptionId] = true
+			pairedMatches[pair.MatchId] = true
+		}
+		answers[answer.QuestionId] = answer
+	}
+	return answers, nil
 }
 
 // CalculateQuizScore takes a number of questions and a score and returns the percentage of the score.
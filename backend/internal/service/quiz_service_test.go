@@ -4,11 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"log"
 	"testing"
 	"time"
 
 	"github.com/lawson/otterprep/domain"
 	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
@@ -135,13 +138,32 @@ func setUpDB(t *testing.T) *sql.DB {
 		t.Fatal(err)
 	}
 	queries := []string{
-		"CREATE TABLE question_options (id integer primary key autoincrement, question_id integer, option text, is_correct boolean, created_at timestamp, updated_at timestamp)",
-		"CREATE TABLE questions (id integer primary key autoincrement, subject_id integer, question text, is_multiple_choice boolean, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE options (id integer primary key autoincrement, question_id integer, option text, is_correct boolean, position integer default 0, match_option_id integer, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE questions (id integer primary key autoincrement, subject_id integer, question text, is_multiple_choice boolean, partial_credit boolean default false, weight real default 1, difficulty integer default 2, attempts integer default 0, correct_attempts integer default 0, question_type text default 'choice', numeric_answer real default 0, tolerance real default 0, unit text default '', accepted_answers text default '[]', topic_id integer, paper_id integer, paper_question_number integer, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE topics (id integer primary key autoincrement, subject_id integer, parent_id integer, name text, created_at timestamp, updated_at timestamp, unique (subject_id, name))",
+		"CREATE TABLE question_tags (question_id integer, tag text, primary key (question_id, tag))",
+		"CREATE TABLE past_papers (id integer primary key autoincrement, subject_id integer, exam_body text, year integer, paper text, duration_seconds integer, created_at timestamp, updated_at timestamp, unique (subject_id, exam_body, year, paper))",
+		"CREATE TABLE question_media (id integer primary key autoincrement, question_id integer, option_id integer, attach_to text, kind text, content_type text, size_bytes integer, storage_key text unique, alt_text text default '', created_at timestamp)",
 		"CREATE TABLE answers (id integer primary key autoincrement, question_id integer, answer text, created_at timestamp, updated_at timestamp)",
 		"CREATE TABLE subjects (id integer primary key autoincrement, name text, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE subject_scoring_policies (id integer primary key autoincrement, subject_id integer unique, name text, wrong_penalty real, unanswered_penalty real, created_at timestamp, updated_at timestamp)",
 		"CREATE TABLE users (id integer primary key autoincrement, name text, email text, password_hash text, created_at timestamp, updated_at timestamp)",
-		"CREATE TABLE scores (id integer primary key autoincrement, user_id integer, score integer, mode text, correct_answers integer, incorrect_answers integer, total_questions integer, time_taken_seconds integer, subject_id integer, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE scores (id integer primary key autoincrement, user_id integer, session_id integer, score integer, mode text, correct_answers integer, incorrect_answers integer, total_questions integer, time_taken_seconds integer, subject_id integer, points real default 0, scoring_policy text default 'standard', created_at timestamp, updated_at timestamp)",
 		"CREATE TABLE user_roles (id integer primary key autoincrement, user_id integer, role text, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE quiz_sessions (id integer primary key autoincrement, user_id integer, subject_id integer, status text, mode text, duration_seconds integer, challenge_id integer, paper_id integer, contest_id integer, daily_date date, expires_at timestamp, submitted_at timestamp, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE quiz_session_questions (id integer primary key autoincrement, session_id integer, question_id integer, subject_id integer, position integer)",
+		"CREATE TABLE quiz_session_subjects (id integer primary key autoincrement, session_id integer, subject_id integer, num_of_questions integer, scoring_policy text)",
+		"CREATE TABLE quiz_answers (id integer primary key autoincrement, score_id integer, session_id integer, user_id integer, question_id integer, subject_id integer, position integer, selected_option_ids text, answer_value real, answer_unit text default '', answer_text text default '', answer_pairs text default '[]', is_correct boolean, credit real, points real, answered_at timestamp)",
+		"CREATE TABLE review_queue (user_id integer, question_id integer, subject_id integer, ease_factor real, interval_days integer, repetitions integer, due_at timestamp, last_reviewed_at timestamp, created_at timestamp, updated_at timestamp, primary key (user_id, question_id))",
+		"CREATE TABLE quiz_challenges (id integer primary key autoincrement, code text unique, seed integer, created_by integer, subject_id integer, mode text, duration_seconds integer, subjects text, question_ids text, created_at timestamp)",
+		"CREATE TABLE quiz_session_options (id integer primary key autoincrement, session_id integer, question_id integer, option_id integer, position integer)",
+		"CREATE TABLE contests (id integer primary key autoincrement, title text, description text default '', subject_id integer, duration_seconds integer, scoring_policy text, question_ids text, starts_at timestamp, ends_at timestamp, frozen_at timestamp, created_by integer, created_at timestamp)",
+		"CREATE TABLE user_streaks (user_id integer primary key, current_streak integer, longest_streak integer, freezes integer, last_active_on date, updated_at timestamp)",
+		"CREATE TABLE user_achievements (user_id integer, code text, earned_at timestamp, primary key (user_id, code))",
+		"CREATE TABLE xp_ledger (id integer primary key autoincrement, user_id integer, session_id integer, subject_id integer, reason text, amount integer, created_at timestamp)",
+		"CREATE TABLE contest_standings (contest_id integer, user_id integer, rank integer, user_name text, score integer, points real, correct_answers integer, total_questions integer, time_taken_seconds integer, submitted_at timestamp, primary key (contest_id, user_id))",
+		"CREATE TABLE study_plans (id integer primary key autoincrement, user_id integer unique, exam_date date, subject_ids text, target_accuracy real, daily_questions integer, created_at timestamp, updated_at timestamp)",
+		"CREATE TABLE study_plan_days (plan_id integer, day date, tasks text, created_at timestamp, primary key (plan_id, day))",
 	}
 	for _, query := range queries {
 		if _, err := db.Exec(query); err != nil {
@@ -163,7 +185,8 @@ func TestGenerateQuizBySubjectID(t *testing.T) {
 	questionRepo := repository.NewQuestionRepository(pool)
 	subjectRepo := repository.NewSubjectRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
 
 	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{
 		Name: "use of english",
@@ -186,12 +209,48 @@ func TestGenerateQuizBySubjectID(t *testing.T) {
 	}
 	fmt.Println("all created questions: ", questions)
 
-	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, 3)
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: 1, NumOfQuestions: 3})
 	assert.Nil(t, err)
 	//assert.Equal(t, 3, len(quiz))
 	fmt.Println("quiz: ", quiz)
 }
 
+func TestGenerateQuizDrawsDistinctQuestions(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	// every question of the subject, each once, with its options
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 4})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, quiz.TotalCount)
+	seen := make(map[int64]bool)
+	for _, question := range quiz.Questions {
+		assert.False(t, seen[question.QuestionId], "question issued twice")
+		seen[question.QuestionId] = true
+		assert.NotEmpty(t, question.Options)
+	}
+
+	_, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 5})
+	assert.ErrorIs(t, err, pkg.ErrNotEnoughQuestions)
+
+	_, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{Mode: domain.ModeAdaptive, SubjectId: subjectId, NumOfQuestions: 4})
+	assert.Nil(t, err)
+}
+
 func TestSubmitQuiz(t *testing.T) {
 	pool := setUpDB(t)
 	defer pool.Close()
@@ -203,7 +262,8 @@ func TestSubmitQuiz(t *testing.T) {
 	questionRepo := repository.NewQuestionRepository(pool)
 	subjectRepo := repository.NewSubjectRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
 
 	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{
 		Name: "use of english",
@@ -226,32 +286,17 @@ func TestSubmitQuiz(t *testing.T) {
 	}
 	fmt.Println("all created questions: ", questions)
 
-	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, 3)
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: 1, NumOfQuestions: 3})
 	assert.Nil(t, err)
-	//assert.Equal(t, 3, len(quiz))
+	assert.NotZero(t, quiz.SessionId)
 	fmt.Println("quiz: ", quiz)
 
-	quizRequest := []domain.SubmitQuizRequest{
-		{
-			QuestionId:       1,
-			IsMultipleChoice: true,
-			OptionIds:        []int64{1},
-		},
-		{
-			QuestionId:       2,
-			IsMultipleChoice: true,
-			OptionIds:        []int64{2},
-		},
-		{
-			QuestionId:       3,
-			IsMultipleChoice: true,
-			OptionIds:        []int64{1},
-		},
-	}
+	quizRequest := answerQuiz(t, ctx, questionRepo, quiz, 1)
 	userID := int64(1)
 	result, err := qs.SubmitQuiz(ctx, userID, quizRequest)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(1), result.Score)
+	assert.Equal(t, quiz.SessionId, result.SessionId)
 	fmt.Println("result: ", result)
 
 	// Verify score is saved
@@ -261,6 +306,801 @@ func TestSubmitQuiz(t *testing.T) {
 	assert.Equal(t, int64(1), scoreStats.TotalCorrectAnswers)
 	assert.Equal(t, int64(2), scoreStats.TotalIncorrectAnswers)
 	fmt.Printf("user score stats: %+v\n", scoreStats)
+
+	// A session can only be graded once
+	_, err = qs.SubmitQuiz(ctx, userID, quizRequest)
+	assert.ErrorIs(t, err, pkg.ErrQuizSessionAlreadySubmitted)
+
+	// A session whose scores cannot be stored is left open to submit again
+	quiz, err = qs.GenerateQuizBySubjectID(ctx, userID, domain.QuizRequest{SubjectId: 1, NumOfQuestions: 3})
+	assert.Nil(t, err)
+	quizRequest = answerQuiz(t, ctx, questionRepo, quiz, 1)
+	_, err = pool.Exec("ALTER TABLE scores RENAME TO scores_offline")
+	assert.Nil(t, err)
+	_, err = qs.SubmitQuiz(ctx, userID, quizRequest)
+	assert.NotNil(t, err)
+	session, err := sessionRepo.GetQuizSessionById(ctx, quiz.SessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, repository.QuizSessionActive, session.Status)
+	_, err = pool.Exec("ALTER TABLE scores_offline RENAME TO scores")
+	assert.Nil(t, err)
+	_, err = qs.SubmitQuiz(ctx, userID, quizRequest)
+	assert.Nil(t, err)
+}
+
+func TestSubmitQuizRejectsAnswersOutsideSession(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2})
+	assert.Nil(t, err)
+	issued := quiz.Questions[0]
+
+	// find a question that was not issued in the session
+	var notIssued int64
+	for id := int64(1); id <= 4; id++ {
+		if id != quiz.Questions[0].QuestionId && id != quiz.Questions[1].QuestionId {
+			notIssued = id
+			break
+		}
+	}
+	notIssuedOptions, err := questionRepo.GetQuestionOptions(ctx, notIssued)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		userID  int64
+		answers []domain.SubmitQuizRequest
+		wantErr error
+	}{
+		{
+			name:    "question not issued",
+			userID:  1,
+			answers: []domain.SubmitQuizRequest{{QuestionId: notIssued, OptionIds: []int64{notIssuedOptions[0].Id}}},
+			wantErr: pkg.ErrQuestionNotInSession,
+		},
+		{
+			name:    "option from another question",
+			userID:  1,
+			answers: []domain.SubmitQuizRequest{{QuestionId: issued.QuestionId, OptionIds: []int64{notIssuedOptions[0].Id}}},
+			wantErr: pkg.ErrOptionNotInSession,
+		},
+		{
+			name:   "question answered twice",
+			userID: 1,
+			answers: []domain.SubmitQuizRequest{
+				{QuestionId: issued.QuestionId, OptionIds: []int64{issued.Options[0].Id}},
+				{QuestionId: issued.QuestionId, OptionIds: []int64{issued.Options[1].Id}},
+			},
+			wantErr: pkg.ErrDuplicateQuizAnswer,
+		},
+		{
+			name:    "session owned by another user",
+			userID:  2,
+			answers: []domain.SubmitQuizRequest{{QuestionId: issued.QuestionId, OptionIds: []int64{issued.Options[0].Id}}},
+			wantErr: pkg.ErrQuizSessionNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := qs.SubmitQuiz(ctx, tt.userID, domain.QuizSubmission{SessionId: quiz.SessionId, Answers: tt.answers})
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	_, err = qs.SubmitQuiz(ctx, 1, domain.QuizSubmission{SessionId: quiz.SessionId + 100, Answers: []domain.SubmitQuizRequest{{QuestionId: issued.QuestionId, OptionIds: []int64{issued.Options[0].Id}}}})
+	assert.ErrorIs(t, err, pkg.ErrQuizSessionNotFound)
+}
+
+func TestSubmitQuizExamMode(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	_, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2, Mode: domain.ModeExam})
+	assert.ErrorIs(t, err, pkg.ErrExamDurationRequired)
+
+	// submitted before the deadline
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2, Mode: domain.ModeExam, DurationSeconds: 600})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ModeExam, quiz.Mode)
+	assert.NotNil(t, quiz.ExpiresAt)
+	result, err := qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, quiz, 2))
+	assert.Nil(t, err)
+	assert.False(t, result.IsLate)
+	assert.Equal(t, domain.ModeExam, result.Mode)
+	assert.Equal(t, int64(2), result.CorrectAnswers)
+
+	// submitted after the deadline: answers are dropped
+	quiz, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2, Mode: domain.ModeExam, DurationSeconds: 600})
+	assert.Nil(t, err)
+	issuedAt := time.Now().Add(-20 * time.Minute)
+	_, err = pool.ExecContext(ctx, "UPDATE quiz_sessions SET created_at = $1, expires_at = $2 WHERE id = $3", issuedAt, issuedAt.Add(10*time.Minute), quiz.SessionId)
+	assert.Nil(t, err)
+	result, err = qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, quiz, 2))
+	assert.Nil(t, err)
+	assert.True(t, result.IsLate)
+	assert.Equal(t, int64(0), result.CorrectAnswers)
+	assert.Equal(t, int64(2), result.IncorrectAnswers)
+	assert.GreaterOrEqual(t, result.TimeTakenSeconds, int64(20*60))
+
+	stats, err := scoreRepo.GetUserOverallScoreStats(ctx, 1)
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, stats.TotalTimeTakenSeconds, int64(20*60))
+}
+
+func TestSubmitQuizNegativeMarking(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+	err = subjectRepo.SetSubjectScoringPolicy(ctx, subjectId, domain.ScoringPolicy{Name: domain.ScoringNegativeMarking, WrongPenalty: 0.5, UnansweredPenalty: 0.25})
+	assert.Nil(t, err)
+
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 3})
+	assert.Nil(t, err)
+	assert.Len(t, quiz.Questions, 3)
+
+	// changing the subject policy does not affect a quiz that was already issued
+	err = subjectRepo.SetSubjectScoringPolicy(ctx, subjectId, domain.DefaultScoringPolicy())
+	assert.Nil(t, err)
+
+	// one correct, one wrong and one left unanswered
+	submission := answerQuiz(t, ctx, questionRepo, quiz, 1)
+	submission.Answers = submission.Answers[:2]
+	result, err := qs.SubmitQuiz(ctx, 1, submission)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ScoringNegativeMarking, result.ScoringPolicy)
+	assert.Equal(t, int64(1), result.CorrectAnswers)
+	assert.Equal(t, int64(2), result.IncorrectAnswers)
+	assert.InDelta(t, 0.25, result.Points, 1e-9)
+	assert.Equal(t, int64(0), result.Score)
+	assert.InDelta(t, -0.5, result.Results[1].Points, 1e-9)
+	assert.InDelta(t, -0.25, result.Results[2].Points, 1e-9)
+}
+
+func TestSubmitMixedQuiz(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	mathsId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	englishId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	for _, subjectId := range []int64{mathsId, englishId} {
+		if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+			t.Fatal("failed to create quiz")
+		}
+	}
+	err = subjectRepo.SetSubjectScoringPolicy(ctx, englishId, domain.ScoringPolicy{Name: domain.ScoringNegativeMarking, WrongPenalty: 1})
+	assert.Nil(t, err)
+
+	_, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{Subjects: []domain.QuizSubjectRequest{
+		{SubjectId: mathsId, NumOfQuestions: 1},
+		{SubjectId: mathsId, NumOfQuestions: 2},
+	}})
+	assert.ErrorIs(t, err, pkg.ErrInvalidQuizSubjects)
+
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{Subjects: []domain.QuizSubjectRequest{
+		{SubjectId: mathsId, NumOfQuestions: 2},
+		{SubjectId: englishId, NumOfQuestions: 2},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), quiz.SubjectId)
+	assert.Len(t, quiz.Questions, 4)
+	for i, question := range quiz.Questions {
+		if i < 2 {
+			assert.Equal(t, mathsId, question.SubjectId)
+		} else {
+			assert.Equal(t, englishId, question.SubjectId)
+		}
+	}
+
+	// both maths questions right, one english question right and one wrong
+	result, err := qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, quiz, 3))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), result.TotalQuestions)
+	assert.Equal(t, int64(3), result.CorrectAnswers)
+	assert.Empty(t, result.ScoringPolicy)
+	assert.Len(t, result.Subjects, 2)
+	assert.Equal(t, domain.QuizSubjectResult{SubjectId: mathsId, TotalQuestions: 2, CorrectAnswers: 2, Score: 2, Points: 2, ScoringPolicy: domain.ScoringStandard}, result.Subjects[0])
+	assert.Equal(t, domain.QuizSubjectResult{SubjectId: englishId, TotalQuestions: 2, CorrectAnswers: 1, IncorrectAnswers: 1, Score: 0, Points: 0, ScoringPolicy: domain.ScoringNegativeMarking}, result.Subjects[1])
+	assert.Equal(t, int64(2), result.Score)
+
+	var rows int
+	err = pool.QueryRowContext(ctx, "SELECT COUNT(*) FROM scores WHERE session_id = $1", quiz.SessionId).Scan(&rows)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rows)
+
+	stats, err := scoreRepo.GetUserOverallScoreStats(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), stats.TotalQuizzesTaken)
+	assert.Equal(t, int64(4), stats.TotalQuestionsAnswered)
+}
+
+func TestGenerateAdaptiveQuiz(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+	// one hard and one easy question, the rest are medium
+	_, err = pool.ExecContext(ctx, "UPDATE questions SET difficulty = $1 WHERE id = 1", domain.DifficultyHard)
+	assert.Nil(t, err)
+	_, err = pool.ExecContext(ctx, "UPDATE questions SET difficulty = $1 WHERE id = 2", domain.DifficultyEasy)
+	assert.Nil(t, err)
+
+	// user 1 answers most questions correctly, user 2 most incorrectly, user 3 has no history
+	for userId, correct := range map[int64]int64{1: 9, 2: 2} {
+		_, err := scoreRepo.StoreUserScore(ctx, domain.UserScore{UserID: userId, SubjectID: subjectId, Score: correct, CorrectAnswers: correct, IncorrectAnswers: 10 - correct, TotalQuestions: 10, Mode: domain.ModePractice, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		assert.Nil(t, err)
+	}
+
+	tests := []struct {
+		userId     int64
+		difficulty int
+	}{
+		{1, domain.DifficultyHard},
+		{2, domain.DifficultyEasy},
+		{3, domain.DifficultyMedium},
+	}
+	for _, tt := range tests {
+		quiz, err := qs.GenerateQuizBySubjectID(ctx, tt.userId, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 1, Mode: domain.ModeAdaptive})
+		assert.Nil(t, err)
+		assert.Len(t, quiz.Questions, 1)
+		assert.Equal(t, tt.difficulty, quiz.Questions[0].Difficulty)
+	}
+
+	// there is only one hard question, so the rest of the quiz is topped up with others
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 3, Mode: domain.ModeAdaptive})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ModeAdaptive, quiz.Mode)
+	assert.Equal(t, domain.DifficultyHard, quiz.Questions[0].Difficulty)
+}
+
+func TestAdaptiveDifficulty(t *testing.T) {
+	tests := []struct {
+		name    string
+		correct int64
+		total   int64
+		want    int
+	}{
+		{"no history", 0, 0, domain.DifficultyMedium},
+		{"too little history", 1, AdaptiveMinQuestions - 1, domain.DifficultyMedium},
+		{"strong", 18, 20, domain.DifficultyHard},
+		{"average", 13, 20, domain.DifficultyMedium},
+		{"weak", 5, 20, domain.DifficultyEasy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, adaptiveDifficulty(tt.correct, tt.total))
+		})
+	}
+}
+
+func TestQuizHistoryAndReview(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	first, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2})
+	assert.Nil(t, err)
+	// one right and one left unanswered
+	submission := answerQuiz(t, ctx, questionRepo, first, 1)
+	submission.Answers = submission.Answers[:1]
+	submitted, err := qs.SubmitQuiz(ctx, 1, submission)
+	assert.Nil(t, err)
+
+	second, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2})
+	assert.Nil(t, err)
+	_, err = qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, second, 2))
+	assert.Nil(t, err)
+
+	// issued but never submitted
+	pending, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2})
+	assert.Nil(t, err)
+
+	history, err := qs.GetQuizHistory(ctx, 1, domain.QuizHistoryQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), history.Total)
+	assert.Len(t, history.Entries, 2)
+	assert.Equal(t, second.SessionId, history.Entries[0].SessionId)
+	assert.Equal(t, int64(2), history.Entries[0].CorrectAnswers)
+	assert.Equal(t, first.SessionId, history.Entries[1].SessionId)
+	assert.Equal(t, subjectId, history.Entries[1].SubjectId)
+
+	history, err = qs.GetQuizHistory(ctx, 1, domain.QuizHistoryQuery{Limit: 1, Offset: 1})
+	assert.Nil(t, err)
+	assert.Len(t, history.Entries, 1)
+	assert.Equal(t, first.SessionId, history.Entries[0].SessionId)
+
+	review, err := qs.GetQuizReview(ctx, 1, first.SessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, submitted.CorrectAnswers, review.CorrectAnswers)
+	assert.Equal(t, submitted.IncorrectAnswers, review.IncorrectAnswers)
+	assert.Equal(t, submitted.Score, review.Score)
+	assert.Equal(t, submitted.Subjects, review.Subjects)
+	assert.Equal(t, submitted.Results, review.Results)
+	assert.Len(t, review.Results[0].SelectedOptions, 1)
+	assert.Empty(t, review.Results[1].SelectedOptions)
+
+	_, err = qs.GetQuizReview(ctx, 2, first.SessionId)
+	assert.ErrorIs(t, err, pkg.ErrQuizAttemptNotFound)
+	_, err = qs.GetQuizReview(ctx, 1, pending.SessionId)
+	assert.ErrorIs(t, err, pkg.ErrQuizAttemptNotFound)
+	_, err = qs.GetQuizReview(ctx, 1, 999)
+	assert.ErrorIs(t, err, pkg.ErrQuizAttemptNotFound)
+}
+
+func TestGenerateReviewQuiz(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	// one answered wrong and one left unanswered, both missed
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2})
+	assert.Nil(t, err)
+	submission := answerQuiz(t, ctx, questionRepo, quiz, 0)
+	submission.Answers = submission.Answers[:1]
+	_, err = qs.SubmitQuiz(ctx, 1, submission)
+	assert.Nil(t, err)
+	missed := []int64{quiz.Questions[0].QuestionId, quiz.Questions[1].QuestionId}
+
+	queue, err := qs.GetReviewQueue(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), queue.Queued)
+	assert.Equal(t, int64(0), queue.Due)
+
+	// nothing is due until tomorrow, so a review quiz is all fresh questions
+	review, err := qs.GenerateReviewQuiz(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ModeReview, review.Mode)
+	assert.False(t, review.Questions[0].Review)
+
+	_, err = pool.Exec("UPDATE review_queue SET due_at = $1", time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	queue, err = qs.GetReviewQueue(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), queue.Due)
+
+	review, err = qs.GenerateReviewQuiz(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 3})
+	assert.Nil(t, err)
+	assert.Len(t, review.Questions, 3)
+	assert.True(t, review.Questions[0].Review)
+	assert.True(t, review.Questions[1].Review)
+	assert.ElementsMatch(t, missed, []int64{review.Questions[0].QuestionId, review.Questions[1].QuestionId})
+	assert.False(t, review.Questions[2].Review)
+	assert.NotContains(t, missed, review.Questions[2].QuestionId)
+
+	// recalling the due items pushes them back out; the fresh question is left out of the queue
+	_, err = qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, review, 3))
+	assert.Nil(t, err)
+	queue, err = qs.GetReviewQueue(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), queue.Queued)
+	assert.Equal(t, int64(0), queue.Due)
+}
+
+func TestGenerateQuizShufflesPerSession(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	english, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	maths, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	for _, subjectId := range []int64{english, maths} {
+		if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+			t.Fatal("failed to create quiz")
+		}
+	}
+
+	// the options shown are stored on the session in the order they were shown
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: english, NumOfQuestions: 4})
+	assert.Nil(t, err)
+	session, err := sessionRepo.GetQuizSessionById(ctx, quiz.SessionId)
+	assert.Nil(t, err)
+	for i, question := range quiz.Questions {
+		shown := make([]int64, len(question.Options))
+		for j, option := range question.Options {
+			shown[j] = option.Id
+		}
+		assert.Equal(t, shown, session.Questions[i].OptionIds)
+	}
+
+	// over enough quizzes options come out of insertion order, and questions mix subjects when asked to
+	optionsShuffled, questionsShuffled := false, false
+	for i := 0; i < 20 && !(optionsShuffled && questionsShuffled); i++ {
+		mixed, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{
+			Subjects:         []domain.QuizSubjectRequest{{SubjectId: english, NumOfQuestions: 2}, {SubjectId: maths, NumOfQuestions: 2}},
+			ShuffleQuestions: true,
+		})
+		assert.Nil(t, err)
+		for _, question := range mixed.Questions {
+			for j := 1; j < len(question.Options); j++ {
+				if question.Options[j].Id < question.Options[j-1].Id {
+					optionsShuffled = true
+				}
+			}
+		}
+		if mixed.Questions[0].SubjectId != english || mixed.Questions[1].SubjectId != english {
+			questionsShuffled = true
+		}
+	}
+	assert.True(t, optionsShuffled, "options were never shuffled")
+	assert.True(t, questionsShuffled, "questions were never shuffled")
+
+	// results list options in the order they were shown
+	submission := answerQuiz(t, ctx, questionRepo, quiz, 4)
+	result, err := qs.SubmitQuiz(ctx, 1, submission)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), result.CorrectAnswers)
+	review, err := qs.GetQuizReview(ctx, 1, quiz.SessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Results, review.Results)
+}
+
+func TestQuizResultFollowsIssuedOrder(t *testing.T) {
+	key := &answerKey{
+		question:     repository.Questions{Id: 1, Question: "pick the primes"},
+		correctIds:   []int64{10, 12, 14},
+		correctTexts: []string{"2", "3", "5"},
+		optionTexts:  map[int64]string{10: "2", 11: "4", 12: "3", 13: "9", 14: "5"},
+	}
+
+	// option 14 was added after the session was issued
+	result := quizResult(key, []int64{13, 12, 11, 10}, domain.QuizAnswer{QuestionId: 1, SelectedOptionIds: []int64{10, 13}})
+	assert.Equal(t, []string{"9", "2"}, result.SelectedOptions)
+	assert.Equal(t, []string{"3", "2", "5"}, result.CorrectAnswers)
+	assert.Equal(t, "3, 2, 5", result.CorrectAnswer)
+}
+
+// bulkOnlyQuestionRepository fails the test when a question is looked up one at a time
+type bulkOnlyQuestionRepository struct {
+	repository.QuestionRepository
+	t *testing.T
+}
+
+func (r bulkOnlyQuestionRepository) GetQuestionById(ctx context.Context, id int64) (*repository.Questions, error) {
+	r.t.Errorf("GetQuestionById called for question %d", id)
+	return r.QuestionRepository.GetQuestionById(ctx, id)
+}
+
+func (r bulkOnlyQuestionRepository) GetAnswerById(ctx context.Context, id int64) (*repository.Answers, error) {
+	r.t.Errorf("GetAnswerById called for question %d", id)
+	return r.QuestionRepository.GetAnswerById(ctx, id)
+}
+
+func (r bulkOnlyQuestionRepository) GetCorrectQuestionOptionsByQuestionID(ctx context.Context, questionId int64) ([]repository.QuestionOptions, error) {
+	r.t.Errorf("GetCorrectQuestionOptionsByQuestionID called for question %d", questionId)
+	return r.QuestionRepository.GetCorrectQuestionOptionsByQuestionID(ctx, questionId)
+}
+
+func (r bulkOnlyQuestionRepository) GetQuestionOptionsById(ctx context.Context, id int64) (*repository.QuestionOptions, error) {
+	r.t.Errorf("GetQuestionOptionsById called for option %d", id)
+	return r.QuestionRepository.GetQuestionOptionsById(ctx, id)
+}
+
+func (r bulkOnlyQuestionRepository) GetQuestionOptions(ctx context.Context, questionId int64) ([]repository.QuestionOptions, error) {
+	r.t.Errorf("GetQuestionOptions called for question %d", questionId)
+	return r.QuestionRepository.GetQuestionOptions(ctx, questionId)
+}
+
+func TestSubmitQuizGradesInBulk(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, bulkOnlyQuestionRepository{questionRepo, t}, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 3})
+	assert.Nil(t, err)
+	submission := answerQuiz(t, ctx, questionRepo, quiz, 3)
+
+	// after the quiz was issued one question is deleted and another loses its correct option
+	deleted, broken := quiz.Questions[1].QuestionId, quiz.Questions[2].QuestionId
+	_, err = pool.Exec("DELETE FROM questions WHERE id = $1", deleted)
+	assert.Nil(t, err)
+	_, err = pool.Exec("UPDATE options SET is_correct = false WHERE question_id = $1", broken)
+	assert.Nil(t, err)
+
+	result, err := qs.SubmitQuiz(ctx, 1, submission)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), result.TotalQuestions)
+	assert.Equal(t, int64(1), result.CorrectAnswers)
+	assert.Equal(t, int64(2), result.IncorrectAnswers)
+	assert.Equal(t, int64(1), result.Score)
+
+	assert.True(t, result.Results[0].IsCorrect)
+	assert.Empty(t, result.Results[0].Error)
+	assert.Equal(t, []string{"test"}, result.Results[0].SelectedOptions)
+	assert.Equal(t, deleted, result.Results[1].QuestionId)
+	assert.Equal(t, pkg.ErrQuestionNotFound.Error(), result.Results[1].Error)
+	assert.Equal(t, float64(0), result.Results[1].Points)
+	assert.Equal(t, pkg.ErrQuestionHasNoCorrectOption.Error(), result.Results[2].Error)
+	assert.Empty(t, result.Results[2].CorrectAnswers)
+
+	// neither question that could not be graded is queued for review
+	queue, err := qs.GetReviewQueue(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), queue.Queued)
+
+	review, err := qs.GetQuizReview(ctx, 1, quiz.SessionId)
+	assert.Nil(t, err)
+	assert.Len(t, review.Results, 2)
+	assert.Equal(t, result.Results[0], review.Results[0])
+	assert.Equal(t, result.Results[2], review.Results[1])
+}
+
+func TestSubmitTypedQuestions(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	qs := NewQuizService(repository.NewQuizRepository(pool), subjectRepo, questionRepo, repository.NewScoreRepository(pool), repository.NewQuizSessionRepository(pool), repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+	questionService := NewQuestionService(questionRepo, subjectRepo, repository.NewMediaRepository(pool), testStorage(t), log.New(io.Discard, "", 0))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+	gravity := 9.81
+	for _, question := range []domain.QuestionsData{
+		{Type: domain.QuestionTypeTrueFalse, Name: "Sound travels faster in water than in air.", Answer: "true", Explanation: "Water is denser."},
+		{Type: domain.QuestionTypeNumeric, Name: "What is g?", NumericAnswer: &gravity, Tolerance: 0.05, Unit: "m/s^2", Explanation: "About 9.81 m/s^2."},
+		{Type: domain.QuestionTypeShortText, Name: "What is the unit of force?", Answer: "Newton", Answers: []string{"newtons"}, Explanation: "Newtons."},
+	} {
+		_, err := questionService.CreateQuestion(ctx, subjectId, question)
+		assert.Nil(t, err)
+	}
+
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 3})
+	assert.Nil(t, err)
+	byType := make(map[string]domain.QuizQuestionResponse, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		byType[question.Type] = question
+	}
+	trueFalse, numeric, shortText := byType[domain.QuestionTypeTrueFalse], byType[domain.QuestionTypeNumeric], byType[domain.QuestionTypeShortText]
+	assert.Len(t, trueFalse.Options, 2)
+	assert.Equal(t, domain.TrueOption, trueFalse.Options[0].Option)
+	assert.Equal(t, domain.FalseOption, trueFalse.Options[1].Option)
+	assert.Equal(t, "m/s^2", numeric.Unit)
+	assert.Empty(t, numeric.Options)
+	assert.Empty(t, shortText.Options)
+
+	// an answer given the wrong way for its question is rejected without closing the session
+	value := 9.8
+	_, err = qs.SubmitQuiz(ctx, 1, domain.QuizSubmission{SessionId: quiz.SessionId, Answers: []domain.SubmitQuizRequest{
+		{QuestionId: numeric.QuestionId, Text: "9.8"},
+	}})
+	assert.ErrorIs(t, err, pkg.ErrAnswerDoesNotMatchType)
+
+	result, err := qs.SubmitQuiz(ctx, 1, domain.QuizSubmission{SessionId: quiz.SessionId, Answers: []domain.SubmitQuizRequest{
+		{QuestionId: trueFalse.QuestionId, OptionIds: []int64{trueFalse.Options[0].Id}},
+		{QuestionId: numeric.QuestionId, Value: &value, Unit: "m / s^2"},
+		{QuestionId: shortText.QuestionId, Text: "  NEWTONS "},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), result.CorrectAnswers)
+	results := make(map[int64]domain.QuizResultResponse, len(result.Results))
+	for _, questionResult := range result.Results {
+		results[questionResult.QuestionId] = questionResult
+	}
+	assert.Equal(t, []string{domain.TrueOption}, results[trueFalse.QuestionId].SelectedOptions)
+	assert.Equal(t, "9.8 m / s^2", results[numeric.QuestionId].Response)
+	assert.Equal(t, "9.81 m/s^2", results[numeric.QuestionId].CorrectAnswer)
+	assert.Equal(t, 0.05, results[numeric.QuestionId].Tolerance)
+	assert.Equal(t, "NEWTONS", results[shortText.QuestionId].Response)
+	assert.Equal(t, []string{"Newton", "newtons"}, results[shortText.QuestionId].CorrectAnswers)
+
+	// the typed answers are kept for review
+	review, err := qs.GetQuizReview(ctx, 1, quiz.SessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Results, review.Results)
+}
+
+func TestSubmitOrderingAndMatchingQuestions(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	qs := NewQuizService(repository.NewQuizRepository(pool), subjectRepo, questionRepo, repository.NewScoreRepository(pool), repository.NewQuizSessionRepository(pool), repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+	questionService := NewQuestionService(questionRepo, subjectRepo, repository.NewMediaRepository(pool), testStorage(t), log.New(io.Discard, "", 0))
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "chemistry"})
+	assert.Nil(t, err)
+	steps := []string{"Dissolve", "Filter", "Evaporate"}
+	for _, question := range []domain.QuestionsData{
+		{Type: domain.QuestionTypeOrdering, Name: "Order the steps to purify rock salt.", Options: steps, Explanation: "Dissolve, filter, then evaporate."},
+		{Type: domain.QuestionTypeMatching, Name: "Match each element to its symbol.", Pairs: []domain.QuestionPair{
+			{Option: "Sodium", Match: "Na"}, {Option: "Iron", Match: "Fe"}, {Option: "Lead", Match: "Pb"},
+		}, Explanation: "The symbols come from Latin names."},
+	} {
+		_, err := questionService.CreateQuestion(ctx, subjectId, question)
+		assert.Nil(t, err)
+	}
+
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 2})
+	assert.Nil(t, err)
+	byType := make(map[string]domain.QuizQuestionResponse, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		byType[question.Type] = question
+	}
+	ordering, matching := byType[domain.QuestionTypeOrdering], byType[domain.QuestionTypeMatching]
+	ids := make(map[string]int64)
+	var issued []string
+	for _, option := range ordering.Options {
+		ids[option.Option] = option.Id
+		issued = append(issued, option.Option)
+	}
+	assert.ElementsMatch(t, steps, issued)
+	assert.NotEqual(t, steps, issued, "ordering options are never issued in order")
+	assert.Empty(t, ordering.Matches)
+	assert.Len(t, matching.Options, 3)
+	assert.Len(t, matching.Matches, 3)
+	for _, option := range append(matching.Options, matching.Matches...) {
+		ids[option.Option] = option.Id
+	}
+	pair := func(option, match string) domain.MatchPair {
+		return domain.MatchPair{OptionId: ids[option], MatchId: ids[match]}
+	}
+
+	// a match can only be used once
+	_, err = qs.SubmitQuiz(ctx, 1, domain.QuizSubmission{SessionId: quiz.SessionId, Answers: []domain.SubmitQuizRequest{
+		{QuestionId: matching.QuestionId, Pairs: []domain.MatchPair{pair("Sodium", "Na"), pair("Iron", "Na")}},
+	}})
+	assert.ErrorIs(t, err, pkg.ErrDuplicateQuizAnswer)
+
+	result, err := qs.SubmitQuiz(ctx, 1, domain.QuizSubmission{SessionId: quiz.SessionId, Answers: []domain.SubmitQuizRequest{
+		{QuestionId: ordering.QuestionId, OptionIds: []int64{ids["Dissolve"], ids["Filter"], ids["Evaporate"]}},
+		{QuestionId: matching.QuestionId, Pairs: []domain.MatchPair{pair("Sodium", "Na"), pair("Iron", "Pb"), pair("Lead", "Fe")}},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), result.CorrectAnswers)
+	results := make(map[int64]domain.QuizResultResponse, len(result.Results))
+	for _, questionResult := range result.Results {
+		results[questionResult.QuestionId] = questionResult
+	}
+	assert.True(t, results[ordering.QuestionId].IsCorrect)
+	assert.Equal(t, steps, results[ordering.QuestionId].SelectedOptions)
+	assert.Equal(t, "Dissolve, Filter, Evaporate", results[ordering.QuestionId].CorrectAnswer)
+	assert.False(t, results[matching.QuestionId].IsCorrect)
+	assert.ElementsMatch(t, []string{"Sodium → Na", "Iron → Pb", "Lead → Fe"}, results[matching.QuestionId].SelectedOptions)
+	assert.ElementsMatch(t, []string{"Sodium → Na", "Iron → Fe", "Lead → Pb"}, results[matching.QuestionId].CorrectAnswers)
+
+	// the pairs are kept for review
+	review, err := qs.GetQuizReview(ctx, 1, quiz.SessionId)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Results, review.Results)
+}
+
+func TestGradeSelection(t *testing.T) {
+	tests := []struct {
+		name          string
+		correct       []int64
+		selected      []int64
+		partialCredit bool
+		want          float64
+	}{
+		{name: "single answer correct", correct: []int64{1}, selected: []int64{1}, want: 1},
+		{name: "single answer wrong", correct: []int64{1}, selected: []int64{2}, want: 0},
+		{name: "unanswered", correct: []int64{1}, selected: nil, want: 0},
+		{name: "selecting every option is not correct", correct: []int64{1}, selected: []int64{1, 2, 3}, want: 0},
+		{name: "multi answer exact match", correct: []int64{1, 3}, selected: []int64{3, 1}, want: 1},
+		{name: "multi answer subset without partial credit", correct: []int64{1, 3}, selected: []int64{1}, want: 0},
+		{name: "multi answer subset with partial credit", correct: []int64{1, 3}, selected: []int64{1}, partialCredit: true, want: 0.5},
+		{name: "wrong option cancels a correct one", correct: []int64{1, 3}, selected: []int64{1, 2}, partialCredit: true, want: 0},
+		{name: "select all with partial credit", correct: []int64{1, 2}, selected: []int64{1, 2, 3, 4}, partialCredit: true, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, gradeSelection(tt.correct, tt.selected, tt.partialCredit))
+		})
+	}
 }
 
 func TestCalculateQuizScore(t *testing.T) {
@@ -272,7 +1112,8 @@ func TestCalculateQuizScore(t *testing.T) {
 	questionRepo := repository.NewQuestionRepository(pool)
 	subjectRepo := repository.NewSubjectRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
 	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{
 		Name:      "use of english",
 		UpdatedAt: time.Now(),
@@ -286,30 +1127,44 @@ func TestCalculateQuizScore(t *testing.T) {
 	createdQuiz, err := qr.GetQuizById(ctx, 1)
 	assert.Nil(t, err)
 	fmt.Println("created quiz: ", createdQuiz)
-	quizRequest := []domain.SubmitQuizRequest{
-		{
-			QuestionId:       1,
-			IsMultipleChoice: true,
-			OptionIds:        []int64{1},
-		},
-		{
-			QuestionId:       2,
-			IsMultipleChoice: true,
-			OptionIds:        []int64{2},
-		},
-		{
-			QuestionId:       3,
-			IsMultipleChoice: true,
-			OptionIds:        []int64{1},
-		},
-	}
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 3})
+	assert.Nil(t, err)
+	quizRequest := answerQuiz(t, ctx, questionRepo, quiz, 1)
 	userID := int64(1)
 	result, err := qs.SubmitQuiz(ctx, userID, quizRequest)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(1), result.Score)
 	fmt.Println("result: ", result)
 
-	numOfQuestions := len(quizRequest)
+	numOfQuestions := len(quizRequest.Answers)
 	finalScore := qs.CalculateQuizScore(ctx, int64(numOfQuestions), result.Score)
 	assert.Equal(t, int64(33), finalScore)
 }
+
+// answerQuiz builds a submission for a generated quiz where the first numCorrect
+// questions are answered correctly and the rest are answered with a wrong option.
+func answerQuiz(t *testing.T, ctx context.Context, questionRepo repository.QuestionRepository, quiz *domain.GeneratedQuizResponse, numCorrect int) domain.QuizSubmission {
+	t.Helper()
+	answers := make([]domain.SubmitQuizRequest, 0, len(quiz.Questions))
+	for i, question := range quiz.Questions {
+		correctOption, err := questionRepo.GetCorrectQuestionOptionByQuestionID(ctx, question.QuestionId)
+		if err != nil {
+			t.Fatal("failed to get correct option")
+		}
+		optionId := correctOption.Id
+		if i >= numCorrect {
+			for _, option := range question.Options {
+				if option.Id != correctOption.Id {
+					optionId = option.Id
+					break
+				}
+			}
+		}
+		answers = append(answers, domain.SubmitQuizRequest{
+			QuestionId:       question.QuestionId,
+			IsMultipleChoice: question.IsMultipleChoice,
+			OptionIds:        []int64{optionId},
+		})
+	}
+	return domain.QuizSubmission{SessionId: quiz.SessionId, Answers: answers}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+)
+
+// Missed questions are scheduled for review with the SM-2 algorithm. Every question
+// starts with ReviewInitialEaseFactor, which never drops below ReviewMinEaseFactor.
+const (
+	ReviewInitialEaseFactor = 2.5
+	ReviewMinEaseFactor     = 1.3
+	// ReviewPassingQuality is the lowest answer quality, from 0 to 5, that counts as remembered
+	ReviewPassingQuality = 3
+)
+
+// reviewQuality grades how well a question was answered on SM-2's 0 to 5 scale from the
+// credit it earned: 5 for a correct answer, 0 for a wrong or missing one.
+func reviewQuality(credit float64) int {
+	return int(math.Round(credit * 5))
+}
+
+// scheduleReview applies one SM-2 repetition to a review item answered with the given
+// quality at now. A lapse starts the item over and brings it back the next day; each
+// successful recall pushes it out by 1 day, then 6 days, then by its ease factor.
+func scheduleReview(item repository.ReviewItem, quality int, now time.Time) repository.ReviewItem {
+	if item.EaseFactor == 0 {
+		item.EaseFactor = ReviewInitialEaseFactor
+	}
+	if quality < ReviewPassingQuality {
+		item.Repetitions = 0
+		item.IntervalDays = 1
+	} else {
+		item.Repetitions++
+		switch item.Repetitions {
+		case 1:
+			item.IntervalDays = 1
+		case 2:
+			item.IntervalDays = 6
+		default:
+			item.IntervalDays = int(math.Round(float64(item.IntervalDays) * item.EaseFactor))
+		}
+	}
+
+	lapse := float64(5 - quality)
+	item.EaseFactor = math.Max(ReviewMinEaseFactor, item.EaseFactor+0.1-lapse*(0.08+lapse*0.02))
+	item.DueAt = now.AddDate(0, 0, item.IntervalDays)
+	item.LastReviewedAt = now
+	item.UpdatedAt = now
+	return item
+}
+
+// reviewUpdates works out the review queue changes for a user's graded answers.
+// Questions already in the queue are rescheduled by how they were answered, and
+// missed questions that are not in the queue yet are added to it.
+func reviewUpdates(userID int64, queued []repository.ReviewItem, answers []domain.QuizAnswer, now time.Time) []repository.ReviewItem {
+	items := make(map[int64]repository.ReviewItem, len(queued))
+	for _, item := range queued {
+		items[item.QuestionId] = item
+	}
+
+	updates := make([]repository.ReviewItem, 0, len(answers))
+	for _, answer := range answers {
+		item, ok := items[answer.QuestionId]
+		if !ok {
+			if answer.IsCorrect {
+				continue
+			}
+			item = repository.ReviewItem{
+				UserId:     userID,
+				QuestionId: answer.QuestionId,
+				SubjectId:  answer.SubjectId,
+				CreatedAt:  now,
+			}
+		}
+		updates = append(updates, scheduleReview(item, reviewQuality(answer.Credit), now))
+	}
+	return updates
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleReview(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		item             repository.ReviewItem
+		quality          int
+		wantRepetitions  int
+		wantIntervalDays int
+		wantEaseFactor   float64
+	}{
+		{
+			name:             "new item missed",
+			item:             repository.ReviewItem{},
+			quality:          0,
+			wantRepetitions:  0,
+			wantIntervalDays: 1,
+			wantEaseFactor:   1.7,
+		},
+		{
+			name:             "first recall",
+			item:             repository.ReviewItem{EaseFactor: 2.5},
+			quality:          5,
+			wantRepetitions:  1,
+			wantIntervalDays: 1,
+			wantEaseFactor:   2.6,
+		},
+		{
+			name:             "second recall",
+			item:             repository.ReviewItem{EaseFactor: 2.5, Repetitions: 1, IntervalDays: 1},
+			quality:          5,
+			wantRepetitions:  2,
+			wantIntervalDays: 6,
+			wantEaseFactor:   2.6,
+		},
+		{
+			name:             "later recall grows by ease factor",
+			item:             repository.ReviewItem{EaseFactor: 2.5, Repetitions: 2, IntervalDays: 6},
+			quality:          4,
+			wantRepetitions:  3,
+			wantIntervalDays: 15,
+			wantEaseFactor:   2.5,
+		},
+		{
+			name:             "lapse starts over",
+			item:             repository.ReviewItem{EaseFactor: 2.5, Repetitions: 4, IntervalDays: 40},
+			quality:          2,
+			wantRepetitions:  0,
+			wantIntervalDays: 1,
+			wantEaseFactor:   2.18,
+		},
+		{
+			name:             "ease factor floor",
+			item:             repository.ReviewItem{EaseFactor: 1.4},
+			quality:          0,
+			wantRepetitions:  0,
+			wantIntervalDays: 1,
+			wantEaseFactor:   ReviewMinEaseFactor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scheduleReview(tt.item, tt.quality, now)
+			assert.Equal(t, tt.wantRepetitions, got.Repetitions)
+			assert.Equal(t, tt.wantIntervalDays, got.IntervalDays)
+			assert.InDelta(t, tt.wantEaseFactor, got.EaseFactor, 0.0001)
+			assert.Equal(t, now.AddDate(0, 0, tt.wantIntervalDays), got.DueAt)
+			assert.Equal(t, now, got.LastReviewedAt)
+		})
+	}
+}
+
+func TestReviewUpdates(t *testing.T) {
+	now := time.Now()
+	queued := []repository.ReviewItem{{UserId: 1, QuestionId: 3, SubjectId: 1, EaseFactor: 2.5, Repetitions: 1, IntervalDays: 1}}
+	answers := []domain.QuizAnswer{
+		{QuestionId: 1, SubjectId: 1, IsCorrect: true, Credit: 1},
+		{QuestionId: 2, SubjectId: 1, Credit: 0},
+		{QuestionId: 3, SubjectId: 1, IsCorrect: true, Credit: 1},
+		{QuestionId: 4, SubjectId: 2, Credit: 0.5},
+	}
+
+	updates := reviewUpdates(1, queued, answers, now)
+	assert.Len(t, updates, 3)
+
+	// a question answered correctly that was not queued stays out of the queue
+	assert.Equal(t, int64(2), updates[0].QuestionId)
+	assert.Equal(t, now, updates[0].CreatedAt)
+	assert.Equal(t, now.AddDate(0, 0, 1), updates[0].DueAt)
+	assert.Equal(t, int64(3), updates[1].QuestionId)
+	assert.Equal(t, 2, updates[1].Repetitions)
+	assert.Equal(t, 6, updates[1].IntervalDays)
+	assert.Equal(t, int64(4), updates[2].QuestionId)
+	assert.Equal(t, int64(2), updates[2].SubjectId)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/richtext"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// canonicalQuestionText checks the rich text of an authored question and returns the
+// question with it in canonical form: its name and explanation, the options and correct
+// answers of choice and ordering questions, and the pairs of matching questions. The
+// answers of true/false and short text questions are typed rather than read, so they are
+// left as they are.
+func canonicalQuestionText(question domain.QuestionsData) (domain.QuestionsData, error) {
+	var err error
+	if question.Name, err = canonicalText("name", question.Name); err != nil {
+		return question, err
+	}
+	if question.Explanation, err = canonicalText("explanation", question.Explanation); err != nil {
+		return question, err
+	}
+	switch question.QuestionType() {
+	case domain.QuestionTypeChoice, domain.QuestionTypeOrdering:
+		question.Options = slices.Clone(question.Options)
+		for i := range question.Options {
+			if question.Options[i], err = canonicalText(fmt.Sprintf("options[%d]", i), question.Options[i]); err != nil {
+				return question, err
+			}
+			if question.Options[i] == "" {
+				return question, pkg.ErrQuestionOptionTextNotFound
+			}
+		}
+		if question.Answer, err = canonicalText("answer", question.Answer); err != nil {
+			return question, err
+		}
+		question.Answers = slices.Clone(question.Answers)
+		for i := range question.Answers {
+			if question.Answers[i], err = canonicalText(fmt.Sprintf("answers[%d]", i), question.Answers[i]); err != nil {
+				return question, err
+			}
+		}
+	case domain.QuestionTypeMatching:
+		question.Pairs = slices.Clone(question.Pairs)
+		for i := range question.Pairs {
+			if question.Pairs[i].Option, err = canonicalText(fmt.Sprintf("pairs[%d].option", i), question.Pairs[i].Option); err != nil {
+				return question, err
+			}
+			if question.Pairs[i].Match, err = canonicalText(fmt.Sprintf("pairs[%d].match", i), question.Pairs[i].Match); err != nil {
+				return question, err
+			}
+		}
+	}
+	return question, nil
+}
+
+// canonicalText returns rich text in canonical form, or pkg.ErrInvalidRichText saying
+// which field is wrong and why.
+func canonicalText(field string, text string) (string, error) {
+	canonical, err := richtext.Canonical(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", pkg.ErrInvalidRichText, field, err)
+	}
+	return canonical, nil
+}
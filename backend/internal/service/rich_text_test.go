@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/internal/richtext"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRichTextQuestions(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	mediaRepo := repository.NewMediaRepository(pool)
+	store := testStorage(t)
+	questionService := NewQuestionService(questionRepo, subjectRepo, mediaRepo, store, log.New(io.Discard, "", 0))
+	qs := NewQuizService(repository.NewQuizRepository(pool), subjectRepo, questionRepo, repository.NewScoreRepository(pool), repository.NewQuizSessionRepository(pool), repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), mediaRepo, store)
+
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	questionId, err := questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Name:        "Solve $x^2 = 4$ for **positive** $x$.  \r\n",
+		Options:     []string{"$x = 2$ ", "$x = 4$"},
+		Answer:      "$x = 2$",
+		Explanation: "Take the square root:\n\n$$\n\\sqrt{4} = 2\n$$",
+	})
+	assert.Nil(t, err)
+
+	// stored in canonical form
+	stored, err := questionRepo.GetQuestionById(ctx, questionId)
+	assert.Nil(t, err)
+	assert.Equal(t, "Solve $x^2 = 4$ for **positive** $x$.", stored.Question)
+	question, err := questionService.GetQuestionById(ctx, questionId)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"$x = 2$", "$x = 4$"}, question.Option)
+	assert.Equal(t, `<p>Solve <span class="math inline">\(x^2 = 4\)</span> for <strong>positive</strong> <span class="math inline">\(x\)</span>.</p>`, question.TextHTML)
+	assert.Equal(t, []string{`<p><span class="math inline">\(x = 2\)</span></p>`, `<p><span class="math inline">\(x = 4\)</span></p>`}, question.OptionHTML)
+
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: 1})
+	assert.Nil(t, err)
+	issued := quiz.Questions[0]
+	assert.Equal(t, question.TextHTML, issued.QuestionHTML)
+	var correctId int64
+	for _, option := range issued.Options {
+		assert.Equal(t, richtext.HTML(option.Option), option.OptionHTML)
+		if option.Option == "$x = 2$" {
+			correctId = option.Id
+		}
+	}
+	result, err := qs.SubmitQuiz(ctx, 1, domain.QuizSubmission{SessionId: quiz.SessionId, Answers: []domain.SubmitQuizRequest{{QuestionId: questionId, OptionIds: []int64{correctId}}}})
+	assert.Nil(t, err)
+	assert.True(t, result.Results[0].IsCorrect)
+	assert.Equal(t, question.TextHTML, result.Results[0].QuestionHTML)
+	assert.Equal(t, "<p>Take the square root:</p>\n<p><span class=\"math display\">\\[\\sqrt{4} = 2\\]</span></p>", result.Results[0].ExplanationHTML)
+
+	tests := []struct {
+		name     string
+		question domain.QuestionsData
+		err      error
+		field    string
+	}{
+		{name: "html in the name", question: domain.QuestionsData{Name: `<img src=x onerror="alert(1)">`, Options: []string{"A", "B"}, Answer: "A", Explanation: "A"}, err: richtext.ErrHTMLNotAllowed, field: "name"},
+		{name: "unclosed math in an option", question: domain.QuestionsData{Name: "Pick one", Options: []string{"A", "$x"}, Answer: "A", Explanation: "A"}, err: richtext.ErrStrayDollar, field: "options[1]"},
+		{name: "blocked command in the explanation", question: domain.QuestionsData{Name: "Pick one", Options: []string{"A", "B"}, Answer: "A", Explanation: `$\href{javascript:alert(1)}{A}$`}, err: richtext.ErrMathCommandNotAllowed, field: "explanation"},
+		{name: "unbalanced braces in a pair", question: domain.QuestionsData{Type: domain.QuestionTypeMatching, Name: "Match", Pairs: []domain.QuestionPair{{Option: "A", Match: `$\frac{1}{2$`}, {Option: "B", Match: "C"}}, Explanation: "A"}, err: richtext.ErrUnbalancedBraces, field: "pairs[0].match"},
+		{name: "unsafe link in the name", question: domain.QuestionsData{Name: "[Pick](javascript:alert(1))", Options: []string{"A", "B"}, Answer: "A", Explanation: "A"}, err: richtext.ErrUnsafeLink, field: "name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := questionService.CreateQuestion(ctx, subjectId, tt.question)
+			assert.ErrorIs(t, err, pkg.ErrInvalidRichText)
+			assert.ErrorIs(t, err, tt.err)
+			assert.ErrorContains(t, err, tt.field)
+		})
+	}
+
+	// a short text answer is typed, not read, so a $ in it is fine
+	_, err = questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+		Type:        domain.QuestionTypeShortText,
+		Name:        "Which symbol marks math?",
+		Answer:      "$",
+		Explanation: `The \$ sign.`,
+	})
+	assert.Nil(t, err)
+	// an option that is blank once in canonical form is no option
+	_, err = questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{Name: "Pick one", Options: []string{"A", " \n "}, Answer: "A", Explanation: "A"})
+	assert.ErrorIs(t, err, pkg.ErrQuestionOptionTextNotFound)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/lawson/otterprep/domain"
+)
+
+// QuestionOutcome is how a single issued question was answered, as seen by a Scorer.
+type QuestionOutcome struct {
+	Answered bool
+	Credit   float64 // 0 to 1, from gradeSelection
+	Weight   float64
+}
+
+// Scorer turns the outcome of a question into points.
+type Scorer interface {
+	Points(outcome QuestionOutcome) float64
+}
+
+// ScorerFunc adapts a plain function to a Scorer.
+type ScorerFunc func(outcome QuestionOutcome) float64
+
+func (f ScorerFunc) Points(outcome QuestionOutcome) float64 {
+	return f(outcome)
+}
+
+// scorers builds a Scorer for every known policy name.
+var scorers = map[string]func(policy domain.ScoringPolicy) Scorer{
+	// standard awards the earned credit scaled by the question weight.
+	domain.ScoringStandard: func(policy domain.ScoringPolicy) Scorer {
+		return ScorerFunc(func(outcome QuestionOutcome) float64 {
+			return outcome.Credit * outcome.Weight
+		})
+	},
+	// negative_marking takes WrongPenalty off for a wrong answer and UnansweredPenalty
+	// off for a skipped question. Partially correct answers are not penalised.
+	domain.ScoringNegativeMarking: func(policy domain.ScoringPolicy) Scorer {
+		return ScorerFunc(func(outcome QuestionOutcome) float64 {
+			if !outcome.Answered {
+				return -policy.UnansweredPenalty * outcome.Weight
+			}
+			if outcome.Credit == 0 {
+				return -policy.WrongPenalty * outcome.Weight
+			}
+			return outcome.Credit * outcome.Weight
+		})
+	},
+	// all_or_nothing ignores partial credit.
+	domain.ScoringAllOrNothing: func(policy domain.ScoringPolicy) Scorer {
+		return ScorerFunc(func(outcome QuestionOutcome) float64 {
+			if outcome.Credit < 1 {
+				return 0
+			}
+			return outcome.Weight
+		})
+	},
+}
+
+// NewScorer returns the Scorer for a scoring policy.
+func NewScorer(policy domain.ScoringPolicy) (Scorer, error) {
+	build, ok := scorers[policy.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scoring policy %q", policy.Name)
+	}
+	return build(policy), nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScorers(t *testing.T) {
+	negativeMarking := domain.ScoringPolicy{Name: domain.ScoringNegativeMarking, WrongPenalty: 0.25, UnansweredPenalty: 0.1}
+	tests := []struct {
+		name    string
+		policy  domain.ScoringPolicy
+		outcome QuestionOutcome
+		want    float64
+	}{
+		{"standard correct", domain.DefaultScoringPolicy(), QuestionOutcome{Answered: true, Credit: 1, Weight: 2}, 2},
+		{"standard partial", domain.DefaultScoringPolicy(), QuestionOutcome{Answered: true, Credit: 0.5, Weight: 2}, 1},
+		{"standard wrong", domain.DefaultScoringPolicy(), QuestionOutcome{Answered: true, Credit: 0, Weight: 1}, 0},
+		{"negative marking correct", negativeMarking, QuestionOutcome{Answered: true, Credit: 1, Weight: 1}, 1},
+		{"negative marking wrong", negativeMarking, QuestionOutcome{Answered: true, Credit: 0, Weight: 2}, -0.5},
+		{"negative marking unanswered", negativeMarking, QuestionOutcome{Answered: false, Credit: 0, Weight: 1}, -0.1},
+		{"negative marking partial", negativeMarking, QuestionOutcome{Answered: true, Credit: 0.5, Weight: 1}, 0.5},
+		{"all or nothing correct", domain.ScoringPolicy{Name: domain.ScoringAllOrNothing}, QuestionOutcome{Answered: true, Credit: 1, Weight: 3}, 3},
+		{"all or nothing partial", domain.ScoringPolicy{Name: domain.ScoringAllOrNothing}, QuestionOutcome{Answered: true, Credit: 0.5, Weight: 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorer, err := NewScorer(tt.policy)
+			assert.Nil(t, err)
+			assert.InDelta(t, tt.want, scorer.Points(tt.outcome), 1e-9)
+		})
+	}
+
+	_, err := NewScorer(domain.ScoringPolicy{Name: "bonus"})
+	assert.NotNil(t, err)
+}
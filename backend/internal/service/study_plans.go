@@ -0,0 +1,381 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+)
+
+const (
+	// DefaultStudyDailyQuestions is how many questions a day a study plan asks for when
+	// no number is given
+	DefaultStudyDailyQuestions = 20
+	// StudyTaskMinQuestions is the fewest questions a study task asks for, so a day covers
+	// at most DailyQuestions / StudyTaskMinQuestions subjects
+	StudyTaskMinQuestions = 5
+	// StudyExamWeekDays is how many days before the exam tasks turn into timed exams
+	StudyExamWeekDays = 7
+	// studyBaseWeight keeps subjects already at the target in the schedule
+	studyBaseWeight = 10
+)
+
+type StudyPlanService interface {
+	CreateStudyPlan(ctx context.Context, userID int64, data domain.StudyPlanData) (*domain.StudyPlan, error)
+	GetStudyPlan(ctx context.Context, userID int64) (*domain.StudyPlan, error)
+	GetStudyPlanToday(ctx context.Context, userID int64) (*domain.StudyDay, error)
+	DeleteStudyPlan(ctx context.Context, userID int64) error
+}
+
+// studyPlanService schedules a user's study towards their exam. Each day's tasks are
+// worked out from how the user is doing in each subject and the days left, and fixed the
+// first time the day is looked at, so adherence is measured against what was asked for.
+// Days are calendar days in UTC, as for daily challenges.
+type studyPlanService struct {
+	studyPlanRepository   repository.StudyPlanRepository
+	subjectRepository     repository.SubjectRepository
+	scoreRepository       repository.ScoreRepository
+	reviewQueueRepository repository.ReviewQueueRepository
+}
+
+func NewStudyPlanService(studyPlanRepository repository.StudyPlanRepository, subjectRepository repository.SubjectRepository, scoreRepository repository.ScoreRepository, reviewQueueRepository repository.ReviewQueueRepository) *studyPlanService {
+	return &studyPlanService{
+		studyPlanRepository:   studyPlanRepository,
+		subjectRepository:     subjectRepository,
+		scoreRepository:       scoreRepository,
+		reviewQueueRepository: reviewQueueRepository,
+	}
+}
+
+// CreateStudyPlan sets the user's study goal, replacing the plan they had.
+func (ss *studyPlanService) CreateStudyPlan(ctx context.Context, userID int64, data domain.StudyPlanData) (*domain.StudyPlan, error) {
+	now := time.Now()
+	examDate, err := time.Parse(time.DateOnly, data.ExamDate)
+	if err != nil || !examDate.After(dailyDate(now)) {
+		return nil, pkg.ErrInvalidExamDate
+	}
+	var subjectIds []int64
+	for _, subjectId := range data.SubjectIds {
+		if slices.Contains(subjectIds, subjectId) {
+			continue
+		}
+		if _, err := ss.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+			fmt.Println("error getting subject: ", err)
+			return nil, pkg.ErrSubjectNotFound
+		}
+		subjectIds = append(subjectIds, subjectId)
+	}
+	if data.DailyQuestions == 0 {
+		data.DailyQuestions = DefaultStudyDailyQuestions
+	}
+
+	_, err = ss.studyPlanRepository.SaveStudyPlan(ctx, repository.StudyPlan{
+		UserId:         userID,
+		ExamDate:       examDate,
+		SubjectIds:     subjectIds,
+		TargetAccuracy: data.TargetAccuracy,
+		DailyQuestions: data.DailyQuestions,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+	if err != nil {
+		fmt.Println("error saving study plan: ", err)
+		return nil, err
+	}
+	return ss.GetStudyPlan(ctx, userID)
+}
+
+// GetStudyPlan returns the user's study plan with how they are doing in its subjects and
+// every day of its schedule so far, today's included.
+func (ss *studyPlanService) GetStudyPlan(ctx context.Context, userID int64) (*domain.StudyPlan, error) {
+	now := time.Now()
+	plan, err := ss.studyPlanRepository.GetUserStudyPlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	subjects, err := ss.planSubjects(ctx, plan, now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ss.fixToday(ctx, plan, subjects, now); err != nil {
+		return nil, err
+	}
+	days, err := ss.planDays(ctx, plan, now)
+	if err != nil {
+		return nil, err
+	}
+
+	today := dailyDate(now)
+	var adherence domain.StudyAdherence
+	for _, day := range days {
+		if day.Date == today.Format(time.DateOnly) && !day.Met {
+			continue
+		}
+		adherence.DaysPlanned++
+		if day.Met {
+			adherence.DaysMet++
+		}
+	}
+	if adherence.DaysPlanned > 0 {
+		adherence.Percent = float64(adherence.DaysMet) / float64(adherence.DaysPlanned) * 100
+	}
+	return &domain.StudyPlan{
+		Id:             plan.Id,
+		ExamDate:       plan.ExamDate.Format(time.DateOnly),
+		TargetAccuracy: plan.TargetAccuracy,
+		DailyQuestions: plan.DailyQuestions,
+		DaysLeft:       max(daysBetween(today, plan.ExamDate), 0),
+		Subjects:       subjects,
+		Adherence:      adherence,
+		Days:           days,
+		CreatedAt:      plan.CreatedAt,
+	}, nil
+}
+
+// GetStudyPlanToday returns today's tasks of the user's study plan and how much of them
+// has been done. There are none from the day of the exam on.
+func (ss *studyPlanService) GetStudyPlanToday(ctx context.Context, userID int64) (*domain.StudyDay, error) {
+	now := time.Now()
+	plan, err := ss.studyPlanRepository.GetUserStudyPlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	subjects, err := ss.planSubjects(ctx, plan, now)
+	if err != nil {
+		return nil, err
+	}
+	today, err := ss.fixToday(ctx, plan, subjects, now)
+	if err != nil {
+		return nil, err
+	}
+	answered, err := ss.answeredByDay(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	day := studyDay(plan, today.Day, today.Tasks, answered[today.Day])
+	return &day, nil
+}
+
+// DeleteStudyPlan removes the user's study plan.
+func (ss *studyPlanService) DeleteStudyPlan(ctx context.Context, userID int64) error {
+	return ss.studyPlanRepository.DeleteUserStudyPlan(ctx, userID)
+}
+
+// planSubjects returns how the user is doing in each subject of their plan, over their
+// last AdaptiveHistoryQuizzes quizzes in it.
+func (ss *studyPlanService) planSubjects(ctx context.Context, plan *repository.StudyPlan, now time.Time) ([]domain.StudyPlanSubject, error) {
+	queues, err := ss.reviewQueueRepository.GetReviewQueue(ctx, plan.UserId, now)
+	if err != nil {
+		fmt.Println("error getting review queue: ", err)
+		return nil, err
+	}
+	subjects := make([]domain.StudyPlanSubject, 0, len(plan.SubjectIds))
+	for _, subjectId := range plan.SubjectIds {
+		subject, err := ss.subjectRepository.GetSubjectById(ctx, subjectId)
+		if err != nil {
+			// A subject removed since the plan was made drops out of it
+			fmt.Println("error getting subject: ", err)
+			continue
+		}
+		correct, total, err := ss.scoreRepository.GetUserRecentSubjectAccuracy(ctx, plan.UserId, subjectId, AdaptiveHistoryQuizzes)
+		if err != nil {
+			fmt.Println("error getting recent accuracy: ", err)
+			return nil, err
+		}
+		planSubject := domain.StudyPlanSubject{SubjectId: subjectId, SubjectName: subject.Name, QuestionsAnswered: total}
+		if total > 0 {
+			planSubject.Accuracy = float64(correct) / float64(total) * 100
+		}
+		planSubject.OnTarget = total >= AdaptiveMinQuestions && planSubject.Accuracy >= plan.TargetAccuracy
+		for _, queue := range queues {
+			if queue.SubjectId == subjectId {
+				planSubject.ReviewsDue = queue.Due
+			}
+		}
+		subjects = append(subjects, planSubject)
+	}
+	return subjects, nil
+}
+
+// fixToday returns today's schedule of the plan, working it out if it has not been yet.
+func (ss *studyPlanService) fixToday(ctx context.Context, plan *repository.StudyPlan, subjects []domain.StudyPlanSubject, now time.Time) (*repository.StudyPlanDay, error) {
+	today := dailyDate(now)
+	day, err := ss.studyPlanRepository.SaveStudyPlanDay(ctx, plan.Id, repository.StudyPlanDay{
+		Day:   today,
+		Tasks: scheduleStudyDay(plan, subjects, today),
+	}, now)
+	if err != nil {
+		fmt.Println("error saving study plan day: ", err)
+		return nil, err
+	}
+	day.Day = asDate(day.Day)
+	return day, nil
+}
+
+// planDays returns every day of the plan from the day it was made to today, or to the day
+// before the exam if that has passed.
+func (ss *studyPlanService) planDays(ctx context.Context, plan *repository.StudyPlan, now time.Time) ([]domain.StudyDay, error) {
+	fixed, err := ss.studyPlanRepository.GetStudyPlanDays(ctx, plan.Id)
+	if err != nil {
+		fmt.Println("error getting study plan days: ", err)
+		return nil, err
+	}
+	tasks := make(map[time.Time][]domain.StudyTask, len(fixed))
+	for _, day := range fixed {
+		tasks[asDate(day.Day)] = day.Tasks
+	}
+	answered, err := ss.answeredByDay(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	last := asDate(plan.ExamDate).AddDate(0, 0, -1)
+	if today := dailyDate(now); today.Before(last) {
+		last = today
+	}
+	days := []domain.StudyDay{}
+	for day := dailyDate(plan.CreatedAt); !day.After(last); day = day.AddDate(0, 0, 1) {
+		days = append(days, studyDay(plan, day, tasks[day], answered[day]))
+	}
+	return days, nil
+}
+
+// answeredByDay returns how many questions of each subject the user has answered on each
+// day since the plan was made.
+func (ss *studyPlanService) answeredByDay(ctx context.Context, plan *repository.StudyPlan) (map[time.Time]map[int64]int64, error) {
+	quizzes, err := ss.scoreRepository.GetUserAnsweredQuestionsSince(ctx, plan.UserId, dailyDate(plan.CreatedAt).Local())
+	if err != nil {
+		fmt.Println("error getting answered questions: ", err)
+		return nil, err
+	}
+	answered := map[time.Time]map[int64]int64{}
+	for _, quiz := range quizzes {
+		day := dailyDate(quiz.AnsweredAt)
+		if answered[day] == nil {
+			answered[day] = map[int64]int64{}
+		}
+		answered[day][quiz.SubjectId] += quiz.TotalQuestions
+	}
+	return answered, nil
+}
+
+// studyDay fills in how much of a day's tasks the questions answered that day have done.
+// A day without tasks was never looked at, and is held to the plan's daily questions.
+func studyDay(plan *repository.StudyPlan, day time.Time, tasks []domain.StudyTask, answered map[int64]int64) domain.StudyDay {
+	studyDay := domain.StudyDay{Date: day.Format(time.DateOnly), Tasks: []domain.StudyTask{}}
+	for _, subjectId := range plan.SubjectIds {
+		studyDay.Answered += answered[subjectId]
+	}
+	if len(tasks) == 0 {
+		if day.Before(asDate(plan.ExamDate)) {
+			studyDay.TargetQuestions = plan.DailyQuestions
+		}
+		studyDay.Met = studyDay.Answered >= studyDay.TargetQuestions
+		return studyDay
+	}
+	studyDay.Met = true
+	for _, task := range tasks {
+		task.Answered = answered[task.SubjectId]
+		task.Done = task.Answered >= task.NumOfQuestions
+		studyDay.Met = studyDay.Met && task.Done
+		studyDay.TargetQuestions += task.NumOfQuestions
+		studyDay.Tasks = append(studyDay.Tasks, task)
+	}
+	return studyDay
+}
+
+// scheduleStudyDay works out the tasks of a day of a plan. The day's questions are shared
+// between the subjects by how far each is below the target accuracy, a subject without
+// enough answers to tell counting as the whole target below, and each gets at least
+// StudyTaskMinQuestions. When there are more subjects than that allows they take turns,
+// weakest first. The day before the exam is a light day of half the questions, and there
+// are no tasks from the exam on.
+func scheduleStudyDay(plan *repository.StudyPlan, subjects []domain.StudyPlanSubject, day time.Time) []domain.StudyTask {
+	daysLeft := daysBetween(day, plan.ExamDate)
+	if daysLeft <= 0 || len(subjects) == 0 {
+		return []domain.StudyTask{}
+	}
+	budget := plan.DailyQuestions
+	if daysLeft == 1 {
+		budget = max(budget/2, StudyTaskMinQuestions)
+	}
+
+	weight := func(subject domain.StudyPlanSubject) float64 {
+		gap := plan.TargetAccuracy
+		if subject.QuestionsAnswered >= AdaptiveMinQuestions {
+			gap = max(plan.TargetAccuracy-subject.Accuracy, 0)
+		}
+		return gap + studyBaseWeight
+	}
+	ranked := slices.Clone(subjects)
+	slices.SortStableFunc(ranked, func(a, b domain.StudyPlanSubject) int {
+		if wa, wb := weight(a), weight(b); wa != wb {
+			if wa > wb {
+				return -1
+			}
+			return 1
+		}
+		return int(a.SubjectId - b.SubjectId)
+	})
+	picked := ranked
+	if perDay := int(budget / StudyTaskMinQuestions); len(ranked) > perDay {
+		offset := int(daysBetween(dailyDate(plan.CreatedAt), day)) * perDay
+		picked = make([]domain.StudyPlanSubject, perDay)
+		for i := range picked {
+			picked[i] = ranked[(offset+i)%len(ranked)]
+		}
+	}
+
+	// Every task gets the minimum, and the rest is shared by weight, largest remainders first
+	spare := budget - int64(len(picked))*StudyTaskMinQuestions
+	var totalWeight float64
+	for _, subject := range picked {
+		totalWeight += weight(subject)
+	}
+	shares := make([]int64, len(picked))
+	remainders := make([]float64, len(picked))
+	var shared int64
+	for i, subject := range picked {
+		exact := float64(spare) * weight(subject) / totalWeight
+		shares[i] = int64(exact)
+		remainders[i] = exact - float64(shares[i])
+		shared += shares[i]
+	}
+	for ; shared < spare; shared++ {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		shares[largest]++
+		remainders[largest] = -1
+	}
+
+	tasks := make([]domain.StudyTask, len(picked))
+	for i, subject := range picked {
+		task := domain.StudyTask{
+			SubjectId:      subject.SubjectId,
+			SubjectName:    subject.SubjectName,
+			NumOfQuestions: StudyTaskMinQuestions + shares[i],
+		}
+		switch {
+		case daysLeft <= StudyExamWeekDays:
+			task.Mode, task.Reason = domain.ModeExam, domain.StudyReasonExamWeek
+		case subject.ReviewsDue*2 >= task.NumOfQuestions:
+			task.Mode, task.Reason = domain.ModeReview, domain.StudyReasonReviewsDue
+		case subject.QuestionsAnswered < AdaptiveMinQuestions:
+			task.Mode, task.Reason = domain.ModePractice, domain.StudyReasonNotStarted
+		case !subject.OnTarget:
+			task.Mode, task.Reason = domain.ModeAdaptive, domain.StudyReasonBelowTarget
+		default:
+			task.Mode, task.Reason = domain.ModePractice, domain.StudyReasonOnTarget
+		}
+		tasks[i] = task
+	}
+	return tasks
+}
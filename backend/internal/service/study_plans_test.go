@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleStudyDay(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	plan := &repository.StudyPlan{
+		ExamDate:       start.AddDate(0, 0, 30),
+		SubjectIds:     []int64{1, 2, 3},
+		TargetAccuracy: 80,
+		DailyQuestions: 20,
+		CreatedAt:      start,
+	}
+	subjects := []domain.StudyPlanSubject{
+		{SubjectId: 1, SubjectName: "english"},
+		{SubjectId: 2, SubjectName: "maths", QuestionsAnswered: 20, Accuracy: 90, OnTarget: true},
+		{SubjectId: 3, SubjectName: "physics", QuestionsAnswered: 20, Accuracy: 50},
+	}
+
+	// the questions go to the subjects furthest below the target, each getting the minimum
+	tasks := scheduleStudyDay(plan, subjects, start)
+	assert.Equal(t, []domain.StudyTask{
+		{SubjectId: 1, SubjectName: "english", Mode: domain.ModePractice, NumOfQuestions: 8, Reason: domain.StudyReasonNotStarted},
+		{SubjectId: 3, SubjectName: "physics", Mode: domain.ModeAdaptive, NumOfQuestions: 7, Reason: domain.StudyReasonBelowTarget},
+		{SubjectId: 2, SubjectName: "maths", Mode: domain.ModePractice, NumOfQuestions: 5, Reason: domain.StudyReasonOnTarget},
+	}, tasks)
+
+	// due reviews take priority over the subject's accuracy
+	subjects[1].ReviewsDue = 3
+	tasks = scheduleStudyDay(plan, subjects, start)
+	assert.Equal(t, domain.ModeReview, tasks[2].Mode)
+	assert.Equal(t, domain.StudyReasonReviewsDue, tasks[2].Reason)
+
+	// the last week is timed exams
+	for _, task := range scheduleStudyDay(plan, subjects, start.AddDate(0, 0, 25)) {
+		assert.Equal(t, domain.ModeExam, task.Mode)
+		assert.Equal(t, domain.StudyReasonExamWeek, task.Reason)
+	}
+
+	// the day before the exam is half the questions, and with room for fewer subjects than
+	// the plan has they take turns
+	eve := start.AddDate(0, 0, 29)
+	tasks = scheduleStudyDay(plan, subjects, eve)
+	assert.Len(t, tasks, 2)
+	var total int64
+	for _, task := range tasks {
+		total += task.NumOfQuestions
+	}
+	assert.Equal(t, int64(10), total)
+	next := scheduleStudyDay(plan, subjects, eve.AddDate(0, 0, -1))
+	assert.NotEqual(t, tasks[0].SubjectId, next[0].SubjectId)
+
+	// there is nothing to do from the exam on
+	assert.Empty(t, scheduleStudyDay(plan, subjects, plan.ExamDate))
+	assert.Empty(t, scheduleStudyDay(plan, subjects, plan.ExamDate.AddDate(0, 0, 1)))
+}
+
+func TestStudyPlan(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	scoreRepo := repository.NewScoreRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, scoreRepo, repository.NewQuizSessionRepository(pool), repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+	ss := NewStudyPlanService(repository.NewStudyPlanRepository(pool), subjectRepo, scoreRepo, repository.NewReviewQueueRepository(pool))
+
+	_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ('ada', 'ada@example.com', 'hash', $1, $1)", time.Now())
+	assert.Nil(t, err)
+	english, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	maths, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	for _, subjectId := range []int64{english, maths} {
+		if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+			t.Fatal("failed to create quiz")
+		}
+	}
+
+	_, err = ss.GetStudyPlan(ctx, 1)
+	assert.ErrorIs(t, err, pkg.ErrStudyPlanNotFound)
+	today := dailyDate(time.Now())
+	data := domain.StudyPlanData{ExamDate: today.Format(time.DateOnly), SubjectIds: []int64{english, maths}, TargetAccuracy: 75, DailyQuestions: 10}
+	_, err = ss.CreateStudyPlan(ctx, 1, data)
+	assert.ErrorIs(t, err, pkg.ErrInvalidExamDate)
+	data.ExamDate = today.AddDate(0, 0, 30).Format(time.DateOnly)
+	data.SubjectIds = []int64{english, 99}
+	_, err = ss.CreateStudyPlan(ctx, 1, data)
+	assert.ErrorIs(t, err, pkg.ErrSubjectNotFound)
+
+	// a new plan schedules today, sharing its questions between its subjects
+	data.SubjectIds = []int64{english, maths, english}
+	plan, err := ss.CreateStudyPlan(ctx, 1, data)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(30), plan.DaysLeft)
+	assert.Len(t, plan.Subjects, 2)
+	assert.Len(t, plan.Days, 1)
+	assert.Equal(t, domain.StudyAdherence{}, plan.Adherence)
+	day, err := ss.GetStudyPlanToday(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, today.Format(time.DateOnly), day.Date)
+	assert.Equal(t, int64(10), day.TargetQuestions)
+	assert.Len(t, day.Tasks, 2)
+	assert.False(t, day.Met)
+
+	// doing today's tasks meets the day, and today's schedule stays as it was
+	for _, task := range day.Tasks {
+		for range 2 {
+			quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: task.SubjectId, NumOfQuestions: 4})
+			assert.Nil(t, err)
+			_, err = qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, quiz, 4))
+			assert.Nil(t, err)
+		}
+	}
+	met, err := ss.GetStudyPlanToday(ctx, 1)
+	assert.Nil(t, err)
+	assert.True(t, met.Met)
+	for i, task := range met.Tasks {
+		assert.Equal(t, day.Tasks[i].NumOfQuestions, task.NumOfQuestions)
+		assert.True(t, task.Done)
+	}
+
+	// days before today the schedule was never looked at are held to the daily questions
+	_, err = pool.Exec("UPDATE study_plans SET created_at = $1", time.Now().AddDate(0, 0, -2))
+	assert.Nil(t, err)
+	_, err = pool.Exec("INSERT INTO scores (user_id, subject_id, mode, correct_answers, total_questions, created_at) VALUES (1, $1, 'practice', 10, 10, $2)", english, time.Now().AddDate(0, 0, -1))
+	assert.Nil(t, err)
+	plan, err = ss.GetStudyPlan(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, plan.Days, 3)
+	assert.False(t, plan.Days[0].Met)
+	assert.True(t, plan.Days[1].Met)
+	assert.Equal(t, int64(10), plan.Days[1].Answered)
+	assert.True(t, plan.Days[2].Met)
+	assert.Equal(t, domain.StudyAdherence{DaysPlanned: 3, DaysMet: 2, Percent: float64(2) / 3 * 100}, plan.Adherence)
+	for _, subject := range plan.Subjects {
+		assert.Equal(t, float64(100), subject.Accuracy)
+	}
+
+	assert.Nil(t, ss.DeleteStudyPlan(ctx, 1))
+	assert.ErrorIs(t, ss.DeleteStudyPlan(ctx, 1), pkg.ErrStudyPlanNotFound)
+	_, err = ss.GetStudyPlanToday(ctx, 1)
+	assert.ErrorIs(t, err, pkg.ErrStudyPlanNotFound)
+}
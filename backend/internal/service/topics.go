@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+)
+
+// topicPathSeparator joins the names of a topic and the topics above it
+const topicPathSeparator = " → "
+
+// CreateTopic creates a topic in a subject, nested under a parent topic of the same
+// subject when ParentId is set. Topic names are unique within a subject, ignoring case
+// and spacing.
+// It returns the id of the created topic and an error if any.
+func (qs *questionService) CreateTopic(ctx context.Context, subjectId int64, topic domain.TopicData) (int64, error) {
+	name := strings.Join(strings.Fields(topic.Name), " ")
+	if name == "" {
+		qs.logger.Println("Topic name is empty. Proceeding to return error.")
+		return 0, pkg.ErrInvalidName
+	}
+	if _, err := qs.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+		qs.logger.Println("Failed to get subject by id: ", err)
+		return 0, pkg.ErrSubjectNotFound
+	}
+	topics, err := qs.subjectRepository.GetSubjectTopics(ctx, subjectId)
+	if err != nil {
+		qs.logger.Println("Failed to get subject topics: ", err)
+		return 0, err
+	}
+	if topic.ParentId != 0 && !slices.ContainsFunc(topics, func(t repository.Topic) bool { return t.Id == topic.ParentId }) {
+		qs.logger.Println("Parent topic is not in the subject. Proceeding to return error.")
+		return 0, pkg.ErrInvalidParentTopic
+	}
+	if topicNameTaken(topics, name, 0) {
+		qs.logger.Println("Topic already exists. Proceeding to return error.")
+		return 0, pkg.ErrTopicWithNameExists
+	}
+	now := time.Now()
+	id, err := qs.subjectRepository.CreateTopic(ctx, repository.Topic{
+		SubjectId: subjectId,
+		ParentId:  topic.ParentId,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		qs.logger.Println("Failed to create topic: ", err)
+		return 0, err
+	}
+	qs.logger.Println("Successfully created topic. Proceeding to return id.")
+	return id, nil
+}
+
+// GetSubjectTopics returns every topic of a subject with its path, in order of path, so
+// each topic comes right after the topics above it.
+func (qs *questionService) GetSubjectTopics(ctx context.Context, subjectId int64) ([]domain.Topic, error) {
+	if _, err := qs.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+		qs.logger.Println("Failed to get subject by id: ", err)
+		return nil, pkg.ErrSubjectNotFound
+	}
+	topics, err := qs.subjectRepository.GetSubjectTopics(ctx, subjectId)
+	if err != nil {
+		qs.logger.Println("Failed to get subject topics: ", err)
+		return nil, err
+	}
+	paths := topicPaths(topics)
+	result := make([]domain.Topic, len(topics))
+	for i, topic := range topics {
+		result[i] = domain.Topic{
+			Id:        topic.Id,
+			SubjectId: topic.SubjectId,
+			ParentId:  topic.ParentId,
+			Name:      topic.Name,
+			Path:      paths[topic.Id],
+			Questions: topic.Questions,
+		}
+	}
+	slices.SortStableFunc(result, func(a, b domain.Topic) int {
+		return slices.Compare(strings.Split(a.Path, topicPathSeparator), strings.Split(b.Path, topicPathSeparator))
+	})
+	return result, nil
+}
+
+// UpdateTopic renames a topic. A topic cannot be moved, so ParentId must be left out or
+// be the topic's current parent.
+func (qs *questionService) UpdateTopic(ctx context.Context, id int64, topic domain.TopicData) error {
+	name := strings.Join(strings.Fields(topic.Name), " ")
+	if name == "" {
+		qs.logger.Println("Topic name is empty. Proceeding to return error.")
+		return pkg.ErrInvalidName
+	}
+	current, err := qs.subjectRepository.GetTopicById(ctx, id)
+	if err != nil {
+		qs.logger.Println("Failed to get topic by id: ", err)
+		return pkg.ErrTopicNotFound
+	}
+	if topic.ParentId != 0 && topic.ParentId != current.ParentId {
+		qs.logger.Println("Topic parent cannot be changed. Proceeding to return error.")
+		return pkg.ErrInvalidParentTopic
+	}
+	topics, err := qs.subjectRepository.GetSubjectTopics(ctx, current.SubjectId)
+	if err != nil {
+		qs.logger.Println("Failed to get subject topics: ", err)
+		return err
+	}
+	if topicNameTaken(topics, name, id) {
+		qs.logger.Println("Topic already exists. Proceeding to return error.")
+		return pkg.ErrTopicWithNameExists
+	}
+	if err := qs.subjectRepository.UpdateTopicName(ctx, id, name); err != nil {
+		qs.logger.Println("Failed to update topic: ", err)
+		return err
+	}
+	qs.logger.Println("Successfully renamed topic: ", id)
+	return nil
+}
+
+// DeleteTopic deletes a topic and every topic below it. Their questions are kept, without a topic.
+func (qs *questionService) DeleteTopic(ctx context.Context, id int64) error {
+	if _, err := qs.subjectRepository.GetTopicById(ctx, id); err != nil {
+		qs.logger.Println("Failed to get topic by id: ", err)
+		return pkg.ErrTopicNotFound
+	}
+	if err := qs.subjectRepository.DeleteTopicById(ctx, id); err != nil {
+		qs.logger.Println("Failed to delete topic: ", err)
+		return err
+	}
+	qs.logger.Println("Successfully deleted topic: ", id)
+	return nil
+}
+
+// ClassifyQuestion files a question under a topic of its subject, or under none when
+// TopicId is 0, and replaces its tags.
+func (qs *questionService) ClassifyQuestion(ctx context.Context, questionId int64, classification domain.QuestionClassification) error {
+	question, err := qs.questionRepository.GetQuestionById(ctx, questionId)
+	if err != nil {
+		qs.logger.Println("Failed to get question by id: ", err)
+		return pkg.ErrQuestionNotFound
+	}
+	if err := qs.checkTopic(ctx, question.SubjectId, classification.TopicId); err != nil {
+		return err
+	}
+	if err := qs.questionRepository.SetQuestionClassification(ctx, questionId, classification.TopicId, normalizeTags(classification.Tags)); err != nil {
+		qs.logger.Println("Failed to classify question: ", err)
+		return err
+	}
+	qs.logger.Println("Successfully classified question: ", questionId)
+	return nil
+}
+
+// GetSubjectTags returns every tag used on the questions of a subject, and how many of them carry it.
+func (qs *questionService) GetSubjectTags(ctx context.Context, subjectId int64) ([]domain.SubjectTag, error) {
+	if _, err := qs.subjectRepository.GetSubjectById(ctx, subjectId); err != nil {
+		qs.logger.Println("Failed to get subject by id: ", err)
+		return nil, pkg.ErrSubjectNotFound
+	}
+	tags, err := qs.questionRepository.GetSubjectTags(ctx, subjectId)
+	if err != nil {
+		qs.logger.Println("Failed to get subject tags: ", err)
+		return nil, err
+	}
+	return tags, nil
+}
+
+// checkTopic makes sure a topic a question is filed under belongs to the question's subject.
+// A topicId of 0 is no topic and always passes.
+func (qs *questionService) checkTopic(ctx context.Context, subjectId int64, topicId int64) error {
+	if topicId == 0 {
+		return nil
+	}
+	topic, err := qs.subjectRepository.GetTopicById(ctx, topicId)
+	if err != nil || topic.SubjectId != subjectId {
+		qs.logger.Println("Topic is not in the subject. Proceeding to return error.")
+		return pkg.ErrTopicNotFound
+	}
+	return nil
+}
+
+// questionFilter turns the topics and tags of a quiz subject into a filter for drawing its
+// questions. Every topic must belong to the subject, and the topics below each of them
+// are included.
+func (qs *quizService) questionFilter(ctx context.Context, subject domain.QuizSubjectRequest) (repository.QuestionFilter, error) {
+	filter := repository.QuestionFilter{Tags: normalizeTags(subject.Tags)}
+	if len(subject.TopicIds) == 0 {
+		return filter, nil
+	}
+	topics, err := qs.subjectRepository.GetSubjectTopics(ctx, subject.SubjectId)
+	if err != nil {
+		fmt.Println("error getting subject topics: ", err)
+		return filter, err
+	}
+	for _, topicId := range subject.TopicIds {
+		if !slices.ContainsFunc(topics, func(topic repository.Topic) bool { return topic.Id == topicId }) {
+			return filter, fmt.Errorf("%w: topic %d is not in subject %d", pkg.ErrTopicNotFound, topicId, subject.SubjectId)
+		}
+	}
+	filter.TopicIds = topicsBelow(topics, subject.TopicIds)
+	return filter, nil
+}
+
+// topicsBelow returns the given topics and every topic nested below any of them, once each.
+func topicsBelow(topics []repository.Topic, topicIds []int64) []int64 {
+	children := make(map[int64][]int64, len(topics))
+	for _, topic := range topics {
+		children[topic.ParentId] = append(children[topic.ParentId], topic.Id)
+	}
+	seen := make(map[int64]bool, len(topics))
+	var below []int64
+	queue := slices.Clone(topicIds)
+	for len(queue) > 0 {
+		topicId := queue[0]
+		queue = queue[1:]
+		if seen[topicId] {
+			continue
+		}
+		seen[topicId] = true
+		below = append(below, topicId)
+		queue = append(queue, children[topicId]...)
+	}
+	return below
+}
+
+// topicPaths names every topic along with the topics above it, keyed by topic id.
+func topicPaths(topics []repository.Topic) map[int64]string {
+	byId := make(map[int64]repository.Topic, len(topics))
+	for _, topic := range topics {
+		byId[topic.Id] = topic
+	}
+	paths := make(map[int64]string, len(topics))
+	for _, topic := range topics {
+		names := []string{topic.Name}
+		// Parents are created before their children and never change, so this cannot loop
+		for parent, ok := byId[topic.ParentId]; ok; parent, ok = byId[parent.ParentId] {
+			names = append(names, parent.Name)
+		}
+		slices.Reverse(names)
+		paths[topic.Id] = strings.Join(names, topicPathSeparator)
+	}
+	return paths
+}
+
+// topicNameTaken reports whether a topic other than exceptId already has the name,
+// ignoring case and spacing.
+func topicNameTaken(topics []repository.Topic, name string, exceptId int64) bool {
+	return slices.ContainsFunc(topics, func(topic repository.Topic) bool {
+		return topic.Id != exceptId && normalizeText(topic.Name) == normalizeText(name)
+	})
+}
+
+// normalizeTags lower cases tags and collapses their spacing, dropping blank and repeated ones.
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = normalizeText(tag); tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
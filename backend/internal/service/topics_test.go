@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopics(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	subjectRepo := repository.NewSubjectRepository(pool)
+	questionService := NewQuestionService(repository.NewQuestionRepository(pool), subjectRepo, repository.NewMediaRepository(pool), testStorage(t), log.New(io.Discard, "", 0))
+
+	maths, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	physics, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+
+	geometry, err := questionService.CreateTopic(ctx, maths, domain.TopicData{Name: "Geometry"})
+	assert.Nil(t, err)
+	algebra, err := questionService.CreateTopic(ctx, maths, domain.TopicData{Name: "Algebra"})
+	assert.Nil(t, err)
+	quadratics, err := questionService.CreateTopic(ctx, maths, domain.TopicData{Name: "  Quadratics ", ParentId: algebra})
+	assert.Nil(t, err)
+	mechanics, err := questionService.CreateTopic(ctx, physics, domain.TopicData{Name: "Mechanics"})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name      string
+		subjectId int64
+		topic     domain.TopicData
+		err       error
+	}{
+		{name: "name taken in the subject", subjectId: maths, topic: domain.TopicData{Name: " ALGEBRA"}, err: pkg.ErrTopicWithNameExists},
+		{name: "parent in another subject", subjectId: maths, topic: domain.TopicData{Name: "Statics", ParentId: mechanics}, err: pkg.ErrInvalidParentTopic},
+		{name: "unknown subject", subjectId: 99, topic: domain.TopicData{Name: "Algebra"}, err: pkg.ErrSubjectNotFound},
+		{name: "blank name", subjectId: maths, topic: domain.TopicData{Name: "  "}, err: pkg.ErrInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := questionService.CreateTopic(ctx, tt.subjectId, tt.topic)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+	// the same name can be used in another subject
+	_, err = questionService.CreateTopic(ctx, physics, domain.TopicData{Name: "Algebra"})
+	assert.Nil(t, err)
+
+	topics, err := questionService.GetSubjectTopics(ctx, maths)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.Topic{
+		{Id: algebra, SubjectId: maths, Name: "Algebra", Path: "Algebra"},
+		{Id: quadratics, SubjectId: maths, ParentId: algebra, Name: "Quadratics", Path: "Algebra → Quadratics"},
+		{Id: geometry, SubjectId: maths, Name: "Geometry", Path: "Geometry"},
+	}, topics)
+
+	assert.Nil(t, questionService.UpdateTopic(ctx, quadratics, domain.TopicData{Name: "Quadratic equations", ParentId: algebra}))
+	assert.ErrorIs(t, questionService.UpdateTopic(ctx, quadratics, domain.TopicData{Name: "geometry"}), pkg.ErrTopicWithNameExists)
+	assert.ErrorIs(t, questionService.UpdateTopic(ctx, quadratics, domain.TopicData{Name: "Quadratics", ParentId: geometry}), pkg.ErrInvalidParentTopic)
+	assert.ErrorIs(t, questionService.UpdateTopic(ctx, 99, domain.TopicData{Name: "Quadratics"}), pkg.ErrTopicNotFound)
+	topics, err = questionService.GetSubjectTopics(ctx, maths)
+	assert.Nil(t, err)
+	assert.Equal(t, "Algebra → Quadratic equations", topics[1].Path)
+
+	assert.Nil(t, questionService.DeleteTopic(ctx, geometry))
+	assert.ErrorIs(t, questionService.DeleteTopic(ctx, geometry), pkg.ErrTopicNotFound)
+	topics, err = questionService.GetSubjectTopics(ctx, maths)
+	assert.Nil(t, err)
+	assert.Len(t, topics, 2)
+}
+
+func TestClassifyQuestion(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	subjectRepo := repository.NewSubjectRepository(pool)
+	questionService := NewQuestionService(repository.NewQuestionRepository(pool), subjectRepo, repository.NewMediaRepository(pool), testStorage(t), log.New(io.Discard, "", 0))
+
+	maths, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	physics, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+	algebra, err := questionService.CreateTopic(ctx, maths, domain.TopicData{Name: "Algebra"})
+	assert.Nil(t, err)
+	mechanics, err := questionService.CreateTopic(ctx, physics, domain.TopicData{Name: "Mechanics"})
+	assert.Nil(t, err)
+
+	question := domain.QuestionsData{
+		Name:        "What is x if 2x = 6?",
+		Options:     []string{"2", "3"},
+		Answer:      "3",
+		Explanation: "Divide both sides by 2.",
+		TopicId:     algebra,
+		Tags:        []string{"WAEC  2019", "linear", "waec 2019", " "},
+	}
+	id, err := questionService.CreateQuestion(ctx, maths, question)
+	assert.Nil(t, err)
+	created, err := questionService.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, algebra, created.TopicId)
+	assert.Equal(t, []string{"linear", "waec 2019"}, created.Tags)
+
+	// a question can only be filed under a topic of its own subject
+	question.Name = "What is y if 3y = 6?"
+	question.TopicId = mechanics
+	_, err = questionService.CreateQuestion(ctx, maths, question)
+	assert.ErrorIs(t, err, pkg.ErrTopicNotFound)
+	assert.ErrorIs(t, questionService.ClassifyQuestion(ctx, id, domain.QuestionClassification{TopicId: mechanics}), pkg.ErrTopicNotFound)
+	assert.ErrorIs(t, questionService.ClassifyQuestion(ctx, 99, domain.QuestionClassification{TopicId: algebra}), pkg.ErrQuestionNotFound)
+
+	assert.Nil(t, questionService.ClassifyQuestion(ctx, id, domain.QuestionClassification{Tags: []string{"Equations"}}))
+	classified, err := questionService.GetQuestionById(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), classified.TopicId)
+	assert.Equal(t, []string{"equations"}, classified.Tags)
+
+	tags, err := questionService.GetSubjectTags(ctx, maths)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.SubjectTag{{Tag: "equations", Questions: 1}}, tags)
+	_, err = questionService.GetSubjectTags(ctx, 99)
+	assert.ErrorIs(t, err, pkg.ErrSubjectNotFound)
+}
+
+func TestGenerateQuizByTopicsAndTags(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	qs := NewQuizService(repository.NewQuizRepository(pool), subjectRepo, questionRepo, repository.NewScoreRepository(pool), repository.NewQuizSessionRepository(pool), repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+	questionService := NewQuestionService(questionRepo, subjectRepo, repository.NewMediaRepository(pool), testStorage(t), log.New(io.Discard, "", 0))
+
+	maths, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "mathematics"})
+	assert.Nil(t, err)
+	physics, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "physics"})
+	assert.Nil(t, err)
+	algebra, err := questionService.CreateTopic(ctx, maths, domain.TopicData{Name: "Algebra"})
+	assert.Nil(t, err)
+	quadratics, err := questionService.CreateTopic(ctx, maths, domain.TopicData{Name: "Quadratics", ParentId: algebra})
+	assert.Nil(t, err)
+	geometry, err := questionService.CreateTopic(ctx, maths, domain.TopicData{Name: "Geometry"})
+	assert.Nil(t, err)
+	mechanics, err := questionService.CreateTopic(ctx, physics, domain.TopicData{Name: "Mechanics"})
+	assert.Nil(t, err)
+
+	// two questions in each maths topic, one of the geometry ones tagged, and one without a topic
+	topicOf := make(map[int64]int64)
+	create := func(subjectId, topicId int64, tags ...string) int64 {
+		id, err := questionService.CreateQuestion(ctx, subjectId, domain.QuestionsData{
+			Name:        fmt.Sprintf("question %d", len(topicOf)),
+			Options:     []string{"yes", "no"},
+			Answer:      "yes",
+			Explanation: "Because.",
+			TopicId:     topicId,
+			Tags:        tags,
+		})
+		assert.Nil(t, err)
+		topicOf[id] = topicId
+		return id
+	}
+	for _, topicId := range []int64{algebra, algebra, quadratics, quadratics, geometry} {
+		create(maths, topicId)
+	}
+	tagged := create(maths, geometry, "waec")
+	create(maths, 0, "jamb")
+	create(physics, mechanics, "waec")
+
+	drawn := func(quiz *domain.GeneratedQuizResponse) []int64 {
+		ids := make([]int64, len(quiz.Questions))
+		for i, question := range quiz.Questions {
+			ids[i] = question.QuestionId
+		}
+		return ids
+	}
+
+	// a topic takes in the topics below it
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: maths, NumOfQuestions: 4, TopicIds: []int64{algebra}})
+	assert.Nil(t, err)
+	for _, id := range drawn(quiz) {
+		assert.Contains(t, []int64{algebra, quadratics}, topicOf[id])
+	}
+	assert.Equal(t, []int64{algebra}, quiz.Subjects[0].TopicIds)
+
+	_, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: maths, NumOfQuestions: 3, TopicIds: []int64{quadratics}})
+	assert.ErrorIs(t, err, pkg.ErrNotEnoughQuestions)
+
+	quiz, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: maths, NumOfQuestions: 1, Tags: []string{" WAEC"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{tagged}, drawn(quiz))
+
+	_, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: maths, NumOfQuestions: 1, TopicIds: []int64{mechanics}})
+	assert.ErrorIs(t, err, pkg.ErrTopicNotFound)
+
+	// a mixed quiz narrows down each subject on its own
+	quiz, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{Subjects: []domain.QuizSubjectRequest{
+		{SubjectId: maths, NumOfQuestions: 2, TopicIds: []int64{geometry}},
+		{SubjectId: physics, NumOfQuestions: 1, Tags: []string{"waec"}},
+	}})
+	assert.Nil(t, err)
+	for _, id := range drawn(quiz) {
+		assert.Contains(t, []int64{geometry, mechanics}, topicOf[id])
+	}
+	_, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{
+		Subjects: []domain.QuizSubjectRequest{{SubjectId: maths, NumOfQuestions: 2}},
+		TopicIds: []int64{geometry},
+	})
+	assert.ErrorIs(t, err, pkg.ErrInvalidQuizSubjects)
+
+	// challenges are drawn from the topics too
+	challenge, err := qs.CreateChallenge(ctx, 1, domain.QuizRequest{SubjectId: maths, NumOfQuestions: 2, TopicIds: []int64{quadratics}})
+	assert.Nil(t, err)
+	_, err = pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ('ada', 'ada@example.com', 'hash', $1, $1)", time.Now())
+	assert.Nil(t, err)
+	quiz, err = qs.TakeChallenge(ctx, 1, challenge.Code)
+	assert.Nil(t, err)
+	for _, id := range drawn(quiz) {
+		assert.Equal(t, quadratics, topicOf[id])
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/lawson/otterprep/domain"
 	"github.com/lawson/otterprep/internal/repository"
@@ -23,19 +24,27 @@ type UserServiceInterface interface {
 	Login(ctx context.Context, email string, password string) (*domain.UserResponse, error)
 	UserDashboard(ctx context.Context, userId int64) (*domain.UserDashboard, error)
 	GetUserRoles(ctx context.Context, userId int64) ([]string, error)
+	GetPublicProfile(ctx context.Context, userId int64) (*domain.PublicProfile, error)
+	GetXPHistory(ctx context.Context, userId int64, query domain.XPHistoryQuery) (*domain.XPHistoryResponse, error)
 }
 
 type userService struct {
-	userRepo  repository.UserRepository
-	scoreRepo repository.ScoreRepository
-	logger    *log.Logger
+	userRepo           repository.UserRepository
+	scoreRepo          repository.ScoreRepository
+	streakRepo         repository.StreakRepository
+	xpRepo             repository.XPRepository
+	achievementService AchievementService
+	logger             *log.Logger
 }
 
-func NewUserService(userRepo repository.UserRepository, scoreRepo repository.ScoreRepository, logger *log.Logger) *userService {
+func NewUserService(userRepo repository.UserRepository, scoreRepo repository.ScoreRepository, streakRepo repository.StreakRepository, xpRepo repository.XPRepository, achievementService AchievementService, logger *log.Logger) *userService {
 	return &userService{
-		userRepo:  userRepo,
-		scoreRepo: scoreRepo,
-		logger:    logger,
+		userRepo:           userRepo,
+		scoreRepo:          scoreRepo,
+		streakRepo:         streakRepo,
+		xpRepo:             xpRepo,
+		achievementService: achievementService,
+		logger:             logger,
 	}
 }
 
@@ -266,6 +275,26 @@ func (s *userService) UserDashboard(ctx context.Context, userId int64) (*domain.
 		s.logger.Println("error getting user roles: ", err)
 		return nil, pkg.ErrInternalServerError
 	}
+	topics, err := s.scoreRepo.GetUserTopicAccuracy(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user topic accuracy: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	streak, err := s.streakRepo.GetUserStreak(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user streak: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	badges, err := s.achievementService.GetUserBadges(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user badges: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	xp, err := s.xpRepo.GetUserXP(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user xp: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
 	userDashboard := &domain.UserDashboard{
 		UserResponse: domain.UserResponse{
 			ID:        user.ID,
@@ -276,6 +305,80 @@ func (s *userService) UserDashboard(ctx context.Context, userId int64) (*domain.
 		},
 		UserStats: *userStats,
 		Roles:     roles,
+		Topics:    topics,
+		Streak:    streakOn(*streak, time.Now()),
+		Badges:    badges,
+		XP:        xpProgress(xp),
 	}
 	return userDashboard, nil
 }
+
+// GetPublicProfile returns what other users can see of a user: their name, stats, streak
+// and badges, but not their email or roles.
+func (s *userService) GetPublicProfile(ctx context.Context, userId int64) (*domain.PublicProfile, error) {
+	if userId == 0 {
+		s.logger.Println("error getting public profile: ", pkg.ErrInvalidUserID)
+		return nil, pkg.ErrInvalidUserID
+	}
+	user, err := s.userRepo.GetUserWithID(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting public profile: ", err)
+		return nil, err
+	}
+	userStats, err := s.scoreRepo.GetUserOverallScoreStats(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user stats: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	streak, err := s.streakRepo.GetUserStreak(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user streak: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	badges, err := s.achievementService.GetUserBadges(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user badges: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	xp, err := s.xpRepo.GetUserXP(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user xp: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	return &domain.PublicProfile{
+		ID:        user.ID,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt,
+		UserStats: *userStats,
+		Streak:    streakOn(*streak, time.Now()),
+		Badges:    badges,
+		XP:        xpProgress(xp),
+	}, nil
+}
+
+// GetXPHistory returns the user's XP and level along with a page of their XP ledger,
+// newest first.
+func (s *userService) GetXPHistory(ctx context.Context, userId int64, query domain.XPHistoryQuery) (*domain.XPHistoryResponse, error) {
+	if userId == 0 {
+		s.logger.Println("error getting xp history: ", pkg.ErrInvalidUserID)
+		return nil, pkg.ErrInvalidUserID
+	}
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	xp, err := s.xpRepo.GetUserXP(ctx, userId)
+	if err != nil {
+		s.logger.Println("error getting user xp: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	entries, totalEntries, err := s.xpRepo.GetUserXPLedger(ctx, userId, query.Limit, query.Offset)
+	if err != nil {
+		s.logger.Println("error getting xp ledger: ", err)
+		return nil, pkg.ErrInternalServerError
+	}
+	return &domain.XPHistoryResponse{
+		XPProgress:   xpProgress(xp),
+		TotalEntries: totalEntries,
+		Entries:      entries,
+	}, nil
+}
@@ -22,7 +22,7 @@ func TestUserServiceCreateUser(t *testing.T) {
 
 	userRepo := repository.NewUserRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	userService := NewUserService(*userRepo, scoreRepo, log.New(os.Stdout, "", 0))
+	userService := NewUserService(*userRepo, scoreRepo, repository.NewStreakRepository(pool), repository.NewXPRepository(pool), testAchievementService(pool), log.New(os.Stdout, "", 0))
 
 	user := domain.User{
 		Name:         "test",
@@ -48,7 +48,7 @@ func TestUserServiceGetUserWithID(t *testing.T) {
 	defer cancel()
 	userRepo := repository.NewUserRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	userService := NewUserService(*userRepo, scoreRepo, log.New(os.Stdout, "", 0))
+	userService := NewUserService(*userRepo, scoreRepo, repository.NewStreakRepository(pool), repository.NewXPRepository(pool), testAchievementService(pool), log.New(os.Stdout, "", 0))
 	newUser := domain.User{
 		Name:         "test",
 		Email:        "test@example.com",
@@ -83,7 +83,7 @@ func TestUserServiceUpdateUsername(t *testing.T) {
 	defer cancel()
 	userRepo := repository.NewUserRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	userService := NewUserService(*userRepo, scoreRepo, log.New(os.Stdout, "", 0))
+	userService := NewUserService(*userRepo, scoreRepo, repository.NewStreakRepository(pool), repository.NewXPRepository(pool), testAchievementService(pool), log.New(os.Stdout, "", 0))
 	newUser := domain.User{
 		Name:         "test",
 		Email:        "test@email.com",
@@ -123,7 +123,7 @@ func TestUserServiceUpdateUserEmail(t *testing.T) {
 	defer cancel()
 	userRepo := repository.NewUserRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	userService := NewUserService(*userRepo, scoreRepo, log.New(os.Stdout, "", 0))
+	userService := NewUserService(*userRepo, scoreRepo, repository.NewStreakRepository(pool), repository.NewXPRepository(pool), testAchievementService(pool), log.New(os.Stdout, "", 0))
 	newUser := domain.User{
 		Name:         "test",
 		Email:        "test@email.com",
@@ -163,7 +163,7 @@ func TestUserServiceUpdatePassword(t *testing.T) {
 	defer cancel()
 	userRepo := repository.NewUserRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	userService := NewUserService(*userRepo, scoreRepo, log.New(os.Stdout, "", 0))
+	userService := NewUserService(*userRepo, scoreRepo, repository.NewStreakRepository(pool), repository.NewXPRepository(pool), testAchievementService(pool), log.New(os.Stdout, "", 0))
 	newUser := domain.User{
 		Name:         "test",
 		Email:        "test@email.com",
@@ -203,7 +203,7 @@ func TestUserServiceDeleteUserByID(t *testing.T) {
 	defer cancel()
 	userRepo := repository.NewUserRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	userService := NewUserService(*userRepo, scoreRepo, log.New(os.Stdout, "", 0))
+	userService := NewUserService(*userRepo, scoreRepo, repository.NewStreakRepository(pool), repository.NewXPRepository(pool), testAchievementService(pool), log.New(os.Stdout, "", 0))
 	newUser := domain.User{
 		Name:         "test",
 		Email:        "test@email.com",
@@ -234,7 +234,7 @@ func TestUserServiceGetAllUsers(t *testing.T) {
 	defer cancel()
 	userRepo := repository.NewUserRepository(pool)
 	scoreRepo := repository.NewScoreRepository(pool)
-	userService := NewUserService(*userRepo, scoreRepo, log.New(os.Stdout, "", 0))
+	userService := NewUserService(*userRepo, scoreRepo, repository.NewStreakRepository(pool), repository.NewXPRepository(pool), testAchievementService(pool), log.New(os.Stdout, "", 0))
 	newUser := []domain.User{
 		{
 			Name:         "test",
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+)
+
+const (
+	// XPPerCorrectAnswer is the XP a fully correct answer earns
+	XPPerCorrectAnswer = 10
+	// XPQuestionCap is the most questions of one quiz that earn XP. The answers of a
+	// longer quiz earn XP as if it had this many questions, so a giant quiz is worth no
+	// more than a full-length one answered as well.
+	XPQuestionCap = 20
+	// XPPerStreakDay is the XP a daily challenge earns for each day of the streak it keeps
+	// going, counting up to XPMaxStreakDays days.
+	XPPerStreakDay  = 5
+	XPMaxStreakDays = 7
+	// XPFirstCompletion is the XP for completing a quiz in a subject for the first time
+	XPFirstCompletion = 50
+	// XPLevelStep sets the level curve: level n is reached at XPLevelStep * n(n-1)/2 XP,
+	// so each level takes XPLevelStep more XP than the one before.
+	XPLevelStep = 100
+)
+
+// xpDifficultyBonus is the XP a fully correct answer earns on top of XPPerCorrectAnswer,
+// by the question's difficulty.
+var xpDifficultyBonus = map[int]float64{
+	domain.DifficultyEasy:   0,
+	domain.DifficultyMedium: 2,
+	domain.DifficultyHard:   5,
+}
+
+// xpLevelStart returns the total XP a level is reached at.
+func xpLevelStart(level int64) int64 {
+	return XPLevelStep * level * (level - 1) / 2
+}
+
+// xpProgress returns the level a total XP reaches and the bounds of that level.
+func xpProgress(total int64) domain.XPProgress {
+	level := int64((1 + math.Sqrt(1+8*float64(max(total, 0))/XPLevelStep)) / 2)
+	// Guard against the square root rounding either way
+	for xpLevelStart(level) > total && level > 1 {
+		level--
+	}
+	for xpLevelStart(level+1) <= total {
+		level++
+	}
+	return domain.XPProgress{
+		Total:        total,
+		Level:        level,
+		LevelStartXP: xpLevelStart(level),
+		NextLevelXP:  xpLevelStart(level + 1),
+	}
+}
+
+// xpService awards XP for quiz submissions into the append-only XP ledger.
+type xpService struct {
+	xpRepository       repository.XPRepository
+	questionRepository repository.QuestionRepository
+	streakRepository   repository.StreakRepository
+}
+
+func NewXPService(xpRepository repository.XPRepository, questionRepository repository.QuestionRepository, streakRepository repository.StreakRepository) *xpService {
+	return &xpService{
+		xpRepository:       xpRepository,
+		questionRepository: questionRepository,
+		streakRepository:   streakRepository,
+	}
+}
+
+// RecordSubmission is a SubmitHook that awards the XP a submission earns and adds it to
+// the result. A quiz earns XP for the credit of its answers, more for harder questions,
+// and for each subject it completes for the first time; a daily challenge also earns XP
+// for the streak it keeps going, so the hook is added after the daily challenge's.
+func (xs *xpService) RecordSubmission(ctx context.Context, session *repository.QuizSession, result *domain.QuizSubmitResponse) error {
+	now := time.Now()
+	entries, err := xs.submissionXP(ctx, session, result, now)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := xs.xpRepository.AppendXP(ctx, entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		result.XP += entry.Amount
+	}
+	return nil
+}
+
+// submissionXP works out the ledger entries of a submission, one for each reason and
+// subject that earns XP.
+func (xs *xpService) submissionXP(ctx context.Context, session *repository.QuizSession, result *domain.QuizSubmitResponse, now time.Time) ([]domain.XPEntry, error) {
+	var entries []domain.XPEntry
+	add := func(subjectId int64, reason string, amount float64) {
+		if rounded := int64(math.Round(amount)); rounded > 0 {
+			entries = append(entries, domain.XPEntry{UserID: session.UserId, SessionID: session.Id, SubjectID: subjectId, Reason: reason, Amount: rounded, CreatedAt: now})
+		}
+	}
+
+	if len(result.Results) > 0 {
+		questionIds := make([]int64, len(result.Results))
+		for i, questionResult := range result.Results {
+			questionIds[i] = questionResult.QuestionId
+		}
+		questions, err := xs.questionRepository.GetQuestionsByIds(ctx, questionIds)
+		if err != nil {
+			return nil, err
+		}
+		byId := make(map[int64]repository.Questions, len(questions))
+		for _, question := range questions {
+			byId[question.Id] = question
+		}
+		// XP goes to the subject a question was issued under, even if it has moved since
+		issuedUnder := make(map[int64]int64, len(session.Questions))
+		for _, issued := range session.Questions {
+			issuedUnder[issued.QuestionId] = issued.SubjectId
+		}
+
+		scale := min(1, float64(XPQuestionCap)/float64(len(result.Results)))
+		correct := map[int64]float64{}
+		difficulty := map[int64]float64{}
+		var subjectIds []int64
+		for _, questionResult := range result.Results {
+			question, ok := byId[questionResult.QuestionId]
+			if !ok {
+				continue
+			}
+			subjectId := issuedUnder[question.Id]
+			if subjectId == 0 {
+				subjectId = question.SubjectId
+			}
+			if _, seen := correct[subjectId]; !seen {
+				subjectIds = append(subjectIds, subjectId)
+			}
+			correct[subjectId] += questionResult.Credit * XPPerCorrectAnswer * scale
+			difficulty[subjectId] += questionResult.Credit * xpDifficultyBonus[question.Difficulty] * scale
+		}
+		for _, subjectId := range subjectIds {
+			add(subjectId, domain.XPReasonCorrectAnswers, correct[subjectId])
+			add(subjectId, domain.XPReasonDifficulty, difficulty[subjectId])
+		}
+	}
+
+	if session.Mode == domain.ModeDaily {
+		streak, err := xs.streakRepository.GetUserStreak(ctx, session.UserId)
+		if err != nil {
+			return nil, err
+		}
+		add(session.SubjectId, domain.XPReasonStreak, float64(XPPerStreakDay*min(streak.Current, XPMaxStreakDays)))
+	}
+
+	for _, subject := range result.Subjects {
+		completed, err := xs.xpRepository.HasCompletedSubject(ctx, session.UserId, subject.SubjectId, session.Id)
+		if err != nil {
+			return nil, err
+		}
+		if !completed {
+			add(subject.SubjectId, domain.XPReasonFirstCompletion, XPFirstCompletion)
+		}
+	}
+	return entries, nil
+}
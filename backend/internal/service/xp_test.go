@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lawson/otterprep/domain"
+	"github.com/lawson/otterprep/internal/repository"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXPProgress(t *testing.T) {
+	tests := []struct {
+		total, level, start, next int64
+	}{
+		{total: 0, level: 1, start: 0, next: 100},
+		{total: 99, level: 1, start: 0, next: 100},
+		{total: 100, level: 2, start: 100, next: 300},
+		{total: 299, level: 2, start: 100, next: 300},
+		{total: 300, level: 3, start: 300, next: 600},
+		{total: 4500, level: 10, start: 4500, next: 5500},
+	}
+	for _, test := range tests {
+		progress := xpProgress(test.total)
+		assert.Equal(t, domain.XPProgress{Total: test.total, Level: test.level, LevelStartXP: test.start, NextLevelXP: test.next}, progress, test.total)
+	}
+}
+
+func TestXP(t *testing.T) {
+	pool := setUpDB(t)
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	qr := repository.NewQuizRepository(pool)
+	questionRepo := repository.NewQuestionRepository(pool)
+	subjectRepo := repository.NewSubjectRepository(pool)
+	sessionRepo := repository.NewQuizSessionRepository(pool)
+	streakRepo := repository.NewStreakRepository(pool)
+	xpRepo := repository.NewXPRepository(pool)
+	qs := NewQuizService(qr, subjectRepo, questionRepo, repository.NewScoreRepository(pool), sessionRepo, repository.NewReviewQueueRepository(pool), repository.NewQuizChallengeRepository(pool), repository.NewMediaRepository(pool), testStorage(t))
+
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	ds := NewDailyChallengeService(redisClient, repository.NewDailyChallengeRepository(pool), streakRepo, subjectRepo, questionRepo, sessionRepo, repository.NewMediaRepository(pool), testStorage(t))
+	qs.OnSubmit(ds.RecordSubmission)
+	qs.OnSubmit(NewXPService(xpRepo, questionRepo, streakRepo).RecordSubmission)
+
+	for _, name := range []string{"ada", "grace"} {
+		_, err := pool.Exec("INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES ($1, $2, 'hash', $3, $3)", name, name+"@example.com", time.Now())
+		assert.Nil(t, err)
+	}
+	subjectId, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "use of english"})
+	assert.Nil(t, err)
+	if _, err := qr.CreateMultipleQuiz(ctx, populateDBWithSubjectID(subjectId)); err != nil {
+		t.Fatal("failed to create quiz")
+	}
+	candidates, err := questionRepo.GetSubjectQuestionIds(ctx, subjectId, repository.QuestionFilter{})
+	assert.Nil(t, err)
+	numOfQuestions := int64(len(candidates))
+	_, err = pool.Exec("UPDATE questions SET difficulty = $1", domain.DifficultyHard)
+	assert.Nil(t, err)
+
+	// the first quiz in a subject earns XP for its answers, their difficulty and completing it
+	quiz, err := qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: numOfQuestions})
+	assert.Nil(t, err)
+	result, err := qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, quiz, int(numOfQuestions)))
+	assert.Nil(t, err)
+	perfectXP := numOfQuestions * (XPPerCorrectAnswer + int64(xpDifficultyBonus[domain.DifficultyHard]))
+	assert.Equal(t, perfectXP+XPFirstCompletion, result.XP)
+
+	// later quizzes earn XP for the credit of their answers only
+	quiz, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: numOfQuestions})
+	assert.Nil(t, err)
+	result, err = qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, quiz, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(XPPerCorrectAnswer)+int64(xpDifficultyBonus[domain.DifficultyHard]), result.XP)
+
+	// a daily challenge also earns XP for the streak
+	daily, err := ds.TakeDailyChallenge(ctx, 2, subjectId)
+	assert.Nil(t, err)
+	result, err = qs.SubmitQuiz(ctx, 2, answerQuiz(t, ctx, questionRepo, daily, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(XPFirstCompletion+XPPerStreakDay), result.XP)
+
+	// the ledger accounts for every XP earned
+	userService := NewUserService(*repository.NewUserRepository(pool), repository.NewScoreRepository(pool), streakRepo, xpRepo, testAchievementService(pool), log.New(os.Stdout, "", 0))
+	history, err := userService.GetXPHistory(ctx, 1, domain.XPHistoryQuery{Limit: 2})
+	assert.Nil(t, err)
+	total := perfectXP + XPFirstCompletion + XPPerCorrectAnswer + int64(xpDifficultyBonus[domain.DifficultyHard])
+	assert.Equal(t, xpProgress(total), history.XPProgress)
+	assert.Equal(t, int64(5), history.TotalEntries)
+	assert.Len(t, history.Entries, 2)
+	assert.Equal(t, quiz.SessionId, history.Entries[0].SessionID)
+	history, err = userService.GetXPHistory(ctx, 2, domain.XPHistoryQuery{})
+	assert.Nil(t, err)
+	reasons := []string{}
+	for _, entry := range history.Entries {
+		reasons = append(reasons, entry.Reason)
+	}
+	assert.ElementsMatch(t, []string{domain.XPReasonStreak, domain.XPReasonFirstCompletion}, reasons)
+
+	dashboard, err := userService.UserDashboard(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, total, dashboard.XP.Total)
+	assert.Equal(t, int64(2), dashboard.XP.Level)
+
+	// XP is a leaderboard metric of its own
+	ls := NewLeaderboardService(repository.NewLeaderboardRepository(pool), subjectRepo)
+	board, err := ls.GetLeaderboard(ctx, domain.LeaderboardQuery{Metric: "xp"})
+	assert.Nil(t, err)
+	assert.Equal(t, "xp", board.Metric)
+	assert.Equal(t, int64(2), board.TotalUsers)
+	assert.Equal(t, int64(1), board.Entries[0].UserID)
+	assert.Equal(t, total, board.Entries[0].XP)
+	assert.Equal(t, int64(2), board.Entries[0].Level)
+	assert.Equal(t, int64(2), board.Entries[0].TotalQuizzes)
+	board, err = ls.GetLeaderboard(ctx, domain.LeaderboardQuery{Metric: "xp", Period: "weekly", SubjectId: &subjectId})
+	assert.Nil(t, err)
+	assert.Equal(t, "use of english", board.SubjectName)
+	assert.Len(t, board.Entries, 2)
+	board, err = ls.GetLeaderboard(ctx, domain.LeaderboardQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, "score", board.Metric)
+
+	// answers earn XP in the subject their questions were issued under, even once moved
+	quiz, err = qs.GenerateQuizBySubjectID(ctx, 1, domain.QuizRequest{SubjectId: subjectId, NumOfQuestions: numOfQuestions})
+	assert.Nil(t, err)
+	movedTo, err := subjectRepo.CreateSubject(ctx, repository.Subject{Name: "literature"})
+	assert.Nil(t, err)
+	_, err = pool.Exec("UPDATE questions SET subject_id = $1 WHERE subject_id = $2", movedTo, subjectId)
+	assert.Nil(t, err)
+	_, err = qs.SubmitQuiz(ctx, 1, answerQuiz(t, ctx, questionRepo, quiz, 1))
+	assert.Nil(t, err)
+	history, err = userService.GetXPHistory(ctx, 1, domain.XPHistoryQuery{Limit: 2})
+	assert.Nil(t, err)
+	for _, entry := range history.Entries {
+		assert.Equal(t, quiz.SessionId, entry.SessionID)
+		assert.Equal(t, subjectId, entry.SubjectID)
+	}
+}
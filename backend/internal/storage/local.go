@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lawson/otterprep/pkg"
+)
+
+// Local keeps media in a directory on the local filesystem. The API serves it itself, at
+// URLs signed with an HMAC of the key and the time they expire, so only URLs it has
+// handed out work.
+type Local struct {
+	dir        string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewLocal returns a storage keeping media under dir, creating it if needed. baseURL is
+// where the API serves the media from, e.g. "http://localhost:8080/media".
+func NewLocal(dir string, baseURL string, signingKey []byte) (*Local, error) {
+	if len(signingKey) == 0 {
+		return nil, errors.New("local media storage needs a signing key")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir, baseURL: strings.TrimRight(baseURL, "/"), signingKey: signingKey}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid media key %q", key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file to a temporary file next to it first, so a failed upload never
+// leaves a partial file under key.
+func (l *Local) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("media %q is %d bytes, expected %d", key, written, size)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) URL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid media key %q", key)
+	}
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	expires := time.Now().Add(expiry).Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {l.sign(key, expires)},
+	}
+	return l.baseURL + "/" + strings.Join(segments, "/") + "?" + query.Encode(), nil
+}
+
+// Open opens the file a signed URL points to, given the key, expiry time and signature
+// from the URL. It fails with pkg.ErrMediaLinkExpired when the signature does not match
+// or the URL has expired, and pkg.ErrMediaNotFound when nothing is stored under the key.
+func (l *Local) Open(key string, expires int64, signature string, now time.Time) (*os.File, error) {
+	if !hmac.Equal([]byte(signature), []byte(l.sign(key, expires))) || now.Unix() > expires {
+		return nil, pkg.ErrMediaLinkExpired
+	}
+	path, err := l.path(key)
+	if err != nil {
+		return nil, pkg.ErrMediaNotFound
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, pkg.ErrMediaNotFound
+	}
+	return file, err
+}
+
+func (l *Local) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	fmt.Fprintf(mac, "%s\n%d", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawson/otterprep/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocal(t.TempDir(), "http://localhost:8080/media/", []byte("secret"))
+	assert.Nil(t, err)
+
+	body := []byte("\x89PNG\r\n\x1a\nimage")
+	assert.Nil(t, local.Put(ctx, "questions/1/diagram.png", bytes.NewReader(body), int64(len(body)), "image/png"))
+	assert.Error(t, local.Put(ctx, "questions/1/short.png", bytes.NewReader(body), int64(len(body))+1, "image/png"))
+	assert.Error(t, local.Put(ctx, "../outside.png", bytes.NewReader(body), int64(len(body)), "image/png"))
+
+	link, err := local.URL(ctx, "questions/1/diagram.png", time.Hour)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(link, "http://localhost:8080/media/questions/1/diagram.png?"))
+	parsed, err := url.Parse(link)
+	assert.Nil(t, err)
+	expires, err := strconv.ParseInt(parsed.Query().Get("expires"), 10, 64)
+	assert.Nil(t, err)
+	signature := parsed.Query().Get("signature")
+
+	file, err := local.Open("questions/1/diagram.png", expires, signature, time.Now())
+	assert.Nil(t, err)
+	content, err := io.ReadAll(file)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+	assert.Equal(t, body, content)
+
+	tests := []struct {
+		name      string
+		key       string
+		expires   int64
+		signature string
+		now       time.Time
+		err       error
+	}{
+		{name: "expired", key: "questions/1/diagram.png", expires: expires, signature: signature, now: time.Unix(expires+1, 0), err: pkg.ErrMediaLinkExpired},
+		{name: "expiry changed", key: "questions/1/diagram.png", expires: expires + 3600, signature: signature, now: time.Now(), err: pkg.ErrMediaLinkExpired},
+		{name: "signed for another key", key: "questions/1/other.png", expires: expires, signature: signature, now: time.Now(), err: pkg.ErrMediaLinkExpired},
+		{name: "short upload was not kept", key: "questions/1/short.png", expires: expires, signature: local.sign("questions/1/short.png", expires), now: time.Now(), err: pkg.ErrMediaNotFound},
+		{name: "outside the directory", key: "../outside.png", expires: expires, signature: local.sign("../outside.png", expires), now: time.Now(), err: pkg.ErrMediaNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := local.Open(tt.key, tt.expires, tt.signature, tt.now)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	assert.Nil(t, local.Delete(ctx, "questions/1/diagram.png"))
+	assert.Nil(t, local.Delete(ctx, "questions/1/diagram.png"))
+	_, err = local.Open("questions/1/diagram.png", expires, signature, time.Now())
+	assert.ErrorIs(t, err, pkg.ErrMediaNotFound)
+
+	_, err = NewLocal(t.TempDir(), "http://localhost:8080/media", nil)
+	assert.Error(t, err)
+}
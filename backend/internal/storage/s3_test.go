@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 stands in for an S3-compatible store such as MinIO. It keeps objects in memory,
+// addressed by path, and only checks that requests are signed the way S3 expects.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	signed := strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") || r.URL.Query().Get("X-Amz-Signature") != ""
+	if !signed {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err == nil && strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			body, err = decodeChunked(body)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		_, _ = w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeChunked strips the chunk headers of a body uploaded with streaming signatures,
+// which is how uploads are sent over plain HTTP.
+func decodeChunked(body []byte) ([]byte, error) {
+	var decoded []byte
+	reader := bufio.NewReader(bytes.NewReader(body))
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return decoded, nil
+		}
+		chunk := make([]byte, size+2) // the chunk and its trailing CRLF
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, chunk[:size]...)
+	}
+}
+
+func TestS3(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3(S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "otterprep",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	assert.Nil(t, err)
+
+	body := []byte("ID3 audio")
+	assert.Nil(t, store.Put(ctx, "questions/1/clip.mp3", bytes.NewReader(body), int64(len(body)), "audio/mpeg"))
+	assert.Equal(t, body, fake.objects["/otterprep/questions/1/clip.mp3"])
+	assert.Equal(t, "audio/mpeg", fake.types["/otterprep/questions/1/clip.mp3"])
+
+	link, err := store.URL(ctx, "questions/1/clip.mp3", time.Hour)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(link, server.URL+"/otterprep/questions/1/clip.mp3?"))
+	assert.Contains(t, link, "X-Amz-Expires=3600")
+	response, err := http.Get(link)
+	assert.Nil(t, err)
+	content, err := io.ReadAll(response.Body)
+	assert.Nil(t, err)
+	assert.Nil(t, response.Body.Close())
+	assert.Equal(t, body, content)
+
+	assert.Nil(t, store.Delete(ctx, "questions/1/clip.mp3"))
+	assert.Empty(t, fake.objects)
+
+	for _, key := range []string{"", "../otherbucket/clip.mp3", "/questions/1/clip.mp3", "questions/../../clip.mp3"} {
+		assert.Error(t, store.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "audio/mpeg"), key)
+		assert.Error(t, store.Delete(ctx, key), key)
+		_, err = store.URL(ctx, key, time.Hour)
+		assert.Error(t, err, key)
+	}
+
+	_, err = NewS3(S3Config{Endpoint: "localhost:9000"})
+	assert.Error(t, err)
+}
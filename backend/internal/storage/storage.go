@@ -0,0 +1,27 @@
+// Package storage keeps the media attached to questions, and hands out URLs for it that
+// only work for a limited time.
+package storage
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Storage stores media files by key. Keys are slash separated relative paths such as
+// "questions/12/3f2c.png".
+type Storage interface {
+	// Put stores size bytes read from body under key, replacing anything stored there.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Delete removes what is stored under key. Deleting a key that holds nothing is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns a URL the file stored under key can be fetched from until expiry has passed.
+	URL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// validKey reports whether key is a clean relative path that stays within the storage.
+func validKey(key string) bool {
+	return key != "" && path.Clean(key) == key && !path.IsAbs(key) && key != ".." && !strings.HasPrefix(key, "../")
+}
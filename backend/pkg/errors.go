@@ -3,30 +3,86 @@ package pkg
 import "errors"
 
 var (
-	ErrQuestionAlreadyExist       = errors.New("question already exist")
-	ErrSubjectNotFound            = errors.New("subject not found")
-	ErrQuestionNotFound           = errors.New("question not found")
-	ErrQuestionOptionNotFound     = errors.New("question option not found")
-	ErrQuizNotFound               = errors.New("quiz not found")
-	ErrInvalidName                = errors.New("invalid name")
-	ErrInvalidEmail               = errors.New("invalid email")
-	ErrInvalidPasswordHash        = errors.New("unauthorized password")
-	ErrInvalidUserID              = errors.New("invalid User ID")
-	ErrInternalServerError        = errors.New("internal server error")
-	ErrQuestionTextNotFound       = errors.New("invalid / empty question text")
-	ErrQuestionOptionTextNotFound = errors.New("invalid / empty question option text")
-	ErrSubjectNameNotFound        = errors.New("invalid / empty subject name")
-	ErrSubjectWithNameExists      = errors.New("subject with name already exists")
-	ErrUserNotFound               = errors.New("user not found")
-	ErrInvalidRole                = errors.New("invalid user role")
-	ErrInvalidPasswordLength      = errors.New("invalid password length should be greater or equal to 6")
-	ErrUnauthorized               = errors.New("unauthorized")
-	ErrUserAlreadyExists          = errors.New("email already exists")
-	ErrInvalidQuestionID          = errors.New("invalid question id")
-	ErrUserRankNotFound           = errors.New("user has no quiz scores yet")
-	ErrInvalidToken               = errors.New("invalid or expired token")
-	ErrRefreshTokenRequired       = errors.New("refresh token is required")
-	ErrPasswordResetTokenExpired  = errors.New("password reset token has expired")
-	ErrPasswordResetTokenInvalid  = errors.New("invalid password reset token")
-	ErrEmailSendFailed            = errors.New("failed to send email")
+	ErrQuestionAlreadyExist        = errors.New("question already exist")
+	ErrSubjectNotFound             = errors.New("subject not found")
+	ErrQuestionNotFound            = errors.New("question not found")
+	ErrQuestionOptionNotFound      = errors.New("question option not found")
+	ErrQuizNotFound                = errors.New("quiz not found")
+	ErrInvalidName                 = errors.New("invalid name")
+	ErrInvalidEmail                = errors.New("invalid email")
+	ErrInvalidPasswordHash         = errors.New("unauthorized password")
+	ErrInvalidUserID               = errors.New("invalid User ID")
+	ErrInternalServerError         = errors.New("internal server error")
+	ErrQuestionTextNotFound        = errors.New("invalid / empty question text")
+	ErrQuestionOptionTextNotFound  = errors.New("invalid / empty question option text")
+	ErrSubjectNameNotFound         = errors.New("invalid / empty subject name")
+	ErrSubjectWithNameExists       = errors.New("subject with name already exists")
+	ErrUserNotFound                = errors.New("user not found")
+	ErrInvalidRole                 = errors.New("invalid user role")
+	ErrInvalidPasswordLength       = errors.New("invalid password length should be greater or equal to 6")
+	ErrUnauthorized                = errors.New("unauthorized")
+	ErrUserAlreadyExists           = errors.New("email already exists")
+	ErrInvalidQuestionID           = errors.New("invalid question id")
+	ErrUserRankNotFound            = errors.New("user has no quiz scores yet")
+	ErrInvalidToken                = errors.New("invalid or expired token")
+	ErrRefreshTokenRequired        = errors.New("refresh token is required")
+	ErrPasswordResetTokenExpired   = errors.New("password reset token has expired")
+	ErrPasswordResetTokenInvalid   = errors.New("invalid password reset token")
+	ErrEmailSendFailed             = errors.New("failed to send email")
+	ErrQuizSessionNotFound         = errors.New("quiz session not found")
+	ErrQuizSessionAlreadySubmitted = errors.New("quiz session has already been submitted")
+	ErrQuestionNotInSession        = errors.New("question was not issued in this quiz session")
+	ErrOptionNotInSession          = errors.New("option was not issued for this question")
+	ErrDuplicateQuizAnswer         = errors.New("question answered more than once")
+	ErrExamDurationRequired        = errors.New("exam mode requires a duration")
+	ErrQuestionAnswerNotFound      = errors.New("invalid / empty question answer")
+	ErrAnswerNotInOptions          = errors.New("question answer does not match any option")
+	ErrInvalidScoringPolicy        = errors.New("invalid scoring policy")
+	ErrQuestionHasNoCorrectOption  = errors.New("question has no correct option")
+	ErrChallengeNotFound           = errors.New("quiz challenge not found")
+	ErrChallengeAlreadyTaken       = errors.New("quiz challenge has already been taken")
+	ErrChallengeModeNotSupported   = errors.New("quiz challenges can only be practice or exam quizzes")
+	ErrNotEnoughQuestions          = errors.New("not enough questions in subject for this quiz")
+	ErrInvalidQuizSubjects         = errors.New("invalid quiz subjects: list each subject once with at least one question, and no more than 200 questions in total")
+	ErrQuizAttemptNotFound         = errors.New("quiz attempt not found")
+	ErrInvalidQuestionType         = errors.New("invalid question type")
+	ErrNotEnoughOptions            = errors.New("choice and ordering questions need at least two options")
+	ErrOptionsNotAllowed           = errors.New("only choice and ordering questions have options")
+	ErrInvalidTrueFalseAnswer      = errors.New("true/false question answer must be true or false")
+	ErrAnswerDoesNotMatchType      = errors.New("answer does not match the question type")
+	ErrInvalidMatchingPairs        = errors.New("matching questions need at least two pairs, with no option or match used twice")
+	ErrDuplicateOptions            = errors.New("question options must be different from each other")
+	ErrTopicNotFound               = errors.New("topic not found in subject")
+	ErrTopicWithNameExists         = errors.New("topic with name already exists in subject")
+	ErrInvalidParentTopic          = errors.New("parent topic must be in the same subject and cannot be changed")
+	ErrPastPaperNotFound           = errors.New("past paper not found in subject")
+	ErrPastPaperExists             = errors.New("past paper already exists in subject")
+	ErrPaperQuestionNumberTaken    = errors.New("another question already has this number on the paper")
+	ErrMediaNotFound               = errors.New("media not found")
+	ErrUnsupportedMediaType        = errors.New("media must be a PNG, JPEG, GIF or WebP image, or MP3, WAV or Ogg audio")
+	ErrMediaTooLarge               = errors.New("media is too large: images can be up to 5 MB and audio up to 20 MB")
+	ErrMediaLinkExpired            = errors.New("media link is invalid or has expired")
+	ErrMediaFileRequired           = errors.New("a media file is required")
+	ErrInvalidRichText             = errors.New("invalid rich text")
+	ErrLiveRoomNotFound            = errors.New("live room not found")
+	ErrLiveRoomStarted             = errors.New("live room has already started")
+	ErrLiveRoomFull                = errors.New("live room is full")
+	ErrLiveRoomNoPlayers           = errors.New("live room has no players yet")
+	ErrNotLiveRoomHost             = errors.New("only the host of the live room can do this")
+	ErrNotLiveRoomPlayer           = errors.New("only players of the live room can answer")
+	ErrInvalidLiveCommand          = errors.New("invalid live room command")
+	ErrLiveCommandNotAllowed       = errors.New("the live room cannot do this right now")
+	ErrLiveQuestionClosed          = errors.New("the question is closed")
+	ErrLiveQuestionAnswered        = errors.New("the question has already been answered")
+	ErrContestNotFound             = errors.New("contest not found")
+	ErrInvalidContestWindow        = errors.New("contest must end after it starts, and in the future")
+	ErrContestQuestionsRequired    = errors.New("contest needs question ids or a number of questions")
+	ErrInvalidContestQuestions     = errors.New("contest questions must be questions of its subject, each given once")
+	ErrContestNotOpen              = errors.New("contest is not open")
+	ErrContestAlreadyEntered       = errors.New("contest already entered")
+	ErrContestLeaderboardNotReady  = errors.New("contest leaderboard is published once the contest closes")
+	ErrDailyChallengeTaken         = errors.New("today's daily challenge for this subject has already been taken")
+	ErrInvalidBadge                = errors.New("invalid badge definition")
+	ErrStudyPlanNotFound           = errors.New("study plan not found")
+	ErrInvalidExamDate             = errors.New("exam date must be after today")
 )
@@ -109,13 +109,18 @@ function Quiz() {
       setQuizState('submitting');
       setError(null);
 
-      const submitData: SubmitQuizRequest[] = quiz.questions.map((q) => ({
-        question_id: q.question_id,
-        is_multiple_choice: q.is_multiple_choice,
-        option_ids: answers.get(q.question_id) || [],
-      }));
-
-      const resultData = await quizService.submitQuiz(submitData);
+      const submitData: SubmitQuizRequest[] = quiz.questions
+        .map((q) => ({
+          question_id: q.question_id,
+          is_multiple_choice: q.is_multiple_choice,
+          option_ids: answers.get(q.question_id) || [],
+        }))
+        .filter((answer) => answer.option_ids.length > 0);
+
+      const resultData = await quizService.submitQuiz({
+        session_id: quiz.session_id,
+        answers: submitData,
+      });
       setResult(resultData);
       setQuizState('result');
 
@@ -1,5 +1,5 @@
 import api from './api';
-import type { QuizRequest, GeneratedQuiz, SubmitQuizRequest, QuizSubmitResponse, ApiResponse } from '../types';
+import type { QuizRequest, GeneratedQuiz, QuizSubmission, QuizSubmitResponse, ApiResponse } from '../types';
 
 export const quizService = {
   async createQuiz(request: QuizRequest): Promise<GeneratedQuiz> {
@@ -7,8 +7,8 @@ export const quizService = {
     return response.data.data!;
   },
 
-  async submitQuiz(answers: SubmitQuizRequest[]): Promise<QuizSubmitResponse> {
-    const response = await api.post<ApiResponse<QuizSubmitResponse>>('/api/v1/quiz/submit', answers);
+  async submitQuiz(submission: QuizSubmission): Promise<QuizSubmitResponse> {
+    const response = await api.post<ApiResponse<QuizSubmitResponse>>('/api/v1/quiz/submit', submission);
     return response.data.data!;
   },
 };
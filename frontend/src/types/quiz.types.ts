@@ -17,6 +17,7 @@ export interface QuizQuestion {
 }
 
 export interface GeneratedQuiz {
+  session_id: number;
   subject_id: number;
   total_count: number;
   questions: QuizQuestion[];
@@ -28,6 +29,11 @@ export interface SubmitQuizRequest {
   option_ids: number[];
 }
 
+export interface QuizSubmission {
+  session_id: number;
+  answers: SubmitQuizRequest[];
+}
+
 export interface QuizResult {
   question_id: number;
   question: string;
@@ -38,6 +44,7 @@ export interface QuizResult {
 }
 
 export interface QuizSubmitResponse {
+  session_id: number;
   user_id: number;
   subject_id: number;
   total_questions: number;
@@ -103,3 +103,412 @@ CREATE TABLE IF NOT EXISTS answers (
 
 CREATE INDEX IF NOT EXISTS idx_answers_question_id ON answers (question_id);
 
+-- Quiz sessions table (a quiz issued to a user, graded on submission)
+CREATE TABLE IF NOT EXISTS quiz_sessions (
+	id SERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	subject_id BIGINT NOT NULL,
+	status VARCHAR(32) NOT NULL DEFAULT 'active',
+	mode VARCHAR(32) NOT NULL DEFAULT 'practice',
+	duration_seconds BIGINT NOT NULL DEFAULT 0,
+	expires_at TIMESTAMP,
+	scoring_policy TEXT NOT NULL DEFAULT '{"name":"standard"}',
+	submitted_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (subject_id) REFERENCES subjects(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quiz_sessions_user_id ON quiz_sessions (user_id);
+
+-- Quiz session questions table (questions issued in a session)
+CREATE TABLE IF NOT EXISTS quiz_session_questions (
+	id SERIAL PRIMARY KEY,
+	session_id BIGINT NOT NULL,
+	question_id BIGINT NOT NULL,
+	position INT NOT NULL,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (session_id) REFERENCES quiz_sessions(id) ON DELETE CASCADE,
+	FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE,
+	UNIQUE (session_id, question_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quiz_session_questions_session_id ON quiz_session_questions (session_id);
+
+-- Quiz session options table (options issued for each question in a session)
+CREATE TABLE IF NOT EXISTS quiz_session_options (
+	id SERIAL PRIMARY KEY,
+	session_id BIGINT NOT NULL,
+	question_id BIGINT NOT NULL,
+	option_id BIGINT NOT NULL,
+	position INT NOT NULL,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (session_id) REFERENCES quiz_sessions(id) ON DELETE CASCADE,
+	FOREIGN KEY (option_id) REFERENCES options(id) ON DELETE CASCADE,
+	UNIQUE (session_id, option_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quiz_session_options_session_id ON quiz_session_options (session_id);
+
+-- Mixed quizzes: a session can draw from several subjects, so it keeps no subject of its own
+-- and snapshots each subject's scoring policy in quiz_session_subjects
+ALTER TABLE quiz_sessions ALTER COLUMN subject_id DROP NOT NULL; -- NULL for quizzes mixing several subjects
+ALTER TABLE quiz_sessions DROP COLUMN IF EXISTS scoring_policy;
+ALTER TABLE quiz_session_questions ADD COLUMN IF NOT EXISTS subject_id BIGINT;
+UPDATE quiz_session_questions SET subject_id = quiz_sessions.subject_id FROM quiz_sessions WHERE quiz_session_questions.session_id = quiz_sessions.id AND quiz_session_questions.subject_id IS NULL;
+ALTER TABLE quiz_session_questions ALTER COLUMN subject_id SET NOT NULL;
+
+-- Quiz session subjects table (subjects drawn from in a session and the scoring policy each was issued with)
+CREATE TABLE IF NOT EXISTS quiz_session_subjects (
+	id SERIAL PRIMARY KEY,
+	session_id BIGINT NOT NULL,
+	subject_id BIGINT NOT NULL,
+	num_of_questions BIGINT NOT NULL,
+	scoring_policy TEXT NOT NULL DEFAULT '{"name":"standard"}',
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (session_id) REFERENCES quiz_sessions(id) ON DELETE CASCADE,
+	FOREIGN KEY (subject_id) REFERENCES subjects(id),
+	UNIQUE (session_id, subject_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quiz_session_subjects_session_id ON quiz_session_subjects (session_id);
+
+-- Multi-answer questions: partial credit when grading "select all that apply"
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS partial_credit BOOLEAN DEFAULT FALSE;
+
+-- Scoring policies per subject (negative marking, weights)
+CREATE TABLE IF NOT EXISTS subject_scoring_policies (
+	id SERIAL PRIMARY KEY,
+	subject_id BIGINT NOT NULL UNIQUE,
+	name VARCHAR(64) NOT NULL,
+	wrong_penalty DOUBLE PRECISION NOT NULL DEFAULT 0,
+	unanswered_penalty DOUBLE PRECISION NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (subject_id) REFERENCES subjects(id) ON DELETE CASCADE
+);
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS weight DOUBLE PRECISION NOT NULL DEFAULT 1;
+ALTER TABLE scores ADD COLUMN IF NOT EXISTS points DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE scores ADD COLUMN IF NOT EXISTS scoring_policy VARCHAR(64) NOT NULL DEFAULT 'standard';
+
+-- Mixed quizzes: a submission stores one score row per subject, grouped by session
+ALTER TABLE scores ADD COLUMN IF NOT EXISTS session_id BIGINT REFERENCES quiz_sessions(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_scores_session_id ON scores (session_id);
+
+-- Question difficulty (1 easy, 2 medium, 3 hard), calibrated from answer history
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS difficulty SMALLINT NOT NULL DEFAULT 2;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS attempts BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS correct_attempts BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_questions_subject_id_difficulty ON questions (subject_id, difficulty);
+
+-- Quiz answers table (every question of a submitted quiz, for reviewing past quizzes)
+CREATE TABLE IF NOT EXISTS quiz_answers (
+	id SERIAL PRIMARY KEY,
+	score_id BIGINT NOT NULL,
+	session_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	question_id BIGINT NOT NULL,
+	subject_id BIGINT NOT NULL,
+	position INT NOT NULL,
+	selected_option_ids TEXT NOT NULL DEFAULT '[]',
+	is_correct BOOLEAN NOT NULL DEFAULT FALSE,
+	credit DOUBLE PRECISION NOT NULL DEFAULT 0,
+	points DOUBLE PRECISION NOT NULL DEFAULT 0,
+	answered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (score_id) REFERENCES scores(id) ON DELETE CASCADE,
+	FOREIGN KEY (session_id) REFERENCES quiz_sessions(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_quiz_answers_session_id ON quiz_answers (session_id);
+CREATE INDEX IF NOT EXISTS idx_quiz_answers_user_id_question_id ON quiz_answers (user_id, question_id);
+
+-- Review queue table (missed questions scheduled for review with the SM-2 algorithm)
+CREATE TABLE IF NOT EXISTS review_queue (
+	user_id BIGINT NOT NULL,
+	question_id BIGINT NOT NULL,
+	subject_id BIGINT NOT NULL,
+	ease_factor DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+	interval_days INT NOT NULL DEFAULT 0,
+	repetitions INT NOT NULL DEFAULT 0,
+	due_at TIMESTAMP NOT NULL,
+	last_reviewed_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (user_id, question_id),
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE,
+	FOREIGN KEY (subject_id) REFERENCES subjects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_queue_user_id_subject_id_due_at ON review_queue (user_id, subject_id, due_at);
+
+-- Quiz challenges table (quizzes shared by a code, drawn from a seed)
+CREATE TABLE IF NOT EXISTS quiz_challenges (
+	id SERIAL PRIMARY KEY,
+	code VARCHAR(16) NOT NULL UNIQUE,
+	seed BIGINT NOT NULL,
+	created_by BIGINT NOT NULL,
+	subject_id BIGINT,
+	mode VARCHAR(32) NOT NULL DEFAULT 'practice',
+	duration_seconds BIGINT NOT NULL DEFAULT 0,
+	subjects TEXT NOT NULL DEFAULT '[]',
+	question_ids TEXT NOT NULL DEFAULT '[]',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (subject_id) REFERENCES subjects(id) ON DELETE CASCADE
+);
+
+-- Quiz sessions issued from a challenge, one per user
+ALTER TABLE quiz_sessions ADD COLUMN IF NOT EXISTS challenge_id BIGINT REFERENCES quiz_challenges(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quiz_sessions_challenge_id_user_id ON quiz_sessions (challenge_id, user_id);
+
+-- Question types: choice, true_false, numeric (answer within a tolerance, in a unit) and
+-- short_text (accepted answers stored as a JSON list, matched ignoring case and spacing)
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS question_type VARCHAR(16) NOT NULL DEFAULT 'choice';
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS numeric_answer DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS tolerance DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS unit VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS accepted_answers TEXT NOT NULL DEFAULT '[]';
+
+-- Numeric and short text answers of submitted quizzes
+ALTER TABLE quiz_answers ADD COLUMN IF NOT EXISTS answer_value DOUBLE PRECISION;
+ALTER TABLE quiz_answers ADD COLUMN IF NOT EXISTS answer_unit VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE quiz_answers ADD COLUMN IF NOT EXISTS answer_text TEXT NOT NULL DEFAULT '';
+
+-- Ordering and matching questions: the correct position of an ordering option, and the
+-- match an option of a matching question is paired with
+ALTER TABLE options ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+ALTER TABLE options ADD COLUMN IF NOT EXISTS match_option_id BIGINT REFERENCES options(id) ON DELETE CASCADE;
+
+-- Pairs given as the answer to a matching question
+ALTER TABLE quiz_answers ADD COLUMN IF NOT EXISTS answer_pairs TEXT NOT NULL DEFAULT '[]';
+
+-- Topics table (topics within a subject, optionally nested under a parent topic of the same subject)
+CREATE TABLE IF NOT EXISTS topics (
+	id SERIAL PRIMARY KEY,
+	subject_id BIGINT NOT NULL,
+	parent_id BIGINT,
+	name VARCHAR(100) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (subject_id) REFERENCES subjects(id) ON DELETE CASCADE,
+	FOREIGN KEY (parent_id) REFERENCES topics(id) ON DELETE CASCADE,
+	UNIQUE (subject_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_topics_parent_id ON topics (parent_id);
+
+-- The topic a question is filed under, if any
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS topic_id BIGINT REFERENCES topics(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_questions_topic_id ON questions (topic_id);
+
+-- Question tags table (free-form tags on questions, stored lower case)
+CREATE TABLE IF NOT EXISTS question_tags (
+	question_id BIGINT NOT NULL,
+	tag VARCHAR(50) NOT NULL,
+
+	PRIMARY KEY (question_id, tag),
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_question_tags_tag ON question_tags (tag);
+
+-- Past papers table (a paper set by an exam body in a year, e.g. WAEC 2019 Mathematics Paper 1, and how long candidates had to sit it)
+CREATE TABLE IF NOT EXISTS past_papers (
+	id SERIAL PRIMARY KEY,
+	subject_id BIGINT NOT NULL,
+	exam_body VARCHAR(50) NOT NULL,
+	year INT NOT NULL,
+	paper VARCHAR(50) NOT NULL,
+	duration_seconds BIGINT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (subject_id) REFERENCES subjects(id) ON DELETE CASCADE,
+	UNIQUE (subject_id, exam_body, year, paper)
+);
+
+-- Where a question was set: the past paper and its number on the paper
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS paper_id BIGINT REFERENCES past_papers(id) ON DELETE SET NULL;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS paper_question_number INT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_questions_paper_id_question_number ON questions (paper_id, paper_question_number);
+
+-- The past paper a mock exam session reconstructs
+ALTER TABLE quiz_sessions ADD COLUMN IF NOT EXISTS paper_id BIGINT REFERENCES past_papers(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_quiz_sessions_paper_id ON quiz_sessions (paper_id);
+
+-- Media table (images and audio attached to a question, one of its options or its explanation; the files are kept in media storage under storage_key)
+CREATE TABLE IF NOT EXISTS question_media (
+	id SERIAL PRIMARY KEY,
+	question_id BIGINT NOT NULL,
+	option_id BIGINT,
+	attach_to VARCHAR(20) NOT NULL CHECK (attach_to IN ('question', 'option', 'explanation')),
+	kind VARCHAR(10) NOT NULL CHECK (kind IN ('image', 'audio')),
+	content_type VARCHAR(100) NOT NULL,
+	size_bytes BIGINT NOT NULL,
+	storage_key VARCHAR(255) NOT NULL UNIQUE,
+	alt_text VARCHAR(300) NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE,
+	FOREIGN KEY (option_id) REFERENCES options(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_question_media_question_id ON question_media (question_id);
+
+-- Contests table (a fixed set of a subject's questions, entered once per user between starts_at and ends_at; the scoring policy is a snapshot of the subject's, frozen_at is set once the leaderboard is frozen)
+CREATE TABLE IF NOT EXISTS contests (
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(200) NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	subject_id BIGINT NOT NULL,
+	duration_seconds BIGINT NOT NULL DEFAULT 0,
+	scoring_policy TEXT NOT NULL,
+	question_ids TEXT NOT NULL,
+	starts_at TIMESTAMP NOT NULL,
+	ends_at TIMESTAMP NOT NULL,
+	frozen_at TIMESTAMP,
+	created_by BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (subject_id) REFERENCES subjects(id) ON DELETE CASCADE,
+	FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+	CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_contests_starts_at ON contests (starts_at);
+CREATE INDEX IF NOT EXISTS idx_contests_ends_at ON contests (ends_at);
+
+-- The contest a quiz session is an entry to; one entry per user
+ALTER TABLE quiz_sessions ADD COLUMN IF NOT EXISTS contest_id BIGINT REFERENCES contests(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quiz_sessions_contest_id_user_id ON quiz_sessions (contest_id, user_id);
+
+-- Contest standings table (a contest's leaderboard, written once when it is frozen after the contest closes)
+CREATE TABLE IF NOT EXISTS contest_standings (
+	contest_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	rank BIGINT NOT NULL,
+	user_name VARCHAR(255) NOT NULL,
+	score BIGINT NOT NULL,
+	points DOUBLE PRECISION NOT NULL,
+	correct_answers BIGINT NOT NULL,
+	total_questions BIGINT NOT NULL,
+	time_taken_seconds BIGINT NOT NULL,
+	submitted_at TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (contest_id, user_id),
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (contest_id) REFERENCES contests(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- The day of the daily challenge a quiz session was issued for; one per user, subject and day
+ALTER TABLE quiz_sessions ADD COLUMN IF NOT EXISTS daily_date DATE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quiz_sessions_user_id_subject_id_daily_date ON quiz_sessions (user_id, subject_id, daily_date);
+CREATE INDEX IF NOT EXISTS idx_quiz_sessions_daily_date ON quiz_sessions (daily_date);
+
+-- User streaks table (days in a row a user has taken a daily challenge, and the streak freezes they have banked)
+CREATE TABLE IF NOT EXISTS user_streaks (
+	user_id BIGINT PRIMARY KEY,
+	current_streak BIGINT NOT NULL DEFAULT 0,
+	longest_streak BIGINT NOT NULL DEFAULT 0,
+	freezes BIGINT NOT NULL DEFAULT 0,
+	last_active_on DATE,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- User achievements table (badges users have earned, by the code of their definition in the achievements configuration; each is earned once)
+CREATE TABLE IF NOT EXISTS user_achievements (
+	user_id BIGINT NOT NULL,
+	code VARCHAR(100) NOT NULL,
+	earned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (user_id, code),
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- XP ledger table (append-only: XP earned by each quiz attempt, one row per reason and subject; a user's XP is the sum of their rows).
+-- session_id and subject_id have no foreign keys so deleting a session or subject never rewrites the ledger.
+CREATE TABLE IF NOT EXISTS xp_ledger (
+	id SERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	session_id BIGINT,
+	subject_id BIGINT,
+	reason VARCHAR(50) NOT NULL,
+	amount BIGINT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_xp_ledger_user_id ON xp_ledger (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_xp_ledger_created_at ON xp_ledger (created_at);
+
+-- Study plans table (a user's exam date, the subjects it covers and the accuracy they are aiming for; one per user; subject_ids is a JSON array)
+CREATE TABLE IF NOT EXISTS study_plans (
+	id SERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL UNIQUE,
+	exam_date DATE NOT NULL,
+	subject_ids TEXT NOT NULL,
+	target_accuracy DOUBLE PRECISION NOT NULL,
+	daily_questions BIGINT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Study plan days table (a day's schedule of a study plan, fixed the first time it is worked out; tasks is a JSON array)
+CREATE TABLE IF NOT EXISTS study_plan_days (
+	plan_id BIGINT NOT NULL,
+	day DATE NOT NULL,
+	tasks TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (plan_id, day),
+	-- FOREIGN KEY CONSTRAINTS
+	FOREIGN KEY (plan_id) REFERENCES study_plans(id) ON DELETE CASCADE
+);
+
+-- Learner analytics: a user's quizzes by subject and time, and every user's totals per subject for percentiles
+CREATE INDEX IF NOT EXISTS idx_scores_user_id_subject_id_created_at ON scores (user_id, subject_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_scores_subject_id_user_id ON scores (subject_id, user_id);